@@ -0,0 +1,507 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	stdioutil "io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/gitattributes"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+)
+
+const (
+	// archiveUmask is removed from the modes of the tar entries, the same
+	// used by git by default with tar.umask.
+	archiveUmask = 0002
+
+	attributesFile     = ".gitattributes"
+	infoAttributesFile = "info/attributes"
+
+	exportIgnoreAttr = "export-ignore"
+	exportSubstAttr  = "export-subst"
+)
+
+// Archive writes the tree of the given tree-ish, a commit, a tag or a tree,
+// to w as an archive, equivalent to `git archive`. The modification time of
+// the entries is the commit time, or the current time for trees, and the
+// hash of the commit is stored in the pax global header of tar archives and
+// in the comment of zip archives.
+//
+// The files and directories with the export-ignore attribute are not
+// archived, and the $Format:...$ placeholders of the files with the
+// export-subst attribute are expanded. The attributes are read from the
+// .gitattributes files of the tree and from the info/attributes file.
+func (r *Repository) Archive(h plumbing.Hash, w io.Writer, o *ArchiveOptions) (err error) {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	tree, commit, err := r.archiveTree(h)
+	if err != nil {
+		return err
+	}
+
+	info, err := r.infoAttributes()
+	if err != nil {
+		return err
+	}
+
+	a := &archiver{r: r, commit: commit, info: info, prefix: o.Prefix}
+	mtime := time.Now().Truncate(time.Second)
+	var comment string
+	if commit != nil {
+		mtime = commit.Committer.When
+		comment = commit.Hash.String()
+	}
+
+	switch o.Format {
+	case ZipArchive:
+		a.w = newZipArchiveWriter(w, mtime, comment)
+	default:
+		a.w, err = newTarArchiveWriter(w, o.Format == TarGzipArchive, mtime, comment)
+		if err != nil {
+			return err
+		}
+	}
+
+	defer ioutil.CheckClose(a.w, &err)
+
+	if strings.HasSuffix(o.Prefix, "/") {
+		if err := a.w.writeDir(strings.TrimSuffix(o.Prefix, "/")); err != nil {
+			return err
+		}
+	}
+
+	return a.writeTree(tree, nil, nil)
+}
+
+// archiveTree returns the tree of the given tree-ish and the commit it
+// belongs to, nil if the tree-ish is a tree.
+func (r *Repository) archiveTree(h plumbing.Hash) (*object.Tree, *object.Commit, error) {
+	obj, err := r.Object(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		switch o := obj.(type) {
+		case *object.Tag:
+			if obj, err = o.Object(); err != nil {
+				return nil, nil, err
+			}
+		case *object.Commit:
+			tree, err := o.Tree()
+			return tree, o, err
+		case *object.Tree:
+			return o, nil, nil
+		default:
+			return nil, nil, plumbing.ErrInvalidType
+		}
+	}
+}
+
+// infoAttributes returns the attributes of the info/attributes file of the
+// repository, nil if the storer is not filesystem based.
+func (r *Repository) infoAttributes() ([]gitattributes.MatchAttribute, error) {
+	fs := r.dotGitFilesystem()
+	if fs == nil {
+		return nil, nil
+	}
+
+	return gitattributes.ReadAttributesFile(fs, nil, infoAttributesFile, true)
+}
+
+type archiver struct {
+	r      *Repository
+	w      archiveWriter
+	commit *object.Commit
+	info   []gitattributes.MatchAttribute
+	prefix string
+}
+
+func (a *archiver) writeTree(t *object.Tree, dir []string, attrs []gitattributes.MatchAttribute) error {
+	attrs, err := a.treeAttributes(t, dir, attrs)
+	if err != nil {
+		return err
+	}
+
+	m := gitattributes.NewMatcher(append(attrs[:len(attrs):len(attrs)], a.info...))
+	for _, e := range t.Entries {
+		path := append(dir[:len(dir):len(dir)], e.Name)
+		isDir := e.Mode == filemode.Dir || e.Mode == filemode.Submodule
+		if isAttributeSet(m, path, isDir, exportIgnoreAttr) {
+			continue
+		}
+
+		name := a.prefix + strings.Join(path, "/")
+		switch e.Mode {
+		case filemode.Dir:
+			if err := a.w.writeDir(name); err != nil {
+				return err
+			}
+
+			subtree, err := a.r.TreeObject(e.Hash)
+			if err != nil {
+				return err
+			}
+
+			if err := a.writeTree(subtree, path, attrs); err != nil {
+				return err
+			}
+		case filemode.Submodule:
+			if err := a.w.writeDir(name); err != nil {
+				return err
+			}
+		default:
+			subst := a.commit != nil && isAttributeSet(m, path, false, exportSubstAttr)
+			if err := a.writeBlob(name, e.Mode, e.Hash, subst); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *archiver) writeBlob(name string, mode filemode.FileMode, h plumbing.Hash, subst bool) (err error) {
+	blob, err := a.r.BlobObject(h)
+	if err != nil {
+		return err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	if mode == filemode.Symlink {
+		target, err := stdioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		return a.w.writeSymlink(name, string(target))
+	}
+
+	if !subst {
+		return a.w.writeFile(name, mode == filemode.Executable, blob.Size, r)
+	}
+
+	content, err := stdioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	content = expandFormatPlaceholders(content, a.commit)
+	return a.w.writeFile(name, mode == filemode.Executable, int64(len(content)), bytes.NewReader(content))
+}
+
+// treeAttributes returns the given attributes followed by the ones of the
+// .gitattributes file of the tree, if any.
+func (a *archiver) treeAttributes(t *object.Tree, dir []string,
+	attrs []gitattributes.MatchAttribute) ([]gitattributes.MatchAttribute, error) {
+
+	for _, e := range t.Entries {
+		if e.Name != attributesFile || !e.Mode.IsFile() {
+			continue
+		}
+
+		content, err := blobContent(a.r.Storer, e.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		tree, err := gitattributes.ParseAttributes(bytes.NewReader(content), dir, len(dir) == 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(attrs[:len(attrs):len(attrs)], tree...), nil
+	}
+
+	return attrs, nil
+}
+
+// isAttributeSet reports if the given attribute is set for the path.
+func isAttributeSet(m gitattributes.Matcher, path []string, isDir bool, attr string) bool {
+	attrs, _ := m.Match(path, isDir, []string{attr})
+	return attrs[attr].IsSet()
+}
+
+// expandFormatPlaceholders replaces the $Format:...$ placeholders of the
+// content with the formatted commit, as done for the export-subst attribute.
+func expandFormatPlaceholders(content []byte, c *object.Commit) []byte {
+	const start = "$Format:"
+
+	var buf bytes.Buffer
+	for {
+		i := bytes.Index(content, []byte(start))
+		if i == -1 {
+			break
+		}
+
+		end := bytes.IndexByte(content[i+len(start):], '$')
+		if end == -1 {
+			break
+		}
+
+		buf.Write(content[:i])
+		buf.WriteString(formatCommit(c, string(content[i+len(start):i+len(start)+end])))
+		content = content[i+len(start)+end+1:]
+	}
+
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+// formatCommit expands the placeholders of a pretty format, as `git log
+// --format`, supporting the ones about hashes, identities, dates and the
+// message. Unknown placeholders are kept as they are.
+func formatCommit(c *object.Commit, format string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			buf.WriteByte(format[i])
+			continue
+		}
+
+		n, value := formatPlaceholder(c, format[i+1:])
+		if n == 0 {
+			buf.WriteByte(format[i])
+			continue
+		}
+
+		buf.WriteString(value)
+		i += n
+	}
+
+	return buf.String()
+}
+
+// formatPlaceholder returns the value of the placeholder at the start of
+// the format and its length, zero if the placeholder is unknown.
+func formatPlaceholder(c *object.Commit, format string) (int, string) {
+	subject, body := splitCommitMessage(c.Message)
+	switch format[0] {
+	case '%':
+		return 1, "%"
+	case 'n':
+		return 1, "\n"
+	case 'H':
+		return 1, c.Hash.String()
+	case 'h':
+		return 1, c.Hash.String()[:DefaultDescribeAbbrev]
+	case 'T':
+		return 1, c.TreeHash.String()
+	case 't':
+		return 1, c.TreeHash.String()[:DefaultDescribeAbbrev]
+	case 'P', 'p':
+		parents := make([]string, len(c.ParentHashes))
+		for i, h := range c.ParentHashes {
+			parents[i] = h.String()
+			if format[0] == 'p' {
+				parents[i] = parents[i][:DefaultDescribeAbbrev]
+			}
+		}
+
+		return 1, strings.Join(parents, " ")
+	case 's':
+		return 1, subject
+	case 'b':
+		return 1, body
+	case 'B':
+		return 1, c.Message
+	case 'a', 'c':
+		if len(format) < 2 {
+			return 0, ""
+		}
+
+		sig := c.Author
+		if format[0] == 'c' {
+			sig = c.Committer
+		}
+
+		if value, ok := formatSignature(sig, format[1]); ok {
+			return 2, value
+		}
+	}
+
+	return 0, ""
+}
+
+func formatSignature(sig object.Signature, placeholder byte) (string, bool) {
+	switch placeholder {
+	case 'n':
+		return sig.Name, true
+	case 'e':
+		return sig.Email, true
+	case 'd':
+		return sig.When.Format("Mon Jan 2 15:04:05 2006 -0700"), true
+	case 'D':
+		return sig.When.Format(patchDateFormat), true
+	case 'i':
+		return sig.When.Format("2006-01-02 15:04:05 -0700"), true
+	case 'I':
+		return sig.When.Format(time.RFC3339), true
+	case 't':
+		return strconv.FormatInt(sig.When.Unix(), 10), true
+	}
+
+	return "", false
+}
+
+// archiveWriter writes the entries of an archive in a given format.
+type archiveWriter interface {
+	writeDir(name string) error
+	writeFile(name string, executable bool, size int64, content io.Reader) error
+	writeSymlink(name, target string) error
+	Close() error
+}
+
+type tarArchiveWriter struct {
+	tw    *tar.Writer
+	gz    *gzip.Writer
+	mtime time.Time
+}
+
+func newTarArchiveWriter(w io.Writer, compress bool, mtime time.Time, comment string) (*tarArchiveWriter, error) {
+	a := &tarArchiveWriter{mtime: mtime}
+	if compress {
+		a.gz = gzip.NewWriter(w)
+		w = a.gz
+	}
+
+	a.tw = tar.NewWriter(w)
+	if comment == "" {
+		return a, nil
+	}
+
+	return a, a.tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       "pax_global_header",
+		PAXRecords: map[string]string{"comment": comment},
+	})
+}
+
+func (a *tarArchiveWriter) header(name string, typeflag byte, mode int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: typeflag,
+		Name:     name,
+		Mode:     mode &^ archiveUmask,
+		ModTime:  a.mtime,
+		Uname:    "root",
+		Gname:    "root",
+	}
+}
+
+func (a *tarArchiveWriter) writeDir(name string) error {
+	return a.tw.WriteHeader(a.header(name+"/", tar.TypeDir, 0777))
+}
+
+func (a *tarArchiveWriter) writeFile(name string, executable bool, size int64, content io.Reader) error {
+	var mode int64 = 0666
+	if executable {
+		mode = 0777
+	}
+
+	h := a.header(name, tar.TypeReg, mode)
+	h.Size = size
+	if err := a.tw.WriteHeader(h); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(a.tw, content)
+	return err
+}
+
+func (a *tarArchiveWriter) writeSymlink(name, target string) error {
+	h := a.header(name, tar.TypeSymlink, 0777)
+	h.Mode = 0777
+	h.Linkname = target
+	return a.tw.WriteHeader(h)
+}
+
+func (a *tarArchiveWriter) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+
+	return nil
+}
+
+type zipArchiveWriter struct {
+	zw    *zip.Writer
+	mtime time.Time
+}
+
+func newZipArchiveWriter(w io.Writer, mtime time.Time, comment string) *zipArchiveWriter {
+	a := &zipArchiveWriter{zw: zip.NewWriter(w), mtime: mtime}
+	a.zw.SetComment(comment)
+	return a
+}
+
+// create adds an entry to the archive, as git does the unix mode is only
+// stored for executables and symlinks, directories only have the MS-DOS
+// directory attribute.
+func (a *zipArchiveWriter) create(name string, method uint16, mode os.FileMode) (io.Writer, error) {
+	const msdosDir = 0x10
+
+	h := &zip.FileHeader{Name: name, Method: method, Modified: a.mtime}
+	switch {
+	case mode.IsDir():
+		h.ExternalAttrs = msdosDir
+	case mode != 0:
+		h.SetMode(mode)
+	}
+
+	return a.zw.CreateHeader(h)
+}
+
+func (a *zipArchiveWriter) writeDir(name string) error {
+	_, err := a.create(name+"/", zip.Store, os.ModeDir)
+	return err
+}
+
+func (a *zipArchiveWriter) writeFile(name string, executable bool, size int64, content io.Reader) error {
+	var mode os.FileMode
+	if executable {
+		mode = 0755
+	}
+
+	w, err := a.create(name, zip.Deflate, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, content)
+	return err
+}
+
+func (a *zipArchiveWriter) writeSymlink(name, target string) error {
+	w, err := a.create(name, zip.Store, os.ModeSymlink|0777)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
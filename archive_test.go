@@ -0,0 +1,181 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+// newArchiveRepository returns a worktree with a commit with an executable,
+// a symlink and files using the export-ignore and export-subst attributes.
+func newArchiveRepository(c *C) (*Worktree, plumbing.Hash) {
+	w, _, _ := newMergeRepository(c, map[string]string{
+		".gitattributes": "ignored export-ignore\nsecret export-ignore\nversion export-subst\n",
+		"foo":            "foo\n",
+		"dir/bar":        "bar\n",
+		"dir/version":    "$Format:%H$ by $Format:%an <%ae>$\n",
+		"ignored":        "ignored\n",
+		"secret/key":     "key\n",
+		"version":        "$Format:%h %s$ $Format:%x$\n",
+	}, nil, nil)
+
+	err := util.WriteFile(w.Filesystem, "run.sh", []byte("#!/bin/sh\n"), 0755)
+	c.Assert(err, IsNil)
+	err = w.Filesystem.Symlink("foo", "link")
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("run.sh")
+	c.Assert(err, IsNil)
+	_, err = w.Add("link")
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit("archive\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	return w, h
+}
+
+type archiveEntry struct {
+	mode    os.FileMode
+	content string
+}
+
+func readTarArchive(c *C, r io.Reader) (map[string]string, map[string]archiveEntry) {
+	var pax map[string]string
+	entries := make(map[string]archiveEntry)
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			pax = h.PAXRecords
+			continue
+		}
+
+		c.Assert(h.ModTime.Equal(defaultSignature().When), Equals, true, Commentf("entry %s", h.Name))
+
+		content, err := ioutil.ReadAll(tr)
+		c.Assert(err, IsNil)
+		if h.Typeflag == tar.TypeSymlink {
+			content = []byte(h.Linkname)
+		}
+
+		entries[h.Name] = archiveEntry{h.FileInfo().Mode(), string(content)}
+	}
+
+	return pax, entries
+}
+
+func (s *RepositorySuite) TestArchive(c *C) {
+	w, h := newArchiveRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.Archive(h, buf, &ArchiveOptions{Prefix: "project/"})
+	c.Assert(err, IsNil)
+
+	pax, entries := readTarArchive(c, buf)
+	c.Assert(pax["comment"], Equals, h.String())
+	c.Assert(entries, DeepEquals, map[string]archiveEntry{
+		"project/":               {os.ModeDir | 0775, ""},
+		"project/.gitattributes": {0664, "ignored export-ignore\nsecret export-ignore\nversion export-subst\n"},
+		"project/dir/":           {os.ModeDir | 0775, ""},
+		"project/dir/bar":        {0664, "bar\n"},
+		"project/dir/version":    {0664, h.String() + " by foo <foo@foo.foo>\n"},
+		"project/foo":            {0664, "foo\n"},
+		"project/link":           {os.ModeSymlink | 0777, "foo"},
+		"project/run.sh":         {0775, "#!/bin/sh\n"},
+		"project/version":        {0664, h.String()[:7] + " archive %x\n"},
+	})
+}
+
+func (s *RepositorySuite) TestArchiveTarGzip(c *C) {
+	w, h := newArchiveRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.Archive(h, buf, &ArchiveOptions{Format: TarGzipArchive})
+	c.Assert(err, IsNil)
+
+	gz, err := gzip.NewReader(buf)
+	c.Assert(err, IsNil)
+
+	_, entries := readTarArchive(c, gz)
+	c.Assert(entries, HasLen, 8)
+	c.Assert(entries["run.sh"], Equals, archiveEntry{0775, "#!/bin/sh\n"})
+}
+
+func (s *RepositorySuite) TestArchiveTree(c *C) {
+	w, h := newArchiveRepository(c)
+
+	commit, err := w.r.CommitObject(h)
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = w.r.Archive(commit.TreeHash, buf, &ArchiveOptions{})
+	c.Assert(err, IsNil)
+
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		c.Assert(err, IsNil)
+		c.Assert(hdr.Typeflag, Not(Equals), byte(tar.TypeXGlobalHeader))
+		if hdr.Name == "version" {
+			break
+		}
+	}
+
+	content, err := ioutil.ReadAll(tr)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "$Format:%h %s$ $Format:%x$\n")
+}
+
+func (s *RepositorySuite) TestArchiveZip(c *C) {
+	w, h := newArchiveRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.Archive(h, buf, &ArchiveOptions{Format: ZipArchive, Prefix: "project-"})
+	c.Assert(err, IsNil)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, IsNil)
+	c.Assert(zr.Comment, Equals, h.String())
+
+	entries := make(map[string]archiveEntry)
+	for _, f := range zr.File {
+		c.Assert(f.Modified.Equal(defaultSignature().When), Equals, true, Commentf("entry %s", f.Name))
+
+		r, err := f.Open()
+		c.Assert(err, IsNil)
+		content, err := ioutil.ReadAll(r)
+		c.Assert(err, IsNil)
+		c.Assert(r.Close(), IsNil)
+
+		entries[f.Name] = archiveEntry{f.Mode(), string(content)}
+	}
+
+	c.Assert(entries, HasLen, 8)
+	c.Assert(entries["project-dir/"], Equals, archiveEntry{os.ModeDir | 0777, ""})
+	c.Assert(entries["project-foo"], Equals, archiveEntry{0666, "foo\n"})
+	c.Assert(entries["project-run.sh"], Equals, archiveEntry{0755, "#!/bin/sh\n"})
+	c.Assert(entries["project-link"], Equals, archiveEntry{os.ModeSymlink | 0777, "foo"})
+	c.Assert(entries["project-dir/version"].content, Equals, h.String()+" by foo <foo@foo.foo>\n")
+}
+
+func (s *RepositorySuite) TestArchiveInvalidFormat(c *C) {
+	w, h := newArchiveRepository(c)
+
+	err := w.r.Archive(h, ioutil.Discard, &ArchiveOptions{Format: ArchiveFormat(42)})
+	c.Assert(err, Equals, ErrInvalidArchiveFormat)
+}
@@ -0,0 +1,606 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+)
+
+// The references and files, relative to the git directory, keeping the state
+// of a bisect, the same used by git.
+const (
+	bisectRefPrefix                              = "refs/bisect/"
+	bisectBadRef          plumbing.ReferenceName = bisectRefPrefix + "bad"
+	bisectGoodRefPrefix                          = bisectRefPrefix + "good-"
+	bisectSkipRefPrefix                          = bisectRefPrefix + "skip-"
+	bisectStartFile                              = "BISECT_START"
+	bisectLogFile                                = "BISECT_LOG"
+	bisectTermsFile                              = "BISECT_TERMS"
+	bisectNamesFile                              = "BISECT_NAMES"
+	bisectExpectedRevFile                        = "BISECT_EXPECTED_REV"
+	bisectAncestorsOKFile                        = "BISECT_ANCESTORS_OK"
+)
+
+var (
+	// ErrBisectInProgress is returned when a bisect is started while another
+	// one is in progress.
+	ErrBisectInProgress = errors.New("a bisect is already in progress")
+	// ErrNoBisectInProgress is returned by OpenBisect when there is no bisect
+	// in progress.
+	ErrNoBisectInProgress = errors.New("no bisect in progress")
+	// ErrBisectOnlySkipped is returned when every commit left to test is
+	// skipped, so the first bad commit can't be found.
+	ErrBisectOnlySkipped = errors.New("only skipped commits left to test")
+	// ErrBisectBadIsGood is returned when the bad commit is reachable from a
+	// good one.
+	ErrBisectBadIsGood = errors.New("the bad commit is an ancestor of a good one")
+)
+
+// BisectResult is the result of testing a commit during a bisect.
+type BisectResult int
+
+const (
+	// BisectGood marks a commit without the searched change.
+	BisectGood BisectResult = iota
+	// BisectBad marks a commit with the searched change.
+	BisectBad
+	// BisectSkip marks a commit that can't be tested.
+	BisectSkip
+)
+
+func (r BisectResult) String() string {
+	switch r {
+	case BisectGood:
+		return "good"
+	case BisectBad:
+		return "bad"
+	case BisectSkip:
+		return "skip"
+	}
+
+	return fmt.Sprintf("BisectResult(%d)", int(r))
+}
+
+// Bisect is a bisect in progress, a binary search of the first bad commit
+// between the commits marked as good and the one marked as bad.
+//
+// Its state is kept at the refs/bisect references, as git does, so it can be
+// resumed with OpenBisect. When the storage is based on a filesystem the
+// BISECT_START and BISECT_LOG files of git are kept too, so the bisect can be
+// continued with git.
+type Bisect struct {
+	r  *Repository
+	w  *Worktree
+	fs billy.Filesystem
+
+	// start is the branch, or the commit if HEAD was detached, checked out
+	// when the bisect was started.
+	start    string
+	bad      plumbing.Hash
+	good     map[plumbing.Hash]bool
+	skip     map[plumbing.Hash]bool
+	log      string
+	current  *object.Commit
+	firstBad *object.Commit
+}
+
+// Bisect starts a bisect between the commits of the given options, checking
+// out the first commit to be tested. The worktree must be clean.
+func (r *Repository) Bisect(o *BisectOptions) (*Bisect, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	b, err := r.newBisect()
+	if err != nil {
+		return nil, err
+	}
+
+	inProgress, err := b.inProgress()
+	if err != nil {
+		return nil, err
+	}
+
+	if inProgress {
+		return nil, ErrBisectInProgress
+	}
+
+	if err := b.w.checkCleanForMerge(); err != nil {
+		return nil, err
+	}
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+		b.start = head.Target().Short()
+	}
+
+	tip, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.start == "" {
+		b.start = tip.Hash().String()
+	}
+
+	if o.Bad.IsZero() {
+		o.Bad = tip.Hash()
+	}
+
+	args := shellQuote(o.Bad.String())
+	for _, h := range o.Good {
+		args += " " + shellQuote(h.String())
+	}
+
+	b.log = fmt.Sprintf("git bisect start %s\n", args)
+	for file, content := range map[string]string{
+		bisectStartFile: b.start,
+		bisectTermsFile: "bad\ngood",
+		bisectNamesFile: "",
+	} {
+		if err := b.write(file, content+"\n"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.mark(o.Bad, BisectBad); err != nil {
+		return nil, err
+	}
+
+	for _, h := range o.Good {
+		if err := b.mark(h, BisectGood); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range o.Skip {
+		if err := b.mark(h, BisectSkip); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, b.next()
+}
+
+// OpenBisect returns the bisect in progress, started by Bisect or by git,
+// checking out the next commit to be tested.
+func (r *Repository) OpenBisect() (*Bisect, error) {
+	b, err := r.newBisect()
+	if err != nil {
+		return nil, err
+	}
+
+	inProgress, err := b.inProgress()
+	if err != nil {
+		return nil, err
+	}
+
+	if !inProgress {
+		return nil, ErrNoBisectInProgress
+	}
+
+	if b.start, err = b.read(bisectStartFile); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if b.log, err = b.read(bisectLogFile); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	b.start = strings.TrimSpace(b.start)
+	err = b.forEachRef(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		switch {
+		case ref.Name() == bisectBadRef:
+			b.bad = ref.Hash()
+		case strings.HasPrefix(name, bisectGoodRefPrefix):
+			b.good[ref.Hash()] = true
+		case strings.HasPrefix(name, bisectSkipRefPrefix):
+			b.skip[ref.Hash()] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b, b.next()
+}
+
+func (r *Repository) newBisect() (*Bisect, error) {
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bisect{
+		r:    r,
+		w:    w,
+		fs:   r.dotGitFilesystem(),
+		good: make(map[plumbing.Hash]bool),
+		skip: make(map[plumbing.Hash]bool),
+	}, nil
+}
+
+// Current returns the commit checked out to be tested, nil once the first bad
+// commit is found or when it can't be found.
+func (b *Bisect) Current() *object.Commit {
+	return b.current
+}
+
+// FirstBad returns the first bad commit, nil until it's found.
+func (b *Bisect) FirstBad() *object.Commit {
+	return b.firstBad
+}
+
+// Log returns the log of the bisect in the format of git bisect log, it
+// can be replayed with git bisect replay.
+func (b *Bisect) Log() string {
+	return b.log
+}
+
+// Mark records the result of testing the given commit, usually the current
+// one, and checks out the next commit to be tested. ErrBisectOnlySkipped is
+// returned when all the commits left are skipped.
+func (b *Bisect) Mark(h plumbing.Hash, result BisectResult) error {
+	if err := b.mark(h, result); err != nil {
+		return err
+	}
+
+	return b.next()
+}
+
+// Run tests the commits with the given function, marking each one with its
+// result, until the first bad commit is found and returned. The function is
+// called with each commit already checked out in the worktree. If the
+// function fails its error is returned and the bisect is left in progress.
+func (b *Bisect) Run(test func(*object.Commit) (BisectResult, error)) (*object.Commit, error) {
+	for b.current != nil {
+		result, err := test(b.current)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := b.Mark(b.current.Hash, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.firstBad, nil
+}
+
+// Reset finishes the bisect, checking out the branch or the commit checked
+// out when it was started and removing its state.
+func (b *Bisect) Reset() error {
+	if b.start != "" {
+		opts := &CheckoutOptions{Branch: plumbing.ReferenceName("refs/heads/" + b.start)}
+		if _, err := b.r.Storer.Reference(opts.Branch); err == plumbing.ErrReferenceNotFound {
+			opts = &CheckoutOptions{Hash: plumbing.NewHash(b.start)}
+		}
+
+		if err := b.w.Checkout(opts); err != nil {
+			return err
+		}
+	}
+
+	var names []plumbing.ReferenceName
+	err := b.forEachRef(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := b.r.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+
+	if b.fs == nil {
+		return nil
+	}
+
+	for _, file := range []string{
+		bisectStartFile, bisectLogFile, bisectTermsFile, bisectNamesFile,
+		bisectExpectedRevFile, bisectAncestorsOKFile,
+	} {
+		if err := b.fs.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Bisect) inProgress() (bool, error) {
+	if b.fs != nil {
+		if _, err := b.fs.Stat(bisectStartFile); err == nil {
+			return true, nil
+		}
+	}
+
+	found := false
+	err := b.forEachRef(func(*plumbing.Reference) error {
+		found = true
+		return storer.ErrStop
+	})
+
+	return found, err
+}
+
+func (b *Bisect) forEachRef(cb func(*plumbing.Reference) error) error {
+	refs, err := b.r.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		if !strings.HasPrefix(ref.Name().String(), bisectRefPrefix) {
+			return nil
+		}
+
+		return cb(ref)
+	})
+}
+
+// mark records the result of testing a commit at the references and the log.
+func (b *Bisect) mark(h plumbing.Hash, result BisectResult) error {
+	c, err := b.r.CommitObject(h)
+	if err != nil {
+		return err
+	}
+
+	var name plumbing.ReferenceName
+	switch result {
+	case BisectGood:
+		name = plumbing.ReferenceName(bisectGoodRefPrefix + h.String())
+		b.good[h] = true
+	case BisectBad:
+		name = bisectBadRef
+		b.bad = h
+	case BisectSkip:
+		name = plumbing.ReferenceName(bisectSkipRefPrefix + h.String())
+		b.skip[h] = true
+	default:
+		return fmt.Errorf("invalid bisect result: %s", result)
+	}
+
+	if err := b.r.Storer.SetReference(plumbing.NewHashReference(name, h)); err != nil {
+		return err
+	}
+
+	return b.appendLog(
+		fmt.Sprintf("# %s: [%s] %s", result, h, commitSubject(c)),
+		fmt.Sprintf("git bisect %s %s", result, h),
+	)
+}
+
+// next checks out the next commit to be tested, the one splitting the
+// commits left to test in two halves, or records the first bad commit once
+// only one is left.
+func (b *Bisect) next() error {
+	b.current = nil
+	if b.bad.IsZero() || len(b.good) == 0 {
+		return nil
+	}
+
+	candidates, err := b.candidates()
+	if err != nil {
+		return err
+	}
+
+	if len(candidates) == 0 {
+		return ErrBisectBadIsGood
+	}
+
+	if len(candidates) == 1 {
+		b.firstBad = candidates[0]
+		return b.appendLog(fmt.Sprintf("# first bad commit: [%s] %s",
+			b.firstBad.Hash, commitSubject(b.firstBad)))
+	}
+
+	best := bestBisection(candidates, b.skip)
+	if best.Hash == b.bad {
+		lines := []string{"# only skipped commits left to test"}
+		for _, c := range candidates {
+			if c.Hash == b.bad || b.skip[c.Hash] {
+				lines = append(lines, fmt.Sprintf("# possible first bad commit: [%s] %s",
+					c.Hash, commitSubject(c)))
+			}
+		}
+
+		if err := b.appendLog(lines...); err != nil {
+			return err
+		}
+
+		return ErrBisectOnlySkipped
+	}
+
+	if err := b.w.Checkout(&CheckoutOptions{Hash: best.Hash}); err != nil {
+		return err
+	}
+
+	b.current = best
+	return b.write(bisectExpectedRevFile, best.Hash.String()+"\n")
+}
+
+// candidates returns the commits reachable from the bad commit and not from
+// the good ones, the bad commit first.
+func (b *Bisect) candidates() ([]*object.Commit, error) {
+	seen := make(map[plumbing.Hash]bool)
+	for h := range b.good {
+		c, err := b.r.CommitObject(h)
+		if err != nil {
+			return nil, err
+		}
+
+		err = object.NewCommitPreorderIter(c, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bad, err := b.r.CommitObject(b.bad)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*object.Commit
+	err = object.NewCommitPreorderIter(bad, seen, nil).ForEach(func(c *object.Commit) error {
+		candidates = append(candidates, c)
+		return nil
+	})
+
+	return candidates, err
+}
+
+// bestBisection returns the candidate, not skipped, with the closest number
+// of candidates reachable from it to the half of them, as git does. The bad
+// commit, the first candidate, is returned if all the others are skipped.
+func bestBisection(candidates []*object.Commit, skip map[plumbing.Hash]bool) *object.Commit {
+	weights := bisectWeights(candidates)
+
+	best, bestDistance := candidates[0], 0
+	for _, c := range candidates {
+		if skip[c.Hash] {
+			continue
+		}
+
+		distance := weights[c.Hash]
+		if rest := len(candidates) - distance; rest < distance {
+			distance = rest
+		}
+
+		if distance > bestDistance {
+			best, bestDistance = c, distance
+		}
+	}
+
+	return best
+}
+
+// bisectWeights returns the number of candidates reachable from each one,
+// itself included. The candidates are weighted from the oldest to the newest
+// so the weight of a commit with only one parent among them is the weight of
+// its parent plus one, only the merges require a walk.
+func bisectWeights(candidates []*object.Commit) map[plumbing.Hash]int {
+	index := make(map[plumbing.Hash]*object.Commit, len(candidates))
+	for _, c := range candidates {
+		index[c.Hash] = c
+	}
+
+	parents := func(c *object.Commit) []plumbing.Hash {
+		var result []plumbing.Hash
+		for _, p := range c.ParentHashes {
+			if _, ok := index[p]; ok {
+				result = append(result, p)
+			}
+		}
+
+		return result
+	}
+
+	children := make(map[plumbing.Hash]int, len(candidates))
+	for _, c := range candidates {
+		for _, p := range parents(c) {
+			children[p]++
+		}
+	}
+
+	// topological order, every commit before its parents
+	var order []*object.Commit
+	for _, c := range candidates {
+		if children[c.Hash] == 0 {
+			order = append(order, c)
+		}
+	}
+
+	for i := 0; i < len(order); i++ {
+		for _, p := range parents(order[i]) {
+			if children[p]--; children[p] == 0 {
+				order = append(order, index[p])
+			}
+		}
+	}
+
+	weights := make(map[plumbing.Hash]int, len(candidates))
+	for i := len(order) - 1; i >= 0; i-- {
+		c := order[i]
+		switch ps := parents(c); len(ps) {
+		case 0:
+			weights[c.Hash] = 1
+		case 1:
+			weights[c.Hash] = weights[ps[0]] + 1
+		default:
+			seen := map[plumbing.Hash]bool{c.Hash: true}
+			pending := ps
+			for len(pending) > 0 {
+				h := pending[len(pending)-1]
+				pending = pending[:len(pending)-1]
+				if seen[h] {
+					continue
+				}
+
+				seen[h] = true
+				pending = append(pending, parents(index[h])...)
+			}
+
+			weights[c.Hash] = len(seen)
+		}
+	}
+
+	return weights
+}
+
+func (b *Bisect) appendLog(lines ...string) error {
+	b.log += strings.Join(lines, "\n") + "\n"
+	return b.write(bisectLogFile, b.log)
+}
+
+// write writes a state file when the storage is based on a filesystem.
+func (b *Bisect) write(file, content string) error {
+	if b.fs == nil {
+		return nil
+	}
+
+	return util.WriteFile(b.fs, file, []byte(content), 0644)
+}
+
+func (b *Bisect) read(file string) (string, error) {
+	if b.fs == nil {
+		return "", nil
+	}
+
+	f, err := b.fs.Open(file)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := buf.ReadFrom(f); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,244 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+type BisectSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&BisectSuite{})
+
+// newBisectRepository returns a repository with a linear history of the
+// given number of commits, the commit i writing i to the version file.
+func newBisectRepository(c *C, s storage.Storer, commits int) (*Repository, []plumbing.Hash) {
+	r, err := Init(s, memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	var hashes []plumbing.Hash
+	for i := 0; i < commits; i++ {
+		err := util.WriteFile(w.Filesystem, "version", []byte(strconv.Itoa(i)), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add("version")
+		c.Assert(err, IsNil)
+
+		h, err := w.Commit(fmt.Sprintf("version %d\n", i), &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+		hashes = append(hashes, h)
+	}
+
+	return r, hashes
+}
+
+// bisectTest returns a test marking as bad the versions from bad on and
+// skipping the given ones, it records the tested versions.
+func bisectTest(c *C, r *Repository, bad int, tested *[]int, skip ...int) func(*object.Commit) (BisectResult, error) {
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	return func(commit *object.Commit) (BisectResult, error) {
+		head, err := r.Head()
+		c.Assert(err, IsNil)
+		c.Assert(head.Hash(), Equals, commit.Hash)
+
+		f, err := w.Filesystem.Open("version")
+		c.Assert(err, IsNil)
+		content, err := ioutil.ReadAll(f)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+
+		version, err := strconv.Atoi(string(content))
+		c.Assert(err, IsNil)
+		*tested = append(*tested, version)
+
+		for _, s := range skip {
+			if version == s {
+				return BisectSkip, nil
+			}
+		}
+
+		if version >= bad {
+			return BisectBad, nil
+		}
+
+		return BisectGood, nil
+	}
+}
+
+func (s *BisectSuite) TestBisectRun(c *C) {
+	r, hashes := newBisectRepository(c, memory.NewStorage(), 16)
+
+	b, err := r.Bisect(&BisectOptions{Good: hashes[:1]})
+	c.Assert(err, IsNil)
+	c.Assert(b.Current(), NotNil)
+
+	var tested []int
+	bad, err := b.Run(bisectTest(c, r, 11, &tested))
+	c.Assert(err, IsNil)
+	c.Assert(bad.Hash, Equals, hashes[11])
+	c.Assert(b.FirstBad().Hash, Equals, hashes[11])
+	c.Assert(b.Current(), IsNil)
+	c.Assert(len(tested) <= 4, Equals, true, Commentf("tested %v", tested))
+
+	ref, err := r.Reference(bisectBadRef, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, hashes[11])
+
+	ref, err = r.Reference(plumbing.ReferenceName(bisectGoodRefPrefix+hashes[10].String()), false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, hashes[10])
+
+	c.Assert(b.Reset(), IsNil)
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.Master)
+
+	_, err = r.Reference(bisectBadRef, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = r.OpenBisect()
+	c.Assert(err, Equals, ErrNoBisectInProgress)
+}
+
+func (s *BisectSuite) TestBisectSkip(c *C) {
+	r, hashes := newBisectRepository(c, memory.NewStorage(), 10)
+
+	b, err := r.Bisect(&BisectOptions{
+		Bad:  hashes[9],
+		Good: hashes[:1],
+		Skip: hashes[4:6],
+	})
+	c.Assert(err, IsNil)
+
+	var tested []int
+	bad, err := b.Run(bisectTest(c, r, 7, &tested, 3))
+	c.Assert(err, IsNil)
+	c.Assert(bad.Hash, Equals, hashes[7])
+
+	for _, v := range tested {
+		c.Assert(v != 4 && v != 5, Equals, true, Commentf("tested %v", tested))
+	}
+}
+
+func (s *BisectSuite) TestBisectOnlySkipped(c *C) {
+	r, hashes := newBisectRepository(c, memory.NewStorage(), 10)
+
+	b, err := r.Bisect(&BisectOptions{Good: hashes[:1]})
+	c.Assert(err, IsNil)
+
+	var tested []int
+	_, err = b.Run(bisectTest(c, r, 5, &tested, 5))
+	c.Assert(err, Equals, ErrBisectOnlySkipped)
+	c.Assert(b.FirstBad(), IsNil)
+	c.Assert(b.Current(), IsNil)
+}
+
+func (s *BisectSuite) TestBisectInvalid(c *C) {
+	r, hashes := newBisectRepository(c, memory.NewStorage(), 3)
+
+	_, err := r.Bisect(&BisectOptions{})
+	c.Assert(err, Equals, ErrMissingGood)
+
+	_, err = r.Bisect(&BisectOptions{Bad: hashes[0], Good: hashes[1:2]})
+	c.Assert(err, Equals, ErrBisectBadIsGood)
+
+	_, err = r.Bisect(&BisectOptions{Good: hashes[:1]})
+	c.Assert(err, Equals, ErrBisectInProgress)
+}
+
+func (s *BisectSuite) TestBisectState(c *C) {
+	st, err := filesystem.NewStorage(memfs.New())
+	c.Assert(err, IsNil)
+
+	r, hashes := newBisectRepository(c, st, 4)
+	fs := st.Filesystem()
+
+	b, err := r.Bisect(&BisectOptions{Good: hashes[:1]})
+	c.Assert(err, IsNil)
+	c.Assert(b.Current().Hash, Equals, hashes[2])
+
+	assertBisectFile(c, fs, bisectStartFile, "master\n")
+	assertBisectFile(c, fs, bisectExpectedRevFile, hashes[2].String()+"\n")
+
+	// a new session continues the one in progress
+	b, err = r.OpenBisect()
+	c.Assert(err, IsNil)
+	c.Assert(b.Current().Hash, Equals, hashes[2])
+	c.Assert(b.Mark(hashes[2], BisectGood), IsNil)
+	c.Assert(b.FirstBad().Hash, Equals, hashes[3])
+
+	expected := fmt.Sprintf("git bisect start '%[1]s' '%[2]s'\n"+
+		"# bad: [%[1]s] version 3\n"+
+		"git bisect bad %[1]s\n"+
+		"# good: [%[2]s] version 0\n"+
+		"git bisect good %[2]s\n"+
+		"# good: [%[3]s] version 2\n"+
+		"git bisect good %[3]s\n"+
+		"# first bad commit: [%[1]s] version 3\n",
+		hashes[3], hashes[0], hashes[2],
+	)
+
+	c.Assert(b.Log(), Equals, expected)
+	assertBisectFile(c, fs, bisectLogFile, expected)
+
+	c.Assert(b.Reset(), IsNil)
+	for _, file := range []string{bisectStartFile, bisectLogFile, bisectExpectedRevFile} {
+		_, err := fs.Stat(file)
+		c.Assert(err, NotNil, Commentf("file %s", file))
+	}
+}
+
+func (s *BisectSuite) TestBisectWeights(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	merge, err := w.Commit("merge\n", &CommitOptions{
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{master, feature},
+	})
+	c.Assert(err, IsNil)
+
+	var candidates []*object.Commit
+	for _, h := range []plumbing.Hash{merge, master, feature} {
+		commit, err := w.r.CommitObject(h)
+		c.Assert(err, IsNil)
+		candidates = append(candidates, commit)
+	}
+
+	weights := bisectWeights(candidates)
+	c.Assert(weights, DeepEquals, map[plumbing.Hash]int{
+		merge: 3, master: 1, feature: 1,
+	})
+}
+
+func assertBisectFile(c *C, fs billy.Filesystem, name, expected string) {
+	f, err := fs.Open(name)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, expected)
+}
@@ -0,0 +1,256 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/config"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/bundle"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/packfile"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/revlist"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+)
+
+var (
+	// ErrEmptyBundle is returned when creating a bundle without objects.
+	ErrEmptyBundle = errors.New("refusing to create empty bundle")
+	// ErrMissingPrerequisites is returned when fetching a bundle whose
+	// prerequisite commits aren't in the repository.
+	ErrMissingPrerequisites = errors.New("repository lacks the prerequisite commits of the bundle")
+	// ErrUnsupportedBundleCapability is returned when fetching a bundle with
+	// unknown capabilities, or with partial or non sha1 packfiles.
+	ErrUnsupportedBundleCapability = errors.New("unsupported bundle capability")
+)
+
+// CreateBundle writes to w a bundle with the given references and the
+// objects required to complete their histories, equivalent to
+// `git bundle create`. The history of the excluded commits isn't included,
+// so the bundle can only be fetched by repositories that already have the
+// commits, the prerequisites of the bundle.
+func (r *Repository) CreateBundle(w io.Writer, o *CreateBundleOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	refs, err := r.bundleReferences(o.References)
+	if err != nil {
+		return err
+	}
+
+	var tips []plumbing.Hash
+	for _, ref := range refs {
+		tips = append(tips, ref.Hash())
+	}
+
+	hashes, err := revlist.Objects(r.Storer, tips, o.Exclude)
+	if err != nil {
+		return err
+	}
+
+	if len(hashes) == 0 {
+		return ErrEmptyBundle
+	}
+
+	prerequisites, err := r.bundlePrerequisites(hashes)
+	if err != nil {
+		return err
+	}
+
+	b := &bundle.Bundle{
+		Version:       o.Version,
+		Prerequisites: prerequisites,
+		References:    refs,
+	}
+
+	if o.Version == bundle.V3 {
+		b.Capabilities = map[string]string{"object-format": "sha1"}
+	}
+
+	if err := bundle.NewEncoder(w).Encode(b); err != nil {
+		return err
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	_, err = packfile.NewEncoder(w, r.Storer, false).Encode(hashes, cfg.Pack.Window)
+	return err
+}
+
+// bundleReferences resolves the references to include in a bundle, by
+// default HEAD, the branches and the tags.
+func (r *Repository) bundleReferences(names []plumbing.ReferenceName) ([]*plumbing.Reference, error) {
+	if len(names) == 0 {
+		return r.allBundleReferences()
+	}
+
+	var refs []*plumbing.Reference
+	for _, name := range names {
+		ref, err := storer.ResolveReference(r.Storer, name)
+		if err != nil {
+			return nil, err
+		}
+
+		refs = append(refs, plumbing.NewHashReference(name, ref.Hash()))
+	}
+
+	return refs, nil
+}
+
+func (r *Repository) allBundleReferences() ([]*plumbing.Reference, error) {
+	var refs []*plumbing.Reference
+	head, err := storer.ResolveReference(r.Storer, plumbing.HEAD)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	if head != nil {
+		refs = append(refs, plumbing.NewHashReference(plumbing.HEAD, head.Hash()))
+	}
+
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var named []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && (ref.Name().IsBranch() || ref.Name().IsTag()) {
+			named = append(named, ref)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(named, func(i, j int) bool { return named[i].Name() < named[j].Name() })
+	return append(refs, named...), nil
+}
+
+// bundlePrerequisites returns the parents of the commits of a bundle that
+// are not included in it.
+func (r *Repository) bundlePrerequisites(hashes []plumbing.Hash) ([]bundle.Prerequisite, error) {
+	included := make(map[plumbing.Hash]bool, len(hashes))
+	for _, h := range hashes {
+		included[h] = true
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	var prerequisites []bundle.Prerequisite
+	for _, h := range hashes {
+		obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return nil, err
+		}
+
+		if obj.Type() != plumbing.CommitObject {
+			continue
+		}
+
+		c, err := object.DecodeCommit(r.Storer, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range c.ParentHashes {
+			if included[p] || seen[p] {
+				continue
+			}
+
+			seen[p] = true
+			parent, err := r.CommitObject(p)
+			if err != nil {
+				return nil, err
+			}
+
+			prerequisites = append(prerequisites, bundle.Prerequisite{
+				Hash:    p,
+				Comment: commitSubject(parent),
+			})
+		}
+	}
+
+	sort.Slice(prerequisites, func(i, j int) bool {
+		return prerequisites[i].Hash.String() < prerequisites[j].Hash.String()
+	})
+
+	return prerequisites, nil
+}
+
+// FetchBundle fetches the references of the bundle read from br, along with
+// the objects of the bundle, equivalent to `git fetch <bundle>`. The
+// repository must have the prerequisite commits of the bundle. It can be
+// used to seed a new repository from a bundle before fetching the rest of
+// the history from a remote.
+//
+// Returns nil if the operation is successful, NoErrAlreadyUpToDate if there
+// are no changes to be fetched, or an error.
+func (r *Repository) FetchBundle(br io.Reader, o *FetchBundleOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	b, err := bundle.NewDecoder(br).Decode()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range b.Capabilities {
+		if k != "object-format" || v != "sha1" {
+			return ErrUnsupportedBundleCapability
+		}
+	}
+
+	for _, p := range b.Prerequisites {
+		if _, err := r.CommitObject(p.Hash); err != nil {
+			if err == plumbing.ErrObjectNotFound {
+				return ErrMissingPrerequisites
+			}
+
+			return err
+		}
+	}
+
+	// the packfiles of bundles with prerequisites can be thin
+	if len(b.Prerequisites) != 0 {
+		err = packfile.UpdateObjectStorageFromThinPackfile(r.Storer, b.Packfile)
+	} else {
+		err = packfile.UpdateObjectStorage(r.Storer, b.Packfile)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	bundleRefs := make(memory.ReferenceStorage)
+	for _, ref := range b.References {
+		if err := bundleRefs.SetReference(ref); err != nil {
+			return err
+		}
+	}
+
+	refs, err := calculateRefs(o.RefSpecs, bundleRefs, o.Tags)
+	if err != nil {
+		return err
+	}
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{Name: o.RemoteName})
+	updated, err := remote.updateLocalReferenceStorage(o.RefSpecs, refs, bundleRefs, o.Tags, o.Force)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return NoErrAlreadyUpToDate
+	}
+
+	return nil
+}
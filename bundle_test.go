@@ -0,0 +1,106 @@
+package git
+
+import (
+	"bytes"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/config"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/bundle"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RepositorySuite) TestCreateBundle(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.CreateBundle(buf, &CreateBundleOptions{})
+	c.Assert(err, IsNil)
+
+	b, err := bundle.NewDecoder(buf).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, bundle.V2)
+	c.Assert(b.Prerequisites, HasLen, 0)
+	c.Assert(b.References, DeepEquals, []*plumbing.Reference{
+		plumbing.NewHashReference(plumbing.HEAD, commits[1]),
+		plumbing.NewHashReference("refs/heads/feature", base),
+		plumbing.NewHashReference(plumbing.Master, commits[1]),
+	})
+
+	buf.Reset()
+	err = w.r.CreateBundle(buf, &CreateBundleOptions{
+		References: []plumbing.ReferenceName{plumbing.Master},
+		Exclude:    []plumbing.Hash{base},
+		Version:    bundle.V3,
+	})
+	c.Assert(err, IsNil)
+
+	b, err = bundle.NewDecoder(buf).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, bundle.V3)
+	c.Assert(b.Capabilities, DeepEquals, map[string]string{"object-format": "sha1"})
+	c.Assert(b.Prerequisites, DeepEquals, []bundle.Prerequisite{{Hash: base, Comment: "files"}})
+	c.Assert(b.References, DeepEquals, []*plumbing.Reference{
+		plumbing.NewHashReference(plumbing.Master, commits[1]),
+	})
+}
+
+func (s *RepositorySuite) TestCreateBundleEmpty(c *C) {
+	w, _, commits := newPatchSeriesRepository(c)
+
+	err := w.r.CreateBundle(bytes.NewBuffer(nil), &CreateBundleOptions{
+		References: []plumbing.ReferenceName{plumbing.Master},
+		Exclude:    []plumbing.Hash{commits[1]},
+	})
+	c.Assert(err, Equals, ErrEmptyBundle)
+}
+
+func (s *RepositorySuite) TestFetchBundle(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+
+	full := bytes.NewBuffer(nil)
+	err := w.r.CreateBundle(full, &CreateBundleOptions{
+		References: []plumbing.ReferenceName{"refs/heads/feature"},
+	})
+	c.Assert(err, IsNil)
+
+	incremental := bytes.NewBuffer(nil)
+	err = w.r.CreateBundle(incremental, &CreateBundleOptions{
+		References: []plumbing.ReferenceName{plumbing.Master},
+		Exclude:    []plumbing.Hash{base},
+	})
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	err = r.FetchBundle(bytes.NewReader(incremental.Bytes()), &FetchBundleOptions{})
+	c.Assert(err, Equals, ErrMissingPrerequisites)
+
+	err = r.FetchBundle(full, &FetchBundleOptions{})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference("refs/remotes/origin/feature", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, base)
+
+	err = r.FetchBundle(bytes.NewReader(incremental.Bytes()), &FetchBundleOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"},
+	})
+	c.Assert(err, IsNil)
+
+	ref, err = r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, commits[1])
+
+	commit, err := r.CommitObject(commits[1])
+	c.Assert(err, IsNil)
+	_, err = commit.Tree()
+	c.Assert(err, IsNil)
+
+	err = r.FetchBundle(bytes.NewReader(incremental.Bytes()), &FetchBundleOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"},
+	})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+}
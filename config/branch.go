@@ -14,8 +14,9 @@ import (
 )
 
 var (
-	errBranchEmptyName    = errors.New("branch config: empty name")
-	errBranchInvalidMerge = errors.New("branch config: invalid merge")
+	errBranchEmptyName     = errors.New("branch config: empty name")
+	errBranchInvalidMerge  = errors.New("branch config: invalid merge")
+	errBranchInvalidRebase = errors.New("branch config: rebase must be one of 'true', 'false', 'merges' or 'interactive'")
 )
 
 // Branch contains information on the
@@ -27,6 +28,10 @@ type Branch struct {
 	Remote string
 	// Merge is the local refspec for the branch
 	Merge plumbing.ReferenceName
+	// Rebase instead of merge when pulling. Valid values are the booleans,
+	// "true", "false" or any other spelling accepted by git, "merges" and
+	// "interactive". If empty, pull.rebase is used.
+	Rebase string
 
 	raw *format.Subsection
 }
@@ -41,6 +46,14 @@ func (b *Branch) Validate() error {
 		return errBranchInvalidMerge
 	}
 
+	switch b.Rebase {
+	case "", "merges", "interactive":
+	default:
+		if _, ok := format.ParseBool(b.Rebase); !ok {
+			return errBranchInvalidRebase
+		}
+	}
+
 	return nil
 }
 
@@ -63,6 +76,12 @@ func (b *Branch) marshal() *format.Subsection {
 		b.raw.SetOption(mergeKey, string(b.Merge))
 	}
 
+	if b.Rebase == "" {
+		b.raw.RemoveOption(rebaseKey)
+	} else {
+		b.raw.SetOption(rebaseKey, b.Rebase)
+	}
+
 	return b.raw
 }
 
@@ -72,6 +91,7 @@ func (b *Branch) unmarshal(s *format.Subsection) error {
 	b.Name = b.raw.Name
 	b.Remote = b.raw.Options.Get(remoteSection)
 	b.Merge = plumbing.ReferenceName(b.raw.Options.Get(mergeKey))
+	b.Rebase = b.raw.Options.Get(rebaseKey)
 
 	return b.Validate()
 }
@@ -27,6 +27,11 @@ func (b *BranchSuite) TestValidateName(c *C) {
 	}
 	c.Assert(goodBranch.Validate(), IsNil)
 	c.Assert(badBranch.Validate(), NotNil)
+
+	for _, rebase := range []string{"true", "Yes", "off", "1"} {
+		goodBranch.Rebase = rebase
+		c.Assert(goodBranch.Validate(), IsNil)
+	}
 }
 
 func (b *BranchSuite) TestValidateMerge(c *C) {
@@ -80,3 +85,42 @@ func (b *BranchSuite) TestUnmarshall(c *C) {
 	c.Assert(branch.Remote, Equals, "fork")
 	c.Assert(branch.Merge, Equals, plumbing.ReferenceName("refs/heads/branch-tracking-on-clone"))
 }
+
+func (b *BranchSuite) TestValidateRebase(c *C) {
+	goodBranch := Branch{
+		Name:   "master",
+		Rebase: "merges",
+	}
+	badBranch := Branch{
+		Name:   "master",
+		Rebase: "blah",
+	}
+	c.Assert(goodBranch.Validate(), IsNil)
+	c.Assert(badBranch.Validate(), NotNil)
+}
+
+func (b *BranchSuite) TestMarshallUnmarshallRebase(c *C) {
+	input := []byte(`[core]
+	bare = false
+[branch "master"]
+	remote = origin
+	merge = refs/heads/master
+	rebase = true
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Branches["master"].Rebase, Equals, "true")
+
+	cfg.Branches["master"].Rebase = "false"
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `[core]
+	bare = false
+[branch "master"]
+	remote = origin
+	merge = refs/heads/master
+	rebase = false
+`)
+}
@@ -46,6 +46,11 @@ type Config struct {
 		IsBare bool
 		// Worktree is the path to the root of the working tree.
 		Worktree string
+		// LogAllRefUpdates enables the reflog of the references, valid values
+		// are "true", logging the branches, the remote-tracking branches, the
+		// notes and HEAD, "always", logging every reference, and "false". If
+		// empty the reflogs are enabled in repositories with worktree.
+		LogAllRefUpdates string
 	}
 
 	Pack struct {
@@ -110,17 +115,19 @@ func (c *Config) Validate() error {
 }
 
 const (
-	remoteSection    = "remote"
-	submoduleSection = "submodule"
-	branchSection    = "branch"
-	coreSection      = "core"
-	packSection      = "pack"
-	fetchKey         = "fetch"
-	urlKey           = "url"
-	bareKey          = "bare"
-	worktreeKey      = "worktree"
-	windowKey        = "window"
-	mergeKey         = "merge"
+	remoteSection       = "remote"
+	submoduleSection    = "submodule"
+	branchSection       = "branch"
+	coreSection         = "core"
+	packSection         = "pack"
+	fetchKey            = "fetch"
+	urlKey              = "url"
+	bareKey             = "bare"
+	worktreeKey         = "worktree"
+	logAllRefUpdatesKey = "logallrefupdates"
+	windowKey           = "window"
+	mergeKey            = "merge"
+	rebaseKey           = "rebase"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
@@ -157,6 +164,7 @@ func (c *Config) unmarshalCore() {
 	}
 
 	c.Core.Worktree = s.Options.Get(worktreeKey)
+	c.Core.LogAllRefUpdates = s.Options.Get(logAllRefUpdatesKey)
 }
 
 func (c *Config) unmarshalPack() error {
@@ -239,6 +247,10 @@ func (c *Config) marshalCore() {
 	if c.Core.Worktree != "" {
 		s.SetOption(worktreeKey, c.Core.Worktree)
 	}
+
+	if c.Core.LogAllRefUpdates != "" {
+		s.SetOption(logAllRefUpdatesKey, c.Core.LogAllRefUpdates)
+	}
 }
 
 func (c *Config) marshalPack() {
@@ -0,0 +1,259 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+)
+
+// ErrNoDescribeTags is returned by Describe when no tag is reachable from the
+// commit and DescribeOptions.Always is not set.
+var ErrNoDescribeTags = errors.New("no tags can describe the commit")
+
+// Description is the description of a commit made by Describe, based on the
+// nearest tag reachable from it.
+type Description struct {
+	// Tag is the reference of the tag, nil if no tag is reachable from the
+	// commit.
+	Tag *plumbing.Reference
+	// Distance is the number of commits reachable from the commit and not
+	// from the tag.
+	Distance int
+	// Hash is the hash of the described commit.
+	Hash plumbing.Hash
+	// Dirty is set if the worktree has local changes.
+	Dirty bool
+
+	o *DescribeOptions
+}
+
+// String returns the description in the format of git describe:
+// <tag>-<distance>-g<abbreviated hash>, or only <tag> if the commit is the
+// tagged one, followed by the dirty mark if the worktree has local changes.
+func (d *Description) String() string {
+	abbrev := d.Hash.String()[:d.o.Abbrev]
+
+	var s string
+	switch {
+	case d.Tag == nil:
+		s = abbrev
+	case d.Distance == 0 && !d.o.Long:
+		s = d.Tag.Name().Short()
+	default:
+		s = fmt.Sprintf("%s-%d-g%s", d.Tag.Name().Short(), d.Distance, abbrev)
+	}
+
+	if d.Dirty {
+		s += d.o.Dirty
+	}
+
+	return s
+}
+
+// describeCandidate is a tag considered by Describe.
+type describeCandidate struct {
+	ref       *plumbing.Reference
+	annotated bool
+	tag       *object.Tag
+}
+
+// better returns whether the candidate is preferred over the given one when
+// both tag the same commit: the annotated tags first, the newest first.
+func (c *describeCandidate) better(o *describeCandidate) bool {
+	if c.annotated != o.annotated {
+		return c.annotated
+	}
+
+	if c.annotated && !c.tag.Tagger.When.Equal(o.tag.Tagger.When) {
+		return c.tag.Tagger.When.After(o.tag.Tagger.When)
+	}
+
+	return c.ref.Name() < o.ref.Name()
+}
+
+// Describe describes the given commit with the nearest tag reachable from it,
+// as git describe does. By default only the annotated tags are used.
+func (r *Repository) Describe(h plumbing.Hash, o *DescribeOptions) (*Description, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	commit, err := r.CommitObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Description{Hash: commit.Hash, o: o}
+	if o.Dirty != "" {
+		if d.Dirty, err = r.isDirty(); err != nil {
+			return nil, err
+		}
+	}
+
+	candidates, err := r.describeCandidates(o)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, ok := candidates[commit.Hash]; ok {
+		d.Tag = c.ref
+		return d, nil
+	}
+
+	var found []plumbing.Hash
+	err = object.NewCommitIterCTime(commit, nil, nil).ForEach(func(c *object.Commit) error {
+		if _, ok := candidates[c.Hash]; ok {
+			found = append(found, c.Hash)
+		}
+
+		if len(found) == o.Candidates {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(found) == 0 {
+		if o.Always {
+			return d, nil
+		}
+
+		return nil, ErrNoDescribeTags
+	}
+
+	d.Distance = -1
+	for _, tagged := range found {
+		distance, err := r.describeDistance(commit, tagged)
+		if err != nil {
+			return nil, err
+		}
+
+		if d.Distance == -1 || distance < d.Distance {
+			d.Tag, d.Distance = candidates[tagged].ref, distance
+		}
+	}
+
+	return d, nil
+}
+
+// describeCandidates returns the tags usable by Describe by the commit they
+// point to.
+func (r *Repository) describeCandidates(o *DescribeOptions) (map[plumbing.Hash]*describeCandidate, error) {
+	refs, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[plumbing.Hash]*describeCandidate)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if !matchDescribePatterns(ref.Name().Short(), o) {
+			return nil
+		}
+
+		c := &describeCandidate{ref: ref}
+		target := ref.Hash()
+
+		tag, err := r.TagObject(ref.Hash())
+		switch err {
+		case nil:
+			c.annotated, c.tag = true, tag
+			commit, err := tag.Commit()
+			if err != nil {
+				// tags of other objects than commits can't describe them
+				return nil
+			}
+
+			target = commit.Hash
+		case plumbing.ErrObjectNotFound:
+			if !o.Tags {
+				return nil
+			}
+		default:
+			return err
+		}
+
+		if prev, ok := candidates[target]; !ok || c.better(prev) {
+			candidates[target] = c
+		}
+
+		return nil
+	})
+
+	return candidates, err
+}
+
+func matchDescribePatterns(name string, o *DescribeOptions) bool {
+	for _, pattern := range o.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+
+	if len(o.Match) == 0 {
+		return true
+	}
+
+	for _, pattern := range o.Match {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describeDistance returns the number of commits reachable from the given
+// commit and not from the tagged one.
+func (r *Repository) describeDistance(commit *object.Commit, tagged plumbing.Hash) (int, error) {
+	t, err := r.CommitObject(tagged)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	err = object.NewCommitPreorderIter(t, nil, nil).ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var distance int
+	err = object.NewCommitPreorderIter(commit, seen, nil).ForEach(func(*object.Commit) error {
+		distance++
+		return nil
+	})
+
+	return distance, err
+}
+
+// isDirty returns whether the worktree of the repository has local changes,
+// the untracked files are ignored.
+func (r *Repository) isDirty() (bool, error) {
+	w, err := r.Worktree()
+	if err != nil {
+		return false, err
+	}
+
+	s, err := w.Status()
+	if err != nil {
+		return false, err
+	}
+
+	for _, fs := range s {
+		if fs.Staging != Unmodified && fs.Staging != Untracked ||
+			fs.Worktree != Unmodified && fs.Worktree != Untracked {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
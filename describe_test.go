@@ -0,0 +1,120 @@
+package git
+
+import (
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+type DescribeSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&DescribeSuite{})
+
+func (s *DescribeSuite) createTag(c *C, r *Repository, name string, h plumbing.Hash, annotated bool) {
+	var opts *CreateTagOptions
+	if annotated {
+		opts = &CreateTagOptions{Tagger: defaultSignature(), Message: name}
+	}
+
+	_, err := r.CreateTag(name, h, opts)
+	c.Assert(err, IsNil)
+}
+
+func (s *DescribeSuite) describe(c *C, r *Repository, h plumbing.Hash, o *DescribeOptions) string {
+	d, err := r.Describe(h, o)
+	c.Assert(err, IsNil)
+	return d.String()
+}
+
+func (s *DescribeSuite) TestDescribe(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+	r := w.r
+
+	first := commitFiles(c, w, map[string]string{"foo": "bar\n"})
+	second := commitFiles(c, w, map[string]string{"foo": "baz\n"})
+	third := commitFiles(c, w, map[string]string{"foo": "qux\n"})
+
+	s.createTag(c, r, "v1.0.0", first, true)
+	s.createTag(c, r, "light", second, false)
+
+	c.Assert(s.describe(c, r, first, &DescribeOptions{}), Equals, "v1.0.0")
+	c.Assert(s.describe(c, r, first, &DescribeOptions{Long: true}), Equals,
+		"v1.0.0-0-g"+first.String()[:7])
+	c.Assert(s.describe(c, r, third, &DescribeOptions{}), Equals,
+		"v1.0.0-2-g"+third.String()[:7])
+	c.Assert(s.describe(c, r, third, &DescribeOptions{Abbrev: 10}), Equals,
+		"v1.0.0-2-g"+third.String()[:10])
+	c.Assert(s.describe(c, r, third, &DescribeOptions{Tags: true}), Equals,
+		"light-1-g"+third.String()[:7])
+
+	d, err := r.Describe(third, &DescribeOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(d.Tag.Name(), Equals, plumbing.ReferenceName("refs/tags/v1.0.0"))
+	c.Assert(d.Distance, Equals, 2)
+	c.Assert(d.Hash, Equals, third)
+}
+
+func (s *DescribeSuite) TestDescribeMatch(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+	r := w.r
+
+	first := commitFiles(c, w, map[string]string{"foo": "bar\n"})
+	second := commitFiles(c, w, map[string]string{"foo": "baz\n"})
+
+	s.createTag(c, r, "v1.0.0", first, true)
+	s.createTag(c, r, "release-1", second, true)
+
+	c.Assert(s.describe(c, r, second, &DescribeOptions{Match: []string{"v*"}}), Equals,
+		"v1.0.0-1-g"+second.String()[:7])
+	c.Assert(s.describe(c, r, second, &DescribeOptions{Exclude: []string{"release-*"}}), Equals,
+		"v1.0.0-1-g"+second.String()[:7])
+
+	_, err := r.Describe(second, &DescribeOptions{Match: []string{"foo"}})
+	c.Assert(err, Equals, ErrNoDescribeTags)
+
+	c.Assert(s.describe(c, r, second, &DescribeOptions{Match: []string{"foo"}, Always: true}), Equals,
+		second.String()[:7])
+}
+
+func (s *DescribeSuite) TestDescribeMerge(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+	r := w.r
+
+	other := commitFiles(c, w, map[string]string{"qux": "qux\n"})
+	s.createTag(c, r, "v1.0.0", other, true)
+	s.createTag(c, r, "v2.0.0", feature, true)
+
+	merge, err := w.Commit("merge\n", &CommitOptions{
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{other, feature},
+	})
+	c.Assert(err, IsNil)
+
+	// master, other and merge aren't reachable from v2.0.0 but only feature
+	// and merge from v1.0.0.
+	c.Assert(s.describe(c, r, merge, &DescribeOptions{}), Equals,
+		"v1.0.0-2-g"+merge.String()[:7])
+}
+
+func (s *DescribeSuite) TestDescribeDirty(c *C) {
+	w, master, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+	r := w.r
+
+	s.createTag(c, r, "v1.0.0", master, true)
+
+	err := util.WriteFile(w.Filesystem, "untracked", []byte("foo\n"), 0644)
+	c.Assert(err, IsNil)
+	c.Assert(s.describe(c, r, master, &DescribeOptions{Dirty: "-dirty"}), Equals, "v1.0.0")
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("bar\n"), 0644)
+	c.Assert(err, IsNil)
+	c.Assert(s.describe(c, r, master, &DescribeOptions{Dirty: "-dirty"}), Equals, "v1.0.0-dirty")
+	c.Assert(s.describe(c, r, master, &DescribeOptions{}), Equals, "v1.0.0")
+}
@@ -0,0 +1,230 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/diff"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/mbox"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+)
+
+// patchDateFormat is the RFC 2822 format of the Date headers.
+const patchDateFormat = "Mon, 2 Jan 2006 15:04:05 -0700"
+
+// FormatPatch writes the non merge commits of the range given by the options
+// to w as a mbox, one message per commit with its patch, parents before
+// their children, equivalent to `git format-patch --stdout`.
+func (r *Repository) FormatPatch(w io.Writer, o *FormatPatchOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	until := o.Until
+	if until.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		until = head.Hash()
+	}
+
+	tip, err := r.CommitObject(until)
+	if err != nil {
+		return err
+	}
+
+	var since *object.Commit
+	if !o.Since.IsZero() {
+		if since, err = r.CommitObject(o.Since); err != nil {
+			return err
+		}
+	}
+
+	commits, err := commitsToRebase(tip, since)
+	if err != nil {
+		return err
+	}
+
+	e := mbox.NewEncoder(w)
+	for i, c := range commits {
+		prefix := fmt.Sprintf("[%s] ", o.SubjectPrefix)
+		if o.Numbered || len(commits) > 1 {
+			prefix = fmt.Sprintf("[%s %d/%d] ", o.SubjectPrefix, i+1, len(commits))
+		}
+
+		m, err := formatPatch(c, prefix, o)
+		if err != nil {
+			return err
+		}
+
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatPatch returns the message with the patch of the given commit,
+// relative to its first parent.
+func formatPatch(c *object.Commit, prefix string, o *FormatPatchOptions) (*mbox.Message, error) {
+	patch, err := commitPatch(c)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, body := splitCommitMessage(c.Message)
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "From: %s\n", formatAddress(c.Author.Name, c.Author.Email))
+	fmt.Fprintf(buf, "Date: %s\n", c.Author.When.Format(patchDateFormat))
+	fmt.Fprintf(buf, "Subject: %s%s\n", prefix, mime.QEncoding.Encode("UTF-8", subject))
+	if !isASCII(c.Message) || !isASCII(c.Author.Name) {
+		buf.WriteString("MIME-Version: 1.0\n")
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\n")
+		buf.WriteString("Content-Transfer-Encoding: 8bit\n")
+	}
+
+	fmt.Fprintf(buf, "\n%s---\n", body)
+
+	stats := patch.Stats()
+	if len(stats) != 0 {
+		fmt.Fprintf(buf, "%s%s\n", stats.String(), statsSummary(stats))
+	}
+
+	writeModeSummary(buf, patch)
+
+	buf.WriteString("\n")
+	if err := diff.NewUnifiedEncoder(buf, o.ContextLines).Encode(patch); err != nil {
+		return nil, err
+	}
+
+	if o.Signature != "" {
+		fmt.Fprintf(buf, "-- \n%s\n\n", strings.TrimRight(o.Signature, "\n"))
+	}
+
+	return &mbox.Message{
+		From:    c.Hash.String(),
+		Date:    mbox.PatchDate,
+		Content: buf.Bytes(),
+	}, nil
+}
+
+// commitPatch returns the patch of the given commit relative to its first
+// parent, or to the empty tree for root commits.
+func commitPatch(c *object.Commit) (*object.Patch, error) {
+	var from *object.Tree
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if from, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	to, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes.Patch()
+}
+
+// splitCommitMessage returns the subject of a commit message, its first
+// paragraph in a single line, and the rest of the message.
+func splitCommitMessage(msg string) (subject, body string) {
+	msg = strings.TrimLeft(msg, "\n")
+	parts := strings.SplitN(msg, "\n\n", 2)
+	subject = strings.Join(strings.Fields(parts[0]), " ")
+	if len(parts) == 2 {
+		body = strings.Trim(parts[1], "\n")
+		if body != "" {
+			body += "\n"
+		}
+	}
+
+	return subject, body
+}
+
+// formatAddress returns the address of a header with the given name and
+// email, the name is quoted or encoded only if needed, as git does.
+func formatAddress(name, email string) string {
+	switch {
+	case name == "":
+		return "<" + email + ">"
+	case !isASCII(name):
+		name = mime.QEncoding.Encode("UTF-8", name)
+	case strings.ContainsAny(name, `()<>[]:;@\,."`):
+		name = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+
+	return fmt.Sprintf("%s <%s>", name, email)
+}
+
+// statsSummary returns the last line of a diffstat, as printed by git.
+func statsSummary(stats object.FileStats) string {
+	var additions, deletions int
+	for _, s := range stats {
+		additions += s.Addition
+		deletions += s.Deletion
+	}
+
+	summary := fmt.Sprintf(" %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if additions != 0 || deletions == 0 {
+		summary += fmt.Sprintf(", %d %s(+)", additions, plural(additions, "insertion", "insertions"))
+	}
+
+	if deletions != 0 || additions == 0 {
+		summary += fmt.Sprintf(", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+
+	return summary
+}
+
+// writeModeSummary writes the files created and deleted by the patch and
+// the changes of mode, as git does after the diffstat.
+func writeModeSummary(w io.Writer, patch *object.Patch) {
+	for _, fp := range patch.FilePatches() {
+		from, to := fp.Files()
+		switch {
+		case from == nil:
+			fmt.Fprintf(w, " create mode %o %s\n", to.Mode(), to.Path())
+		case to == nil:
+			fmt.Fprintf(w, " delete mode %o %s\n", from.Mode(), from.Path())
+		case from.Mode() != to.Mode():
+			fmt.Fprintf(w, " mode change %o => %o %s\n", from.Mode(), to.Mode(), to.Path())
+		}
+	}
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+
+	return many
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
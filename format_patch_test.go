@@ -0,0 +1,130 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+// newPatchSeriesRepository returns a worktree with a base commit and two
+// commits on top of it, the base and the commits are returned.
+func newPatchSeriesRepository(c *C) (*Worktree, plumbing.Hash, []plumbing.Hash) {
+	w, base, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+
+	author := &object.Signature{
+		Name:  "John Doe",
+		Email: "john@doe.com",
+		When:  time.Date(2009, time.November, 10, 23, 0, 0, 0, time.FixedZone("", 3600)),
+	}
+
+	var commits []plumbing.Hash
+	for _, commit := range []struct{ file, content, msg string }{
+		{"bar", "bar\n", "Add bar\n\nbar is needed by foo.\n"},
+		{"foo", "foo\nbar\n", "Use\nbar in foo\n"},
+	} {
+		err := util.WriteFile(w.Filesystem, commit.file, []byte(commit.content), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add(commit.file)
+		c.Assert(err, IsNil)
+
+		h, err := w.Commit(commit.msg, &CommitOptions{Author: author})
+		c.Assert(err, IsNil)
+		commits = append(commits, h)
+	}
+
+	return w, base, commits
+}
+
+func contentHash(content string) plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.BlobObject, []byte(content))
+}
+
+func (s *RepositorySuite) TestFormatPatch(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.FormatPatch(buf, &FormatPatchOptions{Since: base, Signature: "go-git"})
+	c.Assert(err, IsNil)
+
+	c.Assert(buf.String(), Equals, fmt.Sprintf(`From %s Mon Sep 17 00:00:00 2001
+From: John Doe <john@doe.com>
+Date: Tue, 10 Nov 2009 23:00:00 +0100
+Subject: [PATCH 1/2] Add bar
+
+bar is needed by foo.
+---
+ bar | 1 +
+ 1 file changed, 1 insertion(+)
+ create mode 100644 bar
+
+diff --git a/bar b/bar
+new file mode 100644
+index %s..%s
+--- /dev/null
++++ b/bar
+@@ -0,0 +1 @@
++bar
+-- 
+go-git
+
+
+From %s Mon Sep 17 00:00:00 2001
+From: John Doe <john@doe.com>
+Date: Tue, 10 Nov 2009 23:00:00 +0100
+Subject: [PATCH 2/2] Use bar in foo
+
+---
+ foo | 1 +
+ 1 file changed, 1 insertion(+)
+
+diff --git a/foo b/foo
+index %s..%s 100644
+--- a/foo
++++ b/foo
+@@ -1 +1,2 @@
+ foo
++bar
+-- 
+go-git
+
+`, commits[0], plumbing.ZeroHash, contentHash("bar\n"),
+		commits[1], contentHash("foo\n"), contentHash("foo\nbar\n")))
+}
+
+func (s *RepositorySuite) TestFormatPatchSingle(c *C) {
+	w, _, commits := newPatchSeriesRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.FormatPatch(buf, &FormatPatchOptions{Since: commits[0]})
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Contains(buf.Bytes(), []byte("\nSubject: [PATCH] Use bar in foo\n")), Equals, true)
+	c.Assert(bytes.HasSuffix(buf.Bytes(), []byte("+bar\n")), Equals, true)
+
+	buf.Reset()
+	err = w.r.FormatPatch(buf, &FormatPatchOptions{
+		Since:         commits[0],
+		SubjectPrefix: "RFC PATCH",
+		Numbered:      true,
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Contains(buf.Bytes(), []byte("\nSubject: [RFC PATCH 1/1] Use bar in foo\n")), Equals, true)
+}
+
+func (s *RepositorySuite) TestFormatPatchRoot(c *C) {
+	w, _, commits := newPatchSeriesRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.FormatPatch(buf, &FormatPatchOptions{Until: commits[0]})
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Count(buf.Bytes(), []byte("\nSubject: [PATCH ")), Equals, 2)
+	c.Assert(bytes.Contains(buf.Bytes(), []byte("\nSubject: [PATCH 1/2] files\n")), Equals, true)
+	c.Assert(bytes.Contains(buf.Bytes(), []byte("\n+++ b/foo\n@@ -0,0 +1 @@\n+foo\n")), Equals, true)
+}
@@ -0,0 +1,831 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	stdioutil "io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+)
+
+// FsckIssueKind is the kind of a problem found by Fsck.
+type FsckIssueKind int
+
+const (
+	// FsckCorruptObject is an object that can't be read from the storage.
+	FsckCorruptObject FsckIssueKind = iota
+	// FsckHashMismatch is an object whose content doesn't match its hash.
+	FsckHashMismatch
+	// FsckBadObject is an object with an invalid content, like a tree with
+	// duplicated entries or a commit without author.
+	FsckBadObject
+	// FsckMissingObject is an object referenced by a reference, the index
+	// or another object that is not in the storage.
+	FsckMissingObject
+	// FsckDanglingObject is an unreachable object not referenced by other
+	// unreachable objects.
+	FsckDanglingObject
+	// FsckUnreachableObject is an object not reachable from the references,
+	// the reflogs or the index.
+	FsckUnreachableObject
+)
+
+// FsckIssue is a problem found by Fsck.
+type FsckIssue struct {
+	// Kind of the problem.
+	Kind FsckIssueKind
+	// Hash of the object with the problem, or of the missing object.
+	Hash plumbing.Hash
+	// Type of the object, or the expected type of a missing object, it is
+	// plumbing.AnyObject if it's unknown.
+	Type plumbing.ObjectType
+	// ID identifies the problems of FsckBadObject issues, it is the message
+	// id used by git fsck, like duplicateEntries or missingAuthor.
+	ID string
+	// Message describes the problem.
+	Message string
+	// Warning is true for the problems reported as warnings, like the tree
+	// entries named .git, they are errors if FsckOptions.Strict is set.
+	Warning bool
+}
+
+// String returns the issue in the format of the output of git fsck.
+func (i FsckIssue) String() string {
+	typ := "object"
+	if i.Type != plumbing.AnyObject {
+		typ = i.Type.String()
+	}
+
+	switch i.Kind {
+	case FsckCorruptObject:
+		return fmt.Sprintf("unable to read %s %s: %s", typ, i.Hash, i.Message)
+	case FsckHashMismatch:
+		return fmt.Sprintf("hash mismatch %s", i.Hash)
+	case FsckBadObject:
+		level := "error"
+		if i.Warning {
+			level = "warning"
+		}
+
+		if i.ID == "" {
+			return fmt.Sprintf("%s in %s %s: %s", level, typ, i.Hash, i.Message)
+		}
+
+		return fmt.Sprintf("%s in %s %s: %s: %s", level, typ, i.Hash, i.ID, i.Message)
+	case FsckMissingObject:
+		return fmt.Sprintf("missing %s %s: %s", typ, i.Hash, i.Message)
+	case FsckDanglingObject:
+		return fmt.Sprintf("dangling %s %s", typ, i.Hash)
+	default:
+		return fmt.Sprintf("unreachable %s %s", typ, i.Hash)
+	}
+}
+
+// FsckResult is the result of Fsck.
+type FsckResult struct {
+	// Issues are the problems found, sorted by kind and hash.
+	Issues []FsckIssue
+}
+
+// HasErrors returns true if any of the issues is an error, the warnings and
+// the dangling or unreachable objects are not errors.
+func (r *FsckResult) HasErrors() bool {
+	for _, i := range r.Issues {
+		switch i.Kind {
+		case FsckDanglingObject, FsckUnreachableObject:
+		case FsckBadObject:
+			if !i.Warning {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+// fsckMessages are the descriptions of the problems of FsckBadObject issues
+// by id, and whether they are reported as warnings.
+var fsckMessages = map[string]struct {
+	message string
+	warning bool
+}{
+	"badDate":                 {"invalid author/committer line - bad date", false},
+	"badDateOverflow":         {"invalid author/committer line - date causes integer overflow", false},
+	"badEmail":                {"invalid author/committer line - bad email", false},
+	"badFilemode":             {"contains bad file modes", true},
+	"badName":                 {"invalid author/committer line - bad name", false},
+	"badObjectSha1":           {"invalid 'object' line format - bad sha1", false},
+	"badParentSha1":           {"invalid 'parent' line format - bad sha1", false},
+	"badSignature":            {"invalid signature - bad BEGIN/END delimiters", false},
+	"badTimezone":             {"invalid author/committer line - bad time zone", false},
+	"badTree":                 {"cannot be parsed as a tree", false},
+	"badTreeSha1":             {"invalid 'tree' line format - bad sha1", false},
+	"badType":                 {"invalid 'type' value", false},
+	"duplicateEntries":        {"contains duplicate file entries", false},
+	"emptyName":               {"contains empty pathname", true},
+	"fullPathname":            {"contains full pathnames", true},
+	"hasDot":                  {"contains '.'", true},
+	"hasDotdot":               {"contains '..'", true},
+	"hasDotgit":               {"contains '.git'", true},
+	"missingAuthor":           {"invalid format - expected 'author' line", false},
+	"missingCommitter":        {"invalid format - expected 'committer' line", false},
+	"missingEmail":            {"invalid author/committer line - missing email", false},
+	"missingNameBeforeEmail":  {"invalid author/committer line - missing name before email", false},
+	"missingObject":           {"invalid format - expected 'object' line", false},
+	"missingSpaceBeforeDate":  {"invalid author/committer line - missing space before date", false},
+	"missingSpaceBeforeEmail": {"invalid author/committer line - missing space before email", false},
+	"missingTagEntry":         {"invalid format - expected 'tag' line", false},
+	"missingTaggerEntry":      {"invalid format - expected 'tagger' line", true},
+	"missingTree":             {"invalid format - expected 'tree' line", false},
+	"missingTypeEntry":        {"invalid format - expected 'type' line", false},
+	"nulInHeader":             {"unterminated header: NUL in header", false},
+	"nullSha1":                {"contains entries pointing to null sha1", true},
+	"treeNotSorted":           {"not properly sorted", false},
+	"unterminatedHeader":      {"unterminated header", false},
+	"zeroPaddedDate":          {"invalid author/committer line - zero-padded date", false},
+	"zeroPaddedFilemode":      {"contains zero-padded file modes", true},
+}
+
+// fsckTreeChecks is the order in which the problems of a tree are reported.
+var fsckTreeChecks = []string{
+	"badTree", "duplicateEntries", "nullSha1", "fullPathname", "hasDot",
+	"hasDotdot", "hasDotgit", "emptyName", "zeroPaddedFilemode",
+	"badFilemode", "treeNotSorted",
+}
+
+// Fsck verifies the integrity of the repository, equivalent to `git fsck`.
+// The hash and the content of every object are checked, and the objects
+// reachable from the references, the reflogs and the index must be in the
+// storage. The unreachable objects are reported as dangling or unreachable
+// objects.
+//
+// The returned error is only about the access to the storage, the problems
+// found are returned as the issues of the result.
+func (r *Repository) Fsck(o FsckOptions) (*FsckResult, error) {
+	f := &fsck{
+		s:           r.Storer,
+		o:           o,
+		reachable:   make(map[plumbing.Hash]bool),
+		mismatched:  make(map[plumbing.Hash]bool),
+		unreachable: make(map[plumbing.Hash][]fsckLink),
+		types:       make(map[plumbing.Hash]plumbing.ObjectType),
+	}
+
+	if err := f.walkReferences(); err != nil {
+		return nil, err
+	}
+
+	if !o.NoReflogs {
+		if err := f.walkReflogs(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.walkIndex(); err != nil {
+		return nil, err
+	}
+
+	if f.readAllObjects() {
+		f.checkUnreachable()
+	}
+
+	sort.SliceStable(f.issues, func(i, j int) bool {
+		if f.issues[i].Kind != f.issues[j].Kind {
+			return f.issues[i].Kind < f.issues[j].Kind
+		}
+
+		return f.issues[i].Hash.String() < f.issues[j].Hash.String()
+	})
+
+	return &FsckResult{Issues: f.issues}, nil
+}
+
+type fsckLink struct {
+	hash plumbing.Hash
+	typ  plumbing.ObjectType
+}
+
+type fsck struct {
+	s      storage.Storer
+	o      FsckOptions
+	issues []FsckIssue
+	// corrupt is the number of objects that couldn't be read.
+	corrupt int
+	// mismatched are the hashes of the content of the objects stored with
+	// a different hash.
+	mismatched map[plumbing.Hash]bool
+
+	reachable   map[plumbing.Hash]bool
+	unreachable map[plumbing.Hash][]fsckLink
+	types       map[plumbing.Hash]plumbing.ObjectType
+}
+
+func (f *fsck) walkReferences() error {
+	head, err := f.s.Reference(plumbing.HEAD)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	if head != nil && head.Type() == plumbing.HashReference {
+		f.walk(head.Hash(), plumbing.CommitObject, "HEAD: invalid sha1 pointer")
+	}
+
+	iter, err := f.s.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	return iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			f.walk(ref.Hash(), plumbing.AnyObject,
+				fmt.Sprintf("%s: invalid sha1 pointer", ref.Name()))
+		}
+
+		return nil
+	})
+}
+
+func (f *fsck) walkReflogs(r *Repository) error {
+	if _, ok := f.s.(storer.ReflogStorer); !ok {
+		return nil
+	}
+
+	names, err := r.loggedReferences()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		entries, err := r.reflog(name)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			for _, h := range []plumbing.Hash{e.Old, e.New} {
+				if h != plumbing.ZeroHash {
+					f.walk(h, plumbing.AnyObject,
+						fmt.Sprintf("%s: invalid reflog entry", name))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *fsck) walkIndex() error {
+	idx, err := f.s.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Mode != filemode.Submodule {
+			f.walk(e.Hash, plumbing.BlobObject,
+				fmt.Sprintf("%s: invalid sha1 pointer in index", e.Name))
+		}
+	}
+
+	if idx.Cache != nil {
+		for _, e := range idx.Cache.Entries {
+			if e.Entries >= 0 {
+				f.walk(e.Hash, plumbing.TreeObject, "invalid sha1 pointer in cache-tree")
+			}
+		}
+	}
+
+	return nil
+}
+
+// walk checks the object h and the objects reachable from it, source
+// describes where h is referenced from.
+func (f *fsck) walk(h plumbing.Hash, t plumbing.ObjectType, source string) {
+	pending := []fsckLink{{h, t}}
+	sources := []string{source}
+	for len(pending) != 0 {
+		l, source := pending[len(pending)-1], sources[len(sources)-1]
+		pending, sources = pending[:len(pending)-1], sources[:len(sources)-1]
+		if f.reachable[l.hash] {
+			continue
+		}
+
+		f.reachable[l.hash] = true
+		obj, err := f.s.EncodedObject(plumbing.AnyObject, l.hash)
+		if err == plumbing.ErrObjectNotFound {
+			f.report(FsckIssue{Kind: FsckMissingObject, Hash: l.hash, Type: l.typ, Message: source})
+			continue
+		}
+
+		if err != nil {
+			f.reportCorrupt(l.hash, l.typ, err)
+			continue
+		}
+
+		f.types[l.hash] = obj.Type()
+		if l.typ != plumbing.AnyObject && l.typ != obj.Type() {
+			f.report(FsckIssue{
+				Kind:    FsckBadObject,
+				Hash:    l.hash,
+				Type:    obj.Type(),
+				Message: fmt.Sprintf("is a %s, not a %s (%s)", obj.Type(), l.typ, source),
+			})
+		}
+
+		links, _ := f.checkObject(l.hash, obj)
+		for _, link := range links {
+			pending = append(pending, link)
+			sources = append(sources, fmt.Sprintf("broken link from %s %s", obj.Type(), l.hash))
+		}
+	}
+}
+
+// readAllObjects checks the objects not reachable from the references, it
+// returns false if the objects of the storage couldn't be listed, so the
+// unreachable objects are unknown.
+func (f *fsck) readAllObjects() bool {
+	if los, ok := f.s.(storer.LooseObjectStorer); ok {
+		err := los.ForEachObjectHash(func(h plumbing.Hash) error {
+			if f.reachable[h] {
+				return nil
+			}
+
+			obj, err := f.s.EncodedObject(plumbing.AnyObject, h)
+			if err != nil {
+				f.reportCorrupt(h, plumbing.AnyObject, err)
+				f.unreachable[h] = nil
+				return nil
+			}
+
+			f.addUnreachable(h, obj)
+			return nil
+		})
+		if err != nil {
+			f.reportCorrupt(plumbing.ZeroHash, plumbing.AnyObject, err)
+			return false
+		}
+	}
+
+	iter, err := f.s.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		f.reportCorrupt(plumbing.ZeroHash, plumbing.AnyObject, err)
+		return false
+	}
+
+	defer iter.Close()
+
+	// the iterators of the storages skip the objects that can't be read
+	// after returning their error, the errors of the objects already
+	// reported as corrupt are ignored
+	errors := f.corrupt
+	for {
+		obj, err := iter.Next()
+		if err == io.EOF {
+			return true
+		}
+
+		if err != nil {
+			if errors == 0 {
+				f.reportCorrupt(plumbing.ZeroHash, plumbing.AnyObject, err)
+				return false
+			}
+
+			errors--
+			continue
+		}
+
+		h := obj.Hash()
+		if _, ok := f.unreachable[h]; ok || f.reachable[h] || f.mismatched[h] {
+			continue
+		}
+
+		f.addUnreachable(h, obj)
+	}
+}
+
+func (f *fsck) addUnreachable(h plumbing.Hash, obj plumbing.EncodedObject) {
+	links, ok := f.checkObject(h, obj)
+	if ok {
+		f.types[h] = obj.Type()
+	}
+
+	f.unreachable[h] = links
+}
+
+// checkUnreachable reports the dangling or unreachable objects, as git fsck
+// the objects referenced by unreachable objects are not required.
+func (f *fsck) checkUnreachable() {
+	referenced := make(map[plumbing.Hash]bool)
+	for _, links := range f.unreachable {
+		for _, l := range links {
+			referenced[l.hash] = true
+		}
+	}
+
+	for h := range f.unreachable {
+		t, ok := f.types[h]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case f.o.Unreachable:
+			f.report(FsckIssue{Kind: FsckUnreachableObject, Hash: h, Type: t})
+		case !f.o.NoDangling && !referenced[h]:
+			f.report(FsckIssue{Kind: FsckDanglingObject, Hash: h, Type: t})
+		}
+	}
+}
+
+func (f *fsck) report(i FsckIssue) {
+	f.issues = append(f.issues, i)
+}
+
+func (f *fsck) reportCorrupt(h plumbing.Hash, t plumbing.ObjectType, err error) {
+	f.corrupt++
+	f.report(FsckIssue{Kind: FsckCorruptObject, Hash: h, Type: t, Message: err.Error()})
+}
+
+// reportBad reports a problem of the content of an object by id, it returns
+// true if the problem is an error.
+func (f *fsck) reportBad(h plumbing.Hash, t plumbing.ObjectType, id string) bool {
+	m := fsckMessages[id]
+	warning := m.warning && !f.o.Strict
+	f.report(FsckIssue{
+		Kind:    FsckBadObject,
+		Hash:    h,
+		Type:    t,
+		ID:      id,
+		Message: m.message,
+		Warning: warning,
+	})
+
+	return !warning
+}
+
+// checkObject checks the hash and the content of an object, it returns the
+// objects referenced by it, and false if the object can't be read or its
+// hash doesn't match.
+func (f *fsck) checkObject(h plumbing.Hash, obj plumbing.EncodedObject) ([]fsckLink, bool) {
+	content, err := objectContent(obj)
+	if err != nil {
+		f.reportCorrupt(h, obj.Type(), err)
+		return nil, false
+	}
+
+	if computed := plumbing.ComputeHash(obj.Type(), content); computed != h {
+		f.mismatched[computed] = true
+		f.report(FsckIssue{Kind: FsckHashMismatch, Hash: h, Type: obj.Type()})
+		return nil, false
+	}
+
+	switch obj.Type() {
+	case plumbing.CommitObject:
+		return f.checkCommit(h, content), true
+	case plumbing.TreeObject:
+		return f.checkTree(h, content), true
+	case plumbing.TagObject:
+		return f.checkTag(h, content), true
+	default:
+		return nil, true
+	}
+}
+
+func (f *fsck) checkCommit(h plumbing.Hash, content []byte) (links []fsckLink) {
+	lines, ok := f.checkHeader(h, plumbing.CommitObject, content)
+	if !ok {
+		return nil
+	}
+
+	fail := func(id string) []fsckLink {
+		f.reportBad(h, plumbing.CommitObject, id)
+		return links
+	}
+
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "tree ") {
+		return fail("missingTree")
+	}
+
+	if !isFsckHash(lines[0][5:]) {
+		return fail("badTreeSha1")
+	}
+
+	links = append(links, fsckLink{plumbing.NewHash(lines[0][5:]), plumbing.TreeObject})
+	lines = lines[1:]
+	for len(lines) != 0 && strings.HasPrefix(lines[0], "parent ") {
+		if !isFsckHash(lines[0][7:]) {
+			return fail("badParentSha1")
+		}
+
+		links = append(links, fsckLink{plumbing.NewHash(lines[0][7:]), plumbing.CommitObject})
+		lines = lines[1:]
+	}
+
+	for _, field := range []struct{ prefix, missing string }{
+		{"author ", "missingAuthor"},
+		{"committer ", "missingCommitter"},
+	} {
+		if len(lines) == 0 || !strings.HasPrefix(lines[0], field.prefix) {
+			return fail(field.missing)
+		}
+
+		if id := checkIdent(lines[0][len(field.prefix):]); id != "" {
+			return fail(id)
+		}
+
+		lines = lines[1:]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "gpgsig ") {
+			continue
+		}
+
+		// the signature goes on in the continuation lines of the header
+		sig := []string{lines[i][7:]}
+		for ; i+1 < len(lines) && strings.HasPrefix(lines[i+1], " "); i++ {
+			sig = append(sig, lines[i+1][1:])
+		}
+
+		if !isValidSignature(sig) {
+			return fail("badSignature")
+		}
+	}
+
+	return links
+}
+
+func (f *fsck) checkTag(h plumbing.Hash, content []byte) (links []fsckLink) {
+	lines, ok := f.checkHeader(h, plumbing.TagObject, content)
+	if !ok {
+		return nil
+	}
+
+	fail := func(id string) []fsckLink {
+		f.reportBad(h, plumbing.TagObject, id)
+		return links
+	}
+
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "object ") {
+		return fail("missingObject")
+	}
+
+	if !isFsckHash(lines[0][7:]) {
+		return fail("badObjectSha1")
+	}
+
+	target := plumbing.NewHash(lines[0][7:])
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], "type ") {
+		return fail("missingTypeEntry")
+	}
+
+	t, err := plumbing.ParseObjectType(lines[1][5:])
+	if err != nil || t.IsDelta() {
+		return fail("badType")
+	}
+
+	links = append(links, fsckLink{target, t})
+	if len(lines) < 3 || !strings.HasPrefix(lines[2], "tag ") {
+		return fail("missingTagEntry")
+	}
+
+	if len(lines) < 4 || !strings.HasPrefix(lines[3], "tagger ") {
+		return fail("missingTaggerEntry")
+	}
+
+	if id := checkIdent(lines[3][7:]); id != "" {
+		return fail(id)
+	}
+
+	// the signature of a tag is at the end of its message
+	msg := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if begin, _ := object.SignatureDelimiters(content); begin != "" {
+		for i := len(lines) + 1; i < len(msg); i++ {
+			if msg[i] == begin {
+				if !isValidSignature(msg[i:]) {
+					return fail("badSignature")
+				}
+
+				break
+			}
+		}
+	}
+
+	return links
+}
+
+// isValidSignature returns whether the lines of a signature start and end
+// with the delimiters of a PGP or SSH signature.
+func isValidSignature(lines []string) bool {
+	begin, end := object.SignatureDelimiters([]byte(lines[0]))
+	return begin != "" && len(lines) > 1 &&
+		lines[0] == begin && lines[len(lines)-1] == end
+}
+
+// checkHeader returns the lines of the header of a commit or a tag, the
+// content before the first empty line.
+func (f *fsck) checkHeader(h plumbing.Hash, t plumbing.ObjectType, content []byte) ([]string, bool) {
+	header := content
+	if i := bytes.Index(content, []byte("\n\n")); i != -1 {
+		header = content[:i]
+	} else if len(content) != 0 && content[len(content)-1] == '\n' {
+		header = content[:len(content)-1]
+	} else {
+		f.reportBad(h, t, "unterminatedHeader")
+		return nil, false
+	}
+
+	if bytes.IndexByte(header, 0) != -1 {
+		f.reportBad(h, t, "nulInHeader")
+		return nil, false
+	}
+
+	if len(header) == 0 {
+		return nil, true
+	}
+
+	return strings.Split(string(header), "\n"), true
+}
+
+func (f *fsck) checkTree(h plumbing.Hash, content []byte) (links []fsckLink) {
+	found := make(map[string]bool)
+	var prev string
+	var prevDir bool
+	for first := true; len(content) != 0; first = false {
+		sp := bytes.IndexByte(content, ' ')
+		nul := bytes.IndexByte(content, 0)
+		if sp <= 0 || nul < sp || len(content) < nul+1+20 {
+			found["badTree"] = true
+			break
+		}
+
+		mode, err := strconv.ParseUint(string(content[:sp]), 8, 32)
+		if err != nil {
+			found["badTree"] = true
+			break
+		}
+
+		name := string(content[sp+1 : nul])
+		var hash plumbing.Hash
+		copy(hash[:], content[nul+1:nul+21])
+		if content[0] == '0' {
+			found["zeroPaddedFilemode"] = true
+		}
+
+		content = content[nul+21:]
+		fm := filemode.FileMode(mode)
+		switch fm {
+		case filemode.Regular, filemode.Executable, filemode.Symlink, filemode.Dir, filemode.Submodule:
+		case filemode.Deprecated:
+			found["badFilemode"] = found["badFilemode"] || f.o.Strict
+		default:
+			found["badFilemode"] = true
+		}
+
+		if hash == plumbing.ZeroHash {
+			found["nullSha1"] = true
+		}
+
+		switch {
+		case name == "":
+			found["emptyName"] = true
+		case name == ".":
+			found["hasDot"] = true
+		case name == "..":
+			found["hasDotdot"] = true
+		case isDotGitName(name):
+			found["hasDotgit"] = true
+		case strings.IndexByte(name, '/') != -1:
+			found["fullPathname"] = true
+		}
+
+		isDir := fm == filemode.Dir
+		if !first {
+			switch {
+			case prev == name:
+				found["duplicateEntries"] = true
+			case treeEntryKey(prev, prevDir) > treeEntryKey(name, isDir):
+				found["treeNotSorted"] = true
+			}
+		}
+
+		prev, prevDir = name, isDir
+		switch {
+		case isDir:
+			links = append(links, fsckLink{hash, plumbing.TreeObject})
+		case fm != filemode.Submodule:
+			links = append(links, fsckLink{hash, plumbing.BlobObject})
+		}
+	}
+
+	for _, id := range fsckTreeChecks {
+		if found[id] {
+			f.reportBad(h, plumbing.TreeObject, id)
+		}
+	}
+
+	return links
+}
+
+// treeEntryKey returns the key used to sort the entries of a tree, the
+// directories are sorted as if their names ended with a slash.
+func treeEntryKey(name string, isDir bool) string {
+	if isDir {
+		return name + "/"
+	}
+
+	return name
+}
+
+// isDotGitName returns true for the names that can be interpreted as .git
+// by case insensitive filesystems, or by NTFS with its short names and its
+// trailing dots and spaces.
+func isDotGitName(name string) bool {
+	name = strings.TrimRight(name, ". ")
+	return strings.EqualFold(name, ".git") || strings.EqualFold(name, "git~1")
+}
+
+func isFsckHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkIdent checks an author, committer or tagger line, it returns the id
+// of the problem found, if any.
+func checkIdent(ident string) string {
+	if strings.HasPrefix(ident, "<") {
+		return "missingNameBeforeEmail"
+	}
+
+	i := strings.IndexAny(ident, "<>")
+	switch {
+	case i == -1:
+		return "missingEmail"
+	case ident[i] == '>':
+		return "badName"
+	case ident[i-1] != ' ':
+		return "missingSpaceBeforeEmail"
+	}
+
+	ident = ident[i+1:]
+	i = strings.IndexAny(ident, "<>")
+	if i == -1 || ident[i] != '>' {
+		return "badEmail"
+	}
+
+	ident = ident[i+1:]
+	if !strings.HasPrefix(ident, " ") {
+		return "missingSpaceBeforeDate"
+	}
+
+	ident = ident[1:]
+	if strings.HasPrefix(ident, "0") && !strings.HasPrefix(ident, "0 ") {
+		return "zeroPaddedDate"
+	}
+
+	i = strings.IndexByte(ident, ' ')
+	if i <= 0 || strings.Trim(ident[:i], "0123456789") != "" {
+		return "badDate"
+	}
+
+	if _, err := strconv.ParseUint(ident[:i], 10, 64); err != nil {
+		return "badDateOverflow"
+	}
+
+	tz := ident[i+1:]
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') || strings.Trim(tz[1:], "0123456789") != "" {
+		return "badTimezone"
+	}
+
+	return ""
+}
+
+func objectContent(o plumbing.EncodedObject) (content []byte, err error) {
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return stdioutil.ReadAll(r)
+}
@@ -0,0 +1,213 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+func setRawObject(c *C, s storer.EncodedObjectStorer, t plumbing.ObjectType, content string) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	obj.SetType(t)
+	w, err := obj.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	h, err := s.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return h
+}
+
+func rawTreeEntry(mode, name string, h plumbing.Hash) string {
+	return fmt.Sprintf("%s %s\x00%s", mode, name, h[:])
+}
+
+func (s *RepositorySuite) TestFsck(c *C) {
+	w, _, _ := newPatchSeriesRepository(c)
+
+	result, err := w.r.Fsck(FsckOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Issues, HasLen, 0)
+	c.Assert(result.HasErrors(), Equals, false)
+}
+
+func (s *RepositorySuite) TestFsckDangling(c *C) {
+	w, _, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	blob := setRawObject(c, r.Storer, plumbing.BlobObject, "dangling\n")
+	tree := setRawObject(c, r.Storer, plumbing.TreeObject, rawTreeEntry("100644", "foo", blob))
+	commit := setRawObject(c, r.Storer, plumbing.CommitObject, fmt.Sprintf(
+		"tree %s\nparent %s\nauthor foo <foo@foo.foo> 1493849023 +0200\n"+
+			"committer foo <foo@foo.foo> 1493849023 +0200\n\nunreachable\n", tree, commits[1]))
+
+	result, err := r.Fsck(FsckOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Issues, DeepEquals, []FsckIssue{
+		{Kind: FsckDanglingObject, Hash: commit, Type: plumbing.CommitObject},
+	})
+	c.Assert(result.HasErrors(), Equals, false)
+	c.Assert(result.Issues[0].String(), Equals, fmt.Sprintf("dangling commit %s", commit))
+
+	result, err = r.Fsck(FsckOptions{Unreachable: true})
+	c.Assert(err, IsNil)
+	c.Assert(result.Issues, HasLen, 3)
+	for _, i := range result.Issues {
+		c.Assert(i.Kind, Equals, FsckUnreachableObject)
+	}
+
+	result, err = r.Fsck(FsckOptions{NoDangling: true})
+	c.Assert(err, IsNil)
+	c.Assert(result.Issues, HasLen, 0)
+}
+
+func (s *RepositorySuite) TestFsckMissing(c *C) {
+	w, _, _ := newPatchSeriesRepository(c)
+	r := w.r
+
+	missing := plumbing.NewHash("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	err := r.Storer.SetReference(plumbing.NewHashReference("refs/heads/broken", missing))
+	c.Assert(err, IsNil)
+
+	tree := setRawObject(c, r.Storer, plumbing.TreeObject, rawTreeEntry("100644", "foo", missing))
+
+	result, err := r.Fsck(FsckOptions{NoReflogs: true})
+	c.Assert(err, IsNil)
+	c.Assert(result.Issues, DeepEquals, []FsckIssue{{
+		Kind:    FsckMissingObject,
+		Hash:    missing,
+		Type:    plumbing.AnyObject,
+		Message: "refs/heads/broken: invalid sha1 pointer",
+	}, {
+		Kind: FsckDanglingObject,
+		Hash: tree,
+		Type: plumbing.TreeObject,
+	}})
+	c.Assert(result.HasErrors(), Equals, true)
+}
+
+func (s *RepositorySuite) TestFsckBadObjects(c *C) {
+	w, _, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	blob := setRawObject(c, r.Storer, plumbing.BlobObject, "baz\n")
+	tree := setRawObject(c, r.Storer, plumbing.TreeObject,
+		rawTreeEntry("100644", "foo", blob)+rawTreeEntry("100644", "foo", blob)+
+			rawTreeEntry("100755", ".GIT", blob)+rawTreeEntry("40000", "bar", blob))
+	commit := setRawObject(c, r.Storer, plumbing.CommitObject, fmt.Sprintf(
+		"tree %s\nparent %s\ncommitter foo <foo@foo.foo> 1493849023 +0200\n\nbad\n", tree, commits[1]))
+	tag := setRawObject(c, r.Storer, plumbing.TagObject, fmt.Sprintf(
+		"object %s\ntype commit\ntag v1.0.0\n\nno tagger\n", commits[1]))
+
+	for name, h := range map[string]plumbing.Hash{"bad": commit, "v1.0.0": tag} {
+		err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName("refs/tags/"+name), h))
+		c.Assert(err, IsNil)
+	}
+
+	result, err := r.Fsck(FsckOptions{})
+	c.Assert(err, IsNil)
+
+	var issues []string
+	for _, i := range result.Issues {
+		issues = append(issues, i.String())
+	}
+
+	// the issues are sorted by hash
+	c.Assert(issues, DeepEquals, []string{
+		fmt.Sprintf("error in commit %s: missingAuthor: invalid format - expected 'author' line", commit),
+		fmt.Sprintf("warning in tag %s: missingTaggerEntry: invalid format - expected 'tagger' line", tag),
+		fmt.Sprintf("error in blob %s: is a blob, not a tree (broken link from tree %s)", blob, tree),
+		fmt.Sprintf("error in tree %s: duplicateEntries: contains duplicate file entries", tree),
+		fmt.Sprintf("warning in tree %s: hasDotgit: contains '.git'", tree),
+		fmt.Sprintf("error in tree %s: treeNotSorted: not properly sorted", tree),
+	})
+
+	result, err = r.Fsck(FsckOptions{Strict: true})
+	c.Assert(err, IsNil)
+	for _, i := range result.Issues {
+		c.Assert(i.Warning, Equals, false)
+	}
+}
+
+func (s *RepositorySuite) TestFsckBadSignatures(c *C) {
+	w, _, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	parent, err := r.CommitObject(commits[1])
+	c.Assert(err, IsNil)
+
+	head := fmt.Sprintf("tree %s\nauthor foo <foo@foo.foo> 1493849023 +0200\n"+
+		"committer foo <foo@foo.foo> 1493849023 +0200\n", parent.TreeHash)
+	tagHead := fmt.Sprintf("object %s\ntype commit\ntag %%s\n"+
+		"tagger foo <foo@foo.foo> 1493849023 +0200\n\nfoo\n", commits[1])
+
+	objects := map[string]plumbing.Hash{
+		"signed": setRawObject(c, r.Storer, plumbing.CommitObject, head+
+			"gpgsig -----BEGIN PGP SIGNATURE-----\n \n foo\n -----END PGP SIGNATURE-----\n\nfoo\n"),
+		"no-end": setRawObject(c, r.Storer, plumbing.CommitObject, head+
+			"gpgsig -----BEGIN PGP SIGNATURE-----\n \n foo\n\nfoo\n"),
+		"bad-armor": setRawObject(c, r.Storer, plumbing.CommitObject, head+
+			"gpgsig -----BEGIN PGP SIGNATUR-----\n \n foo\n -----END PGP SIGNATURE-----\n\nfoo\n"),
+		"v1.0.0": setRawObject(c, r.Storer, plumbing.TagObject, fmt.Sprintf(tagHead, "v1.0.0")+
+			"-----BEGIN SSH SIGNATURE-----\nfoo\n-----END SSH SIGNATURE-----\n"),
+		"v2.0.0": setRawObject(c, r.Storer, plumbing.TagObject, fmt.Sprintf(tagHead, "v2.0.0")+
+			"-----BEGIN PGP SIGNATURE-----\n\nfoo\n"),
+	}
+
+	for name, h := range objects {
+		err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName("refs/tags/"+name), h))
+		c.Assert(err, IsNil)
+	}
+
+	result, err := r.Fsck(FsckOptions{})
+	c.Assert(err, IsNil)
+
+	issues := make(map[plumbing.Hash]string)
+	for _, i := range result.Issues {
+		issues[i.Hash] = i.String()
+	}
+
+	c.Assert(issues, DeepEquals, map[plumbing.Hash]string{
+		objects["no-end"]:    fmt.Sprintf("error in commit %s: badSignature: invalid signature - bad BEGIN/END delimiters", objects["no-end"]),
+		objects["bad-armor"]: fmt.Sprintf("error in commit %s: badSignature: invalid signature - bad BEGIN/END delimiters", objects["bad-armor"]),
+		objects["v2.0.0"]:    fmt.Sprintf("error in tag %s: badSignature: invalid signature - bad BEGIN/END delimiters", objects["v2.0.0"]),
+	})
+}
+
+func (s *RepositorySuite) TestFsckHashMismatch(c *C) {
+	fs := memfs.New()
+	st, err := filesystem.NewStorage(fs)
+	c.Assert(err, IsNil)
+
+	r, err := Init(st, nil)
+	c.Assert(err, IsNil)
+
+	foo := setRawObject(c, r.Storer, plumbing.BlobObject, "foo\n")
+	bar := setRawObject(c, r.Storer, plumbing.BlobObject, "bar\n")
+
+	// the file of foo is replaced by the one of bar
+	f, err := fs.Open(fs.Join("objects", bar.String()[:2], bar.String()[2:]))
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	err = util.WriteFile(fs, fs.Join("objects", foo.String()[:2], foo.String()[2:]), content, 0444)
+	c.Assert(err, IsNil)
+
+	result, err := r.Fsck(FsckOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Issues, DeepEquals, []FsckIssue{
+		{Kind: FsckHashMismatch, Hash: foo, Type: plumbing.BlobObject},
+		{Kind: FsckDanglingObject, Hash: bar, Type: plumbing.BlobObject},
+	})
+	c.Assert(result.HasErrors(), Equals, true)
+}
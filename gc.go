@@ -0,0 +1,483 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/packfile"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+)
+
+const (
+	gcSection        = "gc"
+	autoKey          = "auto"
+	autoPackLimitKey = "autopacklimit"
+	pruneExpireKey   = "pruneexpire"
+)
+
+// gcSettings are the settings of a garbage collection, from the options and
+// the configuration.
+type gcSettings struct {
+	auto          int
+	autoPackLimit int
+	prune         bool
+	// pruneExpire is the time before which the unreachable objects are
+	// removed, if zero every unreachable object is removed.
+	pruneExpire time.Time
+	packWindow  uint
+}
+
+// GC cleans up the repository, equivalent to `git gc`. The references are
+// packed, the old reflog entries are expired and every object reachable from
+// the references, the reflogs or the index, including the ones of the linked
+// worktrees, is written to a single new pack, replacing the old ones. The
+// packs marked with a .keep file, and their objects, are kept as they are.
+//
+// The unreachable objects of the replaced packs are kept as loose objects,
+// and the unreachable loose objects older than GCOptions.PruneExpire are
+// removed. The repack and the prune are skipped if the storer doesn't support
+// packs or loose objects.
+func (r *Repository) GC(o GCOptions) error {
+	s, err := r.gcSettings(o)
+	if err != nil {
+		return err
+	}
+
+	if o.Auto {
+		needed, err := r.needsGC(s)
+		if err != nil || !needed {
+			return err
+		}
+	}
+
+	if err := r.Storer.PackRefs(); err != nil {
+		return err
+	}
+
+	if err := r.ExpireReflog(&ExpireReflogOptions{}); err != nil && err != ErrReflogNotSupported {
+		return err
+	}
+
+	ow, err := r.gcReachableObjects()
+	if err != nil {
+		return err
+	}
+
+	if err := r.gcRepack(ow, s); err != nil {
+		return err
+	}
+
+	return r.gcPrune(ow, s)
+}
+
+func (r *Repository) gcSettings(o GCOptions) (*gcSettings, error) {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &gcSettings{
+		auto:          DefaultGCAuto,
+		autoPackLimit: DefaultGCAutoPackLimit,
+		prune:         !o.NoPrune,
+		pruneExpire:   o.PruneExpire,
+		packWindow:    cfg.Pack.Window,
+	}
+
+	opts := cfg.Raw.Section(gcSection).Options
+	for key, value := range map[string]*int{
+		autoKey:          &s.auto,
+		autoPackLimitKey: &s.autoPackLimit,
+	} {
+		if v := opts.Get(key); v != "" {
+			if *value, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid gc.%s: %q", key, v)
+			}
+		}
+	}
+
+	if !s.pruneExpire.IsZero() {
+		return s, nil
+	}
+
+	v := opts.Get(pruneExpireKey)
+	if v == "" {
+		s.pruneExpire = time.Now().Add(-DefaultGCPruneExpire)
+		return s, nil
+	}
+
+	never, expire, err := parseExpiry(v, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("invalid gc.%s: %q", pruneExpireKey, v)
+	}
+
+	s.prune = s.prune && !never
+	s.pruneExpire = expire
+	return s, nil
+}
+
+var relativeExpiryRegExp = regexp.MustCompile(`^(\d+)[. ](second|minute|hour|day|week|month|year)s?[. ]ago$`)
+
+var expiryUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// parseExpiry parses an expiry date of the configuration: "now", "never", a
+// relative date like "2.weeks.ago" or an absolute date. For "now" the
+// returned time is zero, everything has expired.
+func parseExpiry(v string, now time.Time) (never bool, t time.Time, err error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch v {
+	case "now", "all":
+		return false, time.Time{}, nil
+	case "never", "false":
+		return true, time.Time{}, nil
+	}
+
+	if m := relativeExpiryRegExp.FindStringSubmatch(v); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return false, t, err
+		}
+
+		return false, now.Add(-time.Duration(n) * expiryUnits[m[2]]), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err = time.ParseInLocation(layout, v, time.Local); err == nil {
+			return false, t, nil
+		}
+	}
+
+	return false, t, err
+}
+
+// needsGC returns true if there are too many loose objects or packs.
+func (r *Repository) needsGC(s *gcSettings) (bool, error) {
+	if s.auto <= 0 {
+		return false, nil
+	}
+
+	if los, ok := r.Storer.(storer.LooseObjectStorer); ok {
+		var loose int
+		err := los.ForEachObjectHash(func(plumbing.Hash) error {
+			if loose++; loose > s.auto {
+				return storer.ErrStop
+			}
+
+			return nil
+		})
+		if err != nil && err != storer.ErrStop {
+			return false, err
+		}
+
+		if loose > s.auto {
+			return true, nil
+		}
+	}
+
+	if s.autoPackLimit <= 0 {
+		return false, nil
+	}
+
+	packs, kept, err := r.objectPacks()
+	if err != nil {
+		return false, err
+	}
+
+	return len(packs)-len(kept) > s.autoPackLimit, nil
+}
+
+// objectPacks returns the packs of the storer and the ones marked to be kept.
+func (r *Repository) objectPacks() (packs []plumbing.Hash, kept map[plumbing.Hash]bool, err error) {
+	kept = make(map[plumbing.Hash]bool)
+	pos, ok := r.Storer.(storer.PackedObjectStorer)
+	if !ok {
+		return nil, kept, nil
+	}
+
+	if packs, err = pos.ObjectPacks(); err != nil {
+		return nil, nil, err
+	}
+
+	if kps, ok := r.Storer.(storer.KeptPackStorer); ok {
+		hashes, err := kps.KeptObjectPacks()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, h := range hashes {
+			kept[h] = true
+		}
+	}
+
+	return packs, kept, nil
+}
+
+// gcReachableObjects walks the objects reachable from the references, the
+// reflogs and the index, of the repository and of its linked worktrees.
+func (r *Repository) gcReachableObjects() (*objectWalker, error) {
+	ow := newObjectWalker(r.Storer)
+	if err := ow.walkAllRefs(); err != nil {
+		return nil, err
+	}
+
+	names, err := r.loggedReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := r.gcRoots(names)
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := r.linkedWorktreeRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lr := range linked {
+		lroots, err := lr.gcRoots([]plumbing.ReferenceName{plumbing.HEAD})
+		if err != nil {
+			return nil, err
+		}
+
+		roots = append(roots, lroots...)
+	}
+
+	for _, h := range roots {
+		if h.IsZero() || ow.isSeen(h) {
+			continue
+		}
+
+		// the reflogs and the index can reference removed objects
+		if exists, err := objectExists(r.Storer, h); err != nil || !exists {
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if err := ow.walkObjectTree(h); err != nil {
+			return nil, err
+		}
+	}
+
+	return ow, nil
+}
+
+// gcRoots returns the objects referenced by HEAD, by the reflogs of the
+// given references and by the index.
+func (r *Repository) gcRoots(names []plumbing.ReferenceName) ([]plumbing.Hash, error) {
+	var roots []plumbing.Hash
+	head, err := storer.ResolveReference(r.Storer, plumbing.HEAD)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	if err == nil {
+		roots = append(roots, head.Hash())
+	}
+
+	if _, ok := r.Storer.(storer.ReflogStorer); ok {
+		for _, name := range names {
+			entries, err := r.reflog(name)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, e := range entries {
+				roots = append(roots, e.Old, e.New)
+			}
+		}
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Mode != filemode.Submodule {
+			roots = append(roots, e.Hash)
+		}
+	}
+
+	if idx.Cache != nil {
+		for _, e := range idx.Cache.Entries {
+			if e.Entries >= 0 {
+				roots = append(roots, e.Hash)
+			}
+		}
+	}
+
+	return roots, nil
+}
+
+// gcRepack writes the reachable objects to a new pack, except the ones of
+// the kept packs, and removes the old packs and the packed loose objects.
+func (r *Repository) gcRepack(ow *objectWalker, s *gcSettings) error {
+	pos, ok := r.Storer.(storer.PackedObjectStorer)
+	if !ok {
+		return nil
+	}
+
+	pfw, ok := r.Storer.(storer.PackfileWriter)
+	if !ok {
+		return nil
+	}
+
+	packs, kept, err := r.objectPacks()
+	if err != nil {
+		return err
+	}
+
+	keptObjects := make(map[plumbing.Hash]bool)
+	kps, _ := r.Storer.(storer.KeptPackStorer)
+	for h := range kept {
+		hashes, err := kps.ObjectPackHashes(h)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range hashes {
+			keptObjects[h] = true
+		}
+	}
+
+	// the unreachable objects are lost if everything is going to be pruned
+	pruneAll := s.prune && s.pruneExpire.IsZero()
+	if kps != nil && !pruneAll {
+		if err := r.loosenUnreachableObjects(ow, kps, packs, kept, keptObjects); err != nil {
+			return err
+		}
+	}
+
+	var objs []plumbing.Hash
+	for h := range ow.seen {
+		if !keptObjects[h] {
+			objs = append(objs, h)
+		}
+	}
+
+	var newPack plumbing.Hash
+	if len(objs) != 0 {
+		if newPack, err = writeObjectPack(pfw, r.Storer, objs, s.packWindow); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range packs {
+		if kept[h] || h == newPack {
+			continue
+		}
+
+		if err := pos.DeleteOldObjectPackAndIndex(h, time.Time{}); err != nil {
+			return err
+		}
+	}
+
+	los, ok := r.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return nil
+	}
+
+	return los.ForEachObjectHash(func(h plumbing.Hash) error {
+		if ow.isSeen(h) || keptObjects[h] {
+			return los.DeleteLooseObject(h)
+		}
+
+		return nil
+	})
+}
+
+// loosenUnreachableObjects writes as loose objects the unreachable objects
+// of the packs that are going to be removed, so they are pruned when they
+// expire.
+func (r *Repository) loosenUnreachableObjects(ow *objectWalker, kps storer.KeptPackStorer,
+	packs []plumbing.Hash, kept, keptObjects map[plumbing.Hash]bool) error {
+	if _, ok := r.Storer.(storer.LooseObjectStorer); !ok {
+		return nil
+	}
+
+	for _, pack := range packs {
+		if kept[pack] {
+			continue
+		}
+
+		hashes, err := kps.ObjectPackHashes(pack)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range hashes {
+			if ow.isSeen(h) || keptObjects[h] {
+				continue
+			}
+
+			obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+			if err != nil {
+				return err
+			}
+
+			if _, err := r.Storer.SetEncodedObject(obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeObjectPack(pfw storer.PackfileWriter, s storer.EncodedObjectStorer,
+	objs []plumbing.Hash, packWindow uint) (h plumbing.Hash, err error) {
+	w, err := pfw.PackfileWriter()
+	if err != nil {
+		return h, err
+	}
+
+	defer ioutil.CheckClose(w, &err)
+	return packfile.NewEncoder(w, s, false).Encode(objs, packWindow)
+}
+
+// gcPrune removes the unreachable loose objects that have expired.
+func (r *Repository) gcPrune(ow *objectWalker, s *gcSettings) error {
+	if !s.prune {
+		return nil
+	}
+
+	los, ok := r.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return nil
+	}
+
+	return los.ForEachObjectHash(func(h plumbing.Hash) error {
+		if ow.isSeen(h) {
+			return nil
+		}
+
+		if !s.pruneExpire.IsZero() {
+			// errors are not fatal, the object may be deleted concurrently
+			t, err := los.LooseObjectTime(h)
+			if err != nil || !t.Before(s.pruneExpire) {
+				return nil
+			}
+		}
+
+		return los.DeleteLooseObject(h)
+	})
+}
@@ -0,0 +1,210 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-billy.v4/osfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+// newGCRepository returns a repository on the OS filesystem, in memfs the
+// mtimes aren't stable and the references can't be packed.
+func newGCRepository(c *C, pruneExpire string) (w *Worktree, dot billy.Filesystem, dir string) {
+	dir, err := ioutil.TempDir("", "gc")
+	c.Assert(err, IsNil)
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("gc").SetOption("pruneExpire", pruneExpire)
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	w, err = r.Worktree()
+	c.Assert(err, IsNil)
+
+	return w, osfs.New(filepath.Join(dir, ".git")), dir
+}
+
+func looseObjects(c *C, r *Repository) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	err := r.Storer.(*filesystem.Storage).ForEachObjectHash(func(h plumbing.Hash) error {
+		hashes = append(hashes, h)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return hashes
+}
+
+func objectPacks(c *C, r *Repository) []plumbing.Hash {
+	packs, err := r.Storer.(*filesystem.Storage).ObjectPacks()
+	c.Assert(err, IsNil)
+	return packs
+}
+
+func (s *RepositorySuite) TestGC(c *C) {
+	w, dot, dir := newGCRepository(c, "now")
+	defer os.RemoveAll(dir)
+	r := w.r
+
+	first := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	second := commitFiles(c, w, map[string]string{"bar": "bar\n"})
+	dangling := setRawObject(c, r.Storer, plumbing.BlobObject, "dangling\n")
+	c.Assert(looseObjects(c, r), HasLen, 7)
+
+	c.Assert(r.GC(GCOptions{}), IsNil)
+	c.Assert(looseObjects(c, r), HasLen, 0)
+	c.Assert(objectPacks(c, r), HasLen, 1)
+
+	_, err := dot.Stat("packed-refs")
+	c.Assert(err, IsNil)
+
+	for _, h := range []plumbing.Hash{first, second} {
+		_, err := r.CommitObject(h)
+		c.Assert(err, IsNil)
+	}
+
+	_, err = r.Storer.EncodedObject(plumbing.AnyObject, dangling)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	result, err := r.Fsck(FsckOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Issues, HasLen, 0)
+}
+
+func (s *RepositorySuite) TestGCReflogAndIndex(c *C) {
+	w, _, dir := newGCRepository(c, "now")
+	defer os.RemoveAll(dir)
+	r := w.r
+
+	first := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	second := commitFiles(c, w, map[string]string{"foo": "bar\n"})
+	c.Assert(w.Reset(&ResetOptions{Commit: first, Mode: HardReset}), IsNil)
+
+	staged := stageFile(c, w, "qux", "qux\n")
+
+	c.Assert(r.GC(GCOptions{}), IsNil)
+	c.Assert(looseObjects(c, r), HasLen, 0)
+
+	_, err := r.CommitObject(second)
+	c.Assert(err, IsNil)
+	_, err = r.BlobObject(staged)
+	c.Assert(err, IsNil)
+}
+
+func stageFile(c *C, w *Worktree, name, content string) plumbing.Hash {
+	err := util.WriteFile(w.Filesystem, name, []byte(content), 0644)
+	c.Assert(err, IsNil)
+
+	h, err := w.Add(name)
+	c.Assert(err, IsNil)
+	return h
+}
+
+func (s *RepositorySuite) TestGCKeepPack(c *C) {
+	w, dot, dir := newGCRepository(c, "now")
+	defer os.RemoveAll(dir)
+	r := w.r
+
+	commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	c.Assert(r.GC(GCOptions{}), IsNil)
+
+	packs := objectPacks(c, r)
+	c.Assert(packs, HasLen, 1)
+	kept := packs[0]
+	err := util.WriteFile(dot, dot.Join("objects", "pack", "pack-"+kept.String()+".keep"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	head := commitFiles(c, w, map[string]string{"bar": "bar\n"})
+	c.Assert(r.GC(GCOptions{}), IsNil)
+	c.Assert(looseObjects(c, r), HasLen, 0)
+
+	packs = objectPacks(c, r)
+	c.Assert(packs, HasLen, 2)
+	c.Assert(packs[0] == kept || packs[1] == kept, Equals, true)
+
+	// only the new commit, its tree and the bar blob are in the new pack
+	for _, h := range packs {
+		if h == kept {
+			continue
+		}
+
+		hashes, err := r.Storer.(*filesystem.Storage).ObjectPackHashes(h)
+		c.Assert(err, IsNil)
+		c.Assert(hashes, HasLen, 3)
+	}
+
+	_, err = r.CommitObject(head)
+	c.Assert(err, IsNil)
+}
+
+func (s *RepositorySuite) TestGCUnreachablePacked(c *C) {
+	w, _, dir := newGCRepository(c, "never")
+	defer os.RemoveAll(dir)
+	r := w.r
+
+	commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	dangling := setRawObject(c, r.Storer, plumbing.BlobObject, "dangling\n")
+	c.Assert(r.GC(GCOptions{}), IsNil)
+	c.Assert(looseObjects(c, r), DeepEquals, []plumbing.Hash{dangling})
+
+	// the unreachable objects are not written to the new pack
+	c.Assert(r.GC(GCOptions{}), IsNil)
+	c.Assert(looseObjects(c, r), DeepEquals, []plumbing.Hash{dangling})
+
+	c.Assert(r.GC(GCOptions{NoPrune: true}), IsNil)
+	c.Assert(looseObjects(c, r), DeepEquals, []plumbing.Hash{dangling})
+}
+
+func (s *RepositorySuite) TestGCAuto(c *C) {
+	w, _, dir := newGCRepository(c, "now")
+	defer os.RemoveAll(dir)
+	r := w.r
+
+	commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	c.Assert(r.GC(GCOptions{Auto: true}), IsNil)
+	c.Assert(looseObjects(c, r), HasLen, 3)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("gc").SetOption("auto", "2")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(r.GC(GCOptions{Auto: true}), IsNil)
+	c.Assert(looseObjects(c, r), HasLen, 0)
+	c.Assert(objectPacks(c, r), HasLen, 1)
+}
+
+func (s *RepositorySuite) TestGCInvalidConfig(c *C) {
+	w, _, dir := newGCRepository(c, "tomorrow")
+	defer os.RemoveAll(dir)
+	c.Assert(w.r.GC(GCOptions{}), ErrorMatches, `invalid gc.pruneexpire: "tomorrow"`)
+}
+
+func (s *RepositorySuite) TestParseExpiry(c *C) {
+	now := time.Date(2018, time.August, 11, 12, 0, 0, 0, time.UTC)
+	for _, t := range []struct {
+		value  string
+		never  bool
+		expire time.Time
+	}{
+		{"now", false, time.Time{}},
+		{"never", true, time.Time{}},
+		{"2.weeks.ago", false, now.Add(-14 * 24 * time.Hour)},
+		{"1 hour ago", false, now.Add(-time.Hour)},
+	} {
+		never, expire, err := parseExpiry(t.value, now)
+		c.Assert(err, IsNil)
+		c.Assert(never, Equals, t.never)
+		c.Assert(expire.Equal(t.expire), Equals, true, Commentf("%s", t.value))
+	}
+}
@@ -0,0 +1,644 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	stdioutil "io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem/dotgit"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-billy.v4/osfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+)
+
+var (
+	// ErrWorktreeExists is returned when adding a linked worktree in a non
+	// empty directory, or with the name of another one.
+	ErrWorktreeExists = errors.New("worktree already exists")
+	// ErrWorktreeNotFound is returned when the linked worktree doesn't exist.
+	ErrWorktreeNotFound = errors.New("worktree not found")
+	// ErrInvalidWorktreeName is returned when the name of a linked worktree
+	// isn't a valid directory name.
+	ErrInvalidWorktreeName = errors.New("invalid worktree name")
+	// ErrWorktreeLocked is returned when removing or locking a locked
+	// worktree.
+	ErrWorktreeLocked = errors.New("worktree is locked")
+	// ErrWorktreeNotLocked is returned when unlocking a worktree not locked.
+	ErrWorktreeNotLocked = errors.New("worktree is not locked")
+	// ErrBranchCheckedOut is returned when adding a linked worktree with a
+	// branch checked out by another worktree.
+	ErrBranchCheckedOut = errors.New("branch is already checked out")
+	// ErrLinkedWorktreesNotSupported is returned when the storage of the
+	// repository is not based on a filesystem.
+	ErrLinkedWorktreesNotSupported = errors.New("linked worktrees not supported by the storer")
+)
+
+const (
+	worktreesDir  = "worktrees"
+	commonDirFile = "commondir"
+	gitDirFile    = "gitdir"
+	lockedFile    = "locked"
+)
+
+// LinkedWorktree is a worktree linked to a repository, besides its main
+// worktree, like the ones added by `git worktree add`.
+type LinkedWorktree struct {
+	// Name of the worktree, its git directory is .git/worktrees/<name>.
+	Name string
+	// Path of the worktree.
+	Path string
+	// HEAD of the worktree, nil if it has no HEAD.
+	HEAD *plumbing.Reference
+	// Locked is true if the worktree is locked, with LockReason as the
+	// reason.
+	Locked     bool
+	LockReason string
+	// Prunable is true if the worktree no longer exists and it's not locked.
+	Prunable bool
+}
+
+// AddWorktree adds a linked worktree at the given path, equivalent to `git
+// worktree add`, and returns its repository. The linked worktrees share the
+// objects, the references and the configuration of the repository, but each
+// one has its own HEAD and index.
+//
+// The repository must be stored in the OS filesystem, like the ones opened by
+// PlainOpen, which also opens the linked worktrees.
+func (r *Repository) AddWorktree(path string, o *AddWorktreeOptions) (lr *Repository, err error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	common, err := r.commonDirFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+
+	created, err := checkWorktreePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := linkedWorktreeName(common, path, o.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	head, h, create, err := r.linkedWorktreeHEAD(common, name, o)
+	if err != nil {
+		return nil, err
+	}
+
+	adminDir := common.Join(worktreesDir, name)
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		util.RemoveAll(common, adminDir)
+		if created {
+			os.RemoveAll(path)
+		}
+	}()
+
+	admin, err := common.Chroot(adminDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := util.WriteFile(admin, gitDirFile, []byte(filepath.Join(path, GitDirName)+"\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	if err := util.WriteFile(admin, commonDirFile, []byte("../..\n"), 0644); err != nil {
+		return nil, err
+	}
+
+	wt := osfs.New(path)
+	if err := util.WriteFile(wt, GitDirName, []byte(fmt.Sprintf("gitdir: %s\n", admin.Root())), 0644); err != nil {
+		return nil, err
+	}
+
+	s, err := filesystem.NewStorage(dotgit.NewRepositoryFilesystem(admin, common))
+	if err != nil {
+		return nil, err
+	}
+
+	if create {
+		if err := s.SetReference(plumbing.NewHashReference(head.Target(), h)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.SetReference(head); err != nil {
+		return nil, err
+	}
+
+	if lr, err = Open(s, wt); err != nil {
+		return nil, err
+	}
+
+	if err := lr.checkoutLinkedWorktree(h); err != nil {
+		return nil, err
+	}
+
+	if o.Lock {
+		return lr, util.WriteFile(admin, lockedFile, []byte(o.LockReason), 0644)
+	}
+
+	return lr, nil
+}
+
+// checkWorktreePath checks that the path of a new worktree doesn't exist or
+// it's an empty directory, returning if it doesn't exist.
+func checkWorktreePath(path string) (notExists bool, err error) {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if !fi.IsDir() {
+		return false, ErrWorktreeExists
+	}
+
+	fis, err := stdioutil.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+
+	if len(fis) != 0 {
+		return false, ErrWorktreeExists
+	}
+
+	return false, nil
+}
+
+// linkedWorktreeName returns the given name of a new worktree, or if empty
+// the base name of its path followed by a number if it's already used.
+func linkedWorktreeName(common billy.Filesystem, path, name string) (string, error) {
+	if name != "" {
+		if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return "", ErrInvalidWorktreeName
+		}
+
+		_, err := common.Stat(common.Join(worktreesDir, name))
+		if err == nil {
+			return "", ErrWorktreeExists
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		return name, nil
+	}
+
+	base := filepath.Base(path)
+	for i := 0; ; i++ {
+		name = base
+		if i > 0 {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+
+		_, err := common.Stat(common.Join(worktreesDir, name))
+		if os.IsNotExist(err) {
+			return name, nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// linkedWorktreeHEAD returns the HEAD of a new worktree, the commit to be
+// checked out and if the branch of HEAD has to be created.
+func (r *Repository) linkedWorktreeHEAD(common billy.Filesystem, name string, o *AddWorktreeOptions) (
+	head *plumbing.Reference, h plumbing.Hash, create bool, err error) {
+
+	branch := o.Branch
+	h, create = o.Hash, o.Create
+	if branch == "" && h.IsZero() {
+		branch, create = plumbing.ReferenceName("refs/heads/"+name), true
+	}
+
+	switch {
+	case create:
+		if _, err = r.Storer.Reference(branch); err == nil {
+			return nil, h, false, ErrBranchExists
+		}
+
+		if err != plumbing.ErrReferenceNotFound {
+			return nil, h, false, err
+		}
+
+		if h.IsZero() {
+			ref, err := r.Head()
+			if err != nil {
+				return nil, h, false, err
+			}
+
+			h = ref.Hash()
+		}
+	case branch != "":
+		ref, err := r.Reference(branch, true)
+		if err != nil {
+			return nil, h, false, err
+		}
+
+		if !o.Force {
+			checkedOut, err := r.isCheckedOut(common, branch)
+			if err != nil {
+				return nil, h, false, err
+			}
+
+			if checkedOut {
+				return nil, h, false, ErrBranchCheckedOut
+			}
+		}
+
+		h = ref.Hash()
+	}
+
+	if _, err = r.CommitObject(h); err != nil {
+		return nil, h, false, err
+	}
+
+	if branch == "" {
+		return plumbing.NewHashReference(plumbing.HEAD, h), h, false, nil
+	}
+
+	return plumbing.NewSymbolicReference(plumbing.HEAD, branch), h, create, nil
+}
+
+// isCheckedOut returns true if the branch is the HEAD of the main worktree or
+// of a linked one.
+func (r *Repository) isCheckedOut(common billy.Filesystem, branch plumbing.ReferenceName) (bool, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return false, err
+	}
+
+	heads := []*plumbing.Reference{}
+	if !cfg.Core.IsBare {
+		head, err := dotgit.New(common).Ref(plumbing.HEAD)
+		if err != nil {
+			return false, err
+		}
+
+		heads = append(heads, head)
+	}
+
+	worktrees, err := r.LinkedWorktrees()
+	if err != nil {
+		return false, err
+	}
+
+	for _, w := range worktrees {
+		if w.HEAD != nil {
+			heads = append(heads, w.HEAD)
+		}
+	}
+
+	for _, head := range heads {
+		if head.Type() == plumbing.SymbolicReference && head.Target() == branch {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkoutLinkedWorktree fills the index and the files of a new worktree with
+// the tree of the given commit.
+func (r *Repository) checkoutLinkedWorktree(h plumbing.Hash) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	t, err := w.getTreeFromCommitHash(h)
+	if err != nil {
+		return err
+	}
+
+	if err := w.resetIndex(t); err != nil {
+		return err
+	}
+
+	return w.resetWorktree(t)
+}
+
+// LinkedWorktrees returns the worktrees linked to the repository, equivalent
+// to `git worktree list` without the main worktree.
+func (r *Repository) LinkedWorktrees() ([]*LinkedWorktree, error) {
+	common, err := r.commonDirFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := common.ReadDir(worktreesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var worktrees []*LinkedWorktree
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+
+		w, err := linkedWorktree(common, fi.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		worktrees = append(worktrees, w)
+	}
+
+	return worktrees, nil
+}
+
+func linkedWorktree(common billy.Filesystem, name string) (*LinkedWorktree, error) {
+	adminDir := common.Join(worktreesDir, name)
+	if _, err := common.Stat(adminDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrWorktreeNotFound
+		}
+
+		return nil, err
+	}
+
+	admin, err := common.Chroot(adminDir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &LinkedWorktree{Name: name}
+	gitDir, exists, err := readLinkedWorktreeFile(admin, gitDirFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		w.Path = filepath.Dir(gitDir)
+	}
+
+	if w.LockReason, w.Locked, err = readLinkedWorktreeFile(admin, lockedFile); err != nil {
+		return nil, err
+	}
+
+	w.HEAD, err = dotgit.New(admin).Ref(plumbing.HEAD)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	if w.Locked {
+		return w, nil
+	}
+
+	if !exists {
+		w.Prunable = true
+		return w, nil
+	}
+
+	if _, err := os.Stat(gitDir); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		w.Prunable = true
+	}
+
+	return w, nil
+}
+
+func readLinkedWorktreeFile(admin billy.Filesystem, name string) (content string, exists bool, err error) {
+	f, err := admin.Open(name)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	b, err := stdioutil.ReadAll(f)
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.TrimSpace(string(b)), true, nil
+}
+
+// RemoveWorktree removes a linked worktree, its files and its git directory,
+// equivalent to `git worktree remove`. The locked worktrees, and the ones with
+// changes or untracked files, are only removed with RemoveWorktreeOptions.Force.
+func (r *Repository) RemoveWorktree(name string, o *RemoveWorktreeOptions) error {
+	common, err := r.commonDirFilesystem()
+	if err != nil {
+		return err
+	}
+
+	w, err := linkedWorktree(common, name)
+	if err != nil {
+		return err
+	}
+
+	if w.Locked && !o.Force {
+		return ErrWorktreeLocked
+	}
+
+	if !w.Prunable && w.Path != "" {
+		if !o.Force {
+			if err := checkLinkedWorktreeClean(w.Path); err != nil {
+				return err
+			}
+		}
+
+		if err := os.RemoveAll(w.Path); err != nil {
+			return err
+		}
+	}
+
+	return removeLinkedWorktreeDir(common, name)
+}
+
+func checkLinkedWorktreeClean(path string) error {
+	lr, err := PlainOpen(path)
+	if err != nil {
+		return err
+	}
+
+	w, err := lr.Worktree()
+	if err != nil {
+		return err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	if !status.IsClean() {
+		return ErrWorktreeNotClean
+	}
+
+	return nil
+}
+
+// removeLinkedWorktreeDir removes the git directory of a linked worktree, and
+// the worktrees directory if it's the last one.
+func removeLinkedWorktreeDir(common billy.Filesystem, name string) error {
+	if err := util.RemoveAll(common, common.Join(worktreesDir, name)); err != nil {
+		return err
+	}
+
+	fis, err := common.ReadDir(worktreesDir)
+	if err != nil || len(fis) != 0 {
+		return err
+	}
+
+	return common.Remove(worktreesDir)
+}
+
+// PruneWorktrees removes the git directories of the linked worktrees that no
+// longer exist, except the locked ones, equivalent to `git worktree prune`.
+// The names of the pruned worktrees are returned.
+func (r *Repository) PruneWorktrees() ([]string, error) {
+	common, err := r.commonDirFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	worktrees, err := r.LinkedWorktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+	for _, w := range worktrees {
+		if !w.Prunable {
+			continue
+		}
+
+		if err := removeLinkedWorktreeDir(common, w.Name); err != nil {
+			return nil, err
+		}
+
+		pruned = append(pruned, w.Name)
+	}
+
+	return pruned, nil
+}
+
+// LockWorktree locks a linked worktree with the given reason, equivalent to
+// `git worktree lock`. A locked worktree isn't pruned, nor removed without
+// RemoveWorktreeOptions.Force, useful for worktrees in removable devices.
+func (r *Repository) LockWorktree(name, reason string) error {
+	common, err := r.commonDirFilesystem()
+	if err != nil {
+		return err
+	}
+
+	w, err := linkedWorktree(common, name)
+	if err != nil {
+		return err
+	}
+
+	if w.Locked {
+		return ErrWorktreeLocked
+	}
+
+	return util.WriteFile(common, common.Join(worktreesDir, name, lockedFile), []byte(reason), 0644)
+}
+
+// UnlockWorktree unlocks a linked worktree, equivalent to `git worktree
+// unlock`.
+func (r *Repository) UnlockWorktree(name string) error {
+	common, err := r.commonDirFilesystem()
+	if err != nil {
+		return err
+	}
+
+	w, err := linkedWorktree(common, name)
+	if err != nil {
+		return err
+	}
+
+	if !w.Locked {
+		return ErrWorktreeNotLocked
+	}
+
+	return common.Remove(common.Join(worktreesDir, name, lockedFile))
+}
+
+// commonDirFilesystem returns the filesystem of the git directory shared by
+// the main worktree and the linked ones.
+func (r *Repository) commonDirFilesystem() (billy.Filesystem, error) {
+	fs := r.dotGitFilesystem()
+	if fs == nil {
+		return nil, ErrLinkedWorktreesNotSupported
+	}
+
+	if rfs, ok := fs.(*dotgit.RepositoryFilesystem); ok {
+		return rfs.CommonDir(), nil
+	}
+
+	return fs, nil
+}
+
+// linkedWorktreeRepositories returns a repository, without worktree, for
+// each linked worktree, used to access their HEAD, index and reflogs.
+func (r *Repository) linkedWorktreeRepositories() ([]*Repository, error) {
+	common, err := r.commonDirFilesystem()
+	if err == ErrLinkedWorktreesNotSupported {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	worktrees, err := r.LinkedWorktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []*Repository
+	for _, w := range worktrees {
+		admin, err := common.Chroot(common.Join(worktreesDir, w.Name))
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := filesystem.NewStorage(dotgit.NewRepositoryFilesystem(admin, common))
+		if err != nil {
+			return nil, err
+		}
+
+		repos = append(repos, newRepository(s, nil))
+	}
+
+	return repos, nil
+}
@@ -0,0 +1,204 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+func newLinkedWorktreeRepository(c *C) (r *Repository, head plumbing.Hash, dir string) {
+	dir, err := ioutil.TempDir("", "linked-worktree")
+	c.Assert(err, IsNil)
+
+	r, err = PlainInit(filepath.Join(dir, "main"), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	return r, commitFiles(c, w, map[string]string{"foo": "foo\n"}), dir
+}
+
+func (s *RepositorySuite) TestAddWorktree(c *C) {
+	r, head, dir := newLinkedWorktreeRepository(c)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "feature")
+	lr, err := r.AddWorktree(path, &AddWorktreeOptions{})
+	c.Assert(err, IsNil)
+
+	ref, err := lr.Head()
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.ReferenceName("refs/heads/feature"))
+	c.Assert(ref.Hash(), Equals, head)
+
+	lw, err := lr.Worktree()
+	c.Assert(err, IsNil)
+	assertFileContent(c, lw, "foo", "foo\n")
+
+	status, err := lw.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	// the commits of the linked worktree are shared, but not its HEAD
+	lr, err = PlainOpen(path)
+	c.Assert(err, IsNil)
+	lw, err = lr.Worktree()
+	c.Assert(err, IsNil)
+	commit := commitFiles(c, lw, map[string]string{"bar": "bar\n"})
+
+	ref, err = r.Reference("refs/heads/feature", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, commit)
+
+	ref, err = r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.Master)
+	c.Assert(ref.Hash(), Equals, head)
+
+	worktrees, err := r.LinkedWorktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, DeepEquals, []*LinkedWorktree{{
+		Name: "feature",
+		Path: path,
+		HEAD: plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/feature"),
+	}})
+}
+
+func (s *RepositorySuite) TestAddWorktreeBranch(c *C) {
+	r, head, dir := newLinkedWorktreeRepository(c)
+	defer os.RemoveAll(dir)
+
+	_, err := r.AddWorktree(filepath.Join(dir, "master"), &AddWorktreeOptions{Branch: plumbing.Master})
+	c.Assert(err, Equals, ErrBranchCheckedOut)
+
+	_, err = r.AddWorktree(filepath.Join(dir, "main"), &AddWorktreeOptions{Hash: head})
+	c.Assert(err, Equals, ErrWorktreeExists)
+
+	lr, err := r.AddWorktree(filepath.Join(dir, "foo"), &AddWorktreeOptions{
+		Name:   "bar",
+		Branch: "refs/heads/qux",
+		Create: true,
+	})
+	c.Assert(err, IsNil)
+
+	ref, err := lr.Head()
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.ReferenceName("refs/heads/qux"))
+
+	_, err = r.AddWorktree(filepath.Join(dir, "qux"), &AddWorktreeOptions{Branch: "refs/heads/qux"})
+	c.Assert(err, Equals, ErrBranchCheckedOut)
+
+	_, err = r.AddWorktree(filepath.Join(dir, "qux"), &AddWorktreeOptions{Branch: "refs/heads/qux", Force: true})
+	c.Assert(err, IsNil)
+
+	lr, err = r.AddWorktree(filepath.Join(dir, "detached"), &AddWorktreeOptions{Hash: head})
+	c.Assert(err, IsNil)
+
+	ref, err = lr.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Type(), Equals, plumbing.HashReference)
+	c.Assert(ref.Hash(), Equals, head)
+
+	worktrees, err := r.LinkedWorktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 3)
+	c.Assert(worktrees[0].Name, Equals, "bar")
+	c.Assert(worktrees[0].Path, Equals, filepath.Join(dir, "foo"))
+}
+
+func (s *RepositorySuite) TestRemoveWorktree(c *C) {
+	r, _, dir := newLinkedWorktreeRepository(c)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "feature")
+	lr, err := r.AddWorktree(path, &AddWorktreeOptions{Lock: true, LockReason: "usb"})
+	c.Assert(err, IsNil)
+
+	err = r.RemoveWorktree("feature", &RemoveWorktreeOptions{})
+	c.Assert(err, Equals, ErrWorktreeLocked)
+	c.Assert(r.LockWorktree("feature", ""), Equals, ErrWorktreeLocked)
+	c.Assert(r.UnlockWorktree("feature"), IsNil)
+	c.Assert(r.UnlockWorktree("feature"), Equals, ErrWorktreeNotLocked)
+
+	lw, err := lr.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(util.WriteFile(lw.Filesystem, "foo", []byte("bar\n"), 0644), IsNil)
+
+	err = r.RemoveWorktree("feature", &RemoveWorktreeOptions{})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+
+	err = r.RemoveWorktree("feature", &RemoveWorktreeOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	_, err = os.Stat(path)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	worktrees, err := r.LinkedWorktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 0)
+
+	err = r.RemoveWorktree("feature", &RemoveWorktreeOptions{})
+	c.Assert(err, Equals, ErrWorktreeNotFound)
+}
+
+func (s *RepositorySuite) TestPruneWorktrees(c *C) {
+	r, _, dir := newLinkedWorktreeRepository(c)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"foo", "bar", "qux"} {
+		_, err := r.AddWorktree(filepath.Join(dir, name), &AddWorktreeOptions{})
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(r.LockWorktree("bar", "usb"), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(dir, "foo")), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(dir, "bar")), IsNil)
+
+	worktrees, err := r.LinkedWorktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 3)
+	c.Assert(worktrees[0].Locked, Equals, true)
+	c.Assert(worktrees[0].LockReason, Equals, "usb")
+	c.Assert(worktrees[0].Prunable, Equals, false)
+	c.Assert(worktrees[1].Prunable, Equals, true)
+	c.Assert(worktrees[2].Prunable, Equals, false)
+
+	pruned, err := r.PruneWorktrees()
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []string{"foo"})
+
+	worktrees, err = r.LinkedWorktrees()
+	c.Assert(err, IsNil)
+	c.Assert(worktrees, HasLen, 2)
+}
+
+func (s *RepositorySuite) TestGCLinkedWorktree(c *C) {
+	r, head, dir := newLinkedWorktreeRepository(c)
+	defer os.RemoveAll(dir)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("gc").SetOption("pruneExpire", "now")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	lr, err := r.AddWorktree(filepath.Join(dir, "detached"), &AddWorktreeOptions{Hash: head})
+	c.Assert(err, IsNil)
+
+	lw, err := lr.Worktree()
+	c.Assert(err, IsNil)
+	commit := commitFiles(c, lw, map[string]string{"bar": "bar\n"})
+	staged := stageFile(c, lw, "qux", "qux\n")
+
+	c.Assert(r.GC(GCOptions{}), IsNil)
+
+	_, err = r.CommitObject(commit)
+	c.Assert(err, IsNil)
+	_, err = r.BlobObject(staged)
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,631 @@
+package git
+
+import (
+	"errors"
+	"io"
+	stdioutil "io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+)
+
+var (
+	// ErrNoteNotFound is returned when an object has no note.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrNoteExists is returned when adding a note to an object that already
+	// has one, without forcing it.
+	ErrNoteExists = errors.New("note already exists")
+	// ErrNotesMergeConflict is returned when merging notes references with
+	// ManualNotesMerge and the same note was changed on both sides.
+	ErrNotesMergeConflict = errors.New("conflicting notes")
+	// ErrInvalidNotesMergeStrategy is returned when an unknown notes merge
+	// strategy is requested.
+	ErrInvalidNotesMergeStrategy = errors.New("invalid notes merge strategy")
+)
+
+// notesFanoutLimit is the number of notes that adds a new level of fan-out
+// directories to a notes tree.
+const notesFanoutLimit = 256
+
+// Note is the note attached to an object.
+type Note struct {
+	// Object is the hash of the annotated object.
+	Object plumbing.Hash
+	// Hash is the hash of the blob holding the note.
+	Hash plumbing.Hash
+	// Content is the content of the note.
+	Content string
+}
+
+// Note returns the note of the given object, if the object has no note
+// ErrNoteNotFound is returned.
+func (r *Repository) Note(h plumbing.Hash, o *NotesOptions) (*Note, error) {
+	if err := o.Validate(r); err != nil {
+		return nil, err
+	}
+
+	commit, err := r.notesCommit(o.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if commit == nil {
+		return nil, ErrNoteNotFound
+	}
+
+	t, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := findNote(t, h.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return r.note(h, blob)
+}
+
+// findNote looks for the note of the given hex name in the tree, descending
+// through the fan-out directories named after the leading bytes of the name.
+func findNote(t *object.Tree, name string) (plumbing.Hash, error) {
+	for {
+		var dir *object.TreeEntry
+		for i, e := range t.Entries {
+			if e.Name == name && e.Mode != filemode.Dir {
+				return e.Hash, nil
+			}
+
+			if len(name) > 2 && e.Name == name[:2] && e.Mode == filemode.Dir {
+				dir = &t.Entries[i]
+			}
+		}
+
+		if dir == nil {
+			return plumbing.ZeroHash, ErrNoteNotFound
+		}
+
+		var err error
+		if t, err = t.Tree(dir.Name); err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		name = name[2:]
+	}
+}
+
+// ListNotes returns all the notes of the notes reference, sorted by the hash
+// of the annotated objects.
+func (r *Repository) ListNotes(o *NotesOptions) ([]*Note, error) {
+	if err := o.Validate(r); err != nil {
+		return nil, err
+	}
+
+	commit, err := r.notesCommit(o.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	nt, err := r.readNotesTree(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*Note
+	for _, h := range nt.objects() {
+		n, err := r.note(h, nt.notes[h])
+		if err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, n)
+	}
+
+	return notes, nil
+}
+
+// AddNote adds a note with the given content to the object, committing it to
+// the notes reference. An empty note removes the note of the object, as git
+// does. The hash of the new notes commit is returned.
+func (r *Repository) AddNote(h plumbing.Hash, content string, o *AddNoteOptions) (plumbing.Hash, error) {
+	if err := o.Validate(r); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit, err := r.notesCommit(o.Ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	nt, err := r.readNotesTree(commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	content = noteContent(content)
+	if blob, ok := nt.notes[h]; ok {
+		switch {
+		case o.Append:
+			current, err := r.note(h, blob)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+
+			content = concatNotes(current.Content, content)
+		case !o.Force:
+			return plumbing.ZeroHash, ErrNoteExists
+		}
+	}
+
+	if err := r.setNote(nt, h, content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.commitNotes(o.Ref, commit, nt, o.Message, o.Author, o.Committer)
+}
+
+// RemoveNote removes the note of the object, committing it to the notes
+// reference. If the object has no note ErrNoteNotFound is returned. The hash
+// of the new notes commit is returned.
+func (r *Repository) RemoveNote(h plumbing.Hash, o *RemoveNoteOptions) (plumbing.Hash, error) {
+	if err := o.Validate(r); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit, err := r.notesCommit(o.Ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	nt, err := r.readNotesTree(commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, ok := nt.notes[h]; !ok {
+		return plumbing.ZeroHash, ErrNoteNotFound
+	}
+
+	delete(nt.notes, h)
+	return r.commitNotes(o.Ref, commit, nt, o.Message, o.Author, o.Committer)
+}
+
+// MergeNotes merges the notes of the given notes reference into the notes
+// reference of the options. The reference is fast-forwarded when possible,
+// otherwise the notes are merged object by object against the merge base and
+// the conflicting notes are resolved with the strategy of the options. The
+// hash of the resulting notes commit is returned.
+func (r *Repository) MergeNotes(ref plumbing.ReferenceName, o *MergeNotesOptions) (plumbing.Hash, error) {
+	if err := o.Validate(r); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref, err := r.notesRef(ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	theirs, err := r.notesCommit(ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if theirs == nil {
+		return plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+	}
+
+	ours, err := r.notesCommit(o.Ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	msg := o.Message
+	if msg == "" {
+		msg = "Merged notes from " + ref.String() + " into " + o.Ref.String()
+	}
+
+	if ours == nil {
+		return theirs.Hash, r.updateNotesRef(o.Ref, nil, theirs.Hash, msg, o.Committer)
+	}
+
+	if ours.Hash == theirs.Hash {
+		return ours.Hash, nil
+	}
+
+	if ok, err := ours.IsAncestor(theirs); err != nil {
+		return plumbing.ZeroHash, err
+	} else if ok {
+		return theirs.Hash, r.updateNotesRef(o.Ref, ours, theirs.Hash, msg, o.Committer)
+	}
+
+	if ok, err := theirs.IsAncestor(ours); err != nil || ok {
+		return ours.Hash, err
+	}
+
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var base *object.Commit
+	if len(bases) != 0 {
+		base = bases[0]
+	}
+
+	nt, err := r.mergeNotesTrees(base, ours, theirs, o.Strategy)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tree, err := r.writeNotesTree(nt)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := r.notesCommitObject(tree, msg, o.Author, o.Committer, ours.Hash, theirs.Hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h, r.updateNotesRef(o.Ref, ours, h, msg, o.Committer)
+}
+
+func (r *Repository) mergeNotesTrees(base, ours, theirs *object.Commit,
+	strategy NotesMergeStrategy) (*notesTree, error) {
+
+	var trees [3]*notesTree
+	for i, c := range []*object.Commit{base, ours, theirs} {
+		var err error
+		if trees[i], err = r.readNotesTree(c); err != nil {
+			return nil, err
+		}
+	}
+
+	b, nt, t := trees[0], trees[1], trees[2]
+	objects := map[plumbing.Hash]bool{}
+	for _, tree := range trees {
+		for h := range tree.notes {
+			objects[h] = true
+		}
+	}
+
+	for h := range objects {
+		ob, ok := nt.notes[h]
+		tb, tok := t.notes[h]
+		bb, bok := b.notes[h]
+		if ok == tok && ob == tb || tok == bok && tb == bb {
+			continue
+		}
+
+		if ok == bok && ob == bb {
+			if tok {
+				nt.notes[h] = tb
+			} else {
+				delete(nt.notes, h)
+			}
+
+			continue
+		}
+
+		switch strategy {
+		case ManualNotesMerge:
+			return nil, ErrNotesMergeConflict
+		case OursNotesMerge:
+			continue
+		case TheirsNotesMerge:
+			if tok {
+				nt.notes[h] = tb
+			} else {
+				delete(nt.notes, h)
+			}
+
+			continue
+		}
+
+		var contents [2]string
+		for i, blob := range []plumbing.Hash{ob, tb} {
+			if blob.IsZero() {
+				continue
+			}
+
+			n, err := r.note(h, blob)
+			if err != nil {
+				return nil, err
+			}
+
+			contents[i] = n.Content
+		}
+
+		content := concatNotes(contents[0], contents[1])
+		if strategy == CatSortUniqNotesMerge {
+			content = catSortUniqNotes(contents[0], contents[1])
+		}
+
+		if err := r.setNote(nt, h, content); err != nil {
+			return nil, err
+		}
+	}
+
+	return nt, nil
+}
+
+// notesRef expands the name of a notes reference as git does, a name without
+// the refs/notes/ or notes/ prefixes is considered relative to refs/notes/.
+// An empty name is the configured core.notesRef or DefaultNotesRef.
+func (r *Repository) notesRef(name plumbing.ReferenceName) (plumbing.ReferenceName, error) {
+	if name == "" {
+		cfg, err := r.Storer.Config()
+		if err != nil {
+			return "", err
+		}
+
+		name = plumbing.ReferenceName(cfg.Raw.Section("core").Option("notesRef"))
+		if name == "" {
+			return DefaultNotesRef, nil
+		}
+	}
+
+	switch s := name.String(); {
+	case name.IsNote():
+		return name, nil
+	case strings.HasPrefix(s, "notes/"):
+		return plumbing.ReferenceName("refs/" + s), nil
+	default:
+		return plumbing.ReferenceName("refs/notes/" + s), nil
+	}
+}
+
+// notesCommit returns the commit of the notes reference, or nil if the
+// reference doesn't exist yet.
+func (r *Repository) notesCommit(name plumbing.ReferenceName) (*object.Commit, error) {
+	ref, err := r.Storer.Reference(name)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CommitObject(ref.Hash())
+}
+
+func (r *Repository) note(h, blob plumbing.Hash) (n *Note, err error) {
+	b, err := r.BlobObject(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(rd, &err)
+
+	content, err := stdioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Note{Object: h, Hash: blob, Content: string(content)}, nil
+}
+
+// notesTree is the content of a notes tree, the notes by annotated object and
+// the files of the tree that aren't notes, kept as they are.
+type notesTree struct {
+	notes map[plumbing.Hash]plumbing.Hash
+	files []*index.Entry
+}
+
+func (r *Repository) readNotesTree(commit *object.Commit) (*notesTree, error) {
+	nt := &notesTree{notes: map[plumbing.Hash]plumbing.Hash{}}
+	if commit == nil {
+		return nt, nil
+	}
+
+	t, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	w := object.NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	for {
+		name, e, err := w.Next()
+		if err == io.EOF {
+			return nt, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Mode == filemode.Dir {
+			continue
+		}
+
+		hex := strings.Replace(name, "/", "", -1)
+		if h := plumbing.NewHash(hex); e.Mode != filemode.Submodule && h.String() == hex {
+			nt.notes[h] = e.Hash
+			continue
+		}
+
+		nt.files = append(nt.files, &index.Entry{Name: name, Mode: e.Mode, Hash: e.Hash})
+	}
+}
+
+// objects returns the annotated objects of the tree, sorted by hash.
+func (nt *notesTree) objects() []plumbing.Hash {
+	objects := make([]plumbing.Hash, 0, len(nt.notes))
+	for h := range nt.notes {
+		objects = append(objects, h)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].String() < objects[j].String()
+	})
+
+	return objects
+}
+
+// setNote stores the content as the note of the object, an empty content
+// removes the note.
+func (r *Repository) setNote(nt *notesTree, h plumbing.Hash, content string) error {
+	if content == "" {
+		delete(nt.notes, h)
+		return nil
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, content); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	blob, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	nt.notes[h] = blob
+	return nil
+}
+
+// writeNotesTree stores the tree of the notes, the notes are split in fan-out
+// directories of two hex digits, one level for each notesFanoutLimit notes.
+func (r *Repository) writeNotesTree(nt *notesTree) (plumbing.Hash, error) {
+	fanout := 0
+	for n := len(nt.notes); n >= notesFanoutLimit; n /= notesFanoutLimit {
+		fanout++
+	}
+
+	idx := &index.Index{Entries: nt.files}
+	for h, blob := range nt.notes {
+		hex := h.String()
+		var name string
+		for i := 0; i < fanout; i++ {
+			name += hex[:2] + "/"
+			hex = hex[2:]
+		}
+
+		idx.Entries = append(idx.Entries, &index.Entry{
+			Name: name + hex,
+			Mode: filemode.Regular,
+			Hash: blob,
+		})
+	}
+
+	h := &buildTreeHelper{s: r.Storer}
+	return h.BuildTree(idx)
+}
+
+// commitNotes commits the notes tree to the notes reference, on top of its
+// current commit.
+func (r *Repository) commitNotes(name plumbing.ReferenceName, parent *object.Commit,
+	nt *notesTree, msg string, author, committer *object.Signature) (plumbing.Hash, error) {
+
+	tree, err := r.writeNotesTree(nt)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var parents []plumbing.Hash
+	if parent != nil {
+		parents = append(parents, parent.Hash)
+	}
+
+	h, err := r.notesCommitObject(tree, msg, author, committer, parents...)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h, r.updateNotesRef(name, parent, h, msg, committer)
+}
+
+func (r *Repository) notesCommitObject(tree plumbing.Hash, msg string,
+	author, committer *object.Signature, parents ...plumbing.Hash) (plumbing.Hash, error) {
+
+	commit := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      noteContent(msg),
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.Storer.SetEncodedObject(obj)
+}
+
+func (r *Repository) updateNotesRef(name plumbing.ReferenceName, old *object.Commit,
+	h plumbing.Hash, msg string, sig *object.Signature) error {
+
+	var oldRef *plumbing.Reference
+	if old != nil {
+		oldRef = plumbing.NewHashReference(name, old.Hash)
+	}
+
+	ref := plumbing.NewHashReference(name, h)
+	return setReferenceWithLog(r.Storer, ref, oldRef, "notes: "+msg, sig)
+}
+
+// noteContent terminates a non empty content with a new line.
+func noteContent(content string) string {
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	return content
+}
+
+// concatNotes joins two notes with a blank line, as `git notes append` and
+// the union merge strategy do.
+func concatNotes(a, b string) string {
+	if a == "" {
+		return b
+	}
+
+	if b == "" {
+		return a
+	}
+
+	return strings.TrimSuffix(a, "\n") + "\n\n" + b
+}
+
+// catSortUniqNotes joins the lines of two notes, sorted and without empty or
+// duplicated lines.
+func catSortUniqNotes(a, b string) string {
+	lines := strings.Split(a+"\n"+b, "\n")
+	sort.Strings(lines)
+
+	var content string
+	for i, l := range lines {
+		if l == "" || i > 0 && l == lines[i-1] {
+			continue
+		}
+
+		content += l + "\n"
+	}
+
+	return content
+}
@@ -0,0 +1,189 @@
+package git
+
+import (
+	"fmt"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RepositorySuite) TestAddNote(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	_, err := r.Note(base, &NotesOptions{})
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	_, err = r.AddNote(base, "foo", &AddNoteOptions{})
+	c.Assert(err, Equals, ErrMissingAuthor)
+
+	h, err := r.AddNote(base, "foo", &AddNoteOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Notes added by 'git notes add'\n")
+
+	n, err := r.Note(base, &NotesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(n.Object, Equals, base)
+	c.Assert(n.Content, Equals, "foo\n")
+
+	_, err = r.AddNote(base, "bar", &AddNoteOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrNoteExists)
+
+	_, err = r.AddNote(base, "bar", &AddNoteOptions{Author: defaultSignature(), Append: true})
+	c.Assert(err, IsNil)
+	n, err = r.Note(base, &NotesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(n.Content, Equals, "foo\n\nbar\n")
+
+	_, err = r.AddNote(base, "qux\n", &AddNoteOptions{Author: defaultSignature(), Force: true})
+	c.Assert(err, IsNil)
+	_, err = r.AddNote(commits[0], "baz\n", &AddNoteOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	notes, err := r.ListNotes(&NotesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(notes, HasLen, 2)
+	contents := map[plumbing.Hash]string{}
+	for _, n := range notes {
+		contents[n.Object] = n.Content
+	}
+	c.Assert(contents, DeepEquals, map[plumbing.Hash]string{base: "qux\n", commits[0]: "baz\n"})
+
+	h, err = r.RemoveNote(base, &RemoveNoteOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	commit, err = r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 1)
+
+	_, err = r.Note(base, &NotesOptions{})
+	c.Assert(err, Equals, ErrNoteNotFound)
+	_, err = r.RemoveNote(base, &RemoveNoteOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrNoteNotFound)
+}
+
+func (s *RepositorySuite) TestNotesRef(c *C) {
+	w, base, _ := newPatchSeriesRepository(c)
+	r := w.r
+
+	_, err := r.AddNote(base, "foo\n", &AddNoteOptions{Ref: "ci", Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	_, err = r.Reference("refs/notes/ci", false)
+	c.Assert(err, IsNil)
+
+	for _, ref := range []plumbing.ReferenceName{"ci", "notes/ci", "refs/notes/ci"} {
+		n, err := r.Note(base, &NotesOptions{Ref: ref})
+		c.Assert(err, IsNil)
+		c.Assert(n.Content, Equals, "foo\n")
+	}
+
+	_, err = r.Note(base, &NotesOptions{})
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption("notesRef", "refs/notes/ci")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = r.Note(base, &NotesOptions{})
+	c.Assert(err, IsNil)
+}
+
+func (s *RepositorySuite) TestNotesFanout(c *C) {
+	w, base, _ := newPatchSeriesRepository(c)
+	r := w.r
+
+	for i := 0; i < notesFanoutLimit-1; i++ {
+		h := plumbing.ComputeHash(plumbing.BlobObject, []byte(fmt.Sprint(i)))
+		_, err := r.AddNote(h, fmt.Sprint(i), &AddNoteOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+	}
+
+	h, err := r.AddNote(base, "foo\n", &AddNoteOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries[0].Mode, Equals, filemode.Dir)
+	c.Assert(tree.Entries[0].Name, HasLen, 2)
+
+	_, err = tree.File(base.String()[:2] + "/" + base.String()[2:])
+	c.Assert(err, IsNil)
+
+	n, err := r.Note(base, &NotesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(n.Content, Equals, "foo\n")
+
+	notes, err := r.ListNotes(&NotesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(notes, HasLen, notesFanoutLimit)
+}
+
+func (s *RepositorySuite) TestMergeNotes(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	add := func(ref plumbing.ReferenceName, h plumbing.Hash, content string) {
+		_, err := r.AddNote(h, content, &AddNoteOptions{Ref: ref, Author: defaultSignature(), Force: true})
+		c.Assert(err, IsNil)
+	}
+
+	add("theirs", base, "foo\n")
+	h, err := r.MergeNotes("theirs", &MergeNotesOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+
+	add("theirs", base, "bar\nfoo\n")
+	add("theirs", commits[0], "baz\n")
+	add("", commits[1], "qux\n")
+
+	h, err = r.MergeNotes("theirs", &MergeNotesOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 2)
+	c.Assert(commit.Message, Equals, "Merged notes from refs/notes/theirs into refs/notes/commits\n")
+
+	notes, err := r.ListNotes(&NotesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(notes, HasLen, 3)
+
+	add("theirs", base, "foo\nqux\n")
+	add("", base, "foo\nbar\n")
+
+	_, err = r.MergeNotes("theirs", &MergeNotesOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrNotesMergeConflict)
+
+	for strategy, content := range map[NotesMergeStrategy]string{
+		OursNotesMerge:        "foo\nbar\n",
+		TheirsNotesMerge:      "foo\nqux\n",
+		UnionNotesMerge:       "foo\nbar\n\nfoo\nqux\n",
+		CatSortUniqNotesMerge: "bar\nfoo\nqux\n",
+	} {
+		ref, err := r.Reference(DefaultNotesRef, false)
+		c.Assert(err, IsNil)
+
+		_, err = r.MergeNotes("theirs", &MergeNotesOptions{Author: defaultSignature(), Strategy: strategy})
+		c.Assert(err, IsNil)
+
+		n, err := r.Note(base, &NotesOptions{})
+		c.Assert(err, IsNil)
+		c.Assert(n.Content, Equals, content)
+
+		c.Assert(r.Storer.SetReference(ref), IsNil)
+	}
+}
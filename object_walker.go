@@ -81,6 +81,13 @@ func (p *objectWalker) walkObjectTree(hash plumbing.Hash) error {
 				return err
 			}
 		}
+	case *object.Tag:
+		err = p.walkObjectTree(obj.Target)
+		if err != nil {
+			return err
+		}
+	case *object.Blob:
+		// Blobs have no children.
 	case *object.Tree:
 		for i := range obj.Entries {
 			// Shortcut for blob objects:
@@ -94,6 +101,10 @@ func (p *objectWalker) walkObjectTree(hash plumbing.Hash) error {
 				p.add(obj.Entries[i].Hash)
 				continue
 			}
+			// Submodule commits are in other repositories.
+			if obj.Entries[i].Mode == filemode.Submodule {
+				continue
+			}
 			// Normal walk for sub-trees (and symlinks etc).
 			err = p.walkObjectTree(obj.Entries[i].Hash)
 			if err != nil {
@@ -8,13 +8,21 @@ package git
 
 import (
 	"errors"
+	"fmt"
+	"path"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/sniperkit/snk.fork.go-git.v4/config"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/bundle"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/diff"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/protocol/packp/sideband"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/transport"
+
+	"golang.org/x/crypto/openpgp"
 )
 
 // SubmoduleRescursivity defines how depth will affect any submodule recursive
@@ -29,6 +37,30 @@ const (
 	NoRecurseSubmodules SubmoduleRescursivity = 0
 	// DefaultSubmoduleRecursionDepth allow recursion in a submodule operation.
 	DefaultSubmoduleRecursionDepth SubmoduleRescursivity = 10
+
+	// DefaultReflogExpire is the age of the reflog entries removed by
+	// default, the same used by git command.
+	DefaultReflogExpire = 90 * 24 * time.Hour
+	// DefaultReflogExpireUnreachable is the age of the unreachable reflog
+	// entries removed by default, the same used by git command.
+	DefaultReflogExpireUnreachable = 30 * 24 * time.Hour
+
+	// DefaultGCAuto is the number of loose objects that triggers an automatic
+	// garbage collection by default, the same used by git command.
+	DefaultGCAuto = 6700
+	// DefaultGCAutoPackLimit is the number of packs that triggers an
+	// automatic garbage collection by default, the same used by git command.
+	DefaultGCAutoPackLimit = 50
+	// DefaultGCPruneExpire is the age of the unreachable loose objects
+	// removed by default, the same used by git command.
+	DefaultGCPruneExpire = 14 * 24 * time.Hour
+
+	// DefaultDescribeAbbrev is the length of the abbreviated hashes used by
+	// describe by default, the same used by git command.
+	DefaultDescribeAbbrev = 7
+	// DefaultDescribeCandidates is the number of candidate tags considered by
+	// describe by default, the same used by git command.
+	DefaultDescribeCandidates = 10
 )
 
 var (
@@ -107,8 +139,42 @@ type PullOptions struct {
 	// Force allows the pull to update a local branch even when the remote
 	// branch does not descend from it.
 	Force bool
+	// Mode is how the fetched branch is integrated into the current branch,
+	// by default the mode configured for the branch is used.
+	Mode PullMode
+	// Author is the author's signature of the merge commit. It's required
+	// when the pull creates a merge commit.
+	Author *object.Signature
+	// Committer is the committer's signature of the merge commit and of the
+	// rebased commits, it's required when the pull rebases the current
+	// branch. If Committer is nil the Author signature is used.
+	Committer *object.Signature
 }
 
+// PullMode defines how the fetched branch is integrated into the current
+// branch when a pull is performed.
+type PullMode int
+
+const (
+	// DefaultPullMode uses the mode configured by branch.<name>.rebase,
+	// pull.rebase and pull.ff, if none of them is set
+	// FastForwardOnlyPullMode is used. The rebase modes merges and
+	// interactive are not supported.
+	DefaultPullMode PullMode = iota
+	// FastForwardOnlyPullMode updates the current branch only when it can be
+	// fast-forwarded, equivalent to `git pull --ff-only`.
+	FastForwardOnlyPullMode
+	// NoFastForwardPullMode always creates a merge commit, even when the
+	// current branch can be fast-forwarded, equivalent to `git pull --no-ff`.
+	NoFastForwardPullMode
+	// MergePullMode fast-forwards the current branch when possible and
+	// creates a merge commit otherwise, equivalent to `git pull --no-rebase`.
+	MergePullMode
+	// RebasePullMode replays the local commits on top of the fetched branch,
+	// equivalent to `git pull --rebase`.
+	RebasePullMode
+)
+
 // Validate validates the fields and sets the default values.
 func (o *PullOptions) Validate() error {
 	if o.RemoteName == "" {
@@ -119,6 +185,10 @@ func (o *PullOptions) Validate() error {
 		o.ReferenceName = plumbing.HEAD
 	}
 
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
 	return nil
 }
 
@@ -340,25 +410,76 @@ var (
 	ErrMissingAuthor = errors.New("author field is required")
 )
 
+// AddOptions describes how an add operation should be performed.
+type AddOptions struct {
+	// Path is the path of the file or directory to add, by default the whole
+	// worktree.
+	Path string
+	// Renormalize applies the line endings conversions again to the tracked
+	// files, adding them even if their content in the worktree didn't change,
+	// equivalent to `git add --renormalize`. The untracked files aren't
+	// added.
+	Renormalize bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddOptions) Validate() error {
+	o.Path = path.Clean(o.Path)
+	return nil
+}
+
 // CommitOptions describes how a commit operation should be performed.
 type CommitOptions struct {
 	// All automatically stage files that have been modified and deleted, but
 	// new files you have not told Git about are not affected.
 	All bool
-	// Author is the author's signature of the commit.
+	// Author is the author's signature of the commit. If nil while a
+	// cherry-pick is stopped by conflicts, the author of the picked commit is
+	// used, and Committer is required.
 	Author *object.Signature
 	// Committer is the committer's signature of the commit. If Committer is
 	// nil the Author signature is used.
 	Committer *object.Signature
 	// Parents are the parents commits for the new commit, by default when
-	// len(Parents) is zero, the hash of HEAD reference is used.
+	// len(Parents) is zero, the hash of HEAD reference is used, followed by
+	// the commit being merged if a merge is in progress.
 	Parents []plumbing.Hash
+	// SignKey is the key used to sign the commit with OpenPGP, if nil the
+	// commit is not signed. The private key must be present and already
+	// decrypted.
+	SignKey *openpgp.Entity
+	// Signer signs the commit, with OpenPGP, SSH or any other format, it is
+	// mutually exclusive with SignKey.
+	Signer object.Signer
 }
 
 // Validate validates the fields and sets the default values.
 func (o *CommitOptions) Validate(r *Repository) error {
 	if o.Author == nil {
-		return ErrMissingAuthor
+		picked, err := r.Storer.Reference(cherryPickHeadRef)
+		if err == plumbing.ErrReferenceNotFound {
+			return ErrMissingAuthor
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if o.Committer == nil {
+			return ErrMissingCommitter
+		}
+
+		c, err := r.CommitObject(picked.Hash())
+		if err != nil {
+			return err
+		}
+
+		author := c.Author
+		o.Author = &author
+	}
+
+	if err := validateSigner(o.SignKey, &o.Signer); err != nil {
+		return err
 	}
 
 	if o.Committer == nil {
@@ -374,11 +495,751 @@ func (o *CommitOptions) Validate(r *Repository) error {
 		if head != nil {
 			o.Parents = []plumbing.Hash{head.Hash()}
 		}
+
+		merge, err := r.Storer.Reference(mergeHeadRef)
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		if merge != nil {
+			o.Parents = append(o.Parents, merge.Hash())
+		}
+	}
+
+	return nil
+}
+
+var (
+	ErrMissingMergeCommit = errors.New("commit field is required")
+)
+
+// MergeOptions describes how a merge operation should be performed.
+type MergeOptions struct {
+	// Commit is the hash of the commit to be merged into the current branch.
+	Commit plumbing.Hash
+	// Message is the message of the merge commit, by default a message
+	// naming the merged commit is used.
+	Message string
+	// Author is the author's signature of the merge commit.
+	Author *object.Signature
+	// Committer is the committer's signature of the merge commit. If Committer
+	// is nil the Author signature is used.
+	Committer *object.Signature
+	// NoFastForward creates a merge commit even when the current branch can
+	// be fast-forwarded.
+	NoFastForward bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *MergeOptions) Validate() error {
+	if o.Commit.IsZero() {
+		return ErrMissingMergeCommit
+	}
+
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	if o.Message == "" {
+		o.Message = fmt.Sprintf("Merge commit '%s'\n", o.Commit)
+	}
+
+	return nil
+}
+
+var (
+	ErrMissingCommitter = errors.New("committer field is required")
+)
+
+// CherryPickOptions describes how a cherry-pick operation should be
+// performed.
+type CherryPickOptions struct {
+	// Mainline is the number, starting at 1, of the parent used as base when
+	// the commit is a merge, equivalent to `git cherry-pick -m`. It must be
+	// set only for merge commits.
+	Mainline int
+	// RecordOrigin appends a "(cherry picked from commit ...)" line to the
+	// message of the commit, equivalent to `git cherry-pick -x`.
+	RecordOrigin bool
+	// Committer is the committer's signature of the new commit, the author
+	// of the picked commit is kept.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CherryPickOptions) Validate() error {
+	if o.Committer == nil {
+		return ErrMissingCommitter
 	}
 
 	return nil
 }
 
+// RevertOptions describes how a revert operation should be performed.
+type RevertOptions struct {
+	// Mainline is the number, starting at 1, of the parent whose changes are
+	// kept when the commit is a merge, equivalent to `git revert -m`. It must
+	// be set only for merge commits.
+	Mainline int
+	// Author is the author's signature of the new commit.
+	Author *object.Signature
+	// Committer is the committer's signature of the new commit. If Committer
+	// is nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RevertOptions) Validate() error {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+var (
+	ErrMissingUpstream = errors.New("upstream field is required")
+)
+
+// RebaseOptions describes how a rebase operation should be performed.
+type RebaseOptions struct {
+	// Upstream is the commit the branch is compared with, the commits of the
+	// branch not reachable from Upstream are replayed.
+	Upstream plumbing.Hash
+	// Onto is the commit the replayed commits are applied on top of,
+	// equivalent to `git rebase --onto`. If empty, Upstream is used.
+	Onto plumbing.Hash
+	// Branch is the branch being rebased, it's checked out once the rebase
+	// finishes or is aborted. If empty, the current branch is used.
+	Branch plumbing.ReferenceName
+	// Todo receives the default list of steps, picking every commit to be
+	// replayed, and returns the list of steps to be performed. If nil, the
+	// default list is used.
+	Todo func(steps []RebaseStep) ([]RebaseStep, error)
+	// Exec is called with the command of each exec step, if it returns an
+	// error the rebase stops. It's required when the list contains exec
+	// steps.
+	Exec func(command string) error
+	// Committer is the committer's signature of the replayed commits.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RebaseOptions) Validate() error {
+	if o.Upstream.IsZero() {
+		return ErrMissingUpstream
+	}
+
+	if o.Onto.IsZero() {
+		o.Onto = o.Upstream
+	}
+
+	if o.Committer == nil {
+		return ErrMissingCommitter
+	}
+
+	return nil
+}
+
+// StashPushOptions describes how a stash push operation should be performed.
+type StashPushOptions struct {
+	// Message describes the stashed changes, by default a message naming the
+	// current branch and commit is used.
+	Message string
+	// IncludeUntracked stashes the untracked files too, removing them from
+	// the worktree, equivalent to `git stash push --include-untracked`.
+	IncludeUntracked bool
+	// Author is the author's signature of the stash commits.
+	Author *object.Signature
+	// Committer is the committer's signature of the stash commits. If
+	// Committer is nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *StashPushOptions) Validate() error {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+// ExpireReflogOptions describes how a reflog expiry should be performed.
+type ExpireReflogOptions struct {
+	// References are the references whose reflogs are expired, by default
+	// HEAD and every reference of the repository.
+	References []plumbing.ReferenceName
+	// Expire removes the entries older than the given time, by default 90
+	// days ago.
+	Expire time.Time
+	// ExpireUnreachable removes the entries older than the given time whose
+	// commit is not reachable from the current value of the reference, by
+	// default 30 days ago.
+	ExpireUnreachable time.Time
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ExpireReflogOptions) Validate() error {
+	now := time.Now()
+	if o.Expire.IsZero() {
+		o.Expire = now.Add(-DefaultReflogExpire)
+	}
+
+	if o.ExpireUnreachable.IsZero() {
+		o.ExpireUnreachable = now.Add(-DefaultReflogExpireUnreachable)
+	}
+
+	return nil
+}
+
+var (
+	ErrMissingTagger          = errors.New("tagger field is required")
+	ErrMissingMessage         = errors.New("message field is required")
+	ErrSignKeySignerExclusive = errors.New("SignKey and Signer are mutually exclusive")
+)
+
+// CreateTagOptions describes how an annotated tag should be created.
+type CreateTagOptions struct {
+	// Tagger is the signature of the creator of the tag.
+	Tagger *object.Signature
+	// Message is the annotation of the tag. During the validation it is
+	// canonicalized into the format expected by git: without leading and
+	// trailing blank lines and ending in a newline.
+	Message string
+	// SignKey is the key used to sign the tag with OpenPGP, if nil the tag is
+	// not signed. The private key must be present and already decrypted.
+	SignKey *openpgp.Entity
+	// Signer signs the tag, with OpenPGP, SSH or any other format, it is
+	// mutually exclusive with SignKey.
+	Signer object.Signer
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CreateTagOptions) Validate() error {
+	if o.Tagger == nil {
+		return ErrMissingTagger
+	}
+
+	o.Message = strings.TrimSpace(o.Message)
+	if o.Message == "" {
+		return ErrMissingMessage
+	}
+
+	o.Message += "\n"
+	return validateSigner(o.SignKey, &o.Signer)
+}
+
+// validateSigner sets signer to an OpenPGP signer using key, if not nil.
+func validateSigner(key *openpgp.Entity, signer *object.Signer) error {
+	if key == nil {
+		return nil
+	}
+
+	if *signer != nil {
+		return ErrSignKeySignerExclusive
+	}
+
+	*signer = object.NewOpenPGPSigner(key)
+	return nil
+}
+
+// DescribeOptions describes how a describe should be performed.
+type DescribeOptions struct {
+	// Tags uses any tag, including the lightweight ones, instead of only the
+	// annotated tags.
+	Tags bool
+	// Match only considers the tags matching any of the given patterns, with
+	// the syntax of path.Match.
+	Match []string
+	// Exclude ignores the tags matching any of the given patterns, with the
+	// syntax of path.Match.
+	Exclude []string
+	// Dirty is appended to the description if the worktree has local
+	// changes, nothing is checked if empty. It is only meaningful when the
+	// described commit is HEAD.
+	Dirty string
+	// Always describes the commit as its abbreviated hash if no tag can
+	// describe it, instead of returning ErrNoDescribeTags.
+	Always bool
+	// Long always uses the long format, even when the commit is tagged.
+	Long bool
+	// Abbrev is the length of the abbreviated hash, by default
+	// DefaultDescribeAbbrev.
+	Abbrev int
+	// Candidates is the number of tags reachable from the commit considered
+	// to find the nearest one, by default DefaultDescribeCandidates.
+	Candidates int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *DescribeOptions) Validate() error {
+	if o.Abbrev <= 0 || o.Abbrev > 40 {
+		o.Abbrev = DefaultDescribeAbbrev
+	}
+
+	if o.Candidates <= 0 {
+		o.Candidates = DefaultDescribeCandidates
+	}
+
+	for _, pattern := range append(o.Match, o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var (
+	ErrMissingGood = errors.New("at least a good commit is required")
+)
+
+// BisectOptions describes how a bisect should be started.
+type BisectOptions struct {
+	// Bad is a commit known to be bad, by default HEAD.
+	Bad plumbing.Hash
+	// Good are commits known to be good, the first bad commit is searched
+	// between them and Bad.
+	Good []plumbing.Hash
+	// Skip are commits that can't be tested, they are never checked out.
+	Skip []plumbing.Hash
+}
+
+// Validate validates the fields and sets the default values.
+func (o *BisectOptions) Validate() error {
+	if len(o.Good) == 0 {
+		return ErrMissingGood
+	}
+
+	return nil
+}
+
+// ApplyOptions describes how a patch should be applied.
+type ApplyOptions struct {
+	// Cached applies the patch to the index only, using the content of the
+	// index instead of the worktree, equivalent to `git apply --cached`.
+	Cached bool
+	// Index applies the patch to both the worktree and the index, the
+	// patched files must match the index, equivalent to `git apply --index`.
+	Index bool
+	// Check only checks if the patch applies, nothing is changed.
+	Check bool
+	// Reverse applies the patch in reverse, undoing it.
+	Reverse bool
+	// Fuzz is the maximum number of context lines, at each end of a hunk,
+	// that are ignored when the hunk doesn't apply with its whole context.
+	Fuzz int
+	// IgnoreWhitespace ignores the changes in the amount of whitespace of
+	// the context lines, equivalent to `git apply --ignore-whitespace`.
+	IgnoreWhitespace bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ApplyOptions) Validate() error {
+	if o.Fuzz < 0 {
+		o.Fuzz = 0
+	}
+
+	return nil
+}
+
+// FormatPatchOptions describes how a range of commits should be formatted
+// as patches.
+type FormatPatchOptions struct {
+	// Since is the commit the range starts at, the commits reachable from it
+	// are not formatted. If empty the commits are formatted down to the root.
+	Since plumbing.Hash
+	// Until is the last commit of the range, by default HEAD.
+	Until plumbing.Hash
+	// SubjectPrefix is the prefix of the subjects inside the brackets, by
+	// default "PATCH".
+	SubjectPrefix string
+	// Numbered numbers the patches as [PATCH n/m] even if the range has a
+	// single commit, by default only series of many patches are numbered.
+	Numbered bool
+	// Signature is written at the end of every patch after a "-- " line,
+	// nothing is written if empty.
+	Signature string
+	// ContextLines is the number of context lines of the diffs, by default
+	// diff.DefaultContextLines.
+	ContextLines int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *FormatPatchOptions) Validate() error {
+	if o.SubjectPrefix == "" {
+		o.SubjectPrefix = "PATCH"
+	}
+
+	if o.ContextLines <= 0 {
+		o.ContextLines = diff.DefaultContextLines
+	}
+
+	return nil
+}
+
+// AmOptions describes how a mbox of patches should be applied.
+type AmOptions struct {
+	// Committer is the committer's signature of the new commits, required.
+	// As in git, it's the identity of whoever applies the patches, with the
+	// current time.
+	Committer *object.Signature
+	// KeepSubject keeps the subjects as they are, by default the leading
+	// bracketed strings, like [PATCH 1/2], are removed.
+	KeepSubject bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AmOptions) Validate() error {
+	if o.Committer == nil {
+		return ErrMissingCommitter
+	}
+
+	return nil
+}
+
+// ArchiveFormat is the format of an archive.
+type ArchiveFormat int
+
+const (
+	// TarArchive is an uncompressed tar archive.
+	TarArchive ArchiveFormat = iota
+	// TarGzipArchive is a tar archive compressed with gzip.
+	TarGzipArchive
+	// ZipArchive is a zip archive, the files are compressed with deflate.
+	ZipArchive
+)
+
+// ErrInvalidArchiveFormat is returned when an unknown archive format is
+// requested.
+var ErrInvalidArchiveFormat = errors.New("invalid archive format")
+
+// ArchiveOptions describes how an archive should be created.
+type ArchiveOptions struct {
+	// Format of the archive, by default TarArchive.
+	Format ArchiveFormat
+	// Prefix is prepended to every path of the archive, it must end with a
+	// slash to put the files in a directory, as `git archive --prefix`.
+	Prefix string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ArchiveOptions) Validate() error {
+	if o.Format < TarArchive || o.Format > ZipArchive {
+		return ErrInvalidArchiveFormat
+	}
+
+	return nil
+}
+
+// CreateBundleOptions describes how a bundle should be created.
+type CreateBundleOptions struct {
+	// References included in the bundle, by default HEAD, every branch and
+	// every tag, as `git bundle create --all`.
+	References []plumbing.ReferenceName
+	// Exclude are commits already present in the repositories receiving the
+	// bundle, their history isn't included in the bundle and the excluded
+	// parents of the included commits become the prerequisites of the bundle.
+	Exclude []plumbing.Hash
+	// Version of the bundle format, by default bundle.V2.
+	Version int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CreateBundleOptions) Validate() error {
+	if o.Version == 0 {
+		o.Version = bundle.V2
+	}
+
+	if o.Version != bundle.V2 && o.Version != bundle.V3 {
+		return bundle.ErrUnsupportedVersion
+	}
+
+	return nil
+}
+
+// FetchBundleOptions describes how the references of a bundle should be
+// fetched.
+type FetchBundleOptions struct {
+	// RemoteName is the name used in the reflog messages and in the default
+	// refspec, the remote doesn't need to exist. Defaults to origin.
+	RemoteName string
+	// RefSpecs maps the references of the bundle to local references, by
+	// default the branches of the bundle are stored as remote branches of
+	// RemoteName.
+	RefSpecs []config.RefSpec
+	// Tags describe how the tags of the bundle will be fetched, by default is
+	// TagFollowing.
+	Tags TagMode
+	// Force allows the fetch to update a local branch even when the branch
+	// of the bundle does not descend from it.
+	Force bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *FetchBundleOptions) Validate() error {
+	if o.RemoteName == "" {
+		o.RemoteName = DefaultRemoteName
+	}
+
+	if len(o.RefSpecs) == 0 {
+		o.RefSpecs = []config.RefSpec{
+			config.RefSpec(fmt.Sprintf(config.DefaultFetchRefSpec, o.RemoteName)),
+		}
+	}
+
+	if o.Tags == InvalidTagMode {
+		o.Tags = TagFollowing
+	}
+
+	for _, r := range o.RefSpecs {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FsckOptions describes how the integrity of a repository should be checked.
+type FsckOptions struct {
+	// Strict reports as errors the problems only reported as warnings by
+	// default, and reports the tree entries with group writable file modes.
+	Strict bool
+	// NoReflogs doesn't consider the commits of the reflogs as reachable.
+	NoReflogs bool
+	// NoDangling doesn't report the dangling objects, the unreachable objects
+	// not referenced by other unreachable objects.
+	NoDangling bool
+	// Unreachable reports every unreachable object, instead of only the
+	// dangling ones.
+	Unreachable bool
+}
+
+// GCOptions describes how a garbage collection should be performed.
+type GCOptions struct {
+	// Auto only collects the garbage when there are more loose objects than
+	// gc.auto, 6700 by default, or more packs than gc.autoPackLimit, 50 by
+	// default, as `git gc --auto`. A gc.auto of 0 disables it.
+	Auto bool
+	// PruneExpire removes the unreachable loose objects older than the given
+	// time, by default the gc.pruneExpire of the configuration or two weeks
+	// ago.
+	PruneExpire time.Time
+	// NoPrune keeps every unreachable object.
+	NoPrune bool
+}
+
+// AddWorktreeOptions describes how a linked worktree should be added.
+type AddWorktreeOptions struct {
+	// Name of the worktree, its git directory is .git/worktrees/<name>. By
+	// default it's the base name of the path of the worktree, followed by a
+	// number if it's already used.
+	Name string
+	// Hash to be checked out, if used HEAD will in detached mode. Branch and
+	// Hash are mutually exclusive, if Create is not used.
+	Hash plumbing.Hash
+	// Branch to be checked out. If Branch and Hash are empty a new branch,
+	// named as the worktree, is created at HEAD.
+	Branch plumbing.ReferenceName
+	// Create a new branch named Branch and start it at Hash, HEAD by default.
+	Create bool
+	// Force checks out Branch even if it's checked out by another worktree.
+	Force bool
+	// Lock the worktree after adding it, with LockReason as the reason.
+	Lock       bool
+	LockReason string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddWorktreeOptions) Validate() error {
+	if !o.Create && !o.Hash.IsZero() && o.Branch != "" {
+		return ErrBranchHashExclusive
+	}
+
+	if o.Create && o.Branch == "" {
+		return ErrCreateRequiresBranch
+	}
+
+	return nil
+}
+
+// RemoveWorktreeOptions describes how a linked worktree should be removed.
+type RemoveWorktreeOptions struct {
+	// Force removes the worktree even if it's locked or it isn't clean.
+	Force bool
+}
+
+// DefaultNotesRef is the notes reference used when none is given, nor
+// configured with core.notesRef.
+const DefaultNotesRef = plumbing.ReferenceName("refs/notes/commits")
+
+// NotesOptions describes the notes to be read.
+type NotesOptions struct {
+	// Ref is the notes reference, by default core.notesRef or
+	// refs/notes/commits. Names outside refs/notes/, like "ci", are
+	// expanded to refs/notes/ci.
+	Ref plumbing.ReferenceName
+}
+
+// Validate validates the fields and sets the default values.
+func (o *NotesOptions) Validate(r *Repository) (err error) {
+	o.Ref, err = r.notesRef(o.Ref)
+	return err
+}
+
+// AddNoteOptions describes how a note should be added.
+type AddNoteOptions struct {
+	// Ref is the notes reference, as in NotesOptions.
+	Ref plumbing.ReferenceName
+	// Force overwrites the existing note of the object, otherwise
+	// ErrNoteExists is returned.
+	Force bool
+	// Append appends the content to the existing note of the object,
+	// separated by a blank line, equivalent to `git notes append`.
+	Append bool
+	// Message is the message of the commit of the notes reference, by default
+	// the one of `git notes add`.
+	Message string
+	// Author is the author's signature of the commit.
+	Author *object.Signature
+	// Committer is the committer's signature of the commit. If Committer is
+	// nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddNoteOptions) Validate(r *Repository) (err error) {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	if o.Message == "" {
+		o.Message = "Notes added by 'git notes add'"
+		if o.Append {
+			o.Message = "Notes added by 'git notes append'"
+		}
+	}
+
+	o.Ref, err = r.notesRef(o.Ref)
+	return err
+}
+
+// RemoveNoteOptions describes how a note should be removed.
+type RemoveNoteOptions struct {
+	// Ref is the notes reference, as in NotesOptions.
+	Ref plumbing.ReferenceName
+	// Message is the message of the commit of the notes reference, by default
+	// the one of `git notes remove`.
+	Message string
+	// Author is the author's signature of the commit.
+	Author *object.Signature
+	// Committer is the committer's signature of the commit. If Committer is
+	// nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RemoveNoteOptions) Validate(r *Repository) (err error) {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	if o.Message == "" {
+		o.Message = "Notes removed by 'git notes remove'"
+	}
+
+	o.Ref, err = r.notesRef(o.Ref)
+	return err
+}
+
+// NotesMergeStrategy defines how the conflicting notes of a notes merge are
+// resolved.
+type NotesMergeStrategy int
+
+const (
+	// ManualNotesMerge fails with ErrNotesMergeConflict if there is any
+	// conflicting note. This is the default strategy.
+	ManualNotesMerge NotesMergeStrategy = iota
+	// OursNotesMerge keeps the local version of the conflicting notes.
+	OursNotesMerge
+	// TheirsNotesMerge keeps the merged version of the conflicting notes.
+	TheirsNotesMerge
+	// UnionNotesMerge concatenates the local and the merged versions of the
+	// conflicting notes.
+	UnionNotesMerge
+	// CatSortUniqNotesMerge concatenates the local and the merged versions
+	// of the conflicting notes, sorting the lines and removing the duplicated
+	// ones.
+	CatSortUniqNotesMerge
+)
+
+// MergeNotesOptions describes how a notes reference should be merged.
+type MergeNotesOptions struct {
+	// Ref is the notes reference where the notes are merged, as in
+	// NotesOptions.
+	Ref plumbing.ReferenceName
+	// Strategy resolves the conflicting notes.
+	Strategy NotesMergeStrategy
+	// Message is the message of the merge commit, by default a message naming
+	// the merged reference is used.
+	Message string
+	// Author is the author's signature of the merge commit.
+	Author *object.Signature
+	// Committer is the committer's signature of the merge commit. If
+	// Committer is nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *MergeNotesOptions) Validate(r *Repository) (err error) {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	if o.Strategy < ManualNotesMerge || o.Strategy > CatSortUniqNotesMerge {
+		return ErrInvalidNotesMergeStrategy
+	}
+
+	o.Ref, err = r.notesRef(o.Ref)
+	return err
+}
+
+// ReplaceOptions describes how an object should be replaced.
+type ReplaceOptions struct {
+	// Force overwrites the existing replacement of the object, and allows to
+	// replace an object with an object of a different type.
+	Force bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ReplaceOptions) Validate() error { return nil }
+
 // ListOptions describes how a remote list should be performed.
 type ListOptions struct {
 	// Auth credentials, if required, to use with the remote repository.
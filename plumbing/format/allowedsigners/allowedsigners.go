@@ -0,0 +1,125 @@
+// Package allowedsigners implements decoding of the allowed signers files used
+// to verify SSH signatures, as described in the ALLOWED SIGNERS section of
+// ssh-keygen(1).
+//
+// Each line of the file is an entry with the format:
+//
+//	<principals> SP [<options> SP] <key type> SP <base64 key> [SP <comment>]
+//
+// Where the principals are a comma separated list of patterns, which may be
+// quoted, and the options a comma separated list of cert-authority,
+// namespaces="<list>", valid-after="<time>" and valid-before="<time>". Empty
+// lines and lines starting with '#' are ignored.
+package allowedsigners
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Entry is an entry of an allowed signers file, a key trusted to sign on
+// behalf of some principals.
+type Entry struct {
+	// Principals are the patterns of the principals allowed to use the key,
+	// a pattern may contain the '*' and '?' wildcards and be negated with a
+	// leading '!'.
+	Principals []string
+	// CertAuthority is set if the key is a certificate authority, trusted to
+	// sign certificates of the principals instead of being used directly.
+	CertAuthority bool
+	// Namespaces are the namespaces the key is allowed to sign in, any
+	// namespace is allowed if empty.
+	Namespaces []string
+	// ValidAfter is the time the key starts being valid, the zero value if
+	// there is no limit.
+	ValidAfter time.Time
+	// ValidBefore is the time the key stops being valid, the zero value if
+	// there is no limit.
+	ValidBefore time.Time
+	// Key is the public key.
+	Key ssh.PublicKey
+	// Comment is the comment following the key, if any.
+	Comment string
+}
+
+// MatchPrincipal returns whether the given principal is allowed by the
+// patterns of the entry. A principal matching a negated pattern is never
+// allowed.
+func (e *Entry) MatchPrincipal(principal string) bool {
+	var matched bool
+	for _, p := range e.Principals {
+		if strings.HasPrefix(p, "!") {
+			if matchPattern(p[1:], principal) {
+				return false
+			}
+
+			continue
+		}
+
+		if matchPattern(p, principal) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// AllowsNamespace returns whether the key is allowed to sign in the given
+// namespace.
+func (e *Entry) AllowsNamespace(namespace string) bool {
+	if len(e.Namespaces) == 0 {
+		return true
+	}
+
+	for _, ns := range e.Namespaces {
+		if matchPattern(ns, namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidAt returns whether the key is valid at the given time.
+func (e *Entry) ValidAt(t time.Time) bool {
+	if !e.ValidAfter.IsZero() && t.Before(e.ValidAfter) {
+		return false
+	}
+
+	if !e.ValidBefore.IsZero() && !t.Before(e.ValidBefore) {
+		return false
+	}
+
+	return true
+}
+
+// matchPattern matches the given string against a pattern with the '*' and
+// '?' wildcards.
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return len(s) == 0
+}
@@ -0,0 +1,152 @@
+package allowedsigners
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// ErrMalformedEntry is returned when a line of the allowed signers file
+	// can't be parsed.
+	ErrMalformedEntry = errors.New("malformed allowed signers entry")
+)
+
+// A Decoder reads and decodes allowed signers files from an input stream.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads all the entries of the allowed signers file.
+func (d *Decoder) Decode() ([]*Entry, error) {
+	var entries []*Entry
+
+	s := bufio.NewScanner(d.r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		e, err := decodeEntry(line)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, s.Err()
+}
+
+func decodeEntry(line string) (*Entry, error) {
+	principals, rest, err := splitPrincipals(line)
+	if err != nil {
+		return nil, err
+	}
+
+	key, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(rest))
+	if err != nil {
+		return nil, ErrMalformedEntry
+	}
+
+	e := &Entry{
+		Principals: strings.Split(principals, ","),
+		Key:        key,
+		Comment:    comment,
+	}
+
+	for _, o := range options {
+		if err := decodeOption(e, o); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// splitPrincipals splits the principals field, quoted or not, from the rest
+// of the line.
+func splitPrincipals(line string) (principals, rest string, err error) {
+	if line[0] == '"' {
+		end := strings.IndexByte(line[1:], '"')
+		if end == -1 {
+			return "", "", ErrMalformedEntry
+		}
+
+		principals, rest = line[1:end+1], line[end+2:]
+	} else {
+		end := strings.IndexAny(line, " \t")
+		if end == -1 {
+			return "", "", ErrMalformedEntry
+		}
+
+		principals, rest = line[:end], line[end:]
+	}
+
+	if principals == "" || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return "", "", ErrMalformedEntry
+	}
+
+	return principals, strings.TrimSpace(rest), nil
+}
+
+func decodeOption(e *Entry, o string) error {
+	name, value := o, ""
+	if i := strings.IndexByte(o, '='); i != -1 {
+		name, value = o[:i], strings.Trim(o[i+1:], `"`)
+	}
+
+	var err error
+	switch strings.ToLower(name) {
+	case "cert-authority":
+		e.CertAuthority = true
+	case "namespaces":
+		e.Namespaces = strings.Split(value, ",")
+	case "valid-after":
+		e.ValidAfter, err = decodeTime(value)
+	case "valid-before":
+		e.ValidBefore, err = decodeTime(value)
+	default:
+		err = ErrMalformedEntry
+	}
+
+	return err
+}
+
+// decodeTime parses the times of the validity options, YYYYMMDD[HHMM[SS]]
+// with an optional trailing Z for UTC, the local time zone is used otherwise.
+func decodeTime(s string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(s, "Z") {
+		s, loc = s[:len(s)-1], time.UTC
+	}
+
+	var layout string
+	switch len(s) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, ErrMalformedEntry
+	}
+
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return time.Time{}, ErrMalformedEntry
+	}
+
+	return t, nil
+}
@@ -0,0 +1,83 @@
+package allowedsigners
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type AllowedSignersSuite struct{}
+
+var _ = Suite(&AllowedSignersSuite{})
+
+const key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAII9UUpjnQkbT6V0hTD/xDkBsTe0+tydage6Ws39xxfV4"
+
+const allowedSignersFixture = "" +
+	"# comment\n" +
+	"\n" +
+	"john@doe.com " + key + " john\n" +
+	`"*@example.com,!root@example.com" cert-authority,namespaces="git,file",valid-after="20200101",valid-before="20300101120000Z" ` + key + "\n"
+
+func (s *AllowedSignersSuite) TestDecode(c *C) {
+	entries, err := NewDecoder(strings.NewReader(allowedSignersFixture)).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+
+	c.Assert(entries[0].Principals, DeepEquals, []string{"john@doe.com"})
+	c.Assert(entries[0].CertAuthority, Equals, false)
+	c.Assert(entries[0].Namespaces, HasLen, 0)
+	c.Assert(entries[0].Key.Type(), Equals, "ssh-ed25519")
+	c.Assert(entries[0].Comment, Equals, "john")
+
+	c.Assert(entries[1].Principals, DeepEquals, []string{"*@example.com", "!root@example.com"})
+	c.Assert(entries[1].CertAuthority, Equals, true)
+	c.Assert(entries[1].Namespaces, DeepEquals, []string{"git", "file"})
+	c.Assert(entries[1].ValidAfter.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)), Equals, true)
+	c.Assert(entries[1].ValidBefore.Equal(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)), Equals, true)
+}
+
+func (s *AllowedSignersSuite) TestDecodeMalformed(c *C) {
+	for _, line := range []string{
+		"john@doe.com",
+		`"john@doe.com ` + key,
+		"john@doe.com ssh-ed25519 foo",
+		"john@doe.com foo " + key,
+		`john@doe.com valid-after="2020" ` + key,
+	} {
+		_, err := NewDecoder(strings.NewReader(line)).Decode()
+		c.Assert(err, Equals, ErrMalformedEntry, Commentf("line %q", line))
+	}
+}
+
+func (s *AllowedSignersSuite) TestMatchPrincipal(c *C) {
+	e := &Entry{Principals: []string{"*@example.com", "!root@example.com", "j?hn"}}
+
+	c.Assert(e.MatchPrincipal("foo@example.com"), Equals, true)
+	c.Assert(e.MatchPrincipal("john"), Equals, true)
+	c.Assert(e.MatchPrincipal("root@example.com"), Equals, false)
+	c.Assert(e.MatchPrincipal("foo@example.org"), Equals, false)
+}
+
+func (s *AllowedSignersSuite) TestAllowsNamespace(c *C) {
+	e := &Entry{}
+	c.Assert(e.AllowsNamespace("git"), Equals, true)
+
+	e.Namespaces = []string{"file", "g*"}
+	c.Assert(e.AllowsNamespace("git"), Equals, true)
+	c.Assert(e.AllowsNamespace("email"), Equals, false)
+}
+
+func (s *AllowedSignersSuite) TestValidAt(c *C) {
+	e := &Entry{
+		ValidAfter:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		ValidBefore: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	c.Assert(e.ValidAt(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)), Equals, false)
+	c.Assert(e.ValidAt(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(e.ValidAt(e.ValidBefore), Equals, false)
+}
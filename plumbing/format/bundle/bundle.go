@@ -0,0 +1,65 @@
+// Package bundle implements encoding and decoding of git bundle files, the
+// files used by git bundle to transport references and objects without a
+// server.
+//
+// A bundle is a header with the references and the prerequisites of the
+// bundle, followed by a packfile with the objects:
+//
+//	"# v2 git bundle" LF
+//	*("-" <hash> [SP <comment>] LF)
+//	*(<hash> SP <refname> LF)
+//	LF
+//	<packfile>
+//
+// The version 3 of the format adds capabilities to the header, after the
+// signature:
+//
+//	"# v3 git bundle" LF
+//	*("@" <key> ["=" <value>] LF)
+//	...
+//
+// The prerequisites are the commits that must be present in the repository
+// unbundling the bundle, the packfile of a bundle with prerequisites can be
+// thin, so some of its deltas can have as base objects not included in it.
+package bundle
+
+import (
+	"io"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+)
+
+const (
+	// V2 is the version 2 of the bundle format.
+	V2 = 2
+	// V3 is the version 3 of the bundle format, it adds capabilities.
+	V3 = 3
+)
+
+var (
+	v2Signature = "# v2 git bundle"
+	v3Signature = "# v3 git bundle"
+)
+
+// Bundle is a git bundle.
+type Bundle struct {
+	// Version is the version of the bundle format, V2 or V3.
+	Version int
+	// Capabilities are the capabilities of a V3 bundle, like object-format,
+	// a capability without value has an empty value.
+	Capabilities map[string]string
+	// Prerequisites are the commits required to unbundle the bundle.
+	Prerequisites []Prerequisite
+	// References are the references included in the bundle.
+	References []*plumbing.Reference
+	// Packfile is the packfile with the objects of the bundle.
+	Packfile io.Reader
+}
+
+// Prerequisite is a commit required to unbundle a bundle.
+type Prerequisite struct {
+	// Hash is the hash of the commit.
+	Hash plumbing.Hash
+	// Comment is an optional comment, git uses the subject of the commit.
+	Comment string
+}
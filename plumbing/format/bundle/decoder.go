@@ -0,0 +1,140 @@
+package bundle
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+)
+
+var (
+	// ErrMalformedBundle is returned when the header of a bundle is invalid.
+	ErrMalformedBundle = errors.New("malformed bundle")
+)
+
+// A Decoder reads and decodes bundles from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the header of a bundle, the Packfile of the returned bundle
+// reads the rest of the input, the packfile following the header.
+func (d *Decoder) Decode() (*Bundle, error) {
+	line, err := d.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{}
+	switch line {
+	case v2Signature:
+		b.Version = V2
+	case v3Signature:
+		b.Version = V3
+	default:
+		if strings.HasPrefix(line, "# v") && strings.HasSuffix(line, " git bundle") {
+			return nil, ErrUnsupportedVersion
+		}
+
+		return nil, ErrMalformedBundle
+	}
+
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "" {
+			break
+		}
+
+		if err := d.decodeLine(b, line); err != nil {
+			return nil, err
+		}
+	}
+
+	b.Packfile = d.r
+	return b, nil
+}
+
+func (d *Decoder) decodeLine(b *Bundle, line string) error {
+	switch {
+	case line[0] == '@':
+		if b.Version < V3 || len(b.Prerequisites) != 0 || len(b.References) != 0 {
+			return ErrMalformedBundle
+		}
+
+		if b.Capabilities == nil {
+			b.Capabilities = make(map[string]string)
+		}
+
+		kv := strings.SplitN(line[1:], "=", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+
+		b.Capabilities[kv[0]] = kv[1]
+	case line[0] == '-':
+		hash, comment := line[1:], ""
+		if i := strings.IndexByte(hash, ' '); i != -1 {
+			hash, comment = hash[:i], hash[i+1:]
+		}
+
+		if !isHash(hash) {
+			return ErrMalformedBundle
+		}
+
+		b.Prerequisites = append(b.Prerequisites, Prerequisite{
+			Hash:    plumbing.NewHash(hash),
+			Comment: comment,
+		})
+	default:
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 || !isHash(parts[0]) || parts[1] == "" {
+			return ErrMalformedBundle
+		}
+
+		b.References = append(b.References, plumbing.NewHashReference(
+			plumbing.ReferenceName(parts[1]), plumbing.NewHash(parts[0]),
+		))
+	}
+
+	return nil
+}
+
+// readLine reads a line of the header without the line feed, the header
+// must end with an empty line, so io.EOF is never expected.
+func (d *Decoder) readLine() (string, error) {
+	line, err := d.r.ReadString('\n')
+	if err == io.EOF {
+		return "", ErrMalformedBundle
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
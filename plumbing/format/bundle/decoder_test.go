@@ -0,0 +1,85 @@
+package bundle
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type BundleSuite struct{}
+
+var _ = Suite(&BundleSuite{})
+
+const v2Fixture = `# v2 git bundle
+-6ecf0ef2c2dffb796033e5a02219af86ec6584e5 vendor stuff
+b029517f6300c2da0f4b651b8642506cd6aaf45d refs/heads/master
+b8e471f58bcbca63b07bda20e428190409c2db47 refs/tags/v1.0.0
+
+PACK`
+
+func (s *BundleSuite) TestDecodeV2(c *C) {
+	b, err := NewDecoder(strings.NewReader(v2Fixture)).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, V2)
+	c.Assert(b.Capabilities, IsNil)
+	c.Assert(b.Prerequisites, DeepEquals, []Prerequisite{{
+		Hash:    plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		Comment: "vendor stuff",
+	}})
+
+	c.Assert(b.References, HasLen, 2)
+	c.Assert(b.References[0].Name(), Equals, plumbing.Master)
+	c.Assert(b.References[0].Hash().String(), Equals, "b029517f6300c2da0f4b651b8642506cd6aaf45d")
+	c.Assert(b.References[1].Name(), Equals, plumbing.ReferenceName("refs/tags/v1.0.0"))
+
+	pack, err := ioutil.ReadAll(b.Packfile)
+	c.Assert(err, IsNil)
+	c.Assert(string(pack), Equals, "PACK")
+}
+
+func (s *BundleSuite) TestDecodeV3(c *C) {
+	fixture := "# v3 git bundle\n@object-format=sha1\n@filter=blob:none\n@foo\n" +
+		"-6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n" +
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d HEAD\n\n"
+
+	b, err := NewDecoder(strings.NewReader(fixture)).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, V3)
+	c.Assert(b.Capabilities, DeepEquals, map[string]string{
+		"object-format": "sha1",
+		"filter":        "blob:none",
+		"foo":           "",
+	})
+
+	c.Assert(b.Prerequisites, HasLen, 1)
+	c.Assert(b.Prerequisites[0].Comment, Equals, "")
+	c.Assert(b.References, HasLen, 1)
+	c.Assert(b.References[0].Name(), Equals, plumbing.HEAD)
+}
+
+func (s *BundleSuite) TestDecodeUnsupportedVersion(c *C) {
+	_, err := NewDecoder(strings.NewReader("# v4 git bundle\n\n")).Decode()
+	c.Assert(err, Equals, ErrUnsupportedVersion)
+}
+
+func (s *BundleSuite) TestDecodeMalformed(c *C) {
+	for _, fixture := range []string{
+		"",
+		"PACK",
+		"# v2 git bundle\n",
+		"# v2 git bundle\n@object-format=sha1\n\n",
+		"# v3 git bundle\n-6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n@foo\n\n",
+		"# v2 git bundle\n-foo\n\n",
+		"# v2 git bundle\nb029517f6300c2da0f4b651b8642506cd6aaf45d\n\n",
+		"# v2 git bundle\nb029517f refs/heads/master\n\n",
+	} {
+		_, err := NewDecoder(strings.NewReader(fixture)).Decode()
+		c.Assert(err, Equals, ErrMalformedBundle, Commentf("fixture: %q", fixture))
+	}
+}
@@ -0,0 +1,93 @@
+package bundle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+var (
+	// ErrUnsupportedVersion is returned when the version of a bundle isn't
+	// supported.
+	ErrUnsupportedVersion = errors.New("unsupported bundle version")
+	// ErrCapabilitiesNotSupported is returned when encoding a V2 bundle with
+	// capabilities.
+	ErrCapabilitiesNotSupported = errors.New("capabilities require a v3 bundle")
+)
+
+// An Encoder writes bundles to an output stream.
+type Encoder struct {
+	w *bufio.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes the bundle b, the header followed by the content of the
+// packfile of the bundle, if any.
+func (e *Encoder) Encode(b *Bundle) error {
+	if err := e.encodeHeader(b); err != nil {
+		return err
+	}
+
+	if b.Packfile != nil {
+		if _, err := io.Copy(e.w, b.Packfile); err != nil {
+			return err
+		}
+	}
+
+	return e.w.Flush()
+}
+
+func (e *Encoder) encodeHeader(b *Bundle) error {
+	switch b.Version {
+	case V2:
+		if len(b.Capabilities) != 0 {
+			return ErrCapabilitiesNotSupported
+		}
+
+		fmt.Fprintln(e.w, v2Signature)
+	case V3:
+		fmt.Fprintln(e.w, v3Signature)
+		e.encodeCapabilities(b.Capabilities)
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	for _, p := range b.Prerequisites {
+		if p.Comment == "" {
+			fmt.Fprintf(e.w, "-%s\n", p.Hash)
+			continue
+		}
+
+		fmt.Fprintf(e.w, "-%s %s\n", p.Hash, p.Comment)
+	}
+
+	for _, r := range b.References {
+		fmt.Fprintf(e.w, "%s %s\n", r.Hash(), r.Name())
+	}
+
+	_, err := fmt.Fprintln(e.w)
+	return err
+}
+
+func (e *Encoder) encodeCapabilities(caps map[string]string) {
+	keys := make([]string, 0, len(caps))
+	for k := range caps {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v := caps[k]; v != "" {
+			fmt.Fprintf(e.w, "@%s=%s\n", k, v)
+			continue
+		}
+
+		fmt.Fprintf(e.w, "@%s\n", k)
+	}
+}
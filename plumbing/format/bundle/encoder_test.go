@@ -0,0 +1,63 @@
+package bundle
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BundleSuite) TestEncodeV2(c *C) {
+	b := &Bundle{
+		Version: V2,
+		Prerequisites: []Prerequisite{{
+			Hash:    plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+			Comment: "vendor stuff",
+		}},
+		References: []*plumbing.Reference{
+			plumbing.NewHashReference(plumbing.Master,
+				plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")),
+			plumbing.NewHashReference("refs/tags/v1.0.0",
+				plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47")),
+		},
+		Packfile: strings.NewReader("PACK"),
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(b), IsNil)
+	c.Assert(buf.String(), Equals, v2Fixture)
+}
+
+func (s *BundleSuite) TestEncodeV3(c *C) {
+	b := &Bundle{
+		Version:      V3,
+		Capabilities: map[string]string{"object-format": "sha1", "foo": ""},
+		Prerequisites: []Prerequisite{{
+			Hash: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(b), IsNil)
+	c.Assert(buf.String(), Equals, "# v3 git bundle\n@foo\n@object-format=sha1\n"+
+		"-6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n\n")
+
+	decoded, err := NewDecoder(buf).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(decoded.Capabilities, DeepEquals, b.Capabilities)
+	c.Assert(decoded.Prerequisites, DeepEquals, b.Prerequisites)
+}
+
+func (s *BundleSuite) TestEncodeErrors(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(&Bundle{Version: 1})
+	c.Assert(err, Equals, ErrUnsupportedVersion)
+
+	err = NewEncoder(buf).Encode(&Bundle{
+		Version:      V2,
+		Capabilities: map[string]string{"object-format": "sha1"},
+	})
+	c.Assert(err, Equals, ErrCapabilitiesNotSupported)
+}
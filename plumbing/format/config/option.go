@@ -37,6 +37,20 @@ func (opts Options) GoString() string {
 	return strings.Join(strs, ", ")
 }
 
+// ParseBool returns the value of a boolean option as git reads it, "true",
+// "yes", "on" and "1" are true, "false", "no", "off" and "0" are false, the
+// case is ignored. ok is false if the value isn't one of them.
+func ParseBool(value string) (b, ok bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0":
+		return false, true
+	}
+
+	return false, false
+}
+
 // Get gets the value for the given key if set,
 // otherwise it returns the empty string.
 //
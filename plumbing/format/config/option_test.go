@@ -37,3 +37,22 @@ func (s *OptionSuite) TestOption_IsKey(c *C) {
 	c.Assert((&Option{Key: "key"}).IsKey(""), Equals, false)
 	c.Assert((&Option{Key: ""}).IsKey("key"), Equals, false)
 }
+
+func (s *OptionSuite) TestParseBool(c *C) {
+	for _, v := range []string{"true", "TRUE", "yes", "Yes", "on", "ON", "1"} {
+		b, ok := ParseBool(v)
+		c.Assert(ok, Equals, true, Commentf("value %q", v))
+		c.Assert(b, Equals, true, Commentf("value %q", v))
+	}
+
+	for _, v := range []string{"false", "False", "no", "NO", "off", "Off", "0"} {
+		b, ok := ParseBool(v)
+		c.Assert(ok, Equals, true, Commentf("value %q", v))
+		c.Assert(b, Equals, false, Commentf("value %q", v))
+	}
+
+	for _, v := range []string{"", "2", "only", "input", "yess"} {
+		_, ok := ParseBool(v)
+		c.Assert(ok, Equals, false, Commentf("value %q", v))
+	}
+}
@@ -0,0 +1,662 @@
+package diff
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+)
+
+// ErrMalformedPatch is returned when a unified diff can't be decoded.
+var ErrMalformedPatch = errors.New("malformed patch")
+
+const (
+	gitDiffPrefix    = "diff --git "
+	fromPathPrefix   = "--- "
+	toPathPrefix     = "+++ "
+	hunkPrefix       = "@@ -"
+	noNewlineMarker  = "\\"
+	gitBinaryPatch   = "GIT binary patch"
+	binaryFilePrefix = "Binary files "
+)
+
+// UnifiedDecoder decodes unified diffs, as the ones generated by git diff,
+// git format-patch, UnifiedEncoder or diff -u, into Patches. The extended
+// headers of git are understood, including creations, deletions, renames,
+// copies and mode changes. The content of binary patches is not decoded.
+type UnifiedDecoder struct {
+	r     *bufio.Reader
+	lines []string
+	pos   int
+}
+
+// NewUnifiedDecoder returns a new UnifiedDecoder reading from r.
+func NewUnifiedDecoder(r io.Reader) *UnifiedDecoder {
+	return &UnifiedDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the whole unified diff and returns the decoded patch, a
+// *UnifiedPatch. Any text before the first file patch is its message.
+func (d *UnifiedDecoder) Decode() (Patch, error) {
+	if err := d.readLines(); err != nil {
+		return nil, err
+	}
+
+	p := &UnifiedPatch{}
+	var message []string
+	for d.pos < len(d.lines) {
+		line := d.lines[d.pos]
+
+		var fp *UnifiedFilePatch
+		var err error
+		switch {
+		case strings.HasPrefix(line, gitDiffPrefix):
+			fp, err = d.decodeGitFilePatch()
+		case d.atPlainFilePatch():
+			fp, err = d.decodePlainFilePatch()
+		default:
+			if len(p.filePatches) == 0 {
+				message = append(message, line)
+			}
+
+			d.pos++
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		p.filePatches = append(p.filePatches, fp)
+	}
+
+	if len(message) != 0 {
+		p.message = strings.Join(message, "\n") + "\n"
+	}
+
+	return p, nil
+}
+
+func (d *UnifiedDecoder) readLines() error {
+	for {
+		line, err := d.r.ReadString('\n')
+		if line != "" {
+			d.lines = append(d.lines, strings.TrimSuffix(line, "\n"))
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// atPlainFilePatch returns whether a file patch without git headers starts
+// at the current line.
+func (d *UnifiedDecoder) atPlainFilePatch() bool {
+	return d.pos+2 < len(d.lines) &&
+		strings.HasPrefix(d.lines[d.pos], fromPathPrefix) &&
+		strings.HasPrefix(d.lines[d.pos+1], toPathPrefix) &&
+		strings.HasPrefix(d.lines[d.pos+2], hunkPrefix)
+}
+
+func (d *UnifiedDecoder) decodeGitFilePatch() (*UnifiedFilePatch, error) {
+	from, to, err := parseGitHeaderNames(d.lines[d.pos][len(gitDiffPrefix):])
+	if err != nil {
+		return nil, err
+	}
+
+	fp := &UnifiedFilePatch{
+		from: &UnifiedFile{path: from},
+		to:   &UnifiedFile{path: to},
+	}
+
+	var created, deleted bool
+	for d.pos++; d.pos < len(d.lines); d.pos++ {
+		line := d.lines[d.pos]
+		if strings.HasPrefix(line, hunkPrefix) {
+			break
+		}
+
+		var err error
+		switch {
+		case hasHeader(line, "old mode "):
+			fp.from.mode, err = filemode.New(headerValue(line))
+		case hasHeader(line, "new mode "):
+			fp.to.mode, err = filemode.New(headerValue(line))
+		case hasHeader(line, "deleted file mode "):
+			deleted = true
+			fp.from.mode, err = filemode.New(headerValue(line))
+		case hasHeader(line, "new file mode "):
+			created = true
+			fp.to.mode, err = filemode.New(headerValue(line))
+		case hasHeader(line, "rename from ", "rename old "):
+			fp.from.path, err = unquotePath(headerValue(line))
+		case hasHeader(line, "rename to ", "rename new "):
+			fp.to.path, err = unquotePath(headerValue(line))
+		case hasHeader(line, "copy from "):
+			fp.copy = true
+			fp.from.path, err = unquotePath(headerValue(line))
+		case hasHeader(line, "copy to "):
+			fp.copy = true
+			fp.to.path, err = unquotePath(headerValue(line))
+		case hasHeader(line, "similarity index ", "dissimilarity index "):
+		case hasHeader(line, "index "):
+			err = fp.parseIndex(headerValue(line))
+		case hasHeader(line, fromPathPrefix):
+			var path string
+			path, err = parsePathLine(line, aDir)
+			if path == "" {
+				created = true
+			} else if fp.from.path == "" {
+				fp.from.path = path
+			}
+		case hasHeader(line, toPathPrefix):
+			var path string
+			path, err = parsePathLine(line, bDir)
+			if path == "" {
+				deleted = true
+			} else if fp.to.path == "" {
+				fp.to.path = path
+			}
+		case hasHeader(line, binaryFilePrefix, gitBinaryPatch):
+			fp.binary = true
+		case strings.HasPrefix(line, gitDiffPrefix) || fp.binary:
+			// the content of binary patches is skipped until the next file
+			if fp.binary && !strings.HasPrefix(line, gitDiffPrefix) {
+				continue
+			}
+
+			return fp.finish(created, deleted)
+		default:
+			return fp.finish(created, deleted)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.decodeHunks(fp); err != nil {
+		return nil, err
+	}
+
+	return fp.finish(created, deleted)
+}
+
+func (d *UnifiedDecoder) decodePlainFilePatch() (*UnifiedFilePatch, error) {
+	from, err := parsePathLine(d.lines[d.pos], "")
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parsePathLine(d.lines[d.pos+1], "")
+	if err != nil {
+		return nil, err
+	}
+
+	d.pos += 2
+	fp := &UnifiedFilePatch{
+		from: &UnifiedFile{path: stripComponent(from)},
+		to:   &UnifiedFile{path: stripComponent(to)},
+	}
+
+	if err := d.decodeHunks(fp); err != nil {
+		return nil, err
+	}
+
+	return fp.finish(from == "", to == "")
+}
+
+func (d *UnifiedDecoder) decodeHunks(fp *UnifiedFilePatch) error {
+	for d.pos < len(d.lines) && strings.HasPrefix(d.lines[d.pos], hunkPrefix) {
+		h, err := d.decodeHunk()
+		if err != nil {
+			return err
+		}
+
+		fp.hunks = append(fp.hunks, h)
+	}
+
+	return nil
+}
+
+func (d *UnifiedDecoder) decodeHunk() (*Hunk, error) {
+	h, err := parseHunkHeader(d.lines[d.pos])
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []Operation
+	var texts []string
+	from, to := h.FromCount, h.ToCount
+	for d.pos++; d.pos < len(d.lines); d.pos++ {
+		line := d.lines[d.pos]
+		if strings.HasPrefix(line, noNewlineMarker) {
+			if len(texts) == 0 {
+				return nil, ErrMalformedPatch
+			}
+
+			texts[len(texts)-1] = strings.TrimSuffix(texts[len(texts)-1], "\n")
+			continue
+		}
+
+		if from == 0 && to == 0 {
+			break
+		}
+
+		op := Equal
+		if line != "" {
+			switch line[0] {
+			case ' ':
+			case '-':
+				op = Delete
+			case '+':
+				op = Add
+			default:
+				return nil, ErrMalformedPatch
+			}
+
+			line = line[1:]
+		}
+
+		if op != Add {
+			from--
+		}
+
+		if op != Delete {
+			to--
+		}
+
+		if from < 0 || to < 0 {
+			return nil, ErrMalformedPatch
+		}
+
+		ops = append(ops, op)
+		texts = append(texts, line+"\n")
+	}
+
+	if from != 0 || to != 0 {
+		return nil, ErrMalformedPatch
+	}
+
+	for i := 0; i < len(ops); {
+		j := i + 1
+		for j < len(ops) && ops[j] == ops[i] {
+			j++
+		}
+
+		h.Chunks = append(h.Chunks, &chunk{
+			content: strings.Join(texts[i:j], ""),
+			op:      ops[i],
+		})
+
+		i = j
+	}
+
+	return h, nil
+}
+
+// parseHunkHeader parses a hunk header: @@ -l[,s] +l[,s] @@ [section].
+func parseHunkHeader(line string) (*Hunk, error) {
+	fields := strings.SplitN(line[len(hunkPrefix):], " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "+") ||
+		!strings.HasPrefix(line[len(hunkPrefix)+len(fields[0])+len(fields[1])+1:], " @@") {
+		return nil, ErrMalformedPatch
+	}
+
+	h := &Hunk{}
+	var err error
+	if h.FromLine, h.FromCount, err = parseRange(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if h.ToLine, h.ToCount, err = parseRange(fields[1][1:]); err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 3 {
+		h.Section = strings.TrimPrefix(strings.TrimPrefix(fields[2], "@@"), " ")
+	}
+
+	return h, nil
+}
+
+func parseRange(s string) (line, count int, err error) {
+	count = 1
+	if i := strings.IndexByte(s, ','); i != -1 {
+		if count, err = strconv.Atoi(s[i+1:]); err != nil {
+			return 0, 0, ErrMalformedPatch
+		}
+
+		s = s[:i]
+	}
+
+	if line, err = strconv.Atoi(s); err != nil || line < 0 || count < 0 {
+		return 0, 0, ErrMalformedPatch
+	}
+
+	return line, count, nil
+}
+
+// parseGitHeaderNames returns the paths of the diff --git line, without the
+// a/ and b/ prefixes. When the paths are ambiguous, due to spaces, only
+// paths being equal are returned, the rename or copy headers having the
+// actual ones otherwise.
+func parseGitHeaderNames(s string) (from, to string, err error) {
+	if strings.HasPrefix(s, `"`) {
+		end := quotedEnd(s)
+		if end == -1 {
+			return "", "", ErrMalformedPatch
+		}
+
+		if from, err = unquotePath(s[:end]); err != nil {
+			return "", "", err
+		}
+
+		to, err = unquotePath(strings.TrimPrefix(s[end:], " "))
+		return strings.TrimPrefix(from, aDir), strings.TrimPrefix(to, bDir), err
+	}
+
+	if i := strings.Index(s, ` "`); i != -1 {
+		to, err = unquotePath(s[i+1:])
+		return strings.TrimPrefix(s[:i], aDir), strings.TrimPrefix(to, bDir), err
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != ' ' {
+			continue
+		}
+
+		from, to = strings.TrimPrefix(s[:i], aDir), strings.TrimPrefix(s[i+1:], bDir)
+		if from == to {
+			return from, to, nil
+		}
+	}
+
+	return "", "", nil
+}
+
+// quotedEnd returns the index following the closing quote of the quoted
+// string at the start of s, or -1.
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// parsePathLine returns the path of a ---/+++ line without the given prefix,
+// or empty for /dev/null.
+func parsePathLine(line, prefix string) (string, error) {
+	path := line[len(fromPathPrefix):]
+	if !strings.HasPrefix(path, `"`) {
+		if i := strings.IndexByte(path, '\t'); i != -1 {
+			path = path[:i]
+		}
+
+		path = strings.TrimRight(path, " ")
+	}
+
+	path, err := unquotePath(path)
+	if err != nil || path == noFilePath {
+		return "", err
+	}
+
+	return strings.TrimPrefix(path, prefix), nil
+}
+
+func unquotePath(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+
+	end := quotedEnd(s)
+	if end == -1 {
+		return "", ErrMalformedPatch
+	}
+
+	path, err := strconv.Unquote(s[:end])
+	if err != nil {
+		return "", ErrMalformedPatch
+	}
+
+	return path, nil
+}
+
+// stripComponent removes the leading directory of a path, as patch -p1 does.
+func stripComponent(path string) string {
+	if i := strings.IndexByte(path, '/'); i != -1 {
+		return path[i+1:]
+	}
+
+	return path
+}
+
+func hasHeader(line string, headers ...string) bool {
+	for _, h := range headers {
+		if strings.HasPrefix(line, h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headerValue returns the value of an extended header line.
+func headerValue(line string) string {
+	for _, h := range []string{
+		"rename from ", "rename to ", "rename old ", "rename new ",
+		"copy from ", "copy to ", "deleted file mode ", "new file mode ",
+		"old mode ", "new mode ", "index ",
+	} {
+		if strings.HasPrefix(line, h) {
+			return line[len(h):]
+		}
+	}
+
+	return ""
+}
+
+// UnifiedPatch is a Patch decoded by UnifiedDecoder.
+type UnifiedPatch struct {
+	message     string
+	filePatches []*UnifiedFilePatch
+}
+
+// FilePatches returns the patches of the files.
+func (p *UnifiedPatch) FilePatches() []FilePatch {
+	result := make([]FilePatch, len(p.filePatches))
+	for i, fp := range p.filePatches {
+		result[i] = fp
+	}
+
+	return result
+}
+
+// Message returns the text found before the first file patch.
+func (p *UnifiedPatch) Message() string {
+	return p.message
+}
+
+// UnifiedFilePatch is a FilePatch decoded by UnifiedDecoder. Unlike other
+// FilePatches, its Chunks only contain the context lines of the hunks and not
+// the whole files, Hunks returns them along with their positions.
+type UnifiedFilePatch struct {
+	from, to *UnifiedFile
+	binary   bool
+	copy     bool
+	hunks    []*Hunk
+}
+
+// IsBinary returns true if the patch is of a binary file.
+func (p *UnifiedFilePatch) IsBinary() bool {
+	return p.binary
+}
+
+// Files returns the from and to files, from is nil if the file is created
+// and to is nil if it's deleted.
+func (p *UnifiedFilePatch) Files() (from, to File) {
+	if p.from != nil {
+		from = p.from
+	}
+
+	if p.to != nil {
+		to = p.to
+	}
+
+	return
+}
+
+// Chunks returns the chunks of all the hunks.
+func (p *UnifiedFilePatch) Chunks() []Chunk {
+	var chunks []Chunk
+	for _, h := range p.hunks {
+		chunks = append(chunks, h.Chunks...)
+	}
+
+	return chunks
+}
+
+// Hunks returns the hunks of the patch.
+func (p *UnifiedFilePatch) Hunks() []*Hunk {
+	return p.hunks
+}
+
+// IsCopy returns true if the to file is a copy of the from file, instead
+// of a rename of it.
+func (p *UnifiedFilePatch) IsCopy() bool {
+	return p.copy
+}
+
+// parseIndex parses the value of an index header: <from>..<to>[ <mode>].
+func (p *UnifiedFilePatch) parseIndex(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return ErrMalformedPatch
+	}
+
+	hashes := strings.Split(fields[0], "..")
+	if len(hashes) != 2 {
+		return ErrMalformedPatch
+	}
+
+	p.from.hash, p.to.hash = parseHash(hashes[0]), parseHash(hashes[1])
+	if len(fields) == 2 {
+		mode, err := filemode.New(fields[1])
+		if err != nil {
+			return err
+		}
+
+		p.from.mode, p.to.mode = mode, mode
+	}
+
+	return nil
+}
+
+func (p *UnifiedFilePatch) finish(created, deleted bool) (*UnifiedFilePatch, error) {
+	if created && deleted {
+		return nil, ErrMalformedPatch
+	}
+
+	if created {
+		p.to.path = firstNonEmpty(p.to.path, p.from.path)
+		p.from = nil
+	}
+
+	if deleted {
+		p.from.path = firstNonEmpty(p.from.path, p.to.path)
+		p.to = nil
+	}
+
+	if p.from != nil && p.from.path == "" || p.to != nil && p.to.path == "" {
+		return nil, ErrMalformedPatch
+	}
+
+	return p, nil
+}
+
+func firstNonEmpty(a, b string) string {
+	if a != "" {
+		return a
+	}
+
+	return b
+}
+
+// parseHash returns the given hash, or the zero hash if it's abbreviated.
+func parseHash(s string) plumbing.Hash {
+	if len(s) != 40 {
+		return plumbing.ZeroHash
+	}
+
+	return plumbing.NewHash(s)
+}
+
+// UnifiedFile is a File of a UnifiedFilePatch. Its mode is zero when the
+// patch doesn't contain it.
+type UnifiedFile struct {
+	path string
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+// Hash returns the hash of the file, the zero hash if the patch doesn't
+// contain it or it's abbreviated.
+func (f *UnifiedFile) Hash() plumbing.Hash {
+	return f.hash
+}
+
+// Mode returns the mode of the file.
+func (f *UnifiedFile) Mode() filemode.FileMode {
+	return f.mode
+}
+
+// Path returns the path of the file.
+func (f *UnifiedFile) Path() string {
+	return f.path
+}
+
+// Hunk is a group of changes of a FilePatch along with their surrounding
+// context lines, as found in a unified diff.
+type Hunk struct {
+	// FromLine and FromCount are the first line and the number of lines of
+	// the hunk at the from file. FromLine is the line preceding the hunk
+	// when FromCount is zero.
+	FromLine, FromCount int
+	// ToLine and ToCount are the first line and the number of lines of the
+	// hunk at the to file.
+	ToLine, ToCount int
+	// Section is the text following the line numbers of the hunk header,
+	// usually the function containing the hunk.
+	Section string
+	// Chunks are the lines of the hunk, ending in a new line unless they are
+	// the last line of a file without a final new line.
+	Chunks []Chunk
+}
+
+type chunk struct {
+	content string
+	op      Operation
+}
+
+func (c *chunk) Content() string {
+	return c.content
+}
+
+func (c *chunk) Type() Operation {
+	return c.op
+}
@@ -0,0 +1,223 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+
+	. "gopkg.in/check.v1"
+)
+
+type UnifiedDecoderTestSuite struct{}
+
+var _ = Suite(&UnifiedDecoderTestSuite{})
+
+// gitPatch is the output of git diff --cached -M.
+const gitPatch = `diff --git a/bar b/bar
+deleted file mode 100644
+index 5716ca5..0000000
+--- a/bar
++++ /dev/null
+@@ -1 +0,0 @@
+-bar
+diff --git a/foo b/foo
+index f00c965..700e820 100644
+--- a/foo
++++ b/foo
+@@ -1,10 +1,10 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+ 7
+ 8
+ 9
+-10
++ten
+\ No newline at end of file
+diff --git a/new b/new
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/new
+@@ -0,0 +1 @@
++new
+diff --git a/q ux b/q ux
+old mode 100644
+new mode 100755
+diff --git a/old b/renamed
+similarity index 83%
+rename from old
+rename to renamed
+index 0fdf397..e0318ee 100644
+--- a/old
++++ b/renamed
+@@ -3,4 +3,4 @@ b
+ c
+ d
+ e
+-f
++F
+`
+
+func (s *UnifiedDecoderTestSuite) decode(c *C, patch string) *UnifiedPatch {
+	p, err := NewUnifiedDecoder(strings.NewReader(patch)).Decode()
+	c.Assert(err, IsNil)
+	return p.(*UnifiedPatch)
+}
+
+func (s *UnifiedDecoderTestSuite) assertChunks(c *C, chunks []Chunk, expected ...interface{}) {
+	c.Assert(chunks, HasLen, len(expected)/2)
+	for i, chunk := range chunks {
+		c.Assert(chunk.Type(), Equals, expected[i*2], Commentf("chunk %d", i))
+		c.Assert(chunk.Content(), Equals, expected[i*2+1], Commentf("chunk %d", i))
+	}
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecode(c *C) {
+	p := s.decode(c, gitPatch)
+	c.Assert(p.Message(), Equals, "")
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 5)
+
+	from, to := fps[0].Files()
+	c.Assert(from.Path(), Equals, "bar")
+	c.Assert(from.Mode(), Equals, filemode.Regular)
+	c.Assert(to, IsNil)
+	s.assertChunks(c, fps[0].Chunks(), Delete, "bar\n")
+
+	from, to = fps[1].Files()
+	c.Assert(from.Path(), Equals, "foo")
+	c.Assert(to.Path(), Equals, "foo")
+	c.Assert(to.Mode(), Equals, filemode.Regular)
+	c.Assert(from.Hash(), Equals, plumbing.ZeroHash)
+
+	hunks := fps[1].(*UnifiedFilePatch).Hunks()
+	c.Assert(hunks, HasLen, 1)
+	c.Assert(hunks[0].FromLine, Equals, 1)
+	c.Assert(hunks[0].FromCount, Equals, 10)
+	c.Assert(hunks[0].ToLine, Equals, 1)
+	c.Assert(hunks[0].ToCount, Equals, 10)
+	s.assertChunks(c, hunks[0].Chunks,
+		Equal, "1\n2\n",
+		Delete, "3\n",
+		Add, "three\n",
+		Equal, "4\n5\n6\n7\n8\n9\n",
+		Delete, "10\n",
+		Add, "ten",
+	)
+
+	from, to = fps[2].Files()
+	c.Assert(from, IsNil)
+	c.Assert(to.Path(), Equals, "new")
+	hunks = fps[2].(*UnifiedFilePatch).Hunks()
+	c.Assert(hunks[0].FromLine, Equals, 0)
+	c.Assert(hunks[0].FromCount, Equals, 0)
+	s.assertChunks(c, hunks[0].Chunks, Add, "new\n")
+
+	from, to = fps[3].Files()
+	c.Assert(from.Path(), Equals, "q ux")
+	c.Assert(to.Path(), Equals, "q ux")
+	c.Assert(from.Mode(), Equals, filemode.Regular)
+	c.Assert(to.Mode(), Equals, filemode.Executable)
+	c.Assert(fps[3].Chunks(), HasLen, 0)
+
+	from, to = fps[4].Files()
+	c.Assert(from.Path(), Equals, "old")
+	c.Assert(to.Path(), Equals, "renamed")
+	c.Assert(fps[4].(*UnifiedFilePatch).IsCopy(), Equals, false)
+	hunks = fps[4].(*UnifiedFilePatch).Hunks()
+	c.Assert(hunks[0].FromLine, Equals, 3)
+	c.Assert(hunks[0].Section, Equals, "b")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeMessage(c *C) {
+	p := s.decode(c, "Subject: foo\n\nbar\n---\n foo | 1 +\n\n"+
+		"diff --git a/foo b/foo\nindex "+
+		"f00c965f00c965f00c965f00c965f00c965f00c9..700e820700e820700e820700e820700e820700e8"+
+		" 100755\n--- a/foo\n+++ b/foo\n@@ -1 +1 @@\n-a\n+b\n-- \n2.20.1\n")
+
+	c.Assert(p.Message(), Equals, "Subject: foo\n\nbar\n---\n foo | 1 +\n\n")
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 1)
+
+	from, to := fps[0].Files()
+	c.Assert(from.Hash(), Equals, plumbing.NewHash("f00c965f00c965f00c965f00c965f00c965f00c9"))
+	c.Assert(to.Hash(), Equals, plumbing.NewHash("700e820700e820700e820700e820700e820700e8"))
+	c.Assert(to.Mode(), Equals, filemode.Executable)
+	s.assertChunks(c, fps[0].Chunks(), Delete, "a\n", Add, "b\n")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeQuotedPaths(c *C) {
+	p := s.decode(c, "diff --git \"a/f\\too\" \"b/b\\303\\244r\"\n"+
+		"similarity index 100%\nrename from \"f\\too\"\nrename to \"b\\303\\244r\"\n")
+
+	from, to := p.FilePatches()[0].Files()
+	c.Assert(from.Path(), Equals, "f\too")
+	c.Assert(to.Path(), Equals, "bär")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeCopyAndBinary(c *C) {
+	p := s.decode(c, "diff --git a/foo b/bar\nsimilarity index 100%\n"+
+		"copy from foo\ncopy to bar\n"+
+		"diff --git a/img b/img\nindex 1234567..89abcde 100644\n"+
+		"GIT binary patch\nliteral 3\nKcmZ?wKm`B-0RR91\n\nliteral 0\nHcmV?d00001\n\n"+
+		"diff --git a/baz b/baz\nindex 1234567..89abcde 100644\n"+
+		"Binary files a/baz and b/baz differ\n")
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 3)
+	c.Assert(fps[0].(*UnifiedFilePatch).IsCopy(), Equals, true)
+	c.Assert(fps[0].IsBinary(), Equals, false)
+	c.Assert(fps[1].IsBinary(), Equals, true)
+	c.Assert(fps[2].IsBinary(), Equals, true)
+
+	from, _ := fps[2].Files()
+	c.Assert(from.Path(), Equals, "baz")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodePlain(c *C) {
+	p := s.decode(c, "--- foo.orig/src/foo.c\t2020-01-01 00:00:00\n"+
+		"+++ foo/src/foo.c\t2020-01-02 00:00:00\n"+
+		"@@ -1,2 +1,2 @@\n a\n-b\n+c\n"+
+		"--- /dev/null\n+++ foo/bar\n@@ -0,0 +1 @@\n+bar\n")
+
+	fps := p.FilePatches()
+	c.Assert(fps, HasLen, 2)
+
+	from, to := fps[0].Files()
+	c.Assert(from.Path(), Equals, "src/foo.c")
+	c.Assert(to.Path(), Equals, "src/foo.c")
+	c.Assert(to.Mode(), Equals, filemode.Empty)
+	s.assertChunks(c, fps[0].Chunks(), Equal, "a\n", Delete, "b\n", Add, "c\n")
+
+	from, to = fps[1].Files()
+	c.Assert(from, IsNil)
+	c.Assert(to.Path(), Equals, "bar")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeEmptyContextLine(c *C) {
+	p := s.decode(c, "diff --git a/foo b/foo\n--- a/foo\n+++ b/foo\n@@ -1,3 +1,3 @@\n a\n\n-b\n+c\n")
+	s.assertChunks(c, p.FilePatches()[0].Chunks(), Equal, "a\n\n", Delete, "b\n", Add, "c\n")
+}
+
+func (s *UnifiedDecoderTestSuite) TestDecodeMalformed(c *C) {
+	for _, patch := range []string{
+		"diff --git a/foo b/foo\n--- a/foo\n+++ b/foo\n@@ -1,2 +1,2 @@\n a\n",
+		"diff --git a/foo b/foo\n--- a/foo\n+++ b/foo\n@@ -1,2 +1,2 @@\n a\n*b\n",
+		"diff --git a/foo b/foo\n--- a/foo\n+++ b/foo\n@@ -a +1 @@\n a\n",
+		"diff --git a/foo b/foo\n--- a/foo\n+++ b/foo\n@@ -1 +1\n a\n",
+		"diff --git a/foo b/bar\nold mode 100644\nnew mode 100755\n",
+		"diff --git a/foo b/foo\nindex \n--- a/foo\n+++ b/foo\n@@ -1 +1 @@\n-a\n+b\n",
+		"diff --git a/foo b/foo\nindex   \n--- a/foo\n+++ b/foo\n@@ -1 +1 @@\n-a\n+b\n",
+	} {
+		_, err := NewUnifiedDecoder(strings.NewReader(patch)).Decode()
+		c.Assert(err, Equals, ErrMalformedPatch, Commentf("patch %q", patch))
+	}
+}
@@ -243,7 +243,7 @@ func (c *hunksGenerator) addLineNumbers(la, lb int, linesBefore int, i int, op O
 	// we need to search for a reference for the next diff
 	switch {
 	case linesBefore != 0 && c.ctxLines != 0:
-		clb = lb - c.ctxLines + 1
+		clb = lb - linesBefore + 1
 	case c.ctxLines == 0:
 		clb = lb - c.ctxLines
 	case i != len(c.chunks)-1:
@@ -296,6 +296,41 @@ index 30d74d258442c7c65512eafab474568dd706c430..d606037cb232bfda7788a8322492312d
 -test
 +test2
 `,
+}, {
+	patch: testPatch{
+		message: "",
+		filePatches: []testFilePatch{{
+			from: &testFile{
+				mode: filemode.Regular,
+				path: "test.txt",
+				seed: "test\n",
+			},
+			to: &testFile{
+				mode: filemode.Regular,
+				path: "test.txt",
+				seed: "test\ntest2\n",
+			},
+
+			chunks: []testChunk{{
+				content: "test\n",
+				op:      Equal,
+			}, {
+				content: "test2\n",
+				op:      Add,
+			}},
+		}},
+	},
+
+	desc:    "one line added with less context lines than the default",
+	context: DefaultContextLines,
+	diff: `diff --git a/test.txt b/test.txt
+index 9daeafb9864cf43055ae93beb0afd6c7d144bfa4..b02def2d0ff040b219b32ff5611e164f7252bc9f 100644
+--- a/test.txt
++++ b/test.txt
+@@ -1 +1,2 @@
+ test
++test2
+`,
 }, {
 	patch: testPatch{
 		message: "this is the message\n",
@@ -0,0 +1,192 @@
+package gitattributes
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	commentPrefix = "#"
+	macroPrefix   = "[attr]"
+	unsetPrefix   = "-"
+	unspecPrefix  = "!"
+	valueSep      = "="
+)
+
+// State is the state of an attribute for a path.
+type State int
+
+const (
+	// Unspecified is the state of an attribute not listed for a path, or
+	// listed with the "!" prefix.
+	Unspecified State = iota
+	// Set is the state of an attribute listed by its name.
+	Set
+	// Unset is the state of an attribute listed with the "-" prefix.
+	Unset
+	// SetToValue is the state of an attribute listed as name=value.
+	SetToValue
+)
+
+// Attribute is an attribute and its state.
+type Attribute struct {
+	Name  string
+	State State
+	// Value is the value of an attribute set to a value.
+	Value string
+}
+
+// IsSet reports whether the attribute is set.
+func (a Attribute) IsSet() bool { return a.State == Set }
+
+// IsUnset reports whether the attribute is unset.
+func (a Attribute) IsUnset() bool { return a.State == Unset }
+
+// IsUnspecified reports whether the attribute is unspecified.
+func (a Attribute) IsUnspecified() bool { return a.State == Unspecified }
+
+// IsValueSet reports whether the attribute is set to a value.
+func (a Attribute) IsValueSet() bool { return a.State == SetToValue }
+
+// String returns the state of the attribute as `git check-attr` prints it,
+// set, unset, unspecified or the value.
+func (a Attribute) String() string {
+	switch a.State {
+	case Set:
+		return "set"
+	case Unset:
+		return "unset"
+	case SetToValue:
+		return a.Value
+	default:
+		return "unspecified"
+	}
+}
+
+// MatchAttribute is a line of a gitattributes file, the attributes of the
+// paths matching a pattern, or the definition of a macro attribute.
+type MatchAttribute struct {
+	// Name is the name of the macro attribute defined, empty for a pattern.
+	Name string
+	// Pattern is the pattern of the paths, nil for a macro definition.
+	Pattern    Pattern
+	Attributes []Attribute
+}
+
+// ParseAttributes parses the lines of a gitattributes file in the given
+// domain, the path of the directory of the file. Macro definitions are only
+// parsed if allowMacro is true, as in git the invalid lines and attributes are
+// ignored.
+func ParseAttributes(r io.Reader, domain []string, allowMacro bool) ([]MatchAttribute, error) {
+	var attrs []MatchAttribute
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		pattern, fields, ok := splitLine(s.Text())
+		if !ok {
+			continue
+		}
+
+		ma := MatchAttribute{}
+		switch {
+		case strings.HasPrefix(pattern, macroPrefix):
+			ma.Name = strings.TrimPrefix(pattern, macroPrefix)
+			if !allowMacro || !validName(ma.Name) {
+				continue
+			}
+		case strings.HasPrefix(pattern, unspecPrefix):
+			// negative patterns are forbidden
+			continue
+		default:
+			ma.Pattern = ParsePattern(pattern, domain)
+		}
+
+		for _, field := range fields {
+			if a, ok := parseAttribute(field); ok {
+				ma.Attributes = append(ma.Attributes, a)
+			}
+		}
+
+		attrs = append(attrs, ma)
+	}
+
+	return attrs, s.Err()
+}
+
+// splitLine splits a line into its pattern, unquoted if quoted, and its
+// attributes, ok is false for blank lines and comments.
+func splitLine(line string) (pattern string, fields []string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, commentPrefix) {
+		return "", nil, false
+	}
+
+	if strings.HasPrefix(line, `"`) {
+		end := closingQuote(line)
+		if end < 0 {
+			return "", nil, false
+		}
+
+		unquoted, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return "", nil, false
+		}
+
+		return unquoted, strings.Fields(line[end+1:]), true
+	}
+
+	fields = strings.Fields(line)
+	return fields[0], fields[1:], true
+}
+
+// closingQuote returns the index of the quote closing the quoted string at
+// the start of s, -1 if it isn't closed.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
+
+func parseAttribute(field string) (Attribute, bool) {
+	var a Attribute
+	switch {
+	case strings.HasPrefix(field, unsetPrefix):
+		a = Attribute{Name: field[1:], State: Unset}
+	case strings.HasPrefix(field, unspecPrefix):
+		a = Attribute{Name: field[1:], State: Unspecified}
+	case strings.Contains(field, valueSep):
+		i := strings.Index(field, valueSep)
+		a = Attribute{Name: field[:i], State: SetToValue, Value: field[i+1:]}
+	default:
+		a = Attribute{Name: field, State: Set}
+	}
+
+	return a, validName(a.Name)
+}
+
+// validName reports whether the name of an attribute is valid, made of
+// letters, digits, dashes, dots and underscores, not starting with a dash.
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(name, unsetPrefix) {
+		return false
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,65 @@
+package gitattributes
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type AttributesSuite struct{}
+
+var _ = Suite(&AttributesSuite{})
+
+func (s *AttributesSuite) TestParseAttributes(c *C) {
+	lines := strings.Join([]string{
+		"# comment",
+		"",
+		"  *.go text eol=lf -diff !merge",
+		`"with space.txt" linguist-generated`,
+		"!negated text",
+		"*.bin invalid/name binary",
+	}, "\n")
+
+	attrs, err := ParseAttributes(strings.NewReader(lines), []string{"sub"}, false)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 3)
+
+	c.Assert(attrs[0].Name, Equals, "")
+	c.Assert(attrs[0].Pattern.Match([]string{"sub", "main.go"}, false), Equals, true)
+	c.Assert(attrs[0].Attributes, DeepEquals, []Attribute{
+		{Name: "text", State: Set},
+		{Name: "eol", State: SetToValue, Value: "lf"},
+		{Name: "diff", State: Unset},
+		{Name: "merge", State: Unspecified},
+	})
+
+	c.Assert(attrs[1].Pattern.Match([]string{"sub", "with space.txt"}, false), Equals, true)
+	c.Assert(attrs[1].Attributes, DeepEquals, []Attribute{{Name: "linguist-generated", State: Set}})
+
+	c.Assert(attrs[2].Attributes, DeepEquals, []Attribute{{Name: "binary", State: Set}})
+}
+
+func (s *AttributesSuite) TestParseAttributesMacro(c *C) {
+	lines := "[attr]generated linguist-generated -diff\n*.pb.go generated\n"
+
+	attrs, err := ParseAttributes(strings.NewReader(lines), nil, false)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 1)
+
+	attrs, err = ParseAttributes(strings.NewReader(lines), nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 2)
+	c.Assert(attrs[0].Name, Equals, "generated")
+	c.Assert(attrs[0].Pattern, IsNil)
+	c.Assert(attrs[0].Attributes, DeepEquals, []Attribute{
+		{Name: "linguist-generated", State: Set},
+		{Name: "diff", State: Unset},
+	})
+}
+
+func (s *AttributesSuite) TestAttributeString(c *C) {
+	c.Assert(Attribute{Name: "text", State: Set}.String(), Equals, "set")
+	c.Assert(Attribute{Name: "text", State: Unset}.String(), Equals, "unset")
+	c.Assert(Attribute{Name: "text"}.String(), Equals, "unspecified")
+	c.Assert(Attribute{Name: "eol", State: SetToValue, Value: "crlf"}.String(), Equals, "crlf")
+}
@@ -0,0 +1,141 @@
+package gitattributes
+
+import (
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/config"
+	gioutil "github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+)
+
+const (
+	coreSection       = "core"
+	attributesfile    = "attributesfile"
+	gitDir            = ".git"
+	gitattributesFile = ".gitattributes"
+	gitconfigFile     = ".gitconfig"
+	xdgAttributesFile = ".config/git/attributes"
+	systemFile        = "/etc/gitattributes"
+	homePrefix        = "~/"
+)
+
+// ReadAttributesFile reads the lines of a gitattributes file, the name is
+// relative to the path, the domain of its patterns. Macro definitions are
+// only read if allowMacro is true. If the file doesn't exist nil is returned.
+func ReadAttributesFile(fs billy.Filesystem, path []string, name string, allowMacro bool) (attrs []MatchAttribute, err error) {
+	f, err := fs.Open(fs.Join(append(path, name)...))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer gioutil.CheckClose(f, &err)
+	return ParseAttributes(f, path, allowMacro)
+}
+
+// ReadPatterns reads the .gitattributes files recursively traversing through
+// the directory structure, macros are only read from the top-level one. The
+// result is in the ascending order of priority (last higher).
+func ReadPatterns(fs billy.Filesystem, path []string) (attrs []MatchAttribute, err error) {
+	attrs, err = ReadAttributesFile(fs, path, gitattributesFile, len(path) == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := fs.ReadDir(fs.Join(path...))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() || fi.Name() == gitDir {
+			continue
+		}
+
+		subattrs, err := ReadPatterns(fs, append(path[:len(path):len(path)], fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		attrs = append(attrs, subattrs...)
+	}
+
+	return attrs, nil
+}
+
+// LoadGlobalPatterns loads the attributes of the file declared by the
+// core.attributesFile property of the user's ~/.gitconfig file, by default
+// ~/.config/git/attributes. If the file doesn't exist the function returns
+// nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadGlobalPatterns(fs billy.Filesystem) (attrs []MatchAttribute, err error) {
+	usr, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := configAttributesFile(fs, fs.Join(usr.HomeDir, gitconfigFile))
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = fs.Join(usr.HomeDir, xdgAttributesFile)
+	}
+
+	return LoadAttributesFile(fs, name)
+}
+
+// LoadSystemPatterns loads the attributes of the system's /etc/gitattributes
+// file. If the file doesn't exist the function returns nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadSystemPatterns(fs billy.Filesystem) ([]MatchAttribute, error) {
+	return ReadAttributesFile(fs, nil, systemFile, true)
+}
+
+// LoadAttributesFile loads the attributes of a global attributes file, as
+// the one declared by a core.attributesFile property, a leading ~/ is the
+// user's home directory. If the file doesn't exist the function returns nil.
+//
+// The function assumes fs is rooted at the root filesystem.
+func LoadAttributesFile(fs billy.Filesystem, name string) ([]MatchAttribute, error) {
+	if strings.HasPrefix(name, homePrefix) {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		name = fs.Join(usr.HomeDir, strings.TrimPrefix(name, homePrefix))
+	}
+
+	return ReadAttributesFile(fs, nil, name, true)
+}
+
+// configAttributesFile returns the core.attributesFile property of the given
+// config file, empty if the file or the property don't exist.
+func configAttributesFile(fs billy.Filesystem, path string) (name string, err error) {
+	f, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	defer gioutil.CheckClose(f, &err)
+
+	raw := config.New()
+	if err := config.NewDecoder(f).Decode(raw); err != nil {
+		return "", err
+	}
+
+	return raw.Section(coreSection).Options.Get(attributesfile), nil
+}
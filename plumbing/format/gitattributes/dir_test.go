@@ -0,0 +1,88 @@
+package gitattributes
+
+import (
+	"os/user"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+type DirSuite struct{}
+
+var _ = Suite(&DirSuite{})
+
+func writeFiles(c *C, files map[string]string) billy.Filesystem {
+	fs := memfs.New()
+	for name, content := range files {
+		c.Assert(util.WriteFile(fs, name, []byte(content), 0644), IsNil)
+	}
+
+	return fs
+}
+
+func (s *DirSuite) TestReadPatterns(c *C) {
+	fs := writeFiles(c, map[string]string{
+		".gitattributes":            "[attr]generated linguist-generated\n*.go text\n",
+		"vendor/.gitattributes":     "[attr]ignored -text\n*.go -text\n",
+		"vendor/foo/bar.go":         "",
+		".git/info/attributes":      "*.go eol=lf\n",
+		"vendor/foo/.gitattributes": "*.go generated\n",
+	})
+
+	attrs, err := ReadPatterns(fs, nil)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 4)
+	c.Assert(attrs[0].Name, Equals, "generated")
+
+	info, err := ReadAttributesFile(fs, nil, ".git/info/attributes", true)
+	c.Assert(err, IsNil)
+
+	m := NewMatcher(append(attrs, info...))
+	result, _ := m.Match([]string{"vendor", "foo", "bar.go"}, false, nil)
+	c.Assert(result, DeepEquals, map[string]Attribute{
+		"text":               {Name: "text", State: Unset},
+		"eol":                {Name: "eol", State: SetToValue, Value: "lf"},
+		"generated":          {Name: "generated", State: Set},
+		"linguist-generated": {Name: "linguist-generated", State: Set},
+	})
+
+	result, _ = m.Match([]string{"main.go"}, false, nil)
+	c.Assert(result["text"].IsSet(), Equals, true)
+}
+
+func (s *DirSuite) TestLoadGlobalPatterns(c *C) {
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+
+	fs := writeFiles(c, map[string]string{
+		usr.HomeDir + "/.gitconfig":             "[core]\n\tattributesFile = ~/.attributes\n",
+		usr.HomeDir + "/.attributes":            "*.png binary\n",
+		usr.HomeDir + "/.config/git/attributes": "*.jpg binary\n",
+	})
+
+	attrs, err := LoadGlobalPatterns(fs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs[0].Pattern.Match([]string{"logo.png"}, false), Equals, true)
+
+	c.Assert(fs.Remove(usr.HomeDir+"/.gitconfig"), IsNil)
+	attrs, err = LoadGlobalPatterns(fs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs[0].Pattern.Match([]string{"logo.jpg"}, false), Equals, true)
+
+	attrs, err = LoadGlobalPatterns(memfs.New())
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 0)
+}
+
+func (s *DirSuite) TestLoadSystemPatterns(c *C) {
+	fs := writeFiles(c, map[string]string{systemFile: "[attr]generated -diff\n"})
+
+	attrs, err := LoadSystemPatterns(fs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, HasLen, 1)
+	c.Assert(attrs[0].Name, Equals, "generated")
+}
@@ -0,0 +1,42 @@
+// Package gitattributes implements the gitattributes files, their parsing and
+// the matching of paths to their attributes, the equivalent of
+// `git check-attr`. The files are read from every level of the worktree, from
+// the info/attributes file of the repository, and from the global and system
+// attributes files, in the order of their priorities. The format is specified
+// in the original gitattributes documentation, summarized below:
+//
+//   Format
+//   ======
+//
+//		- Each line is a pattern followed by a list of attributes separated
+//		  by white spaces. Leading and trailing white spaces are ignored, as
+//		  well as the lines starting with #. A pattern starting with a quote
+//		  is read in the C style.
+//
+//		- The patterns follow the rules of gitignore, except that negative
+//		  patterns are forbidden and patterns matching a directory don't
+//		  match the paths inside it.
+//
+//		- Each attribute is in one of these states for a path:
+//
+//		  Set: the attribute is listed by its name, as in "text".
+//
+//		  Unset: the attribute is listed with a dash prefix, as in "-text".
+//
+//		  Set to a value: the attribute is listed as name=value, as in
+//		  "eol=lf".
+//
+//		  Unspecified: no pattern matches the path, or the attribute is
+//		  listed with an exclamation mark prefix, as in "!text".
+//
+//		- When more than one pattern matches a path, a later line overrides
+//		  an earlier line, the .gitattributes files of the deeper directories
+//		  override the ones of their parents, info/attributes overrides them
+//		  all, and the global and system files have the lowest priorities.
+//
+//		- Macro attributes are defined with "[attr]name" instead of a
+//		  pattern, followed by the attributes they set, only in the top-level
+//		  .gitattributes, info/attributes and the global and system files.
+//		  When a macro attribute is set for a path, its attributes are set as
+//		  well. The built-in "binary" macro is "-diff -merge -text".
+package gitattributes
@@ -0,0 +1,88 @@
+package gitattributes
+
+// binaryMacro is the built-in macro attribute "binary".
+var binaryMacro = []Attribute{
+	{Name: "diff", State: Unset},
+	{Name: "merge", State: Unset},
+	{Name: "text", State: Unset},
+}
+
+// Matcher defines a matcher of the attributes of the paths.
+type Matcher interface {
+	// Match returns the attributes of the path that aren't unspecified, with
+	// the macro attributes expanded, only the given attributes if any is
+	// given. matched reports whether any pattern matches the path.
+	Match(path []string, isDir bool, attributes []string) (attrs map[string]Attribute, matched bool)
+}
+
+// NewMatcher constructs a new matcher. The lines must be given in the order of
+// increasing priority, the system and global files first, then the
+// .gitattributes files from the top-level one down the tree, and finally
+// info/attributes.
+func NewMatcher(stack []MatchAttribute) Matcher {
+	m := &matcher{macros: map[string][]Attribute{"binary": binaryMacro}}
+	for _, ma := range stack {
+		if ma.Pattern == nil {
+			m.macros[ma.Name] = ma.Attributes
+			continue
+		}
+
+		m.stack = append(m.stack, ma)
+	}
+
+	return m
+}
+
+type matcher struct {
+	stack  []MatchAttribute
+	macros map[string][]Attribute
+}
+
+func (m *matcher) Match(path []string, isDir bool, attributes []string) (map[string]Attribute, bool) {
+	states := make(map[string]Attribute)
+	var matched bool
+	for i := len(m.stack) - 1; i >= 0; i-- {
+		if !m.stack[i].Pattern.Match(path, isDir) {
+			continue
+		}
+
+		matched = true
+		m.fill(states, m.stack[i].Attributes)
+	}
+
+	attrs := make(map[string]Attribute)
+	for name, a := range states {
+		if a.State != Unspecified {
+			attrs[name] = a
+		}
+	}
+
+	if len(attributes) == 0 {
+		return attrs, matched
+	}
+
+	filtered := make(map[string]Attribute)
+	for _, name := range attributes {
+		if a, ok := attrs[name]; ok {
+			filtered[name] = a
+		}
+	}
+
+	return filtered, matched
+}
+
+// fill sets the states of the attributes not set by a line of higher priority,
+// the last attributes of a line first, expanding the macros that are set.
+func (m *matcher) fill(states map[string]Attribute, attrs []Attribute) {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		a := attrs[i]
+		if _, ok := states[a.Name]; ok {
+			continue
+		}
+
+		states[a.Name] = a
+		if macro, ok := m.macros[a.Name]; ok && a.State == Set {
+			m.fill(states, macro)
+		}
+	}
+}
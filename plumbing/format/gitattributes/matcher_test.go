@@ -0,0 +1,88 @@
+package gitattributes
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type MatcherSuite struct{}
+
+var _ = Suite(&MatcherSuite{})
+
+func parseLines(c *C, domain []string, allowMacro bool, lines ...string) []MatchAttribute {
+	attrs, err := ParseAttributes(strings.NewReader(strings.Join(lines, "\n")), domain, allowMacro)
+	c.Assert(err, IsNil)
+	return attrs
+}
+
+func (s *MatcherSuite) TestMatch(c *C) {
+	var stack []MatchAttribute
+	stack = append(stack, parseLines(c, nil, true,
+		"*.go text diff=golang",
+		"*_test.go -text",
+		"*.go !diff",
+	)...)
+	stack = append(stack, parseLines(c, []string{"vendor"}, false,
+		"* linguist-vendored",
+	)...)
+
+	m := NewMatcher(stack)
+	attrs, matched := m.Match([]string{"main.go"}, false, nil)
+	c.Assert(matched, Equals, true)
+	c.Assert(attrs, DeepEquals, map[string]Attribute{
+		"text": {Name: "text", State: Set},
+	})
+
+	attrs, _ = m.Match([]string{"vendor", "lib_test.go"}, false, nil)
+	c.Assert(attrs, DeepEquals, map[string]Attribute{
+		"text":              {Name: "text", State: Unset},
+		"linguist-vendored": {Name: "linguist-vendored", State: Set},
+	})
+
+	attrs, _ = m.Match([]string{"vendor", "lib_test.go"}, false, []string{"linguist-vendored", "eol"})
+	c.Assert(attrs, DeepEquals, map[string]Attribute{
+		"linguist-vendored": {Name: "linguist-vendored", State: Set},
+	})
+
+	attrs, matched = m.Match([]string{"README"}, false, nil)
+	c.Assert(matched, Equals, false)
+	c.Assert(attrs, HasLen, 0)
+}
+
+func (s *MatcherSuite) TestMatchMacro(c *C) {
+	stack := parseLines(c, nil, true,
+		"[attr]generated linguist-generated -diff",
+		"*.pb.go generated",
+		"*.png binary",
+		"*.svg binary diff",
+		"*.gif -binary",
+	)
+
+	m := NewMatcher(stack)
+	attrs, _ := m.Match([]string{"api.pb.go"}, false, nil)
+	c.Assert(attrs, DeepEquals, map[string]Attribute{
+		"generated":          {Name: "generated", State: Set},
+		"linguist-generated": {Name: "linguist-generated", State: Set},
+		"diff":               {Name: "diff", State: Unset},
+	})
+
+	attrs, _ = m.Match([]string{"logo.png"}, false, nil)
+	c.Assert(attrs, DeepEquals, map[string]Attribute{
+		"binary": {Name: "binary", State: Set},
+		"diff":   {Name: "diff", State: Unset},
+		"merge":  {Name: "merge", State: Unset},
+		"text":   {Name: "text", State: Unset},
+	})
+
+	attrs, _ = m.Match([]string{"logo.svg"}, false, []string{"diff", "text"})
+	c.Assert(attrs, DeepEquals, map[string]Attribute{
+		"diff": {Name: "diff", State: Set},
+		"text": {Name: "text", State: Unset},
+	})
+
+	attrs, _ = m.Match([]string{"logo.gif"}, false, nil)
+	c.Assert(attrs, DeepEquals, map[string]Attribute{
+		"binary": {Name: "binary", State: Unset},
+	})
+}
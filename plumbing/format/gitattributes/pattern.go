@@ -0,0 +1,96 @@
+package gitattributes
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	patternDirSep  = "/"
+	zeroToManyDirs = "**"
+)
+
+// Pattern defines a single gitattributes pattern.
+type Pattern interface {
+	// Match reports whether the path matches the pattern.
+	Match(path []string, isDir bool) bool
+}
+
+type pattern struct {
+	domain  []string
+	pattern []string
+	dirOnly bool
+	isGlob  bool
+}
+
+// ParsePattern parses a gitattributes pattern of the given domain, the path
+// of the directory of the gitattributes file.
+func ParsePattern(p string, domain []string) Pattern {
+	res := pattern{domain: domain}
+
+	if strings.HasSuffix(p, patternDirSep) {
+		res.dirOnly = true
+		p = strings.TrimSuffix(p, patternDirSep)
+	}
+
+	if strings.Contains(p, patternDirSep) {
+		res.isGlob = true
+		p = strings.TrimPrefix(p, patternDirSep)
+	}
+
+	res.pattern = strings.Split(p, patternDirSep)
+	return &res
+}
+
+func (p *pattern) Match(path []string, isDir bool) bool {
+	if len(path) <= len(p.domain) {
+		return false
+	}
+
+	for i, e := range p.domain {
+		if path[i] != e {
+			return false
+		}
+	}
+
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	path = path[len(p.domain):]
+	if !p.isGlob {
+		match, err := filepath.Match(p.pattern[0], path[len(path)-1])
+		return err == nil && match
+	}
+
+	return globMatch(p.pattern, path)
+}
+
+// globMatch reports whether the whole path matches the pattern, where "**"
+// matches zero or more directories, and everything inside when trailing.
+func globMatch(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	if pattern[0] == zeroToManyDirs {
+		if len(pattern) == 1 {
+			return len(path) > 0
+		}
+
+		for i := 0; i <= len(path); i++ {
+			if globMatch(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+
+	match, err := filepath.Match(pattern[0], path[0])
+	return err == nil && match && globMatch(pattern[1:], path[1:])
+}
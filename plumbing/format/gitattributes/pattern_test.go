@@ -0,0 +1,62 @@
+package gitattributes
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PatternSuite struct{}
+
+var _ = Suite(&PatternSuite{})
+
+func (s *PatternSuite) TestSimpleMatch(c *C) {
+	p := ParsePattern("*.go", nil)
+	c.Assert(p.Match([]string{"main.go"}, false), Equals, true)
+	c.Assert(p.Match([]string{"cmd", "main.go"}, false), Equals, true)
+	c.Assert(p.Match([]string{"main.go", "foo"}, false), Equals, false)
+	c.Assert(p.Match([]string{"main.c"}, false), Equals, false)
+}
+
+func (s *PatternSuite) TestSimpleMatchDomain(c *C) {
+	p := ParsePattern("*.go", []string{"cmd"})
+	c.Assert(p.Match([]string{"cmd", "main.go"}, false), Equals, true)
+	c.Assert(p.Match([]string{"cmd", "foo", "main.go"}, false), Equals, true)
+	c.Assert(p.Match([]string{"main.go"}, false), Equals, false)
+	c.Assert(p.Match([]string{"cmd"}, true), Equals, false)
+}
+
+func (s *PatternSuite) TestDirOnly(c *C) {
+	p := ParsePattern("vendor/", nil)
+	c.Assert(p.Match([]string{"vendor"}, true), Equals, true)
+	c.Assert(p.Match([]string{"vendor"}, false), Equals, false)
+	c.Assert(p.Match([]string{"vendor", "foo"}, false), Equals, false)
+}
+
+func (s *PatternSuite) TestGlobMatch(c *C) {
+	p := ParsePattern("/docs/*.md", nil)
+	c.Assert(p.Match([]string{"docs", "README.md"}, false), Equals, true)
+	c.Assert(p.Match([]string{"docs", "api", "README.md"}, false), Equals, false)
+	c.Assert(p.Match([]string{"foo", "docs", "README.md"}, false), Equals, false)
+
+	p = ParsePattern("/README.md", []string{"docs"})
+	c.Assert(p.Match([]string{"docs", "README.md"}, false), Equals, true)
+	c.Assert(p.Match([]string{"README.md"}, false), Equals, false)
+}
+
+func (s *PatternSuite) TestGlobMatchZeroToManyDirs(c *C) {
+	p := ParsePattern("**/gen/*.pb.go", nil)
+	c.Assert(p.Match([]string{"gen", "foo.pb.go"}, false), Equals, true)
+	c.Assert(p.Match([]string{"a", "b", "gen", "foo.pb.go"}, false), Equals, true)
+	c.Assert(p.Match([]string{"a", "gen", "x", "foo.pb.go"}, false), Equals, false)
+
+	p = ParsePattern("a/**/b", nil)
+	c.Assert(p.Match([]string{"a", "b"}, false), Equals, true)
+	c.Assert(p.Match([]string{"a", "x", "y", "b"}, false), Equals, true)
+
+	p = ParsePattern("vendor/**", nil)
+	c.Assert(p.Match([]string{"vendor", "foo", "bar.go"}, false), Equals, true)
+	c.Assert(p.Match([]string{"vendor"}, true), Equals, false)
+}
@@ -61,6 +61,35 @@ func (s *IndexSuite) TestEncode(c *C) {
 
 }
 
+func (s *IndexSuite) TestEncodeUnmergedEntries(c *C) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{
+			{Name: "foo", Stage: TheirMode},
+			{Name: "foo", Stage: AncestorMode},
+			{Name: "bar", Stage: Merged},
+			{Name: "foo", Stage: OurMode},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	err := e.Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	d := NewDecoder(buf)
+	err = d.Decode(output)
+	c.Assert(err, IsNil)
+
+	c.Assert(output.Entries, HasLen, 4)
+	c.Assert(output.Entries[0].Name, Equals, "bar")
+	c.Assert(output.Entries[0].Stage, Equals, Merged)
+	c.Assert(output.Entries[1].Stage, Equals, AncestorMode)
+	c.Assert(output.Entries[2].Stage, Equals, OurMode)
+	c.Assert(output.Entries[3].Stage, Equals, TheirMode)
+}
+
 func (s *IndexSuite) TestEncodeUnsuportedVersion(c *C) {
 	idx := &Index{Version: 3}
 
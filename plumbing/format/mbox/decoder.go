@@ -0,0 +1,129 @@
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrMalformedMbox is returned when the input doesn't start with a
+	// From_ line.
+	ErrMalformedMbox = errors.New("malformed mbox")
+)
+
+// A Decoder reads and decodes mbox messages from an input stream.
+type Decoder struct {
+	r    *bufio.Reader
+	next []byte
+	err  error
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next message of the mbox, io.EOF is returned when there
+// are no more messages.
+func (d *Decoder) Decode() (*Message, error) {
+	if d.next == nil {
+		if err := d.readFromLine(); err != nil {
+			return nil, err
+		}
+	}
+
+	m := decodeFromLine(d.next)
+	d.next = nil
+
+	var content bytes.Buffer
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) != 0 && isFromLine(line) {
+			d.next = line
+			break
+		}
+
+		content.Write(line)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the empty line separating the messages isn't part of the content
+	m.Content = content.Bytes()
+	if d.next != nil && bytes.HasSuffix(m.Content, []byte("\n\n")) {
+		m.Content = m.Content[:len(m.Content)-1]
+	}
+
+	return m, nil
+}
+
+// readFromLine reads the first From_ line of the mbox, skipping the leading
+// empty lines.
+func (d *Decoder) readFromLine() error {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return err
+		}
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if !isFromLine(line) {
+			return ErrMalformedMbox
+		}
+
+		d.next = line
+		return nil
+	}
+}
+
+func decodeFromLine(line []byte) *Message {
+	line = bytes.TrimRight(line[len("From "):], "\r\n")
+
+	m := &Message{}
+	if i := bytes.IndexByte(line, ' '); i != -1 {
+		m.From = string(line[:i])
+		m.Date, _ = time.Parse(DateFormat, string(bytes.TrimSpace(line[i+1:])))
+	}
+
+	return m
+}
+
+// isFromLine reports if the line is a From_ line, a line starting with
+// "From " and ending with a date, using the same heuristic as git mailsplit.
+func isFromLine(line []byte) bool {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) < 20 || !bytes.HasPrefix(line, []byte("From ")) {
+		return false
+	}
+
+	colon := bytes.LastIndexByte(line, ':')
+	if colon < len("From ")+4 || colon+3 > len(line) {
+		return false
+	}
+
+	for _, i := range []int{colon - 4, colon - 2, colon - 1, colon + 1, colon + 2} {
+		if line[i] < '0' || line[i] > '9' {
+			return false
+		}
+	}
+
+	year := bytes.TrimSpace(line[colon+3:])
+	if i := bytes.IndexByte(year, ' '); i != -1 {
+		year = year[:i]
+	}
+
+	y, err := strconv.Atoi(string(year))
+	return err == nil && y > 90
+}
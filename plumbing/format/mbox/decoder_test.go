@@ -0,0 +1,80 @@
+package mbox
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MboxSuite struct{}
+
+var _ = Suite(&MboxSuite{})
+
+const mboxFixture = `From 8f0bd5a31f5f5ec2bc3d4e7e5d3e0a1f2b3c4d5e Mon Sep 17 00:00:00 2001
+From: John Doe <john@doe.com>
+Date: Tue, 10 Nov 2009 23:00:00 +0100
+Subject: [PATCH 1/2] foo
+
+From here on, everything is fine.
+---
+ foo | 1 +
+
+From 1d5f4a7e0bd5a31f5f5ec2bc3d4e7e5d3e0a1f2b Mon Sep 17 00:00:00 2001
+From: John Doe <john@doe.com>
+Subject: [PATCH 2/2] bar
+
+bar
+`
+
+func (s *MboxSuite) TestDecode(c *C) {
+	d := NewDecoder(strings.NewReader(mboxFixture))
+
+	m, err := d.Decode()
+	c.Assert(err, IsNil)
+	c.Assert(m.From, Equals, "8f0bd5a31f5f5ec2bc3d4e7e5d3e0a1f2b3c4d5e")
+	c.Assert(m.Date.Equal(PatchDate), Equals, true)
+	c.Assert(string(m.Content), Equals, "From: John Doe <john@doe.com>\n"+
+		"Date: Tue, 10 Nov 2009 23:00:00 +0100\n"+
+		"Subject: [PATCH 1/2] foo\n\n"+
+		"From here on, everything is fine.\n---\n foo | 1 +\n")
+
+	m, err = d.Decode()
+	c.Assert(err, IsNil)
+	c.Assert(m.From, Equals, "1d5f4a7e0bd5a31f5f5ec2bc3d4e7e5d3e0a1f2b")
+	c.Assert(string(m.Content), Equals, "From: John Doe <john@doe.com>\n"+
+		"Subject: [PATCH 2/2] bar\n\nbar\n")
+
+	_, err = d.Decode()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *MboxSuite) TestDecodeNoTrailingNewLine(c *C) {
+	d := NewDecoder(strings.NewReader("\nFrom foo Tue Nov 10 23:00:00 2009\nSubject: foo\n\nfoo"))
+
+	m, err := d.Decode()
+	c.Assert(err, IsNil)
+	c.Assert(m.From, Equals, "foo")
+	c.Assert(m.Date.Equal(time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(string(m.Content), Equals, "Subject: foo\n\nfoo")
+
+	_, err = d.Decode()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *MboxSuite) TestDecodeMalformed(c *C) {
+	_, err := NewDecoder(strings.NewReader("Subject: foo\n\nfoo\n")).Decode()
+	c.Assert(err, Equals, ErrMalformedMbox)
+}
+
+func (s *MboxSuite) TestIsFromLine(c *C) {
+	c.Assert(isFromLine([]byte("From foo Mon Sep 17 00:00:00 2001\n")), Equals, true)
+	c.Assert(isFromLine([]byte("From foo Mon Sep 17 00:00:00 2001\r\n")), Equals, true)
+	c.Assert(isFromLine([]byte("From foo Mon Sep 17 00:00:00 70\n")), Equals, false)
+	c.Assert(isFromLine([]byte("From here on, everything is fine.\n")), Equals, false)
+	c.Assert(isFromLine([]byte("From: John Doe <john@doe.com>\n")), Equals, false)
+}
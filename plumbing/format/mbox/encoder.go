@@ -0,0 +1,54 @@
+package mbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// An Encoder writes mbox messages to an output stream.
+type Encoder struct {
+	w       io.Writer
+	written bool
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the given messages, separated by empty lines. A new line is
+// added to the content of the messages not ending with one.
+func (e *Encoder) Encode(msgs ...*Message) error {
+	for _, m := range msgs {
+		if err := e.encodeMessage(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeMessage(m *Message) error {
+	if e.written {
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	e.written = true
+	_, err := fmt.Fprintf(e.w, "From %s %s\n", m.From, m.Date.Format(DateFormat))
+	if err != nil {
+		return err
+	}
+
+	if _, err := e.w.Write(m.Content); err != nil {
+		return err
+	}
+
+	if !bytes.HasSuffix(m.Content, []byte("\n")) {
+		_, err = io.WriteString(e.w, "\n")
+	}
+
+	return err
+}
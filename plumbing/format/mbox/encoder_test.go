@@ -0,0 +1,40 @@
+package mbox
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *MboxSuite) TestEncode(c *C) {
+	var msgs []*Message
+	d := NewDecoder(strings.NewReader(mboxFixture))
+	for {
+		m, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+		msgs = append(msgs, m)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(msgs...)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, mboxFixture)
+}
+
+func (s *MboxSuite) TestEncodeNoTrailingNewLine(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(&Message{
+		From:    "foo",
+		Date:    PatchDate,
+		Content: []byte("Subject: foo\n\nfoo"),
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, "From foo Mon Sep 17 00:00:00 2001\nSubject: foo\n\nfoo\n")
+}
@@ -0,0 +1,39 @@
+// Package mbox implements encoding and decoding of mbox files, the mailbox
+// format used by git format-patch and git am to store a series of patches.
+//
+// Every message of a mbox starts with a From_ line, followed by the message
+// itself in RFC 2822 format, the messages are separated by empty lines:
+//
+//	"From " <sender> SP <date> LF
+//	<headers> LF
+//	LF
+//	<body> LF
+//	LF
+//	"From " <sender> SP <date> LF
+//	...
+//
+// The lines of the bodies starting with "From " are not quoted, as git does,
+// so only the From_ lines ending with a date are taken as message starts.
+package mbox
+
+import (
+	"time"
+)
+
+// DateFormat is the format of the date of the From_ lines.
+const DateFormat = time.ANSIC
+
+// PatchDate is the fixed date used by git format-patch in the From_ lines,
+// used by tools like file(1) to recognize the output of format-patch.
+var PatchDate = time.Date(2001, time.September, 17, 0, 0, 0, 0, time.UTC)
+
+// Message is a message of a mbox.
+type Message struct {
+	// From is the sender of the From_ line, git format-patch uses the hash
+	// of the formatted commit.
+	From string
+	// Date is the date of the From_ line.
+	Date time.Time
+	// Content is the message, headers and body, as defined by RFC 2822.
+	Content []byte
+}
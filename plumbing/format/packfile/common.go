@@ -9,8 +9,10 @@ package packfile
 import (
 	"bytes"
 	"io"
+	stdioutil "io/ioutil"
 	"sync"
 
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
 	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
 )
@@ -61,6 +63,150 @@ func WritePackfileToObjectStorage(
 	return err
 }
 
+// UpdateObjectStorageFromThinPackfile updates the storer with the objects in
+// the given thin packfile, a packfile whose reference deltas can have as base
+// objects already in the storer instead of in the packfile, like the ones of
+// the bundles with prerequisites. The objects are stored one by one, and the
+// content of the objects of the packfile is kept in memory to resolve the
+// offset deltas.
+func UpdateObjectStorageFromThinPackfile(s storer.EncodedObjectStorer, packfile io.Reader) error {
+	scanner := NewScanner(packfile)
+	_, count, err := scanner.Header()
+	if err != nil {
+		return err
+	}
+
+	byOffset := make(map[int64]plumbing.EncodedObject, count)
+	var pending []*pendingRefDelta
+
+	buf := bytes.NewBuffer(nil)
+	for i := uint32(0); i < count; i++ {
+		h, err := scanner.NextObjectHeader()
+		if err != nil {
+			return err
+		}
+
+		buf.Reset()
+		if _, _, err := scanner.NextObject(buf); err != nil {
+			return err
+		}
+
+		data := append([]byte(nil), buf.Bytes()...)
+		var base plumbing.EncodedObject
+		switch h.Type {
+		case plumbing.OFSDeltaObject:
+			var ok bool
+			if base, ok = byOffset[h.OffsetReference]; !ok {
+				return plumbing.ErrObjectNotFound
+			}
+		case plumbing.REFDeltaObject:
+			base, err = s.EncodedObject(plumbing.AnyObject, h.Reference)
+			if err == plumbing.ErrObjectNotFound {
+				pending = append(pending, &pendingRefDelta{h.Offset, h.Reference, data})
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		obj, err := storeThinPackfileObject(s, h.Type, base, data)
+		if err != nil {
+			return err
+		}
+
+		byOffset[h.Offset] = obj
+	}
+
+	if _, err := scanner.Checksum(); err != nil && err != io.EOF {
+		return err
+	}
+
+	return resolvePendingRefDeltas(s, pending, byOffset)
+}
+
+type pendingRefDelta struct {
+	offset int64
+	base   plumbing.Hash
+	delta  []byte
+}
+
+// resolvePendingRefDeltas resolves the reference deltas whose base was after
+// them in the packfile.
+func resolvePendingRefDeltas(s storer.EncodedObjectStorer,
+	pending []*pendingRefDelta, byOffset map[int64]plumbing.EncodedObject) error {
+	for len(pending) != 0 {
+		var unresolved []*pendingRefDelta
+		for _, p := range pending {
+			base, err := s.EncodedObject(plumbing.AnyObject, p.base)
+			if err == plumbing.ErrObjectNotFound {
+				unresolved = append(unresolved, p)
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			obj, err := storeThinPackfileObject(s, plumbing.REFDeltaObject, base, p.delta)
+			if err != nil {
+				return err
+			}
+
+			byOffset[p.offset] = obj
+		}
+
+		if len(unresolved) == len(pending) {
+			return ErrReferenceDeltaNotFound
+		}
+
+		pending = unresolved
+	}
+
+	return nil
+}
+
+// storeThinPackfileObject stores an object of a packfile, applying the delta
+// in data to the base object if t is a delta type.
+func storeThinPackfileObject(s storer.EncodedObjectStorer, t plumbing.ObjectType,
+	base plumbing.EncodedObject, data []byte) (plumbing.EncodedObject, error) {
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(t)
+	if t.IsDelta() {
+		baseContent, err := objectContent(base)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = PatchDelta(baseContent, data); err != nil {
+			return nil, err
+		}
+
+		obj.SetType(base.Type())
+	}
+
+	if _, err := obj.Write(data); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.SetEncodedObject(obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+func objectContent(o plumbing.EncodedObject) (content []byte, err error) {
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return stdioutil.ReadAll(r)
+}
+
 var bufPool = sync.Pool{
 	New: func() interface{} {
 		return bytes.NewBuffer(nil)
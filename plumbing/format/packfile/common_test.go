@@ -7,9 +7,11 @@ Sniperkit-Bot
 package packfile
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
 
 	. "gopkg.in/check.v1"
 )
@@ -40,3 +42,58 @@ func genBytes(elements []piece) []byte {
 
 	return result
 }
+
+type CommonSuite struct{}
+
+var _ = Suite(&CommonSuite{})
+
+// thinPackfile returns a packfile with a chain of reference deltas of the
+// targets, the base of the first one, src, is not in the packfile.
+func thinPackfile(c *C, src plumbing.EncodedObject, targets ...plumbing.EncodedObject) *bytes.Buffer {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, memory.NewStorage(), true)
+
+	base := newObjectToPack(src)
+	base.Offset = 2 // marked as written, so it isn't included
+
+	var objects []*ObjectToPack
+	for _, target := range targets {
+		delta, err := GetDelta(src, target)
+		c.Assert(err, IsNil)
+
+		base = newDeltaObjectToPack(base, target, delta)
+		objects = append(objects, base)
+		src = target
+	}
+
+	_, err := enc.encode(objects)
+	c.Assert(err, IsNil)
+	return buf
+}
+
+func (s *CommonSuite) TestUpdateObjectStorageFromThinPackfile(c *C) {
+	src := newObject(plumbing.BlobObject, []byte("0"))
+	target := newObject(plumbing.BlobObject, []byte("01"))
+	other := newObject(plumbing.BlobObject, []byte("011111"))
+
+	store := memory.NewStorage()
+	_, err := store.SetEncodedObject(src)
+	c.Assert(err, IsNil)
+
+	err = UpdateObjectStorageFromThinPackfile(store, thinPackfile(c, src, target, other))
+	c.Assert(err, IsNil)
+
+	for _, expected := range []plumbing.EncodedObject{target, other} {
+		obj, err := store.EncodedObject(plumbing.BlobObject, expected.Hash())
+		c.Assert(err, IsNil)
+		objectsEqual(c, obj, expected)
+	}
+}
+
+func (s *CommonSuite) TestUpdateObjectStorageFromThinPackfileMissingBase(c *C) {
+	src := newObject(plumbing.BlobObject, []byte("0"))
+	target := newObject(plumbing.BlobObject, []byte("01"))
+
+	err := UpdateObjectStorageFromThinPackfile(memory.NewStorage(), thinPackfile(c, src, target))
+	c.Assert(err, Equals, ErrReferenceDeltaNotFound)
+}
@@ -0,0 +1,105 @@
+package reflog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+)
+
+var (
+	// ErrMalformedEntry is returned when a line of the reflog can't be
+	// parsed.
+	ErrMalformedEntry = errors.New("malformed reflog entry")
+)
+
+// A Decoder reads and decodes reflog files from an input stream.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads all the entries of the reflog, oldest first.
+func (d *Decoder) Decode() ([]*Entry, error) {
+	var entries []*Entry
+
+	s := bufio.NewScanner(d.r)
+	for s.Scan() {
+		line := s.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		e, err := decodeEntry(line)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, s.Err()
+}
+
+func decodeEntry(line []byte) (*Entry, error) {
+	const hashes = 2*40 + 2
+	if len(line) < hashes || line[40] != ' ' || line[81] != ' ' {
+		return nil, ErrMalformedEntry
+	}
+
+	e := &Entry{
+		Old: plumbing.NewHash(string(line[:40])),
+		New: plumbing.NewHash(string(line[41:81])),
+	}
+
+	sig := line[hashes:]
+	if tab := bytes.IndexByte(sig, '\t'); tab != -1 {
+		e.Message = string(sig[tab+1:])
+		sig = sig[:tab]
+	}
+
+	open := bytes.LastIndexByte(sig, '<')
+	close := bytes.LastIndexByte(sig, '>')
+	if open == -1 || close < open {
+		return nil, ErrMalformedEntry
+	}
+
+	e.Name = string(bytes.TrimSpace(sig[:open]))
+	e.Email = string(sig[open+1 : close])
+
+	when, err := decodeTime(bytes.TrimSpace(sig[close+1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	e.When = when
+	return e, nil
+}
+
+func decodeTime(b []byte) (time.Time, error) {
+	fields := bytes.Fields(b)
+	if len(fields) != 2 {
+		return time.Time{}, ErrMalformedEntry
+	}
+
+	ts, err := strconv.ParseInt(string(fields[0]), 10, 64)
+	if err != nil {
+		return time.Time{}, ErrMalformedEntry
+	}
+
+	tz, err := time.Parse("-0700", string(fields[1]))
+	if err != nil {
+		return time.Time{}, ErrMalformedEntry
+	}
+
+	_, offset := tz.Zone()
+	return time.Unix(ts, 0).In(time.FixedZone("", offset)), nil
+}
@@ -0,0 +1,66 @@
+package reflog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ReflogSuite struct{}
+
+var _ = Suite(&ReflogSuite{})
+
+const reflogFixture = "" +
+	"0000000000000000000000000000000000000000 e8d3ffab552895c19b9fcf7aa264d277cde33881 John Doe <john@doe.com> 1257894000 +0100\tcommit (initial): foo\n" +
+	"e8d3ffab552895c19b9fcf7aa264d277cde33881 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 John Doe <john@doe.com> 1257894060 -0230\n"
+
+func (s *ReflogSuite) TestDecode(c *C) {
+	entries, err := NewDecoder(strings.NewReader(reflogFixture)).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+
+	c.Assert(entries[0].Old, Equals, plumbing.ZeroHash)
+	c.Assert(entries[0].New, Equals, plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"))
+	c.Assert(entries[0].Name, Equals, "John Doe")
+	c.Assert(entries[0].Email, Equals, "john@doe.com")
+	c.Assert(entries[0].When.Unix(), Equals, int64(1257894000))
+	c.Assert(entries[0].Message, Equals, "commit (initial): foo")
+
+	_, offset := entries[1].When.Zone()
+	c.Assert(offset, Equals, -(2*60+30)*60)
+	c.Assert(entries[1].Message, Equals, "")
+}
+
+func (s *ReflogSuite) TestDecodeMalformed(c *C) {
+	for _, line := range []string{
+		"foo\n",
+		"0000000000000000000000000000000000000000 e8d3ffab552895c19b9fcf7aa264d277cde33881 John Doe 1257894000 +0100\n",
+		"0000000000000000000000000000000000000000 e8d3ffab552895c19b9fcf7aa264d277cde33881 John Doe <john@doe.com> foo +0100\n",
+	} {
+		_, err := NewDecoder(strings.NewReader(line)).Decode()
+		c.Assert(err, Equals, ErrMalformedEntry, Commentf("line %q", line))
+	}
+}
+
+func (s *ReflogSuite) TestDecodeEmpty(c *C) {
+	entries, err := NewDecoder(strings.NewReader("")).Decode()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
+func newEntry(old, new string, when time.Time, msg string) *Entry {
+	return &Entry{
+		Old:     plumbing.NewHash(old),
+		New:     plumbing.NewHash(new),
+		Name:    "John Doe",
+		Email:   "john@doe.com",
+		When:    when,
+		Message: msg,
+	}
+}
@@ -0,0 +1,50 @@
+package reflog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An Encoder writes reflog entries to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the given entries, the new lines of the messages are replaced
+// by spaces.
+func (e *Encoder) Encode(entries ...*Entry) error {
+	for _, entry := range entries {
+		if err := e.encodeEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeEntry(entry *Entry) error {
+	_, err := fmt.Fprintf(e.w, "%s %s %s <%s> %d %s",
+		entry.Old, entry.New, entry.Name, entry.Email,
+		entry.When.Unix(), entry.When.Format("-0700"),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if entry.Message != "" {
+		msg := strings.Replace(strings.TrimRight(entry.Message, "\n"), "\n", " ", -1)
+		if _, err := fmt.Fprintf(e.w, "\t%s", msg); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(e.w, "\n")
+	return err
+}
@@ -0,0 +1,35 @@
+package reflog
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *ReflogSuite) TestEncode(c *C) {
+	entries, err := NewDecoder(strings.NewReader(reflogFixture)).Decode()
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	err = NewEncoder(buf).Encode(entries...)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, reflogFixture)
+}
+
+func (s *ReflogSuite) TestEncodeMultilineMessage(c *C) {
+	when := time.Unix(1257894000, 0).In(time.FixedZone("", 3600))
+	e := newEntry(
+		"0000000000000000000000000000000000000000",
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		when, "foo\nbar\n",
+	)
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(e)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, "0000000000000000000000000000000000000000 "+
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881 John Doe <john@doe.com> "+
+		"1257894000 +0100\tfoo bar\n")
+}
@@ -0,0 +1,33 @@
+// Package reflog implements encoding and decoding of reflog files, the logs
+// of the values taken by a reference stored by git under the logs directory.
+//
+// Each line of a reflog file is an entry with the format:
+//
+//	<old hash> SP <new hash> SP <name> SP "<" <email> ">" SP <time> SP <tz> TAB <message> LF
+//
+// Where the message is optional, in which case the TAB is omitted too. The
+// entries are sorted from the oldest to the newest.
+package reflog
+
+import (
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+)
+
+// Entry is an entry of a reflog, a change of the value of a reference.
+type Entry struct {
+	// Old is the value of the reference before the change, ZeroHash if the
+	// reference was created.
+	Old plumbing.Hash
+	// New is the value of the reference after the change.
+	New plumbing.Hash
+	// Name of the committer of the change.
+	Name string
+	// Email of the committer of the change.
+	Email string
+	// When is the time of the change.
+	When time.Time
+	// Message describes the change, it never contains new lines.
+	Message string
+}
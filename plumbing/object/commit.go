@@ -44,7 +44,8 @@ type Commit struct {
 	// Committer is the one performing the commit, might be different from
 	// Author.
 	Committer Signature
-	// PGPSignature is the PGP signature of the commit.
+	// PGPSignature is the signature of the commit, an OpenPGP or, despite the
+	// name, an SSH one.
 	PGPSignature string
 	// Message is the commit message, contains arbitrary text.
 	Message string
@@ -273,12 +274,18 @@ func (b *Commit) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 			return err
 		}
 
-		// Split all the signature lines and write with a left padding and
-		// newline at the end.
+		// Split all the signature lines and write them with a left padding,
+		// the newline ending the last one is written along with the message.
 		signature := strings.TrimSuffix(b.PGPSignature, "\n")
 		lines := strings.Split(signature, "\n")
-		for _, line := range lines {
-			if _, err = fmt.Fprintf(w, " %s\n", line); err != nil {
+		for i, line := range lines {
+			if i > 0 {
+				line = "\n " + line
+			} else {
+				line = " " + line
+			}
+
+			if _, err = fmt.Fprint(w, line); err != nil {
 				return err
 			}
 		}
@@ -350,6 +357,22 @@ func (c *Commit) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature)
 }
 
+// VerifyWith verifies the signature of the commit, OpenPGP or SSH, with the given
+// Verifier and returns the identity of the signer on success.
+func (c *Commit) VerifyWith(v Verifier) (string, error) {
+	encoded := &plumbing.MemoryObject{}
+	// Encode commit components, excluding signature and get a reader object.
+	if err := c.encode(encoded, false); err != nil {
+		return "", err
+	}
+	er, err := encoded.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	return v.Verify(er, c.PGPSignature)
+}
+
 func indent(t string) string {
 	var output []string
 	for _, line := range strings.Split(t, "\n") {
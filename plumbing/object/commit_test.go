@@ -365,6 +365,31 @@ RUysgqjcpT8+iQM1PblGfHR4XAhuOqN5Fx06PSaFZhqvWFezJ28/CLyX5q+oIVk=
 	c.Assert(decoded.PGPSignature, Equals, commit.PGPSignature)
 }
 
+func (s *SuiteCommit) TestPGPSignatureDecodeMessage(c *C) {
+	commit := &Commit{
+		Author:       Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1511524851, 0).UTC()},
+		Committer:    Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1511524851, 0).UTC()},
+		Message:      "foo\n",
+		TreeHash:     plumbing.NewHash("064f92fe00e70e6b64cb358a65039daa4b6ae8d2"),
+		PGPSignature: "-----BEGIN PGP SIGNATURE-----\n\nfoo\n-----END PGP SIGNATURE-----\n",
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	err := commit.Encode(encoded)
+	c.Assert(err, IsNil)
+
+	decoded := &Commit{}
+	err = decoded.Decode(encoded)
+	c.Assert(err, IsNil)
+	c.Assert(decoded.Message, Equals, commit.Message)
+	c.Assert(decoded.PGPSignature, Equals, commit.PGPSignature)
+
+	reencoded := &plumbing.MemoryObject{}
+	err = decoded.Encode(reencoded)
+	c.Assert(err, IsNil)
+	c.Assert(reencoded.Hash(), Equals, encoded.Hash())
+}
+
 func (s *SuiteCommit) TestStat(c *C) {
 	aCommit := s.commit(c, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
 	fileStats, err := aCommit.Stats()
@@ -0,0 +1,242 @@
+package object
+
+import (
+	"sort"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+)
+
+// Flags painted on the commits by paintDownToCommon.
+const (
+	paintedOne = 1 << iota
+	paintedTwo
+	paintedStale
+	paintedResult
+)
+
+// MergeBase returns the best common ancestors of the commit and the given
+// one, the ones not reachable from any other common ancestor, as git
+// merge-base --all does. They are sorted by committer time, the most recent
+// first, the first one being the one returned by git merge-base.
+func (c *Commit) MergeBase(other *Commit) ([]*Commit, error) {
+	return mergeBases(c, []*Commit{other})
+}
+
+// IsAncestor returns whether the commit is an ancestor of the given one, as
+// git merge-base --is-ancestor does. A commit is an ancestor of itself.
+func (c *Commit) IsAncestor(other *Commit) (bool, error) {
+	if c.Hash == other.Hash {
+		return true, nil
+	}
+
+	p, err := paintDownToCommon(c, []*Commit{other}, true)
+	if err != nil {
+		return false, err
+	}
+
+	return p.flags[c.Hash]&paintedTwo != 0, nil
+}
+
+// MergeBaseOctopus returns the best common ancestors of all the given
+// commits, as git merge-base --octopus --all does, to be used by an n-way
+// merge.
+func MergeBaseOctopus(commits ...*Commit) ([]*Commit, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	result := commits[:1]
+	for _, c := range commits[1:] {
+		var next []*Commit
+		seen := make(map[plumbing.Hash]bool)
+		for _, r := range result {
+			bases, err := r.MergeBase(c)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, b := range bases {
+				if !seen[b.Hash] {
+					seen[b.Hash] = true
+					next = append(next, b)
+				}
+			}
+		}
+
+		if len(next) == 0 {
+			return nil, nil
+		}
+
+		result = next
+	}
+
+	return Independents(result)
+}
+
+// Independents returns the given commits that are not reachable from any
+// other of them, as git merge-base --independent does. The order of the
+// commits is kept and the duplicates are removed.
+func Independents(commits []*Commit) ([]*Commit, error) {
+	var unique []*Commit
+	seen := make(map[plumbing.Hash]bool)
+	for _, c := range commits {
+		if !seen[c.Hash] {
+			seen[c.Hash] = true
+			unique = append(unique, c)
+		}
+	}
+
+	redundant := make(map[plumbing.Hash]bool)
+	for i, c := range unique {
+		if redundant[c.Hash] {
+			continue
+		}
+
+		var others []*Commit
+		for j, o := range unique {
+			if j != i && !redundant[o.Hash] {
+				others = append(others, o)
+			}
+		}
+
+		if len(others) == 0 {
+			break
+		}
+
+		p, err := paintDownToCommon(c, others, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.flags[c.Hash]&paintedTwo != 0 {
+			redundant[c.Hash] = true
+			continue
+		}
+
+		for _, o := range others {
+			if p.flags[o.Hash]&paintedOne != 0 {
+				redundant[o.Hash] = true
+			}
+		}
+	}
+
+	var result []*Commit
+	for _, c := range unique {
+		if !redundant[c.Hash] {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// mergeBases returns the best common ancestors of one and all the twos.
+func mergeBases(one *Commit, twos []*Commit) ([]*Commit, error) {
+	for _, two := range twos {
+		if one.Hash == two.Hash {
+			return []*Commit{one}, nil
+		}
+	}
+
+	p, err := paintDownToCommon(one, twos, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var bases []*Commit
+	for _, c := range p.result {
+		if p.flags[c.Hash]&paintedStale == 0 {
+			bases = append(bases, c)
+		}
+	}
+
+	if len(bases) > 1 {
+		if bases, err = Independents(bases); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(bases, func(i, j int) bool {
+		return bases[i].Committer.When.After(bases[j].Committer.When)
+	})
+
+	return bases, nil
+}
+
+// painting is the state of a paintDownToCommon walk.
+type painting struct {
+	flags  map[plumbing.Hash]int
+	result []*Commit
+}
+
+// paintDownToCommon walks the history of one and the twos at the same time,
+// painting the commits reachable from each side, and returns the commits
+// found reachable from both sides first, as paint_down_to_common of git does.
+// The commits are visited by committer time, as a stand-in for generation
+// numbers, so the walk stops as soon as every pending commit is known to be
+// reachable from a common ancestor, without walking the whole history. If
+// untilOne is set the walk stops as soon as one is found reachable from the
+// twos.
+func paintDownToCommon(one *Commit, twos []*Commit, untilOne bool) (*painting, error) {
+	p := &painting{flags: make(map[plumbing.Hash]int)}
+	queue := binaryheap.NewWith(func(a, b interface{}) int {
+		if a.(*Commit).Committer.When.Before(b.(*Commit).Committer.When) {
+			return 1
+		}
+		return -1
+	})
+
+	p.flags[one.Hash] |= paintedOne
+	queue.Push(one)
+	for _, two := range twos {
+		p.flags[two.Hash] |= paintedTwo
+		queue.Push(two)
+	}
+
+	for p.hasNonStale(queue) {
+		v, _ := queue.Pop()
+		c := v.(*Commit)
+
+		flags := p.flags[c.Hash] & (paintedOne | paintedTwo | paintedStale)
+		if flags == paintedOne|paintedTwo {
+			if p.flags[c.Hash]&paintedResult == 0 {
+				p.flags[c.Hash] |= paintedResult
+				p.result = append(p.result, c)
+			}
+
+			// the ancestors of a common ancestor aren't the best ones
+			flags |= paintedStale
+		}
+
+		for _, h := range c.ParentHashes {
+			if p.flags[h]&flags == flags {
+				continue
+			}
+
+			parent, err := GetCommit(c.s, h)
+			if err != nil {
+				return nil, err
+			}
+
+			p.flags[h] |= flags
+			queue.Push(parent)
+		}
+
+		if untilOne && p.flags[one.Hash]&paintedTwo != 0 {
+			break
+		}
+	}
+
+	return p, nil
+}
+
+func (p *painting) hasNonStale(queue *binaryheap.Heap) bool {
+	for _, v := range queue.Values() {
+		if p.flags[v.(*Commit).Hash]&paintedStale == 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package object
+
+import (
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type MergeBaseSuite struct {
+	s       storer.EncodedObjectStorer
+	commits map[string]*Commit
+}
+
+var _ = Suite(&MergeBaseSuite{})
+
+// SetUpTest creates the following history, the commits being created from
+// left to right:
+//
+//	A - B - C - D
+//	     \       \
+//	      E - F - G
+//	 X - P1 - M1
+//	   \    X
+//	    P2 - M2
+//	R
+//
+// where M1 and M2 are both merges of P1 and P2.
+func (s *MergeBaseSuite) SetUpTest(c *C) {
+	s.s = memory.NewStorage()
+	s.commits = make(map[string]*Commit)
+
+	for _, def := range [][]string{
+		{"A"}, {"B", "A"}, {"C", "B"}, {"E", "B"}, {"D", "C"}, {"F", "E"},
+		{"G", "F", "D"},
+		{"X"}, {"P1", "X"}, {"P2", "X"}, {"M1", "P1", "P2"}, {"M2", "P2", "P1"},
+		{"R"},
+	} {
+		s.commit(c, def[0], def[1:]...)
+	}
+}
+
+func (s *MergeBaseSuite) commit(c *C, name string, parents ...string) {
+	commit := &Commit{
+		Author: Signature{
+			Name: "foo", Email: "foo@foo.foo",
+			When: time.Unix(int64(1500000000+len(s.commits)*60), 0).UTC(),
+		},
+		Message:  name,
+		TreeHash: plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+	}
+	commit.Committer = commit.Author
+
+	for _, p := range parents {
+		commit.ParentHashes = append(commit.ParentHashes, s.commits[p].Hash)
+	}
+
+	obj := s.s.NewEncodedObject()
+	c.Assert(commit.Encode(obj), IsNil)
+	h, err := s.s.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	s.commits[name], err = GetCommit(s.s, h)
+	c.Assert(err, IsNil)
+}
+
+func (s *MergeBaseSuite) names(commits []*Commit) []string {
+	names := make(map[plumbing.Hash]string)
+	for name, c := range s.commits {
+		names[c.Hash] = name
+	}
+
+	var result []string
+	for _, c := range commits {
+		result = append(result, names[c.Hash])
+	}
+
+	return result
+}
+
+func (s *MergeBaseSuite) list(names ...string) []*Commit {
+	var commits []*Commit
+	for _, name := range names {
+		commits = append(commits, s.commits[name])
+	}
+
+	return commits
+}
+
+func (s *MergeBaseSuite) TestMergeBase(c *C) {
+	for _, t := range []struct {
+		a, b     string
+		expected []string
+	}{
+		{"D", "F", []string{"B"}},
+		{"F", "D", []string{"B"}},
+		{"G", "D", []string{"D"}},
+		{"C", "C", []string{"C"}},
+		{"M1", "M2", []string{"P2", "P1"}},
+		{"D", "R", nil},
+		{"G", "M1", nil},
+	} {
+		bases, err := s.commits[t.a].MergeBase(s.commits[t.b])
+		c.Assert(err, IsNil)
+		c.Assert(s.names(bases), DeepEquals, t.expected, Commentf("%s %s", t.a, t.b))
+	}
+}
+
+func (s *MergeBaseSuite) TestIsAncestor(c *C) {
+	for _, t := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{"A", "A", true},
+		{"A", "G", true},
+		{"D", "G", true},
+		{"E", "G", true},
+		{"G", "D", false},
+		{"C", "F", false},
+		{"X", "R", false},
+		{"P1", "M2", true},
+	} {
+		ok, err := s.commits[t.a].IsAncestor(s.commits[t.b])
+		c.Assert(err, IsNil)
+		c.Assert(ok, Equals, t.expected, Commentf("%s %s", t.a, t.b))
+	}
+}
+
+func (s *MergeBaseSuite) TestMergeBaseOctopus(c *C) {
+	for i, t := range []struct {
+		commits  []string
+		expected []string
+	}{
+		{[]string{"D", "F", "E"}, []string{"B"}},
+		{[]string{"G", "D", "C"}, []string{"C"}},
+		{[]string{"M1", "M2", "P1"}, []string{"P1"}},
+		{[]string{"D", "F", "R"}, nil},
+	} {
+		bases, err := MergeBaseOctopus(s.list(t.commits...)...)
+		c.Assert(err, IsNil)
+		c.Assert(s.names(bases), DeepEquals, t.expected, Commentf("case %d", i))
+	}
+}
+
+func (s *MergeBaseSuite) TestIndependents(c *C) {
+	for i, t := range []struct {
+		commits  []string
+		expected []string
+	}{
+		{[]string{"A", "D", "F", "G"}, []string{"G"}},
+		{[]string{"D", "F", "B"}, []string{"D", "F"}},
+		{[]string{"D", "D", "R"}, []string{"D", "R"}},
+		{[]string{"M1", "P1", "P2", "M2"}, []string{"M1", "M2"}},
+	} {
+		result, err := Independents(s.list(t.commits...))
+		c.Assert(err, IsNil)
+		c.Assert(s.names(result), DeepEquals, t.expected, Commentf("case %d", i))
+	}
+}
@@ -0,0 +1,91 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// ErrUnsupportedSignature is returned by a Verifier when the signature is not
+// in the format it verifies.
+var ErrUnsupportedSignature = errors.New("unsupported signature format")
+
+// Signer signs the encoded form of commits and tags. The signatures are stored
+// in the gpgsig header of the commits and after the message of the tags.
+type Signer interface {
+	// Sign returns the armored signature of the given message.
+	Sign(message io.Reader) (string, error)
+}
+
+// Verifier checks the signatures of commits and tags.
+type Verifier interface {
+	// Verify checks that signature is a valid armored signature of the given
+	// message, made by a trusted key. It returns the identity of the signer.
+	Verify(message io.Reader, signature string) (string, error)
+}
+
+// OpenPGPSigner is a Signer making armored detached OpenPGP signatures.
+type OpenPGPSigner struct {
+	entity *openpgp.Entity
+}
+
+// NewOpenPGPSigner returns a Signer using the given entity, its private key
+// must be present and already decrypted.
+func NewOpenPGPSigner(e *openpgp.Entity) *OpenPGPSigner {
+	return &OpenPGPSigner{entity: e}
+}
+
+// Sign returns the armored detached signature of the given message.
+func (s *OpenPGPSigner) Sign(message io.Reader) (string, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, s.entity, message, nil); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// OpenPGPVerifier is a Verifier of armored detached OpenPGP signatures.
+type OpenPGPVerifier struct {
+	keyring openpgp.EntityList
+}
+
+// NewOpenPGPVerifier returns a Verifier trusting the keys of the given armored
+// keyring.
+func NewOpenPGPVerifier(armoredKeyRing string) (*OpenPGPVerifier, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKeyRing))
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpenPGPVerifier{keyring: keyring}, nil
+}
+
+// Verify checks the signature and returns the name of the primary identity of
+// the signer.
+func (v *OpenPGPVerifier) Verify(message io.Reader, signature string) (string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(signature), beginpgp) {
+		return "", ErrUnsupportedSignature
+	}
+
+	e, err := openpgp.CheckArmoredDetachedSignature(v.keyring, message, strings.NewReader(signature))
+	if err != nil {
+		return "", err
+	}
+
+	var name string
+	for _, id := range e.Identities {
+		if id.SelfSignature != nil && id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId {
+			return id.Name, nil
+		}
+
+		if name == "" || id.Name < name {
+			name = id.Name
+		}
+	}
+
+	return name, nil
+}
@@ -0,0 +1,246 @@
+package object
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/allowedsigners"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	beginsshsig = "-----BEGIN SSH SIGNATURE-----"
+	endsshsig   = "-----END SSH SIGNATURE-----"
+
+	// SSHSignatureNamespace is the namespace of the SSH signatures of commits
+	// and tags, as used by git.
+	SSHSignatureNamespace = "git"
+
+	sshsigMagic   = "SSHSIG"
+	sshsigVersion = 1
+	sshsigWidth   = 70
+)
+
+var (
+	// ErrInvalidSSHSignature is returned when an SSH signature is malformed
+	// or doesn't match the message.
+	ErrInvalidSSHSignature = errors.New("invalid SSH signature")
+	// ErrSSHSignerNotAllowed is returned when an SSH signature is made by a
+	// key not present in the allowed signers.
+	ErrSSHSignerNotAllowed = errors.New("SSH signer not allowed")
+)
+
+// sshsigBlob is the content of an SSHSIG signature, following the magic
+// preamble.
+type sshsigBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshsigSignedData is the data actually signed by the key, following the
+// magic preamble.
+type sshsigSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// SSHSigner is a Signer making armored SSH signatures in the SSHSIG format
+// of ssh-keygen -Y sign, the format used by git when gpg.format is ssh.
+type SSHSigner struct {
+	signer ssh.Signer
+}
+
+// NewSSHSigner returns a Signer using the given key.
+func NewSSHSigner(s ssh.Signer) *SSHSigner {
+	return &SSHSigner{signer: s}
+}
+
+// Sign returns the armored SSH signature of the given message, in the git
+// namespace and hashed with SHA-512.
+func (s *SSHSigner) Sign(message io.Reader) (string, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return "", err
+	}
+
+	data := sshsigData(&sshsigSignedData{
+		Namespace:     SSHSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          h.Sum(nil),
+	})
+
+	sig, err := s.sign(data)
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte(sshsigMagic), ssh.Marshal(&sshsigBlob{
+		Version:       sshsigVersion,
+		PublicKey:     s.signer.PublicKey().Marshal(),
+		Namespace:     SSHSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+
+	return armorSSHSignature(blob), nil
+}
+
+// sign signs the data, using SHA-512 with RSA keys since SHA-1 signatures
+// are rejected by ssh-keygen.
+func (s *SSHSigner) sign(data []byte) (*ssh.Signature, error) {
+	if as, ok := s.signer.(ssh.AlgorithmSigner); ok && s.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		return as.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+	}
+
+	return s.signer.Sign(rand.Reader, data)
+}
+
+// SSHVerifier is a Verifier of armored SSH signatures in the SSHSIG format,
+// trusting the keys of an allowed signers file.
+type SSHVerifier struct {
+	signers []*allowedsigners.Entry
+	now     func() time.Time
+}
+
+// NewSSHVerifier returns a Verifier trusting the given allowed signers, as
+// read by an allowedsigners.Decoder. The validity of the keys is checked
+// against the current time.
+func NewSSHVerifier(signers []*allowedsigners.Entry) *SSHVerifier {
+	return &SSHVerifier{signers: signers, now: time.Now}
+}
+
+// Verify checks the signature and returns the principals allowed to use the
+// signing key, as written in the allowed signers file. Signatures made with
+// certificates are not supported.
+func (v *SSHVerifier) Verify(message io.Reader, signature string) (string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(signature), beginsshsig) {
+		return "", ErrUnsupportedSignature
+	}
+
+	key, err := verifySSHSignature(message, signature, SSHSignatureNamespace)
+	if err != nil {
+		return "", err
+	}
+
+	now := v.now()
+	for _, e := range v.signers {
+		if e.CertAuthority || !bytes.Equal(e.Key.Marshal(), key.Marshal()) {
+			continue
+		}
+
+		if e.AllowsNamespace(SSHSignatureNamespace) && e.ValidAt(now) {
+			return strings.Join(e.Principals, ","), nil
+		}
+	}
+
+	return "", ErrSSHSignerNotAllowed
+}
+
+// verifySSHSignature checks that the armored SSHSIG signature is a valid
+// signature of the message in the given namespace, and returns the key used.
+func verifySSHSignature(message io.Reader, signature, namespace string) (ssh.PublicKey, error) {
+	blob, err := dearmorSSHSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(blob, []byte(sshsigMagic)) {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	sig := &sshsigBlob{}
+	if err := ssh.Unmarshal(blob[len(sshsigMagic):], sig); err != nil {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	if sig.Version != sshsigVersion || sig.Namespace != namespace {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, ErrInvalidSSHSignature
+	}
+
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+
+	s := &ssh.Signature{}
+	if err := ssh.Unmarshal(sig.Signature, s); err != nil || s.Format == ssh.KeyAlgoRSA {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	data := sshsigData(&sshsigSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})
+
+	if err := key.Verify(data, s); err != nil {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	return key, nil
+}
+
+func sshsigData(d *sshsigSignedData) []byte {
+	return append([]byte(sshsigMagic), ssh.Marshal(d)...)
+}
+
+func armorSSHSignature(blob []byte) string {
+	enc := base64.StdEncoding.EncodeToString(blob)
+
+	var b bytes.Buffer
+	b.WriteString(beginsshsig + "\n")
+	for len(enc) > sshsigWidth {
+		b.WriteString(enc[:sshsigWidth] + "\n")
+		enc = enc[sshsigWidth:]
+	}
+
+	b.WriteString(enc + "\n" + endsshsig + "\n")
+	return b.String()
+}
+
+func dearmorSSHSignature(signature string) ([]byte, error) {
+	signature = strings.TrimSpace(signature)
+	if !strings.HasPrefix(signature, beginsshsig) || !strings.HasSuffix(signature, endsshsig) {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	enc := signature[len(beginsshsig) : len(signature)-len(endsshsig)]
+	enc = strings.Join(strings.Fields(enc), "")
+
+	blob, err := base64.StdEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, ErrInvalidSSHSignature
+	}
+
+	return blob, nil
+}
@@ -39,7 +39,8 @@ type Tag struct {
 	Tagger Signature
 	// Message is an arbitrary text message.
 	Message string
-	// PGPSignature is the PGP signature of the tag.
+	// PGPSignature is the signature of the tag, an OpenPGP or, despite the
+	// name, an SSH one.
 	PGPSignature string
 	// TargetType is the object type of the target.
 	TargetType plumbing.ObjectType
@@ -138,15 +139,15 @@ func (t *Tag) Decode(o plumbing.EncodedObject) (err error) {
 	}
 
 	var pgpsig bool
-	// Check if data contains PGP signature.
-	if bytes.Contains(data, []byte(beginpgp)) {
-		// Split the lines at newline.
-		messageAndSig := bytes.Split(data, []byte("\n"))
+	// Check if data contains a PGP or SSH signature.
+	if begin, end := SignatureDelimiters(data); begin != "" {
+		// Split the lines at newline, the last one ends the signature.
+		messageAndSig := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
 
 		for _, l := range messageAndSig {
 			if pgpsig {
-				if bytes.Contains(l, []byte(endpgp)) {
-					t.PGPSignature += endpgp + "\n"
+				if bytes.Contains(l, []byte(end)) {
+					t.PGPSignature += end + "\n"
 					pgpsig = false
 				} else {
 					t.PGPSignature += string(l) + "\n"
@@ -154,9 +155,9 @@ func (t *Tag) Decode(o plumbing.EncodedObject) (err error) {
 				continue
 			}
 
-			// Check if it's the beginning of a PGP signature.
-			if bytes.Contains(l, []byte(beginpgp)) {
-				t.PGPSignature += beginpgp + "\n"
+			// Check if it's the beginning of the signature.
+			if bytes.Contains(l, []byte(begin)) {
+				t.PGPSignature += begin + "\n"
 				pgpsig = true
 				continue
 			}
@@ -203,7 +204,7 @@ func (t *Tag) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 
 	if t.PGPSignature != "" && includeSig {
 		// Split all the signature lines and write with a newline at the end.
-		lines := strings.Split(t.PGPSignature, "\n")
+		lines := strings.Split(strings.TrimSuffix(t.PGPSignature, "\n"), "\n")
 		for _, line := range lines {
 			if _, err = fmt.Fprintf(w, "%s\n", line); err != nil {
 				return err
@@ -214,6 +215,19 @@ func (t *Tag) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 	return err
 }
 
+// SignatureDelimiters returns the delimiters of the PGP or SSH signature
+// contained in the given data, empty if it isn't signed.
+func SignatureDelimiters(data []byte) (begin, end string) {
+	switch {
+	case bytes.Contains(data, []byte(beginpgp)):
+		return beginpgp, endpgp
+	case bytes.Contains(data, []byte(beginsshsig)):
+		return beginsshsig, endsshsig
+	}
+
+	return "", ""
+}
+
 // Commit returns the commit pointed to by the tag. If the tag points to a
 // different type of object ErrUnsupportedObject will be returned.
 func (t *Tag) Commit() (*Commit, error) {
@@ -305,6 +319,22 @@ func (t *Tag) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature)
 }
 
+// VerifyWith verifies the signature of the tag, OpenPGP or SSH, with the given
+// Verifier and returns the identity of the signer on success.
+func (t *Tag) VerifyWith(v Verifier) (string, error) {
+	encoded := &plumbing.MemoryObject{}
+	// Encode tag components, excluding signature and get a reader object.
+	if err := t.encode(encoded, false); err != nil {
+		return "", err
+	}
+	er, err := encoded.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	return v.Verify(er, t.PGPSignature)
+}
+
 // TagIter provides an iterator for a set of tags.
 type TagIter struct {
 	storer.EncodedObjectIter
@@ -318,6 +318,32 @@ RUysgqjcpT8+iQM1PblGfHR4XAhuOqN5Fx06PSaFZhqvWFezJ28/CLyX5q+oIVk=
 	c.Assert(decoded.PGPSignature, Equals, pgpsignature)
 }
 
+func (s *TagSuite) TestPGPSignatureDecodeMessage(c *C) {
+	tag := &Tag{
+		Name:         "foo",
+		Tagger:       Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1511524851, 0).UTC()},
+		Message:      "foo\n",
+		TargetType:   plumbing.CommitObject,
+		Target:       plumbing.NewHash("064f92fe00e70e6b64cb358a65039daa4b6ae8d2"),
+		PGPSignature: "-----BEGIN PGP SIGNATURE-----\n\nfoo\n-----END PGP SIGNATURE-----\n",
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	err := tag.Encode(encoded)
+	c.Assert(err, IsNil)
+
+	decoded := &Tag{}
+	err = decoded.Decode(encoded)
+	c.Assert(err, IsNil)
+	c.Assert(decoded.Message, Equals, tag.Message)
+	c.Assert(decoded.PGPSignature, Equals, tag.PGPSignature)
+
+	reencoded := &plumbing.MemoryObject{}
+	err = decoded.Encode(reencoded)
+	c.Assert(err, IsNil)
+	c.Assert(reencoded.Hash(), Equals, encoded.Hash())
+}
+
 func (s *TagSuite) TestVerify(c *C) {
 	ts := time.Unix(1511524851, 0)
 	loc, _ := time.LoadLocation("Asia/Kolkata")
@@ -13,12 +13,13 @@ import (
 )
 
 const (
-	refPrefix       = "refs/"
-	refHeadPrefix   = refPrefix + "heads/"
-	refTagPrefix    = refPrefix + "tags/"
-	refRemotePrefix = refPrefix + "remotes/"
-	refNotePrefix   = refPrefix + "notes/"
-	symrefPrefix    = "ref: "
+	refPrefix        = "refs/"
+	refHeadPrefix    = refPrefix + "heads/"
+	refTagPrefix     = refPrefix + "tags/"
+	refRemotePrefix  = refPrefix + "remotes/"
+	refNotePrefix    = refPrefix + "notes/"
+	refReplacePrefix = refPrefix + "replace/"
+	symrefPrefix     = "ref: "
 )
 
 // RefRevParseRules are a set of rules to parse references into short names.
@@ -71,6 +72,11 @@ func (r ReferenceName) IsNote() bool {
 	return strings.HasPrefix(string(r), refNotePrefix)
 }
 
+// IsReplace check if a reference is the replacement of an object
+func (r ReferenceName) IsReplace() bool {
+	return strings.HasPrefix(string(r), refReplacePrefix)
+}
+
 // IsRemote check if a reference is a remote
 func (r ReferenceName) IsRemote() bool {
 	return strings.HasPrefix(string(r), refRemotePrefix)
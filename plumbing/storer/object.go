@@ -91,6 +91,16 @@ type PackedObjectStorer interface {
 	DeleteOldObjectPackAndIndex(plumbing.Hash, time.Time) error
 }
 
+// KeptPackStorer is an optional interface for storers whose object packs can
+// be marked to be kept, with a .keep file, excluding them from the repacks.
+type KeptPackStorer interface {
+	// KeptObjectPacks returns the hashes of the object packs marked to be
+	// kept.
+	KeptObjectPacks() ([]plumbing.Hash, error)
+	// ObjectPackHashes returns the hashes of the objects of an object pack.
+	ObjectPackHashes(pack plumbing.Hash) ([]plumbing.Hash, error)
+}
+
 // PackfileWriter is a optional method for ObjectStorer, it enable direct write
 // of packfile to the storage
 type PackfileWriter interface {
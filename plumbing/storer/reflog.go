@@ -0,0 +1,108 @@
+package storer
+
+import (
+	"io"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
+)
+
+// ReflogStorer is a storage of reflogs, the logs of the values taken by the
+// references.
+type ReflogStorer interface {
+	// Reflog returns the entries of the log of the given reference, oldest
+	// first. If the reference has no log an empty list is returned.
+	Reflog(name plumbing.ReferenceName) ([]*reflog.Entry, error)
+	// AppendReflog adds an entry at the end of the log of the given
+	// reference, creating the log if needed.
+	AppendReflog(name plumbing.ReferenceName, e *reflog.Entry) error
+	// SetReflog replaces the log of the given reference, an empty list of
+	// entries removes the log.
+	SetReflog(name plumbing.ReferenceName, entries []*reflog.Entry) error
+}
+
+// LoggedReferenceStorer is a ReferenceStorer that logs the updates of the
+// references in their reflogs.
+type LoggedReferenceStorer interface {
+	// SetReferenceWithLog stores the reference as CheckAndSetReference does,
+	// old may be nil to skip the check. If the reference is logged the update
+	// is recorded with the identity, time and message of the given entry, its
+	// Old and New hashes are ignored.
+	SetReferenceWithLog(ref, old *plumbing.Reference, e *reflog.Entry) error
+}
+
+// SetReferenceWithLog stores the reference, checking its previous value if old
+// is not nil. If the storer is a LoggedReferenceStorer the update is described
+// in the reflog with the given entry.
+func SetReferenceWithLog(s ReferenceStorer, ref, old *plumbing.Reference, e *reflog.Entry) error {
+	if ls, ok := s.(LoggedReferenceStorer); ok {
+		return ls.SetReferenceWithLog(ref, old, e)
+	}
+
+	if old != nil {
+		return s.CheckAndSetReference(ref, old)
+	}
+
+	return s.SetReference(ref)
+}
+
+// ReflogIter is a generic closable interface for iterating over reflog
+// entries.
+type ReflogIter interface {
+	Next() (*reflog.Entry, error)
+	ForEach(func(*reflog.Entry) error) error
+	Close()
+}
+
+// ReflogSliceIter implements ReflogIter. It iterates over a series of entries
+// stored in a slice and yields each one in turn when Next() is called.
+//
+// The ReflogSliceIter must be closed with a call to Close() when it is no
+// longer needed.
+type ReflogSliceIter struct {
+	series []*reflog.Entry
+	pos    int
+}
+
+// NewReflogSliceIter returns a reflog iterator for the given slice of
+// entries.
+func NewReflogSliceIter(series []*reflog.Entry) ReflogIter {
+	return &ReflogSliceIter{
+		series: series,
+	}
+}
+
+// Next returns the next entry from the iterator. If the iterator has reached
+// the end it will return io.EOF as an error.
+func (iter *ReflogSliceIter) Next() (*reflog.Entry, error) {
+	if iter.pos >= len(iter.series) {
+		return nil, io.EOF
+	}
+
+	e := iter.series[iter.pos]
+	iter.pos++
+	return e, nil
+}
+
+// ForEach call the cb function for each entry contained on this iter until
+// an error happens or the end of the iter is reached. If ErrStop is sent the
+// iteration is stop but no error is returned. The iterator is closed.
+func (iter *ReflogSliceIter) ForEach(cb func(*reflog.Entry) error) error {
+	defer iter.Close()
+	for _, e := range iter.series {
+		if err := cb(e); err != nil {
+			if err == ErrStop {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close releases any resources used by the iterator.
+func (iter *ReflogSliceIter) Close() {
+	iter.pos = len(iter.series)
+}
@@ -0,0 +1,65 @@
+package storer
+
+import (
+	"io"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
+	. "gopkg.in/check.v1"
+)
+
+type ReflogSuite struct{}
+
+var _ = Suite(&ReflogSuite{})
+
+func (s *ReflogSuite) TestReflogSliceIterNext(c *C) {
+	slice := []*reflog.Entry{{Message: "foo"}, {Message: "bar"}}
+
+	i := NewReflogSliceIter(slice)
+	foo, err := i.Next()
+	c.Assert(err, IsNil)
+	c.Assert(foo == slice[0], Equals, true)
+
+	bar, err := i.Next()
+	c.Assert(err, IsNil)
+	c.Assert(bar == slice[1], Equals, true)
+
+	empty, err := i.Next()
+	c.Assert(err, Equals, io.EOF)
+	c.Assert(empty, IsNil)
+}
+
+func (s *ReflogSuite) TestReflogSliceIterForEachStop(c *C) {
+	slice := []*reflog.Entry{{Message: "foo"}, {Message: "bar"}}
+
+	i := NewReflogSliceIter(slice)
+	var count int
+	err := i.ForEach(func(e *reflog.Entry) error {
+		c.Assert(e == slice[count], Equals, true)
+		count++
+		return ErrStop
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+}
+
+type loggedReferenceStorer struct {
+	ReferenceStorer
+	entries []*reflog.Entry
+}
+
+func (s *loggedReferenceStorer) SetReferenceWithLog(ref, old *plumbing.Reference, e *reflog.Entry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *ReflogSuite) TestSetReferenceWithLog(c *C) {
+	ref := plumbing.NewReferenceFromStrings("refs/heads/foo", "b66c08ba28aa1f81eb06a1127aa3936ff77e5e2c")
+	e := &reflog.Entry{Message: "foo"}
+
+	ls := &loggedReferenceStorer{}
+	err := SetReferenceWithLog(ls, ref, nil, e)
+	c.Assert(err, IsNil)
+	c.Assert(ls.entries, DeepEquals, []*reflog.Entry{e})
+}
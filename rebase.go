@@ -0,0 +1,1045 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+)
+
+// rebaseMergeDir is the directory, relative to the git directory, where the
+// state of a rebase is stored, as git does for merge based rebases.
+const rebaseMergeDir = "rebase-merge"
+
+// origHeadRef is the reference pointing to the commit at HEAD before an
+// operation rewriting the history.
+const origHeadRef plumbing.ReferenceName = "ORIG_HEAD"
+
+var (
+	// ErrRebaseConflict is returned when a commit can't be replayed without
+	// conflicts during a rebase.
+	ErrRebaseConflict = errors.New("rebase conflict")
+	// ErrRebaseInProgress is returned when a rebase is started while another
+	// one is stopped.
+	ErrRebaseInProgress = errors.New("a rebase is already in progress")
+	// ErrNoRebaseInProgress is returned when a rebase is continued, skipped or
+	// aborted but there is no stopped rebase.
+	ErrNoRebaseInProgress = errors.New("no rebase in progress")
+	// ErrRebaseNotSupported is returned when the storage of the repository
+	// is not based on a filesystem, where the rebase state is kept.
+	ErrRebaseNotSupported = errors.New("rebase is only supported by filesystem based storages")
+	// ErrInvalidRebaseTodo is returned when the list of steps of a rebase is
+	// not valid.
+	ErrInvalidRebaseTodo = errors.New("invalid rebase todo list")
+)
+
+// RebaseAction is the action performed by a rebase step.
+type RebaseAction int
+
+const (
+	// PickAction replays the commit.
+	PickAction RebaseAction = iota
+	// RewordAction replays the commit using the message of the step.
+	RewordAction
+	// SquashAction melds the commit into the previous one, the messages of
+	// both commits are joined unless the step has a message.
+	SquashAction
+	// FixupAction melds the commit into the previous one, keeping the message
+	// of the previous commit.
+	FixupAction
+	// DropAction removes the commit.
+	DropAction
+	// ExecAction runs the command of the step with RebaseOptions.Exec.
+	ExecAction
+)
+
+var rebaseActionNames = map[RebaseAction]string{
+	PickAction:   "pick",
+	RewordAction: "reword",
+	SquashAction: "squash",
+	FixupAction:  "fixup",
+	DropAction:   "drop",
+	ExecAction:   "exec",
+}
+
+// String returns the name of the action, as used by git todo lists.
+func (a RebaseAction) String() string {
+	return rebaseActionNames[a]
+}
+
+// RebaseStep is an entry of the todo list of a rebase.
+type RebaseStep struct {
+	// Action is the action to be performed.
+	Action RebaseAction
+	// Commit is the commit the action is performed on, it's empty for exec
+	// steps.
+	Commit plumbing.Hash
+	// Message is the message of the commit for reword steps and, optionally,
+	// of the melded commit for squash steps.
+	Message string
+	// Command is the command of exec steps.
+	Command string
+}
+
+// Rebase replays the commits of a branch that are not reachable from
+// o.Upstream on top of o.Onto, following the list of steps returned by o.Todo.
+// The merge commits of the branch are dropped. Once all the steps are
+// performed the branch is updated to point to the last replayed commit.
+//
+// The state of the rebase is kept at the rebase-merge directory of the git
+// directory, the same used by git. If a commit can't be replayed cleanly
+// ErrRebaseConflict is returned and the conflicts are recorded in the index,
+// once they are resolved and added the rebase is resumed with RebaseContinue.
+// If an exec step fails its error is returned and the rebase can be resumed
+// too. RebaseSkip and RebaseAbort discard the current step or the whole
+// rebase.
+func (r *Repository) Rebase(o *RebaseOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	fs, err := r.rebaseFilesystem()
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.Stat(rebaseMergeDir); err == nil {
+		return ErrRebaseInProgress
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if err := w.checkCleanForMerge(); err != nil {
+		return err
+	}
+
+	name := plumbing.HEAD
+	if o.Branch != "" {
+		name = o.Branch
+	}
+
+	head, err := r.Storer.Reference(name)
+	if err != nil {
+		return err
+	}
+
+	tip, err := storer.ResolveReference(r.Storer, name)
+	if err != nil {
+		return err
+	}
+
+	state := &rebaseState{fs: fs, orig: tip.Hash(), onto: o.Onto}
+	switch {
+	case o.Branch != "":
+		state.head = o.Branch
+	case head.Type() == plumbing.SymbolicReference:
+		state.head = head.Target()
+	}
+
+	if state.todo, err = r.rebaseTodo(tip.Hash(), o); err != nil {
+		return err
+	}
+
+	// the state is saved before touching HEAD and the worktree, so the
+	// rebase can be aborted from here on
+	if err := state.save(); err != nil {
+		if rerr := util.RemoveAll(fs, rebaseMergeDir); rerr != nil {
+			return rerr
+		}
+
+		return err
+	}
+
+	if err := r.Storer.SetReference(
+		plumbing.NewHashReference(origHeadRef, tip.Hash()),
+	); err != nil {
+		return err
+	}
+
+	if err := setReferenceWithLog(r.Storer,
+		plumbing.NewHashReference(plumbing.HEAD, o.Onto), nil,
+		fmt.Sprintf("rebase (start): checkout %s", o.Onto), o.Committer,
+	); err != nil {
+		return err
+	}
+
+	if err := w.Reset(&ResetOptions{Mode: HardReset, Commit: o.Onto}); err != nil {
+		return err
+	}
+
+	return w.runRebase(state, o)
+}
+
+// RebaseContinue resumes a stopped rebase. If it was stopped by conflicts,
+// they must be resolved and added to the index, the index is committed with
+// the message and author of the replayed commit. Only the Exec and Committer
+// fields of o are used.
+func (r *Repository) RebaseContinue(o *RebaseOptions) error {
+	w, state, err := r.resumeRebase(o)
+	if err != nil {
+		return err
+	}
+
+	if err := w.commitStoppedStep(state, o.Committer); err != nil {
+		return err
+	}
+
+	return w.runRebase(state, o)
+}
+
+// RebaseSkip resumes a stopped rebase discarding the changes of the step that
+// stopped it. Only the Exec and Committer fields of o are used.
+func (r *Repository) RebaseSkip(o *RebaseOptions) error {
+	w, state, err := r.resumeRebase(o)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Reset(&ResetOptions{Mode: HardReset}); err != nil {
+		return err
+	}
+
+	if err := state.clearStop(); err != nil {
+		return err
+	}
+
+	return w.runRebase(state, o)
+}
+
+// RebaseAbort stops a rebase restoring the branch, the index and the worktree
+// to the state previous to the rebase.
+func (r *Repository) RebaseAbort() error {
+	fs, err := r.rebaseFilesystem()
+	if err != nil {
+		return err
+	}
+
+	state, err := loadRebaseState(fs)
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	head := plumbing.NewHashReference(plumbing.HEAD, state.orig)
+	to := state.orig.String()
+	if state.head != "" {
+		head = plumbing.NewSymbolicReference(plumbing.HEAD, state.head)
+		to = state.head.String()
+	}
+
+	msg := "rebase (abort): returning to " + to
+	if err := setReferenceWithLog(r.Storer, head, nil, msg, nil); err != nil {
+		return err
+	}
+
+	if err := w.Reset(&ResetOptions{Mode: HardReset, Commit: state.orig}); err != nil {
+		return err
+	}
+
+	return util.RemoveAll(fs, rebaseMergeDir)
+}
+
+func (r *Repository) rebaseFilesystem() (billy.Filesystem, error) {
+	fs := r.dotGitFilesystem()
+	if fs == nil {
+		return nil, ErrRebaseNotSupported
+	}
+
+	return fs, nil
+}
+
+// dotGitFilesystem returns the filesystem of the git directory, nil if the
+// storage of the repository is not based on a filesystem.
+func (r *Repository) dotGitFilesystem() billy.Filesystem {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	s, ok := r.Storer.(fsBased)
+	if !ok {
+		return nil
+	}
+
+	return s.Filesystem()
+}
+
+func (r *Repository) resumeRebase(o *RebaseOptions) (*Worktree, *rebaseState, error) {
+	if o.Committer == nil {
+		return nil, nil, ErrMissingCommitter
+	}
+
+	fs, err := r.rebaseFilesystem()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state, err := loadRebaseState(fs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range state.todo {
+		if s.Action == ExecAction && o.Exec == nil {
+			return nil, nil, fmt.Errorf("%s: exec steps require an Exec function",
+				ErrInvalidRebaseTodo)
+		}
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return w, state, nil
+}
+
+// rebaseTodo returns the list of steps of a rebase of tip.
+func (r *Repository) rebaseTodo(tip plumbing.Hash, o *RebaseOptions) ([]RebaseStep, error) {
+	c, err := r.CommitObject(tip)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream, err := r.CommitObject(o.Upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := commitsToRebase(c, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]RebaseStep, len(commits))
+	for i, c := range commits {
+		steps[i] = RebaseStep{Action: PickAction, Commit: c.Hash}
+	}
+
+	if o.Todo != nil {
+		if steps, err = o.Todo(steps); err != nil {
+			return nil, err
+		}
+	}
+
+	return steps, validateRebaseTodo(steps, o)
+}
+
+func validateRebaseTodo(steps []RebaseStep, o *RebaseOptions) error {
+	picked := false
+	for _, s := range steps {
+		switch s.Action {
+		case PickAction, RewordAction:
+			picked = true
+		case SquashAction, FixupAction:
+			if !picked {
+				return fmt.Errorf("%s: cannot %s without a previous commit",
+					ErrInvalidRebaseTodo, s.Action)
+			}
+		case DropAction:
+		case ExecAction:
+			if o.Exec == nil {
+				return fmt.Errorf("%s: exec steps require an Exec function",
+					ErrInvalidRebaseTodo)
+			}
+
+			continue
+		default:
+			return fmt.Errorf("%s: unknown action %d", ErrInvalidRebaseTodo, s.Action)
+		}
+
+		if s.Action == RewordAction && s.Message == "" {
+			return fmt.Errorf("%s: reword of %s without message",
+				ErrInvalidRebaseTodo, s.Commit)
+		}
+
+		if s.Commit.IsZero() {
+			return fmt.Errorf("%s: %s without commit", ErrInvalidRebaseTodo, s.Action)
+		}
+	}
+
+	return nil
+}
+
+// runRebase performs the pending steps of the rebase, updating the branch
+// once all of them are done.
+func (w *Worktree) runRebase(state *rebaseState, o *RebaseOptions) error {
+	for len(state.todo) != 0 {
+		step := state.todo[0]
+		state.todo = state.todo[1:]
+		state.done = append(state.done, step)
+		if err := state.save(); err != nil {
+			return err
+		}
+
+		if err := w.rebaseStep(state, step, o); err != nil {
+			return err
+		}
+	}
+
+	return w.finishRebase(state)
+}
+
+func (w *Worktree) rebaseStep(state *rebaseState, step RebaseStep, o *RebaseOptions) error {
+	switch step.Action {
+	case DropAction:
+		return nil
+	case ExecAction:
+		return o.Exec(step.Command)
+	}
+
+	c, err := w.r.CommitObject(step.Commit)
+	if err != nil {
+		return err
+	}
+
+	parent, err := mainlineParent(c, 0)
+	if err != nil {
+		return err
+	}
+
+	stop := &rebaseStop{commit: c.Hash, author: c.Author, message: c.Message}
+	if step.Action == RewordAction {
+		stop.message = step.Message
+	}
+
+	if step.Action == SquashAction || step.Action == FixupAction {
+		head, err := w.r.Head()
+		if err != nil {
+			return err
+		}
+
+		prev, err := w.r.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+
+		stop.amend = prev.Hash
+		stop.author = prev.Author
+		stop.message = prev.Message
+		if step.Action == SquashAction {
+			stop.message = squashMessage(prev.Message, c.Message, step.Message)
+		}
+	}
+
+	_, err = w.applyChanges(parent, c, commitLabel(c), "", "")
+	switch {
+	case err == ErrMergeConflict:
+		if err := state.saveStop(stop); err != nil {
+			return err
+		}
+
+		return ErrRebaseConflict
+	case err == ErrEmptyCommit && stop.amend.IsZero():
+		return nil
+	case err != nil && err != ErrEmptyCommit:
+		return err
+	}
+
+	return w.commitRebaseStep(stop, o.Committer)
+}
+
+// commitStoppedStep commits the resolution of the step that stopped the
+// rebase, if any.
+func (w *Worktree) commitStoppedStep(state *rebaseState, committer *object.Signature) error {
+	stop, err := state.loadStop()
+	if err != nil || stop == nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage != index.Merged {
+			return ErrUnmergedEntries
+		}
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return err
+	}
+
+	c, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	staged, err := w.hasStagedChanges(c)
+	if err != nil {
+		return err
+	}
+
+	if staged || !stop.amend.IsZero() {
+		if err := w.commitRebaseStep(stop, committer); err != nil {
+			return err
+		}
+	}
+
+	return state.clearStop()
+}
+
+// commitRebaseStep commits the index, amending HEAD for squash and fixup
+// steps.
+func (w *Worktree) commitRebaseStep(stop *rebaseStop, committer *object.Signature) error {
+	var parents []plumbing.Hash
+	if !stop.amend.IsZero() {
+		c, err := w.r.CommitObject(stop.amend)
+		if err != nil {
+			return err
+		}
+
+		parents = c.ParentHashes
+	} else {
+		head, err := w.r.Head()
+		if err != nil {
+			return err
+		}
+
+		parents = append(parents, head.Hash())
+	}
+
+	author := stop.author
+	_, err := w.Commit(stop.message, &CommitOptions{
+		Author:    &author,
+		Committer: committer,
+		Parents:   parents,
+	})
+
+	return err
+}
+
+// finishRebase points the rebased branch to HEAD, checks it out and removes
+// the state of the rebase.
+func (w *Worktree) finishRebase(state *rebaseState) error {
+	if state.head != "" {
+		head, err := w.r.Head()
+		if err != nil {
+			return err
+		}
+
+		if err := setReferenceWithLog(w.r.Storer,
+			plumbing.NewHashReference(state.head, head.Hash()), nil,
+			fmt.Sprintf("rebase (finish): %s onto %s", state.head, state.onto), nil,
+		); err != nil {
+			return err
+		}
+
+		if err := setReferenceWithLog(w.r.Storer,
+			plumbing.NewSymbolicReference(plumbing.HEAD, state.head), nil,
+			fmt.Sprintf("rebase (finish): returning to %s", state.head), nil,
+		); err != nil {
+			return err
+		}
+	}
+
+	return util.RemoveAll(state.fs, rebaseMergeDir)
+}
+
+func squashMessage(prev, msg, override string) string {
+	if override != "" {
+		return override
+	}
+
+	return strings.TrimRight(prev, "\n") + "\n\n" + msg
+}
+
+// rebaseState is the state of a rebase in progress, stored using the same
+// files and format than git.
+type rebaseState struct {
+	fs billy.Filesystem
+	// head is the branch being rebased, empty if HEAD was detached.
+	head plumbing.ReferenceName
+	onto plumbing.Hash
+	orig plumbing.Hash
+	todo []RebaseStep
+	done []RebaseStep
+}
+
+// rebaseStop is the step that stopped a rebase due to conflicts.
+type rebaseStop struct {
+	commit  plumbing.Hash
+	author  object.Signature
+	message string
+	// amend is the commit amended by squash and fixup steps.
+	amend plumbing.Hash
+}
+
+const (
+	rebaseHeadNameFile = "head-name"
+	rebaseOntoFile     = "onto"
+	rebaseOrigHeadFile = "orig-head"
+	rebaseTodoFile     = "git-rebase-todo"
+	rebaseDoneFile     = "done"
+	rebaseMsgNumFile   = "msgnum"
+	rebaseEndFile      = "end"
+	rebaseInteractive  = "interactive"
+	rebaseStoppedFile  = "stopped-sha"
+	rebaseMessageFile  = "message"
+	rebaseAuthorFile   = "author-script"
+	rebaseAmendFile    = "amend"
+	// rebaseMessagesDir keeps the messages of the reword and squash steps,
+	// unknown to git, in files named by the number of the step as msgnum.
+	rebaseMessagesDir = "messages"
+
+	detachedHeadName = "detached HEAD"
+)
+
+func loadRebaseState(fs billy.Filesystem) (*rebaseState, error) {
+	s := &rebaseState{fs: fs}
+
+	head, err := s.read(rebaseHeadNameFile)
+	if os.IsNotExist(err) {
+		return nil, ErrNoRebaseInProgress
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if head != detachedHeadName {
+		s.head = plumbing.ReferenceName(head)
+	}
+
+	for file, h := range map[string]*plumbing.Hash{
+		rebaseOntoFile:     &s.onto,
+		rebaseOrigHeadFile: &s.orig,
+	} {
+		content, err := s.read(file)
+		if err != nil {
+			return nil, err
+		}
+
+		*h = plumbing.NewHash(content)
+	}
+
+	if s.done, err = s.readSteps(rebaseDoneFile, 1); err != nil {
+		return nil, err
+	}
+
+	if s.todo, err = s.readSteps(rebaseTodoFile, len(s.done)+1); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *rebaseState) save() error {
+	head := detachedHeadName
+	if s.head != "" {
+		head = s.head.String()
+	}
+
+	for file, content := range map[string]string{
+		rebaseHeadNameFile: head,
+		rebaseOntoFile:     s.onto.String(),
+		rebaseOrigHeadFile: s.orig.String(),
+		rebaseMsgNumFile:   strconv.Itoa(len(s.done)),
+		rebaseEndFile:      strconv.Itoa(len(s.done) + len(s.todo)),
+		rebaseInteractive:  "",
+	} {
+		if err := s.write(file, content); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeSteps(rebaseTodoFile, s.todo, len(s.done)+1); err != nil {
+		return err
+	}
+
+	return s.writeSteps(rebaseDoneFile, s.done, 1)
+}
+
+func (s *rebaseState) saveStop(stop *rebaseStop) error {
+	buf := bytes.NewBuffer(nil)
+	for _, v := range [][2]string{
+		{"GIT_AUTHOR_NAME", stop.author.Name},
+		{"GIT_AUTHOR_EMAIL", stop.author.Email},
+		{"GIT_AUTHOR_DATE", fmt.Sprintf("@%d %s",
+			stop.author.When.Unix(), stop.author.When.Format("-0700"))},
+	} {
+		fmt.Fprintf(buf, "%s=%s\n", v[0], shellQuote(v[1]))
+	}
+
+	files := map[string]string{
+		rebaseStoppedFile: stop.commit.String(),
+		rebaseMessageFile: stop.message,
+		rebaseAuthorFile:  buf.String(),
+	}
+
+	if !stop.amend.IsZero() {
+		files[rebaseAmendFile] = stop.amend.String()
+	}
+
+	for file, content := range files {
+		if err := s.write(file, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadStop returns the step that stopped the rebase due to conflicts, nil if
+// the rebase was not stopped by conflicts.
+func (s *rebaseState) loadStop() (*rebaseStop, error) {
+	commit, err := s.read(rebaseStoppedFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	stop := &rebaseStop{commit: plumbing.NewHash(commit)}
+	if stop.message, err = s.readRaw(rebaseMessageFile); err != nil {
+		return nil, err
+	}
+
+	script, err := s.read(rebaseAuthorFile)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	for _, line := range strings.Split(script, "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			vars[parts[0]] = shellUnquote(parts[1])
+		}
+	}
+
+	stop.author.Decode([]byte(fmt.Sprintf("%s <%s> %s",
+		vars["GIT_AUTHOR_NAME"], vars["GIT_AUTHOR_EMAIL"],
+		strings.TrimPrefix(vars["GIT_AUTHOR_DATE"], "@"),
+	)))
+
+	amend, err := s.read(rebaseAmendFile)
+	if err == nil {
+		stop.amend = plumbing.NewHash(amend)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return stop, nil
+}
+
+func (s *rebaseState) clearStop() error {
+	for _, file := range []string{
+		rebaseStoppedFile, rebaseMessageFile, rebaseAuthorFile, rebaseAmendFile,
+	} {
+		err := s.fs.Remove(path.Join(rebaseMergeDir, file))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSteps writes the list of steps to the given file, first is the number
+// of the first step, used to name the files of their messages.
+func (s *rebaseState) writeSteps(file string, steps []RebaseStep, first int) error {
+	buf := bytes.NewBuffer(nil)
+	for i, step := range steps {
+		if step.Action == ExecAction {
+			fmt.Fprintf(buf, "%s %s\n", step.Action, step.Command)
+			continue
+		}
+
+		fmt.Fprintf(buf, "%s %s\n", step.Action, step.Commit)
+		if step.Message == "" {
+			continue
+		}
+
+		name := path.Join(rebaseMessagesDir, strconv.Itoa(first+i))
+		if err := s.write(name, step.Message); err != nil {
+			return err
+		}
+	}
+
+	return s.write(file, buf.String())
+}
+
+// readSteps reads the list of steps of the given file, first is the number of
+// its first step.
+func (s *rebaseState) readSteps(file string, first int) ([]RebaseStep, error) {
+	content, err := s.read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []RebaseStep
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		step, err := s.parseStep(line, first+len(steps))
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, scanner.Err()
+}
+
+// parseStep parses a line of a git todo list, the short names of the actions
+// used by git are accepted too. The message of the step is read from the file
+// of the step number.
+func (s *rebaseState) parseStep(line string, number int) (RebaseStep, error) {
+	fields := strings.SplitN(line, " ", 2)
+	var step RebaseStep
+	switch fields[0] {
+	case "pick", "p":
+		step.Action = PickAction
+	case "reword", "r":
+		step.Action = RewordAction
+	case "squash", "s":
+		step.Action = SquashAction
+	case "fixup", "f":
+		step.Action = FixupAction
+	case "drop", "d":
+		step.Action = DropAction
+	case "exec", "x":
+		step.Action = ExecAction
+	default:
+		return step, fmt.Errorf("%s: unknown action %q", ErrInvalidRebaseTodo, fields[0])
+	}
+
+	if len(fields) != 2 {
+		return step, fmt.Errorf("%s: missing argument %q", ErrInvalidRebaseTodo, line)
+	}
+
+	if step.Action == ExecAction {
+		step.Command = fields[1]
+		return step, nil
+	}
+
+	step.Commit = plumbing.NewHash(strings.SplitN(fields[1], " ", 2)[0])
+	msg, err := s.readRaw(path.Join(rebaseMessagesDir, strconv.Itoa(number)))
+	if err != nil && !os.IsNotExist(err) {
+		return step, err
+	}
+
+	step.Message = msg
+	return step, nil
+}
+
+func (s *rebaseState) write(file, content string) error {
+	return util.WriteFile(s.fs, path.Join(rebaseMergeDir, file), []byte(content), 0644)
+}
+
+// read returns the content of the file without the trailing new line.
+func (s *rebaseState) read(file string) (string, error) {
+	content, err := s.readRaw(file)
+	return strings.TrimRight(content, "\n"), err
+}
+
+func (s *rebaseState) readRaw(file string) (string, error) {
+	f, err := s.fs.Open(path.Join(rebaseMergeDir, file))
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := buf.ReadFrom(f); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// shellQuote quotes s as a single quoted sh string.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func shellUnquote(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "'"), "'")
+	return strings.Replace(s, `'\''`, "'", -1)
+}
+
+// rebaseOnto replays the commits of the branch at head that aren't reachable
+// from onto on top of it, the merge commits are dropped. If a commit can't be
+// replayed cleanly the branch is restored and ErrRebaseConflict is returned.
+func (w *Worktree) rebaseOnto(head *plumbing.Reference, onto plumbing.Hash,
+	committer *object.Signature) error {
+
+	if err := w.checkCleanForMerge(); err != nil {
+		return err
+	}
+
+	tip, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	base, err := w.r.CommitObject(onto)
+	if err != nil {
+		return err
+	}
+
+	commits, err := commitsToRebase(tip, base)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Reset(&ResetOptions{Mode: HardReset, Commit: onto}); err != nil {
+		return err
+	}
+
+	for _, c := range commits {
+		if err := w.replayCommit(c, committer); err != nil {
+			if rerr := w.Reset(&ResetOptions{
+				Mode:   HardReset,
+				Commit: tip.Hash,
+			}); rerr != nil {
+				return rerr
+			}
+
+			if rerr := w.removeMergeState(); rerr != nil {
+				return rerr
+			}
+
+			if err == ErrMergeConflict {
+				return ErrRebaseConflict
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replayCommit cherry-picks c on top of HEAD, commits that become empty are
+// skipped.
+func (w *Worktree) replayCommit(c *object.Commit, committer *object.Signature) error {
+	_, err := w.CherryPick(c.Hash, &CherryPickOptions{Committer: committer})
+	if err == ErrEmptyCommit {
+		return nil
+	}
+
+	return err
+}
+
+// commitsToRebase returns the non merge commits reachable from tip and not
+// from upstream, sorted to be replayed, parents before their children. If
+// upstream is nil all the commits reachable from tip are returned.
+//
+// The history is walked from tip down to the merge bases with upstream, as
+// long as no merge commit is found the first commit reachable from upstream
+// is a merge base, the commits reached past a merge are checked to be
+// ancestors of upstream to skip the ones it already contains.
+func commitsToRebase(tip, upstream *object.Commit) ([]*object.Commit, error) {
+	bases := make(map[plumbing.Hash]bool)
+	if upstream != nil {
+		found, err := tip.MergeBase(upstream)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range found {
+			bases[b.Hash] = true
+		}
+	}
+
+	merges := false
+	contained := func(c *object.Commit) (bool, error) {
+		switch {
+		case upstream == nil:
+			return false, nil
+		case bases[c.Hash]:
+			return true, nil
+		case !merges:
+			return false, nil
+		}
+
+		return c.IsAncestor(upstream)
+	}
+
+	type frame struct {
+		commit *object.Commit
+		parent int
+	}
+
+	var commits []*object.Commit
+	var stack []*frame
+	visited := make(map[plumbing.Hash]bool)
+	push := func(c *object.Commit) error {
+		visited[c.Hash] = true
+		skip, err := contained(c)
+		if err != nil || skip {
+			return err
+		}
+
+		if c.NumParents() > 1 {
+			merges = true
+		}
+
+		stack = append(stack, &frame{commit: c})
+		return nil
+	}
+
+	if err := push(tip); err != nil {
+		return nil, err
+	}
+
+	for len(stack) != 0 {
+		f := stack[len(stack)-1]
+		if f.parent == f.commit.NumParents() {
+			stack = stack[:len(stack)-1]
+			if f.commit.NumParents() <= 1 {
+				commits = append(commits, f.commit)
+			}
+
+			continue
+		}
+
+		i := f.parent
+		f.parent++
+		if visited[f.commit.ParentHashes[i]] {
+			continue
+		}
+
+		parent, err := f.commit.Parent(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := push(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	return commits, nil
+}
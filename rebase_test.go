@@ -0,0 +1,542 @@
+package git
+
+import (
+	"errors"
+	"io/ioutil"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+type RebaseSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RebaseSuite{})
+
+// newRebaseRepository returns a repository, using a filesystem storage, with
+// a master and a feature branch forked from it. The feature branch, with the
+// given commits, is checked out.
+func newRebaseRepository(c *C, base, master map[string]string,
+	feature ...map[string]string) (r *Repository, dotgit billy.Filesystem) {
+
+	dotgit = memfs.New()
+	st, err := filesystem.NewStorage(dotgit)
+	c.Assert(err, IsNil)
+
+	r, err = Init(st, memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, base)
+	err = w.Checkout(&CheckoutOptions{
+		Branch: plumbing.ReferenceName("refs/heads/feature"),
+		Create: true,
+	})
+	c.Assert(err, IsNil)
+
+	for _, files := range feature {
+		commitFiles(c, w, files)
+	}
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, master)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature"})
+	c.Assert(err, IsNil)
+
+	return r, dotgit
+}
+
+func masterHash(c *C, r *Repository) plumbing.Hash {
+	ref, err := r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	return ref.Hash()
+}
+
+// rebasedCommits returns the commits of the feature branch not reachable
+// from master, the newest first.
+func rebasedCommits(c *C, r *Repository) []string {
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/feature"))
+
+	master := masterHash(c, r)
+
+	var messages []string
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	for commit.Hash != master {
+		messages = append(messages, commit.Message)
+		c.Assert(commit.NumParents(), Equals, 1)
+
+		commit, err = commit.Parent(0)
+		c.Assert(err, IsNil)
+	}
+
+	return messages
+}
+
+func (s *RebaseSuite) TestRebaseInvalidOptions(c *C) {
+	r, _ := newRebaseRepository(c, map[string]string{"foo": "foo\n"}, nil)
+
+	err := r.Rebase(&RebaseOptions{})
+	c.Assert(err, Equals, ErrMissingUpstream)
+
+	err = r.Rebase(&RebaseOptions{Upstream: masterHash(c, r)})
+	c.Assert(err, Equals, ErrMissingCommitter)
+}
+
+func (s *RebaseSuite) TestRebaseNotSupported(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrRebaseNotSupported)
+}
+
+func (s *RebaseSuite) TestRebase(c *C) {
+	r, dotgit := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+		map[string]string{"qux": "qux\n"},
+	)
+
+	orig, err := r.Head()
+	c.Assert(err, IsNil)
+
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), DeepEquals, []string{"files\n", "files\n"})
+
+	ref, err := r.Reference(origHeadRef, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, orig.Hash())
+
+	_, err = dotgit.Stat(rebaseMergeDir)
+	c.Assert(err, NotNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	for _, name := range []string{"foo", "bar", "baz", "qux"} {
+		assertFileContent(c, w, name, name+"\n")
+	}
+}
+
+func (s *RebaseSuite) TestRebaseMergedUpstream(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.Merge(&MergeOptions{
+		Commit: masterHash(c, r),
+		Author: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, map[string]string{"qux": "qux\n"})
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, map[string]string{"quux": "quux\n"})
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature"})
+	c.Assert(err, IsNil)
+
+	// the commit of master reached through the merge isn't replayed
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), DeepEquals, []string{"files\n", "files\n"})
+
+	for _, name := range []string{"foo", "bar", "baz", "qux", "quux"} {
+		assertFileContent(c, w, name, name+"\n")
+	}
+}
+
+func (s *RebaseSuite) TestRebaseBranchNotClean(c *C) {
+	r, dotgit := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "bar", []byte("qux\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Branch:    "refs/heads/feature",
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+	assertFileContent(c, w, "bar", "qux\n")
+
+	_, err = dotgit.Stat(rebaseMergeDir)
+	c.Assert(err, NotNil)
+}
+
+func (s *RebaseSuite) TestRebaseBranch(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Branch:    "refs/heads/feature",
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), DeepEquals, []string{"files\n"})
+}
+
+func (s *RebaseSuite) TestRebaseTodo(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"a": "a\n"},
+		map[string]string{"b": "b\n"},
+		map[string]string{"c": "c\n"},
+		map[string]string{"d": "d\n"},
+		map[string]string{"e": "e\n"},
+	)
+
+	var commands []string
+	err := r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+		Todo: func(steps []RebaseStep) ([]RebaseStep, error) {
+			c.Assert(steps, HasLen, 5)
+
+			steps[0].Action = RewordAction
+			steps[0].Message = "a\n"
+			steps[1].Action = SquashAction
+			steps[2].Action = DropAction
+			steps[3].Message = "d\n"
+			steps[4].Action = FixupAction
+			return append(steps, RebaseStep{Action: ExecAction, Command: "test"}), nil
+		},
+		Exec: func(cmd string) error {
+			commands = append(commands, cmd)
+			return nil
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(commands, DeepEquals, []string{"test"})
+	c.Assert(rebasedCommits(c, r), DeepEquals, []string{"files\n", "a\n\nfiles\n"})
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	assertFileContent(c, w, "e", "e\n")
+
+	_, err = w.Filesystem.Stat("c")
+	c.Assert(err, NotNil)
+}
+
+func (s *RebaseSuite) TestRebaseInvalidTodo(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"a": "a\n"},
+	)
+
+	err := r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+		Todo: func(steps []RebaseStep) ([]RebaseStep, error) {
+			steps[0].Action = FixupAction
+			return steps, nil
+		},
+	})
+	c.Assert(err, ErrorMatches, ErrInvalidRebaseTodo.Error()+".*")
+}
+
+func (s *RebaseSuite) TestRebaseConflictContinue(c *C) {
+	r, dotgit := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"foo": "bar\n"},
+		map[string]string{"foo": "baz\n"},
+		map[string]string{"qux": "qux\n"},
+	)
+
+	err := r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrRebaseConflict)
+
+	for _, file := range []string{"head-name", "onto", "orig-head",
+		"git-rebase-todo", "done", "stopped-sha", "author-script", "message"} {
+		_, err := dotgit.Stat(rebaseMergeDir + "/" + file)
+		c.Assert(err, IsNil, Commentf("file %s", file))
+	}
+
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrRebaseInProgress)
+
+	err = r.RebaseContinue(&RebaseOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrUnmergedEntries)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("bar\nbaz\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	err = r.RebaseContinue(&RebaseOptions{Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), HasLen, 2)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	parent, err := commit.Parent(0)
+	c.Assert(err, IsNil)
+	c.Assert(parent.Author, DeepEquals, *defaultSignature())
+
+	assertFileContent(c, w, "foo", "bar\nbaz\n")
+	assertFileContent(c, w, "qux", "qux\n")
+
+	err = r.RebaseContinue(&RebaseOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrNoRebaseInProgress)
+}
+
+func (s *RebaseSuite) TestRebaseContinueEmptyWithUntracked(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"foo": "bar\n"},
+		map[string]string{"foo": "baz\n"},
+		map[string]string{"qux": "qux\n"},
+	)
+
+	err := r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrRebaseConflict)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("bar\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "untracked", []byte("untracked\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = r.RebaseContinue(&RebaseOptions{Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), HasLen, 1)
+	assertFileContent(c, w, "qux", "qux\n")
+}
+
+func (s *RebaseSuite) TestRebaseSkip(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"foo": "bar\n"},
+		map[string]string{"foo": "baz\n"},
+		map[string]string{"qux": "qux\n"},
+	)
+
+	err := r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrRebaseConflict)
+
+	err = r.RebaseSkip(&RebaseOptions{Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), HasLen, 1)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	assertFileContent(c, w, "foo", "bar\n")
+	assertFileContent(c, w, "qux", "qux\n")
+}
+
+func (s *RebaseSuite) TestRebaseAbort(c *C) {
+	r, dotgit := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"foo": "bar\n"},
+		map[string]string{"qux": "qux\n"},
+		map[string]string{"foo": "baz\n"},
+	)
+
+	orig, err := r.Head()
+	c.Assert(err, IsNil)
+
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrRebaseConflict)
+
+	err = r.RebaseAbort()
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, orig.Name())
+	c.Assert(head.Hash(), Equals, orig.Hash())
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	assertFileContent(c, w, "foo", "baz\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	_, err = dotgit.Stat(rebaseMergeDir)
+	c.Assert(err, NotNil)
+}
+
+func (s *RebaseSuite) TestRebaseExecFailure(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"a": "a\n"},
+		map[string]string{"b": "b\n"},
+	)
+
+	fail := errors.New("failed")
+	exec := func(string) error { return fail }
+
+	err := r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+		Todo: func(steps []RebaseStep) ([]RebaseStep, error) {
+			return []RebaseStep{
+				steps[0], {Action: ExecAction, Command: "test"}, steps[1],
+			}, nil
+		},
+		Exec: exec,
+	})
+	c.Assert(err, Equals, fail)
+
+	err = r.RebaseContinue(&RebaseOptions{Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), HasLen, 2)
+}
+
+func (s *RebaseSuite) TestRebaseExecFailureMessages(c *C) {
+	r, dotgit := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"a": "a\n"},
+	)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+
+	fail := errors.New("failed")
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  masterHash(c, r),
+		Committer: defaultSignature(),
+		Todo: func(steps []RebaseStep) ([]RebaseStep, error) {
+			return []RebaseStep{
+				{Action: PickAction, Commit: head.Hash()},
+				{Action: ExecAction, Command: "test"},
+				{Action: RewordAction, Commit: head.Hash(), Message: "second\n"},
+			}, nil
+		},
+		Exec: func(string) error { return fail },
+	})
+	c.Assert(err, Equals, fail)
+
+	// the messages are kept by step number, the same commit can be in several
+	// steps
+	f, err := dotgit.Open(rebaseMergeDir + "/" + rebaseMessagesDir + "/3")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(string(content), Equals, "second\n")
+
+	state, err := loadRebaseState(dotgit)
+	c.Assert(err, IsNil)
+	c.Assert(state.done, HasLen, 2)
+	c.Assert(state.done[0].Message, Equals, "")
+	c.Assert(state.todo, HasLen, 1)
+	c.Assert(state.todo[0].Message, Equals, "second\n")
+}
+
+func (s *RebaseSuite) TestRebaseOnto(c *C) {
+	r, _ := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"a": "a\n"},
+		map[string]string{"b": "b\n"},
+	)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+
+	err = r.Rebase(&RebaseOptions{
+		Upstream:  commit.ParentHashes[0],
+		Onto:      masterHash(c, r),
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rebasedCommits(c, r), HasLen, 1)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	assertFileContent(c, w, "b", "b\n")
+
+	_, err = w.Filesystem.Stat("a")
+	c.Assert(err, NotNil)
+}
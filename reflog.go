@@ -0,0 +1,236 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/internal/revision"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+)
+
+// ErrReflogNotSupported is returned when the storer of the repository doesn't
+// keep reflogs.
+var ErrReflogNotSupported = errors.New("reflogs not supported by the storer")
+
+// Reflog returns an iterator over the entries of the reflog of the given
+// reference, the most recent first. The position of an entry in the iterator
+// is the number used to refer to it, as <name>@{n}.
+func (r *Repository) Reflog(name plumbing.ReferenceName) (storer.ReflogIter, error) {
+	entries, err := r.reflog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewReflogSliceIter(entries), nil
+}
+
+// ExpireReflog removes the old entries of the reflogs, as `git reflog expire`
+// does.
+func (r *Repository) ExpireReflog(o *ExpireReflogOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return ErrReflogNotSupported
+	}
+
+	names := o.References
+	if len(names) == 0 {
+		var err error
+		if names, err = r.loggedReferences(); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		if err := r.expireReflog(rs, name, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) expireReflog(rs storer.ReflogStorer, name plumbing.ReferenceName,
+	o *ExpireReflogOptions) error {
+
+	entries, err := rs.Reflog(name)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	var reachable map[plumbing.Hash]bool
+	var kept []*reflog.Entry
+	for _, e := range entries {
+		if e.When.Before(o.Expire) {
+			continue
+		}
+
+		if e.When.Before(o.ExpireUnreachable) {
+			if reachable == nil {
+				if reachable, err = r.reachableFrom(name); err != nil {
+					return err
+				}
+			}
+
+			if !reachable[e.New] {
+				continue
+			}
+		}
+
+		kept = append(kept, e)
+	}
+
+	if len(kept) == len(entries) {
+		return nil
+	}
+
+	return rs.SetReflog(name, kept)
+}
+
+// loggedReferences returns HEAD and every reference of the repository, the
+// ones without reflog are ignored by the expiry.
+func (r *Repository) loggedReferences() ([]plumbing.ReferenceName, error) {
+	names := []plumbing.ReferenceName{plumbing.HEAD}
+	refs, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() != plumbing.HEAD {
+			names = append(names, ref.Name())
+		}
+
+		return nil
+	})
+
+	return names, err
+}
+
+// reachableFrom returns the commits reachable from the current value of the
+// given reference.
+func (r *Repository) reachableFrom(name plumbing.ReferenceName) (map[plumbing.Hash]bool, error) {
+	reachable := make(map[plumbing.Hash]bool)
+	ref, err := storer.ResolveReference(r.Storer, name)
+	if err == plumbing.ErrReferenceNotFound {
+		return reachable, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	err = object.NewCommitPreorderIter(c, nil, nil).ForEach(func(c *object.Commit) error {
+		reachable[c.Hash] = true
+		return nil
+	})
+
+	return reachable, err
+}
+
+// reflog returns the entries of the reflog of the given reference, the most
+// recent first.
+func (r *Repository) reflog(name plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return nil, ErrReflogNotSupported
+	}
+
+	stored, err := rs.Reflog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// the storage may return its own slice, so it's reversed into a copy
+	entries := make([]*reflog.Entry, len(stored))
+	for i, e := range stored {
+		entries[len(stored)-1-i] = e
+	}
+
+	return entries, nil
+}
+
+// resolveReflog returns the value of the given reference described by a
+// <name>@{n} or <name>@{date} item. If name is empty the current branch is
+// used.
+func (r *Repository) resolveReflog(name plumbing.ReferenceName, item revision.Revisioner) (plumbing.Hash, error) {
+	if name == "" {
+		head, err := r.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		name = plumbing.HEAD
+		if head.Type() == plumbing.SymbolicReference {
+			name = head.Target()
+		}
+	}
+
+	entries, err := r.reflog(name)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, fmt.Errorf(`log for "%s" is empty`, name.Short())
+	}
+
+	switch item := item.(type) {
+	case revision.AtReflog:
+		if item.Depth >= len(entries) {
+			return plumbing.ZeroHash, fmt.Errorf(`log for "%s" only has %d entries`,
+				name.Short(), len(entries))
+		}
+
+		return entries[item.Depth].New, nil
+	case revision.AtDate:
+		return reflogValueAt(entries, item.Date), nil
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("unsupported reflog revision %T", item)
+}
+
+// reflogValueAt returns the value of the reference at the given time, being
+// entries its reflog, the most recent first. The oldest value is returned if
+// the log doesn't go back to the time.
+func reflogValueAt(entries []*reflog.Entry, t time.Time) plumbing.Hash {
+	for _, e := range entries {
+		if !e.When.After(t) {
+			return e.New
+		}
+	}
+
+	oldest := entries[len(entries)-1]
+	if oldest.Old.IsZero() {
+		return oldest.New
+	}
+
+	return oldest.Old
+}
+
+// setReferenceWithLog stores the reference, checking its previous value if old
+// is not nil, and describes the update in the reflog with the given message.
+// The identity of sig is used if not nil, otherwise the storer picks it. As in
+// git, the update is logged with the current time, not the one of sig.
+func setReferenceWithLog(s storer.ReferenceStorer, ref, old *plumbing.Reference,
+	msg string, sig *object.Signature) error {
+
+	e := &reflog.Entry{Message: msg}
+	if sig != nil {
+		e.Name, e.Email = sig.Name, sig.Email
+	}
+
+	return storer.SetReferenceWithLog(s, ref, old, e)
+}
@@ -0,0 +1,239 @@
+package git
+
+import (
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	. "gopkg.in/check.v1"
+)
+
+type ReflogSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ReflogSuite{})
+
+// newReflogRepository returns a repository, using a filesystem storage, with
+// the given configuration of core.logAllRefUpdates.
+func newReflogRepository(c *C, logAllRefUpdates string) *Repository {
+	st, err := filesystem.NewStorage(memfs.New())
+	c.Assert(err, IsNil)
+
+	r, err := Init(st, memfs.New())
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.LogAllRefUpdates = logAllRefUpdates
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	return r
+}
+
+func reflogMessages(c *C, r *Repository, name plumbing.ReferenceName) []string {
+	iter, err := r.Reflog(name)
+	c.Assert(err, IsNil)
+
+	var messages []string
+	err = iter.ForEach(func(e *reflog.Entry) error {
+		messages = append(messages, e.Message)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	return messages
+}
+
+// backdateReflog sets the time of all the entries of the reflog of the given
+// reference, as if they were logged at the given time.
+func backdateReflog(c *C, r *Repository, name plumbing.ReferenceName, when time.Time) {
+	st := r.Storer.(*filesystem.Storage)
+	entries, err := st.Reflog(name)
+	c.Assert(err, IsNil)
+
+	for _, e := range entries {
+		e.When = when
+	}
+
+	c.Assert(st.SetReflog(name, entries), IsNil)
+}
+
+func (s *ReflogSuite) TestReflog(c *C) {
+	r := newReflogRepository(c, "")
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	first := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	second := commitFiles(c, w, map[string]string{"bar": "bar\n"})
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Create: true})
+	c.Assert(err, IsNil)
+
+	err = w.Reset(&ResetOptions{Mode: HardReset, Commit: first})
+	c.Assert(err, IsNil)
+
+	c.Assert(reflogMessages(c, r, plumbing.Master), DeepEquals, []string{
+		"commit: files",
+		"commit (initial): files",
+	})
+
+	c.Assert(reflogMessages(c, r, "refs/heads/feature"), DeepEquals, []string{
+		"reset: moving to " + first.String(),
+		"branch: Created from " + second.String(),
+	})
+
+	c.Assert(reflogMessages(c, r, plumbing.HEAD), DeepEquals, []string{
+		"reset: moving to " + first.String(),
+		"checkout: moving from master to feature",
+		"commit: files",
+		"commit (initial): files",
+	})
+
+	iter, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+
+	e, err := iter.Next()
+	c.Assert(err, IsNil)
+	c.Assert(e.Old, Equals, second)
+	c.Assert(e.New, Equals, first)
+
+	// logged with the current time, not the one of the commit
+	c.Assert(e.When.After(defaultSignature().When), Equals, true)
+}
+
+func (s *ReflogSuite) TestReflogMemoryStorage(c *C) {
+	st := memory.NewStorage()
+	r, err := Init(st, nil)
+	c.Assert(err, IsNil)
+
+	for _, msg := range []string{"one", "two"} {
+		err := st.AppendReflog("refs/stash", &reflog.Entry{Message: msg})
+		c.Assert(err, IsNil)
+	}
+
+	for i := 0; i < 2; i++ {
+		c.Assert(reflogMessages(c, r, "refs/stash"), DeepEquals, []string{"two", "one"})
+	}
+}
+
+func (s *ReflogSuite) TestReflogMemoryStorageUpdates(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Create: true})
+	c.Assert(err, IsNil)
+
+	c.Assert(reflogMessages(c, r, plumbing.Master), DeepEquals, []string{
+		"commit (initial): files",
+	})
+
+	c.Assert(reflogMessages(c, r, plumbing.HEAD), DeepEquals, []string{
+		"checkout: moving from master to feature",
+		"commit (initial): files",
+	})
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Storer.RemoveReference("refs/heads/feature"), IsNil)
+	c.Assert(reflogMessages(c, r, "refs/heads/feature"), HasLen, 0)
+}
+
+func (s *ReflogSuite) TestReflogDisabled(c *C) {
+	r := newReflogRepository(c, "false")
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	c.Assert(reflogMessages(c, r, plumbing.Master), HasLen, 0)
+	c.Assert(reflogMessages(c, r, plumbing.HEAD), HasLen, 0)
+}
+
+func (s *ReflogSuite) TestReflogAlways(c *C) {
+	r := newReflogRepository(c, "always")
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	h := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	err = r.Storer.SetReference(plumbing.NewHashReference("refs/tags/v1", h))
+	c.Assert(err, IsNil)
+
+	c.Assert(reflogMessages(c, r, "refs/tags/v1"), HasLen, 1)
+}
+
+func (s *ReflogSuite) TestResolveRevision(c *C) {
+	r := newReflogRepository(c, "")
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	first := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	second := commitFiles(c, w, map[string]string{"bar": "bar\n"})
+	backdateReflog(c, r, plumbing.Master, defaultSignature().When)
+
+	err = w.Reset(&ResetOptions{Mode: HardReset, Commit: first})
+	c.Assert(err, IsNil)
+
+	for rev, expected := range map[string]plumbing.Hash{
+		"master@{0}":                    first,
+		"master@{1}":                    second,
+		"master@{2}":                    first,
+		"HEAD@{1}":                      second,
+		"@{1}":                          second,
+		"master@{2017-05-03T22:03:43Z}": second,
+		"master@{2000-01-01T00:00:00Z}": first,
+	} {
+		h, err := r.ResolveRevision(plumbing.Revision(rev))
+		c.Assert(err, IsNil, Commentf("revision %s", rev))
+		c.Assert(*h, Equals, expected, Commentf("revision %s", rev))
+	}
+
+	_, err = r.ResolveRevision("master@{3}")
+	c.Assert(err, ErrorMatches, `log for "master" only has 3 entries`)
+}
+
+func (s *ReflogSuite) TestExpireReflog(c *C) {
+	r := newReflogRepository(c, "")
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	first := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	commitFiles(c, w, map[string]string{"bar": "bar\n"})
+	backdateReflog(c, r, plumbing.Master, defaultSignature().When)
+	backdateReflog(c, r, plumbing.HEAD, defaultSignature().When)
+
+	err = w.Reset(&ResetOptions{Mode: HardReset, Commit: first})
+	c.Assert(err, IsNil)
+
+	// the commits are logged in 2017, the reset with the current time.
+	err = r.ExpireReflog(&ExpireReflogOptions{
+		References:        []plumbing.ReferenceName{plumbing.Master},
+		Expire:            time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExpireUnreachable: time.Now().Add(-time.Hour),
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(reflogMessages(c, r, plumbing.Master), DeepEquals, []string{
+		"reset: moving to " + first.String(),
+		"commit (initial): files",
+	})
+	c.Assert(reflogMessages(c, r, plumbing.HEAD), HasLen, 3)
+
+	err = r.ExpireReflog(&ExpireReflogOptions{})
+	c.Assert(err, IsNil)
+
+	c.Assert(reflogMessages(c, r, plumbing.Master), DeepEquals, []string{
+		"reset: moving to " + first.String(),
+	})
+	c.Assert(reflogMessages(c, r, plumbing.HEAD), HasLen, 1)
+}
@@ -215,7 +215,7 @@ func (r *Remote) updateRemoteReferenceStorage(
 			ref := plumbing.NewHashReference(local, c.New)
 			switch c.Action() {
 			case packp.Create, packp.Update:
-				if err := r.s.SetReference(ref); err != nil {
+				if err := setReferenceWithLog(r.s, ref, nil, "update by push", nil); err != nil {
 					return err
 				}
 			case packp.Delete:
@@ -760,17 +760,16 @@ func isFastForward(s storer.EncodedObjectStorer, old, new plumbing.Hash) (bool,
 		return false, err
 	}
 
-	found := false
-	iter := object.NewCommitPreorderIter(c, nil, nil)
-	err = iter.ForEach(func(c *object.Commit) error {
-		if c.Hash != old {
-			return nil
-		}
+	oldCommit, err := object.GetCommit(s, old)
+	if err == plumbing.ErrObjectNotFound {
+		return false, nil
+	}
 
-		found = true
-		return storer.ErrStop
-	})
-	return found, err
+	if err != nil {
+		return false, err
+	}
+
+	return oldCommit.IsAncestor(c)
 }
 
 func (r *Remote) newUploadPackRequest(o *FetchOptions,
@@ -867,7 +866,12 @@ func (r *Remote) updateLocalReferenceStorage(
 				}
 			}
 
-			refUpdated, err := checkAndUpdateReferenceStorerIfNeeded(r.s, new, old)
+			var msg string
+			if old == nil || old.Hash() != new.Hash() {
+				msg = r.fetchReflogMessage(old, new)
+			}
+
+			refUpdated, err := checkAndUpdateReferenceStorerIfNeeded(r.s, new, old, msg)
 			if err != nil {
 				return updated, err
 			}
@@ -902,6 +906,22 @@ func (r *Remote) updateLocalReferenceStorage(
 	return
 }
 
+// fetchReflogMessage returns the reflog message of a reference updated by a
+// fetch from old to new, old is nil for new references. The history of
+// shallow repositories may be incomplete, so updates whose history can't be
+// walked are considered forced.
+func (r *Remote) fetchReflogMessage(old, new *plumbing.Reference) string {
+	reason := "storing head"
+	if old != nil {
+		reason = "forced-update"
+		if ff, err := isFastForward(r.s, old.Hash(), new.Hash()); err == nil && ff {
+			reason = "fast-forward"
+		}
+	}
+
+	return fmt.Sprintf("fetch %s: %s", r.c.Name, reason)
+}
+
 func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, err error) {
 	for _, ref := range refs {
 		if !ref.Name().IsTag() {
@@ -917,7 +937,8 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, e
 			return false, err
 		}
 
-		refUpdated, err := updateReferenceStorerIfNeeded(r.s, ref)
+		msg := fmt.Sprintf("fetch %s: storing head", r.c.Name)
+		refUpdated, err := updateReferenceStorerIfNeeded(r.s, ref, msg)
 		if err != nil {
 			return updated, err
 		}
@@ -0,0 +1,387 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+)
+
+var (
+	// ErrReplacementExists is returned when replacing an object that is
+	// already replaced, without forcing it.
+	ErrReplacementExists = errors.New("replace ref already exists")
+	// ErrReplacementNotFound is returned when deleting the replacement of an
+	// object that isn't replaced.
+	ErrReplacementNotFound = errors.New("replace ref not found")
+	// ErrReplacementType is returned when an object is replaced by an object
+	// of a different type, without forcing it.
+	ErrReplacementType = errors.New("objects must be of the same type")
+	// ErrReplaceItself is returned when an object is replaced by itself.
+	ErrReplaceItself = errors.New("an object can't be replaced by itself")
+	// ErrReplaceDepth is returned when an object is replaced through too many
+	// replacements, most likely a cycle.
+	ErrReplaceDepth = errors.New("replace depth too high")
+)
+
+const (
+	// noReplaceObjectsEnv is the environment variable that disables the
+	// replacements and grafts, as in git.
+	noReplaceObjectsEnv = "GIT_NO_REPLACE_OBJECTS"
+	graftsPath          = "info/grafts"
+	replaceRefPrefix    = "refs/replace/"
+	// maxReplaceDepth is the longest chain of replacements followed.
+	maxReplaceDepth = 5
+)
+
+// ReplaceObject replaces the object with the replacement, creating the
+// refs/replace/<object> reference. Once replaced, the objects read through the
+// repository, and the commits walked from them, have the content of the
+// replacement under the hash of the original object, unless the replacements
+// are disabled with core.useReplaceRefs or the GIT_NO_REPLACE_OBJECTS
+// environment variable. As in git, the replacements are read once, the changes
+// not made through the repository are seen when the repository is opened
+// again.
+func (r *Repository) ReplaceObject(h, replacement plumbing.Hash, o *ReplaceOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if h == replacement {
+		return ErrReplaceItself
+	}
+
+	original, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return err
+	}
+
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, replacement)
+	if err != nil {
+		return err
+	}
+
+	if original.Type() != obj.Type() && !o.Force {
+		return ErrReplacementType
+	}
+
+	return r.setReplaceRef(h, replacement, o.Force)
+}
+
+// GraftCommit replaces the commit with a copy of it with the given parents, as
+// `git replace --graft` does. The signature of the commit, if any, is dropped.
+// The hash of the replacement commit is returned.
+func (r *Repository) GraftCommit(h plumbing.Hash, parents []plumbing.Hash, o *ReplaceOptions) (plumbing.Hash, error) {
+	if err := o.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	replacement, err := r.graftCommitObject(h, parents)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if replacement == h {
+		return plumbing.ZeroHash, ErrReplaceItself
+	}
+
+	return replacement, r.setReplaceRef(h, replacement, o.Force)
+}
+
+func (r *Repository) graftCommitObject(h plumbing.Hash, parents []plumbing.Hash) (plumbing.Hash, error) {
+	commit, err := object.GetCommit(r.Storer, h)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	for _, p := range parents {
+		if _, err := object.GetCommit(r.Storer, p); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	graft := *commit
+	graft.ParentHashes = parents
+	graft.PGPSignature = ""
+
+	obj := r.Storer.NewEncodedObject()
+	if err := graft.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.Storer.SetEncodedObject(obj)
+}
+
+func (r *Repository) setReplaceRef(h, replacement plumbing.Hash, force bool) error {
+	name := plumbing.ReferenceName(replaceRefPrefix + h.String())
+	_, err := r.Storer.Reference(name)
+	if err == nil && !force {
+		return ErrReplacementExists
+	}
+
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	defer r.resetObjectStorer()
+	return r.Storer.SetReference(plumbing.NewHashReference(name, replacement))
+}
+
+// Replacements returns all the references replacing objects, the name of each
+// reference ends with the hash of the replaced object.
+func (r *Repository) Replacements() (storer.ReferenceIter, error) {
+	refIter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewReferenceFilteredIter(
+		func(r *plumbing.Reference) bool {
+			return r.Name().IsReplace()
+		}, refIter), nil
+}
+
+// DeleteReplacement deletes the replacement of the object, if the object isn't
+// replaced ErrReplacementNotFound is returned.
+func (r *Repository) DeleteReplacement(h plumbing.Hash) error {
+	name := plumbing.ReferenceName(replaceRefPrefix + h.String())
+	_, err := r.Storer.Reference(name)
+	if err == plumbing.ErrReferenceNotFound {
+		return ErrReplacementNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer r.resetObjectStorer()
+	return r.Storer.RemoveReference(name)
+}
+
+// ConvertGrafts converts the grafts of the legacy info/grafts file to grafted
+// replacement commits, as `git replace --convert-graft-file` does, removing
+// the file afterwards. The hashes of the converted commits are returned.
+func (r *Repository) ConvertGrafts() ([]plumbing.Hash, error) {
+	grafts, err := r.grafts()
+	if err != nil || len(grafts) == 0 {
+		return nil, err
+	}
+
+	var converted []plumbing.Hash
+	for _, g := range grafts {
+		if _, err := r.GraftCommit(g.commit, g.parents, &ReplaceOptions{Force: true}); err != nil {
+			return converted, err
+		}
+
+		converted = append(converted, g.commit)
+	}
+
+	defer r.resetObjectStorer()
+	return converted, r.dotGitFilesystem().Remove(graftsPath)
+}
+
+type graft struct {
+	commit  plumbing.Hash
+	parents []plumbing.Hash
+}
+
+// grafts reads the info/grafts file, a commit followed by its parents per
+// line, empty lines and comments are ignored.
+func (r *Repository) grafts() (grafts []graft, err error) {
+	fs := r.dotGitFilesystem()
+	if fs == nil {
+		return nil, nil
+	}
+
+	f, err := fs.Open(graftsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var hashes []plumbing.Hash
+		for _, hex := range strings.Fields(line) {
+			h := plumbing.NewHash(hex)
+			if h.String() != hex {
+				return nil, fmt.Errorf("bad graft data: %s", line)
+			}
+
+			hashes = append(hashes, h)
+		}
+
+		grafts = append(grafts, graft{hashes[0], hashes[1:]})
+	}
+
+	return grafts, s.Err()
+}
+
+// objectStorer returns the storer to read the objects of the repository, that
+// honors the replacements and the grafts unless they are disabled. As git
+// does, the replacements, the grafts and core.useReplaceRefs are read once,
+// and read again only after they are changed through the repository or
+// references are fetched.
+func (r *Repository) objectStorer() (storer.EncodedObjectStorer, error) {
+	if os.Getenv(noReplaceObjectsEnv) != "" {
+		return r.Storer, nil
+	}
+
+	r.replaceMu.Lock()
+	defer r.replaceMu.Unlock()
+
+	if r.replaceStorer == nil {
+		s, err := r.newObjectStorer()
+		if err != nil {
+			return nil, err
+		}
+
+		r.replaceStorer = s
+	}
+
+	return r.replaceStorer, nil
+}
+
+// resetObjectStorer discards the replacements and grafts read by
+// objectStorer.
+func (r *Repository) resetObjectStorer() {
+	r.replaceMu.Lock()
+	r.replaceStorer = nil
+	r.replaceMu.Unlock()
+}
+
+func (r *Repository) newObjectStorer() (storer.EncodedObjectStorer, error) {
+	s := &replaceObjectStorer{EncodedObjectStorer: r.Storer}
+	refs, err := r.Replacements()
+	if err != nil {
+		return nil, err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		hex := strings.TrimPrefix(ref.Name().String(), replaceRefPrefix)
+		h := plumbing.NewHash(hex)
+		if ref.Type() != plumbing.HashReference || h.String() != hex {
+			return nil
+		}
+
+		if s.replace == nil {
+			s.replace = map[plumbing.Hash]plumbing.Hash{}
+		}
+
+		s.replace[h] = ref.Hash()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	grafts, err := r.grafts()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(grafts) != 0 {
+		s.grafts = map[plumbing.Hash][]plumbing.Hash{}
+		for _, g := range grafts {
+			s.grafts[g.commit] = g.parents
+		}
+	}
+
+	if s.replace == nil && s.grafts == nil {
+		return r.Storer, nil
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Raw.Section("core").Option("useReplaceRefs") == "false" {
+		return r.Storer, nil
+	}
+
+	return s, nil
+}
+
+// replaceObjectStorer reads the replacement of the replaced objects, and the
+// grafted parents of the grafted commits, keeping the hash of the original
+// object.
+type replaceObjectStorer struct {
+	storer.EncodedObjectStorer
+	replace map[plumbing.Hash]plumbing.Hash
+	grafts  map[plumbing.Hash][]plumbing.Hash
+}
+
+func (s *replaceObjectStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	replacement := h
+	for depth := 0; ; depth++ {
+		next, ok := s.replace[replacement]
+		if !ok {
+			break
+		}
+
+		if depth == maxReplaceDepth {
+			return nil, ErrReplaceDepth
+		}
+
+		replacement = next
+	}
+
+	obj, err := s.EncodedObjectStorer.EncodedObject(t, replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	if parents, ok := s.grafts[h]; ok && obj.Type() == plumbing.CommitObject {
+		if obj, err = graftObject(obj, parents); err != nil {
+			return nil, err
+		}
+	} else if replacement == h {
+		return obj, nil
+	}
+
+	return &replacedObject{EncodedObject: obj, hash: h}, nil
+}
+
+// graftObject returns a copy of the commit with the given parents.
+func graftObject(obj plumbing.EncodedObject, parents []plumbing.Hash) (plumbing.EncodedObject, error) {
+	commit := &object.Commit{}
+	if err := commit.Decode(obj); err != nil {
+		return nil, err
+	}
+
+	commit.ParentHashes = parents
+	graft := &plumbing.MemoryObject{}
+	if err := commit.Encode(graft); err != nil {
+		return nil, err
+	}
+
+	return graft, nil
+}
+
+// replacedObject is the replacement of an object, under the hash of the
+// original object.
+type replacedObject struct {
+	plumbing.EncodedObject
+	hash plumbing.Hash
+}
+
+func (o *replacedObject) Hash() plumbing.Hash {
+	return o.hash
+}
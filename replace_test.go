@@ -0,0 +1,145 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+func logHashes(c *C, r *Repository, from plumbing.Hash) []plumbing.Hash {
+	iter, err := r.Log(&LogOptions{From: from})
+	c.Assert(err, IsNil)
+
+	var hashes []plumbing.Hash
+	err = iter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return hashes
+}
+
+func (s *RepositorySuite) TestReplaceObject(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	c.Assert(r.ReplaceObject(base, base, &ReplaceOptions{}), Equals, ErrReplaceItself)
+
+	tree, err := r.TreeObject(base)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+	c.Assert(tree, IsNil)
+
+	commit, err := r.CommitObject(base)
+	c.Assert(err, IsNil)
+	c.Assert(r.ReplaceObject(base, commit.TreeHash, &ReplaceOptions{}), Equals, ErrReplacementType)
+
+	c.Assert(r.ReplaceObject(commits[1], commits[0], &ReplaceOptions{}), IsNil)
+	c.Assert(r.ReplaceObject(commits[1], base, &ReplaceOptions{}), Equals, ErrReplacementExists)
+
+	commit, err = r.CommitObject(commits[1])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, commits[1])
+	c.Assert(commit.Message, Equals, "Add bar\n\nbar is needed by foo.\n")
+	c.Assert(logHashes(c, r, commits[1]), DeepEquals, []plumbing.Hash{commits[1], base})
+
+	refs, err := r.Replacements()
+	c.Assert(err, IsNil)
+	var names []plumbing.ReferenceName
+	c.Assert(refs.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name())
+		return nil
+	}), IsNil)
+	c.Assert(names, DeepEquals, []plumbing.ReferenceName{
+		plumbing.ReferenceName("refs/replace/" + commits[1].String()),
+	})
+
+	c.Assert(r.DeleteReplacement(commits[1]), IsNil)
+	c.Assert(r.DeleteReplacement(commits[1]), Equals, ErrReplacementNotFound)
+	c.Assert(logHashes(c, r, commits[1]), HasLen, 3)
+}
+
+func (s *RepositorySuite) TestReplaceObjectDisabled(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	c.Assert(r.ReplaceObject(commits[1], commits[0], &ReplaceOptions{}), IsNil)
+
+	c.Assert(os.Setenv(noReplaceObjectsEnv, "1"), IsNil)
+	c.Assert(logHashes(c, r, commits[1]), HasLen, 3)
+	c.Assert(os.Unsetenv(noReplaceObjectsEnv), IsNil)
+	c.Assert(logHashes(c, r, commits[1]), DeepEquals, []plumbing.Hash{commits[1], base})
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption("useReplaceRefs", "false")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+	c.Assert(logHashes(c, r, commits[1]), DeepEquals, []plumbing.Hash{commits[1], base})
+
+	r, err = Open(r.Storer, w.Filesystem)
+	c.Assert(err, IsNil)
+	c.Assert(logHashes(c, r, commits[1]), HasLen, 3)
+}
+
+func (s *RepositorySuite) TestGraftCommit(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+	r := w.r
+
+	h, err := r.GraftCommit(commits[1], []plumbing.Hash{base}, &ReplaceOptions{})
+	c.Assert(err, IsNil)
+
+	commit, err := object.GetCommit(r.Storer, h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{base})
+	c.Assert(logHashes(c, r, commits[1]), DeepEquals, []plumbing.Hash{commits[1], base})
+
+	_, err = r.GraftCommit(commits[1], nil, &ReplaceOptions{})
+	c.Assert(err, Equals, ErrReplacementExists)
+
+	_, err = r.GraftCommit(commits[1], nil, &ReplaceOptions{Force: true})
+	c.Assert(err, IsNil)
+	c.Assert(logHashes(c, r, commits[1]), DeepEquals, []plumbing.Hash{commits[1]})
+}
+
+func (s *RepositorySuite) TestConvertGrafts(c *C) {
+	dir, err := ioutil.TempDir("", "grafts")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	base := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+	middle := commitFiles(c, w, map[string]string{"foo": "bar\n"})
+	head := commitFiles(c, w, map[string]string{"foo": "qux\n"})
+
+	grafts := "# grafted\n" + head.String() + " " + base.String() + "\n"
+	c.Assert(util.WriteFile(r.dotGitFilesystem(), graftsPath, []byte(grafts), 0644), IsNil)
+	c.Assert(logHashes(c, r, head), HasLen, 3)
+
+	r, err = PlainOpen(dir)
+	c.Assert(err, IsNil)
+	c.Assert(logHashes(c, r, head), DeepEquals, []plumbing.Hash{head, base})
+
+	converted, err := r.ConvertGrafts()
+	c.Assert(err, IsNil)
+	c.Assert(converted, DeepEquals, []plumbing.Hash{head})
+
+	_, err = os.Stat(filepath.Join(dir, ".git", "info", "grafts"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	ref, err := r.Reference(plumbing.ReferenceName("refs/replace/"+head.String()), false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Not(Equals), head)
+	c.Assert(logHashes(c, r, head), DeepEquals, []plumbing.Hash{head, base})
+
+	c.Assert(r.DeleteReplacement(head), IsNil)
+	c.Assert(logHashes(c, r, head), DeepEquals, []plumbing.Hash{head, middle, base})
+}
@@ -12,8 +12,10 @@ import (
 	"fmt"
 	stdioutil "io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sniperkit/snk.fork.go-git.v4/config"
@@ -24,6 +26,7 @@ import (
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
 	"github.com/sniperkit/snk.fork.go-git.v4/storage"
 	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem/dotgit"
 	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
 
 	"github.com/sniperkit/snk.fork.go-billy.v4"
@@ -37,7 +40,14 @@ var (
 	// ErrBranchExists an error stating the specified branch already exists
 	ErrBranchExists = errors.New("branch already exists")
 	// ErrBranchNotFound an error stating the specified branch does not exist
-	ErrBranchNotFound            = errors.New("branch not found")
+	ErrBranchNotFound = errors.New("branch not found")
+	// ErrTagExists an error stating the specified tag already exists
+	ErrTagExists = errors.New("tag already exists")
+	// ErrTagNotFound an error stating the specified tag does not exist
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrInvalidTagName an error stating the specified tag name is not a
+	// valid reference name
+	ErrInvalidTagName            = errors.New("invalid tag name")
 	ErrInvalidReference          = errors.New("invalid reference, should be a tag or a branch")
 	ErrRepositoryNotExists       = errors.New("repository does not exist")
 	ErrRepositoryAlreadyExists   = errors.New("repository already exists")
@@ -55,6 +65,11 @@ type Repository struct {
 
 	r  map[string]*Remote
 	wt billy.Filesystem
+
+	// replaceStorer is the storer honoring the replacements and the grafts,
+	// read once as git does, see objectStorer.
+	replaceMu     sync.Mutex
+	replaceStorer storer.EncodedObjectStorer
 }
 
 // Init creates an empty git repository, based on the given Storer and worktree.
@@ -258,6 +273,10 @@ func PlainOpenWithOptions(path string, o *PlainOpenOptions) (*Repository, error)
 		return nil, err
 	}
 
+	if dot, err = linkedWorktreeFilesystem(dot); err != nil {
+		return nil, err
+	}
+
 	s, err := filesystem.NewStorage(dot)
 	if err != nil {
 		return nil, err
@@ -336,6 +355,33 @@ func dotGitFileToOSFilesystem(path string, fs billy.Filesystem) (bfs billy.Files
 	return osfs.New(fs.Join(path, gitdir)), nil
 }
 
+// linkedWorktreeFilesystem returns the filesystem of a linked worktree, joining
+// its git directory with the common one, if the git directory contains a
+// commondir file. Otherwise the given filesystem is returned.
+func linkedWorktreeFilesystem(dot billy.Filesystem) (bfs billy.Filesystem, err error) {
+	f, err := dot.Open(commonDirFile)
+	if os.IsNotExist(err) {
+		return dot, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	b, err := stdioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	commonDir := strings.TrimSpace(string(b))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(dot.Root(), commonDir)
+	}
+
+	return dotgit.NewRepositoryFilesystem(dot, osfs.New(commonDir)), nil
+}
+
 // PlainClone a repository into the path with the given options, isBare defines
 // if the new repository will be bare or normal. If the path is not empty
 // ErrRepositoryAlreadyExists is returned.
@@ -490,6 +536,127 @@ func (r *Repository) DeleteBranch(name string) error {
 	return r.Storer.SetConfig(cfg)
 }
 
+// Tag returns the reference of the tag with the given name, if the tag doesn't
+// exist ErrTagNotFound is returned.
+func (r *Repository) Tag(name string) (*plumbing.Reference, error) {
+	ref, err := r.Storer.Reference(plumbing.ReferenceName(path.Join("refs", "tags", name)))
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, ErrTagNotFound
+	}
+
+	return ref, err
+}
+
+// CreateTag creates a tag with the given name pointing to the given object. If
+// opts is nil a lightweight tag is created, otherwise an annotated tag object
+// is stored and signed if a SignKey is provided. ErrTagExists is returned if
+// the tag already exists.
+func (r *Repository) CreateTag(name string, hash plumbing.Hash, opts *CreateTagOptions) (*plumbing.Reference, error) {
+	if !isValidTagName(name) {
+		return nil, ErrInvalidTagName
+	}
+
+	rname := plumbing.ReferenceName(path.Join("refs", "tags", name))
+	_, err := r.Storer.Reference(rname)
+	switch err {
+	case nil:
+		return nil, ErrTagExists
+	case plumbing.ErrReferenceNotFound:
+	default:
+		return nil, err
+	}
+
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	target := hash
+	if opts != nil {
+		if target, err = r.createTagObject(name, obj, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	ref := plumbing.NewHashReference(rname, target)
+	if err := r.Storer.SetReference(ref); err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+func (r *Repository) createTagObject(name string, target plumbing.EncodedObject,
+	opts *CreateTagOptions) (plumbing.Hash, error) {
+
+	if err := opts.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tag := &object.Tag{
+		Name:       name,
+		Tagger:     *opts.Tagger,
+		Message:    opts.Message,
+		TargetType: target.Type(),
+		Target:     target.Hash(),
+	}
+
+	if opts.Signer != nil {
+		sig, err := signObject(tag, opts.Signer)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		tag.PGPSignature = sig
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	if err := tag.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.Storer.SetEncodedObject(obj)
+}
+
+// signObject returns the armored signature of the encoded form of the given
+// object, made with the given signer. The object must not be signed yet.
+func signObject(o object.Object, signer object.Signer) (string, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := o.Encode(encoded); err != nil {
+		return "", err
+	}
+
+	rd, err := encoded.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	return signer.Sign(rd)
+}
+
+// isValidTagName returns whether the given name follows the rules of
+// git-check-ref-format and doesn't describe a revision.
+func isValidTagName(name string) bool {
+	items, err := revision.NewParserFromString(name).Parse()
+	if err != nil || len(items) != 1 {
+		return false
+	}
+
+	ref, ok := items[0].(revision.Ref)
+	return ok && string(ref) == name
+}
+
+// DeleteTag deletes the tag with the given name, if the tag doesn't exist
+// ErrTagNotFound is returned. The tag object of an annotated tag is kept.
+func (r *Repository) DeleteTag(name string) error {
+	ref, err := r.Tag(name)
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.RemoveReference(ref.Name())
+}
+
 func (r *Repository) resolveToCommitHash(h plumbing.Hash) (plumbing.Hash, error) {
 	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
 	if err != nil {
@@ -654,6 +821,7 @@ func (r *Repository) fetchAndUpdateReferences(
 
 	objsUpdated := true
 	remoteRefs, err := remote.fetch(ctx, o)
+	r.resetObjectStorer()
 	if err == NoErrAlreadyUpToDate {
 		objsUpdated = false
 	} else if err != nil {
@@ -665,7 +833,8 @@ func (r *Repository) fetchAndUpdateReferences(
 		return nil, err
 	}
 
-	refsUpdated, err := r.updateReferences(remote.c.Fetch, resolvedRef)
+	msg := "clone: from " + remote.c.URLs[0]
+	refsUpdated, err := r.updateReferences(remote.c.Fetch, resolvedRef, msg)
 	if err != nil {
 		return nil, err
 	}
@@ -678,7 +847,7 @@ func (r *Repository) fetchAndUpdateReferences(
 }
 
 func (r *Repository) updateReferences(spec []config.RefSpec,
-	resolvedRef *plumbing.Reference) (updated bool, err error) {
+	resolvedRef *plumbing.Reference, msg string) (updated bool, err error) {
 
 	if !resolvedRef.Name().IsBranch() {
 		// Detached HEAD mode
@@ -687,7 +856,7 @@ func (r *Repository) updateReferences(spec []config.RefSpec,
 			return false, err
 		}
 		head := plumbing.NewHashReference(plumbing.HEAD, h)
-		return updateReferenceStorerIfNeeded(r.Storer, head)
+		return updateReferenceStorerIfNeeded(r.Storer, head, msg)
 	}
 
 	refs := []*plumbing.Reference{
@@ -700,7 +869,7 @@ func (r *Repository) updateReferences(spec []config.RefSpec,
 	refs = append(refs, r.calculateRemoteHeadReference(spec, resolvedRef)...)
 
 	for _, ref := range refs {
-		u, err := updateReferenceStorerIfNeeded(r.Storer, ref)
+		u, err := updateReferenceStorerIfNeeded(r.Storer, ref, msg)
 		if err != nil {
 			return updated, err
 		}
@@ -737,7 +906,7 @@ func (r *Repository) calculateRemoteHeadReference(spec []config.RefSpec,
 }
 
 func checkAndUpdateReferenceStorerIfNeeded(
-	s storer.ReferenceStorer, r, old *plumbing.Reference) (
+	s storer.ReferenceStorer, r, old *plumbing.Reference, msg string) (
 	updated bool, err error) {
 	p, err := s.Reference(r.Name())
 	if err != nil && err != plumbing.ErrReferenceNotFound {
@@ -746,7 +915,7 @@ func checkAndUpdateReferenceStorerIfNeeded(
 
 	// we use the string method to compare references, is the easiest way
 	if err == plumbing.ErrReferenceNotFound || r.String() != p.String() {
-		if err := s.CheckAndSetReference(r, old); err != nil {
+		if err := setReferenceWithLog(s, r, old, msg, nil); err != nil {
 			return false, err
 		}
 
@@ -757,8 +926,8 @@ func checkAndUpdateReferenceStorerIfNeeded(
 }
 
 func updateReferenceStorerIfNeeded(
-	s storer.ReferenceStorer, r *plumbing.Reference) (updated bool, err error) {
-	return checkAndUpdateReferenceStorerIfNeeded(s, r, nil)
+	s storer.ReferenceStorer, r *plumbing.Reference, msg string) (updated bool, err error) {
+	return checkAndUpdateReferenceStorerIfNeeded(s, r, nil, msg)
 }
 
 // Fetch fetches references along with the objects necessary to complete
@@ -789,6 +958,7 @@ func (r *Repository) FetchContext(ctx context.Context, o *FetchOptions) error {
 		return err
 	}
 
+	defer r.resetObjectStorer()
 	return remote.FetchContext(ctx, o)
 }
 
@@ -896,7 +1066,12 @@ func (r *Repository) Notes() (storer.ReferenceIter, error) {
 // TreeObject return a Tree with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned
 func (r *Repository) TreeObject(h plumbing.Hash) (*object.Tree, error) {
-	return object.GetTree(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(s, h)
 }
 
 // TreeObjects returns an unsorted TreeIter with all the trees in the repository
@@ -912,7 +1087,12 @@ func (r *Repository) TreeObjects() (*object.TreeIter, error) {
 // CommitObject return a Commit with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) CommitObject(h plumbing.Hash) (*object.Commit, error) {
-	return object.GetCommit(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetCommit(s, h)
 }
 
 // CommitObjects returns an unsorted CommitIter with all the commits in the repository.
@@ -928,7 +1108,12 @@ func (r *Repository) CommitObjects() (object.CommitIter, error) {
 // BlobObject returns a Blob with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) BlobObject(h plumbing.Hash) (*object.Blob, error) {
-	return object.GetBlob(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetBlob(s, h)
 }
 
 // BlobObjects returns an unsorted BlobIter with all the blobs in the repository.
@@ -945,7 +1130,12 @@ func (r *Repository) BlobObjects() (*object.BlobIter, error) {
 // plumbing.ErrObjectNotFound is returned. This method only returns
 // annotated Tags, no lightweight Tags.
 func (r *Repository) TagObject(h plumbing.Hash) (*object.Tag, error) {
-	return object.GetTag(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTag(s, h)
 }
 
 // TagObjects returns a unsorted TagIter that can step through all of the annotated
@@ -962,12 +1152,17 @@ func (r *Repository) TagObjects() (*object.TagIter, error) {
 // Object returns an Object with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) Object(t plumbing.ObjectType, h plumbing.Hash) (object.Object, error) {
-	obj, err := r.Storer.EncodedObject(t, h)
+	s, err := r.objectStorer()
 	if err != nil {
 		return nil, err
 	}
 
-	return object.DecodeObject(r.Storer, obj)
+	obj, err := s.EncodedObject(t, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.DecodeObject(s, obj)
 }
 
 // Objects returns an unsorted ObjectIter with all the objects in the repository.
@@ -1015,7 +1210,8 @@ func (r *Repository) Worktree() (*Worktree, error) {
 // ResolveRevision resolves revision to corresponding hash.
 //
 // Implemented resolvers : HEAD, branch, tag, heads/branch, refs/heads/branch,
-// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug})
+// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug}),
+// reflog entries (HEAD@{1}, master@{2006-01-02T15:04:05Z}, @{1})
 func (r *Repository) ResolveRevision(rev plumbing.Revision) (*plumbing.Hash, error) {
 	p := revision.NewParserFromString(string(rev))
 
@@ -1026,6 +1222,7 @@ func (r *Repository) ResolveRevision(rev plumbing.Revision) (*plumbing.Hash, err
 	}
 
 	var commit *object.Commit
+	var refName plumbing.ReferenceName
 
 	for _, item := range items {
 		switch item.(type) {
@@ -1036,7 +1233,8 @@ func (r *Repository) ResolveRevision(rev plumbing.Revision) (*plumbing.Hash, err
 			var rErr, hErr error
 
 			for _, rule := range append([]string{"%s"}, plumbing.RefRevParseRules...) {
-				ref, err = storer.ResolveReference(r.Storer, plumbing.ReferenceName(fmt.Sprintf(rule, revisionRef)))
+				refName = plumbing.ReferenceName(fmt.Sprintf(rule, revisionRef))
+				ref, err = storer.ResolveReference(r.Storer, refName)
 
 				if err == nil {
 					break
@@ -1060,11 +1258,24 @@ func (r *Repository) ResolveRevision(rev plumbing.Revision) (*plumbing.Hash, err
 				commit = refCommit
 			case rErr != nil && isHash && hErr == nil:
 				commit = hashCommit
+				refName = ""
 			case rErr == nil && isHash && hErr == nil:
 				return &plumbing.ZeroHash, fmt.Errorf(`refname "%s" is ambiguous`, revisionRef)
 			default:
 				return &plumbing.ZeroHash, plumbing.ErrReferenceNotFound
 			}
+		case revision.AtReflog, revision.AtDate:
+			h, err := r.resolveReflog(refName, item)
+			if err != nil {
+				return &plumbing.ZeroHash, err
+			}
+
+			c, err := r.CommitObject(h)
+			if err != nil {
+				return &plumbing.ZeroHash, err
+			}
+
+			commit = c
 		case revision.CaretPath:
 			depth := item.(revision.CaretPath).Depth
 
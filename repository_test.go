@@ -31,6 +31,8 @@ import (
 	"github.com/sniperkit/snk.fork.go-billy.v4/osfs"
 	"github.com/sniperkit/snk.fork.go-billy.v4/util"
 	"github.com/sniperkit/snk.fork.go-git-fixtures.v3"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
 	. "gopkg.in/check.v1"
 )
 
@@ -363,6 +365,118 @@ func (s *RepositorySuite) TestDeleteBranch(c *C) {
 	c.Assert(err, Equals, ErrBranchNotFound)
 }
 
+func (s *RepositorySuite) TestCreateTagLightweight(c *C) {
+	r, _ := Init(memory.NewStorage(), memfs.New())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	h := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	ref, err := r.CreateTag("v1.0.0", h, nil)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.ReferenceName("refs/tags/v1.0.0"))
+	c.Assert(ref.Hash(), Equals, h)
+
+	tag, err := r.Tag("v1.0.0")
+	c.Assert(err, IsNil)
+	c.Assert(tag, DeepEquals, ref)
+
+	_, err = r.CreateTag("v1.0.0", h, nil)
+	c.Assert(err, Equals, ErrTagExists)
+}
+
+func (s *RepositorySuite) TestCreateTagAnnotated(c *C) {
+	r, _ := Init(memory.NewStorage(), memfs.New())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	h := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	ref, err := r.CreateTag("v1.0.0", h, &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "\nfoo bar\n\n",
+	})
+	c.Assert(err, IsNil)
+
+	tag, err := r.TagObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.Name, Equals, "v1.0.0")
+	c.Assert(tag.Message, Equals, "foo bar\n")
+	c.Assert(tag.Tagger.Name, Equals, defaultSignature().Name)
+	c.Assert(tag.TargetType, Equals, plumbing.CommitObject)
+	c.Assert(tag.Target, Equals, h)
+	c.Assert(tag.PGPSignature, Equals, "")
+
+	_, err = r.CreateTag("v1.0.1", h, &CreateTagOptions{Message: "foo"})
+	c.Assert(err, Equals, ErrMissingTagger)
+
+	_, err = r.CreateTag("v1.0.1", h, &CreateTagOptions{Tagger: defaultSignature()})
+	c.Assert(err, Equals, ErrMissingMessage)
+}
+
+func (s *RepositorySuite) TestCreateTagSigned(c *C) {
+	r, _ := Init(memory.NewStorage(), memfs.New())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	h := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	key, err := openpgp.NewEntity("foo", "", "foo@foo.foo", nil)
+	c.Assert(err, IsNil)
+
+	ref, err := r.CreateTag("v1.0.0", h, &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "foo",
+		SignKey: key,
+	})
+	c.Assert(err, IsNil)
+
+	tag, err := r.TagObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.PGPSignature, Not(Equals), "")
+
+	var pub bytes.Buffer
+	aw, err := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	c.Assert(err, IsNil)
+	c.Assert(key.Serialize(aw), IsNil)
+	c.Assert(aw.Close(), IsNil)
+
+	signer, err := tag.Verify(pub.String())
+	c.Assert(err, IsNil)
+	c.Assert(signer.PrimaryKey.KeyId, Equals, key.PrimaryKey.KeyId)
+}
+
+func (s *RepositorySuite) TestCreateTagInvalid(c *C) {
+	r, _ := Init(memory.NewStorage(), memfs.New())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	h := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	for _, name := range []string{"", "foo..bar", "foo bar", "foo~1", "foo^", "foo.lock", "/foo", "foo@{1}"} {
+		_, err := r.CreateTag(name, h, nil)
+		c.Assert(err, Equals, ErrInvalidTagName, Commentf("name %q", name))
+	}
+
+	_, err = r.CreateTag("foo", plumbing.NewHash("b66c08ba28aa1f81eb06a1127aa3936ff77e5e2c"), nil)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *RepositorySuite) TestDeleteTag(c *C) {
+	r, _ := Init(memory.NewStorage(), memfs.New())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	h := commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	_, err = r.CreateTag("v1.0.0", h, nil)
+	c.Assert(err, IsNil)
+
+	err = r.DeleteTag("v1.0.0")
+	c.Assert(err, IsNil)
+
+	_, err = r.Tag("v1.0.0")
+	c.Assert(err, Equals, ErrTagNotFound)
+
+	err = r.DeleteTag("v1.0.0")
+	c.Assert(err, Equals, ErrTagNotFound)
+}
+
 func (s *RepositorySuite) TestPlainInit(c *C) {
 	dir, err := ioutil.TempDir("", "plain-init")
 	c.Assert(err, IsNil)
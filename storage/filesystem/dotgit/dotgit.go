@@ -35,6 +35,7 @@ const (
 	objectsPath    = "objects"
 	packPath       = "pack"
 	refsPath       = "refs"
+	logsPath       = "logs"
 
 	tmpPackedRefsPrefix = "._packed-refs"
 
@@ -123,6 +124,46 @@ func (d *DotGit) Index() (billy.File, error) {
 	return d.fs.Open(indexPath)
 }
 
+// Reflog returns a file pointer for read to the reflog of the given
+// reference, nil if the reference has no reflog.
+func (d *DotGit) Reflog(name plumbing.ReferenceName) (billy.File, error) {
+	f, err := d.fs.Open(d.reflogPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ReflogWriter returns a file pointer for write to the reflog of the given
+// reference, the content is appended to the existing log when append is true.
+func (d *DotGit) ReflogWriter(name plumbing.ReferenceName, append bool) (billy.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	return d.fs.OpenFile(d.reflogPath(name), flags, 0666)
+}
+
+// RemoveReflog removes the reflog of the given reference, if any.
+func (d *DotGit) RemoveReflog(name plumbing.ReferenceName) error {
+	err := d.fs.Remove(d.reflogPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (d *DotGit) reflogPath(name plumbing.ReferenceName) string {
+	return d.fs.Join(logsPath, name.String())
+}
+
 // ShallowWriter returns a file pointer for write to the shallow file
 func (d *DotGit) ShallowWriter() (billy.File, error) {
 	return d.fs.Create(shallowPath)
@@ -195,6 +236,31 @@ func (d *DotGit) objectPackOpen(hash plumbing.Hash, extension string) (billy.Fil
 	return pack, nil
 }
 
+// KeptObjectPacks returns the hashes of the packfiles marked to be kept with
+// a .keep file.
+func (d *DotGit) KeptObjectPacks() ([]plumbing.Hash, error) {
+	packs, err := d.ObjectPacks()
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []plumbing.Hash
+	for _, h := range packs {
+		_, err := d.fs.Stat(d.objectPackPath(h, `keep`))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		kept = append(kept, h)
+	}
+
+	return kept, nil
+}
+
 // ObjectPack returns a fs.File of the given packfile
 func (d *DotGit) ObjectPack(hash plumbing.Hash) (billy.File, error) {
 	return d.objectPackOpen(hash, `pack`)
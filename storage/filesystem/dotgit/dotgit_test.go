@@ -8,6 +8,7 @@ package dotgit
 
 import (
 	"bufio"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -450,6 +451,25 @@ func (s *SuiteDotGit) TestObjectPacks(c *C) {
 	c.Assert(hashes[0], Equals, hashes2[0])
 }
 
+func (s *SuiteDotGit) TestKeptObjectPacks(c *C) {
+	f := fixtures.Basic().ByTag(".git").One()
+	fs := f.DotGit()
+	dir := New(fs)
+
+	hashes, err := dir.KeptObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 0)
+
+	keep, err := fs.Create(fmt.Sprintf("objects/pack/pack-%s.keep", f.PackfileHash))
+	c.Assert(err, IsNil)
+	err = keep.Close()
+	c.Assert(err, IsNil)
+
+	hashes, err = dir.KeptObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{f.PackfileHash})
+}
+
 func (s *SuiteDotGit) TestObjectPack(c *C) {
 	f := fixtures.Basic().ByTag(".git").One()
 	fs := f.DotGit()
@@ -0,0 +1,154 @@
+package dotgit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4"
+)
+
+const (
+	worktreesPath = "worktrees"
+	commonDirPath = "commondir"
+	branchesPath  = "branches"
+	hooksPath     = "hooks"
+	infoPath      = "info"
+	remotesPath   = "remotes"
+)
+
+// RepositoryFilesystem is the filesystem of a linked worktree, it joins the
+// administrative directory of the worktree, .git/worktrees/<name>, with the
+// common directory shared by all the worktrees, the .git directory of the
+// main worktree. The paths are mapped as described at
+// https://git-scm.com/docs/gitrepository-layout: the objects, the references
+// and the configuration are in the common directory, while HEAD, the index
+// and the other per-worktree files are in the administrative directory.
+type RepositoryFilesystem struct {
+	dotGitFs       billy.Filesystem
+	commonDotGitFs billy.Filesystem
+}
+
+// NewRepositoryFilesystem returns the filesystem of a linked worktree given
+// its administrative directory and the common directory.
+func NewRepositoryFilesystem(dotGitFs, commonDotGitFs billy.Filesystem) *RepositoryFilesystem {
+	return &RepositoryFilesystem{
+		dotGitFs:       dotGitFs,
+		commonDotGitFs: commonDotGitFs,
+	}
+}
+
+// CommonDir returns the filesystem of the common directory.
+func (fs *RepositoryFilesystem) CommonDir() billy.Filesystem {
+	return fs.commonDotGitFs
+}
+
+func (fs *RepositoryFilesystem) mapToRepositoryFsByPath(path string) billy.Filesystem {
+	path = filepath.ToSlash(filepath.Clean(path))
+	switch path {
+	case logsPath + "/HEAD":
+		return fs.dotGitFs
+	}
+
+	for _, prefix := range []string{
+		refsPath + "/bisect", refsPath + "/worktree",
+		logsPath + "/" + refsPath + "/bisect", logsPath + "/" + refsPath + "/worktree",
+	} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return fs.dotGitFs
+		}
+	}
+
+	first := strings.Split(path, "/")[0]
+	switch first {
+	case objectsPath, refsPath, packedRefsPath, configPath, branchesPath,
+		hooksPath, infoPath, remotesPath, logsPath, shallowPath, worktreesPath:
+		return fs.commonDotGitFs
+	}
+
+	// the temporal files of packed-refs are renamed to it
+	if strings.HasPrefix(first, tmpPackedRefsPrefix) {
+		return fs.commonDotGitFs
+	}
+
+	return fs.dotGitFs
+}
+
+// Create creates the named file, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Create(filename string) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(filename).Create(filename)
+}
+
+// Open opens the named file for reading, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Open(filename string) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(filename).Open(filename)
+}
+
+// OpenFile opens the named file with the given flags, see billy.Filesystem.
+func (fs *RepositoryFilesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(filename).OpenFile(filename, flag, perm)
+}
+
+// Stat returns the FileInfo of the named file, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Stat(filename string) (os.FileInfo, error) {
+	return fs.mapToRepositoryFsByPath(filename).Stat(filename)
+}
+
+// Rename renames oldpath to newpath, both paths must be in the same
+// directory, common or administrative, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Rename(oldpath, newpath string) error {
+	return fs.mapToRepositoryFsByPath(newpath).Rename(oldpath, newpath)
+}
+
+// Remove removes the named file or directory, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Remove(filename string) error {
+	return fs.mapToRepositoryFsByPath(filename).Remove(filename)
+}
+
+// Join joins any number of path elements into a single path, see
+// billy.Filesystem.
+func (fs *RepositoryFilesystem) Join(elem ...string) string {
+	return fs.dotGitFs.Join(elem...)
+}
+
+// TempFile creates a new temporary file in the directory dir, see
+// billy.Filesystem.
+func (fs *RepositoryFilesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.mapToRepositoryFsByPath(fs.Join(dir, prefix)).TempFile(dir, prefix)
+}
+
+// ReadDir reads the directory named by dirname, see billy.Filesystem.
+func (fs *RepositoryFilesystem) ReadDir(path string) ([]os.FileInfo, error) {
+	return fs.mapToRepositoryFsByPath(path).ReadDir(path)
+}
+
+// MkdirAll creates a directory named path, see billy.Filesystem.
+func (fs *RepositoryFilesystem) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.mapToRepositoryFsByPath(filename).MkdirAll(filename, perm)
+}
+
+// Lstat returns the FileInfo of the named file without following symbolic
+// links, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Lstat(filename string) (os.FileInfo, error) {
+	return fs.mapToRepositoryFsByPath(filename).Lstat(filename)
+}
+
+// Symlink creates a symbolic link, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Symlink(target, link string) error {
+	return fs.mapToRepositoryFsByPath(link).Symlink(target, link)
+}
+
+// Readlink returns the target of a symbolic link, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Readlink(link string) (string, error) {
+	return fs.mapToRepositoryFsByPath(link).Readlink(link)
+}
+
+// Chroot returns a filesystem rooted at path, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Chroot(path string) (billy.Filesystem, error) {
+	return fs.mapToRepositoryFsByPath(path).Chroot(path)
+}
+
+// Root returns the root of the administrative directory.
+func (fs *RepositoryFilesystem) Root() string {
+	return fs.dotGitFs.Root()
+}
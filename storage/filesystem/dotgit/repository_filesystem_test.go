@@ -0,0 +1,57 @@
+package dotgit
+
+import (
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+
+	. "gopkg.in/check.v1"
+)
+
+type RepositoryFilesystemSuite struct{}
+
+var _ = Suite(&RepositoryFilesystemSuite{})
+
+func (s *RepositoryFilesystemSuite) TestMapping(c *C) {
+	fs := memfs.New()
+	common, err := fs.Chroot("common")
+	c.Assert(err, IsNil)
+	admin, err := fs.Chroot("admin")
+	c.Assert(err, IsNil)
+
+	dir := New(NewRepositoryFilesystem(admin, common))
+	c.Assert(dir.Initialize(), IsNil)
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/foo")
+	c.Assert(dir.SetRef(head, nil), IsNil)
+	foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(dir.SetRef(foo, nil), IsNil)
+	bisect := plumbing.NewReferenceFromStrings("refs/bisect/bad", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(dir.SetRef(bisect, nil), IsNil)
+
+	for _, path := range []string{
+		"common/objects/pack", "common/refs/heads/foo", "admin/HEAD", "admin/refs/bisect/bad",
+	} {
+		_, err := fs.Stat(path)
+		c.Assert(err, IsNil, Commentf("%s", path))
+	}
+
+	for _, path := range []string{"admin/objects", "admin/refs/heads", "common/HEAD"} {
+		_, err := fs.Stat(path)
+		c.Assert(err, NotNil, Commentf("%s", path))
+	}
+
+	tmp, err := dir.Fs().TempFile("", tmpPackedRefsPrefix)
+	c.Assert(err, IsNil)
+	c.Assert(tmp.Close(), IsNil)
+	_, err = common.Stat(tmp.Name())
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(dir.Fs(), "logs/HEAD", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(dir.Fs(), "logs/refs/heads/foo", nil, 0644), IsNil)
+	for _, path := range []string{"admin/logs/HEAD", "common/logs/refs/heads/foo"} {
+		_, err := fs.Stat(path)
+		c.Assert(err, IsNil, Commentf("%s", path))
+	}
+}
@@ -624,5 +624,43 @@ func (s *ObjectStorage) ObjectPacks() ([]plumbing.Hash, error) {
 }
 
 func (s *ObjectStorage) DeleteOldObjectPackAndIndex(h plumbing.Hash, t time.Time) error {
+	// the indexes are reloaded, so the objects of deleted packs are not found
+	s.index = nil
 	return s.dir.DeleteOldObjectPackAndIndex(h, t)
 }
+
+func (s *ObjectStorage) KeptObjectPacks() ([]plumbing.Hash, error) {
+	return s.dir.KeptObjectPacks()
+}
+
+func (s *ObjectStorage) ObjectPackHashes(pack plumbing.Hash) ([]plumbing.Hash, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	idx, ok := s.index[pack]
+	if !ok {
+		return nil, dotgit.ErrPackfileNotFound
+	}
+
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+
+	var hashes []plumbing.Hash
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return hashes, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, e.Hash)
+	}
+}
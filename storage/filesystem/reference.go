@@ -7,21 +7,37 @@ Sniperkit-Bot
 package filesystem
 
 import (
+	"sync"
+
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
 	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem/dotgit"
 )
 
 type ReferenceStorage struct {
 	dir *dotgit.DotGit
+
+	mu     sync.Mutex
+	reflog *reflogConfig
 }
 
 func (r *ReferenceStorage) SetReference(ref *plumbing.Reference) error {
-	return r.dir.SetRef(ref, nil)
+	return r.setReference(ref, nil, &reflog.Entry{})
 }
 
 func (r *ReferenceStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
-	return r.dir.SetRef(ref, old)
+	return r.setReference(ref, old, &reflog.Entry{})
+}
+
+// SetReferenceWithLog stores the reference, checking its previous value if old
+// is not nil. The update is logged with the identity, time and message of the
+// given entry when the reference has a reflog or when core.logAllRefUpdates
+// requires it. If the entry has no identity the one at the user section of the
+// config is used, and if it has no time the current one. The config is read
+// once, the changes made through SetConfig of the Storage are seen.
+func (r *ReferenceStorage) SetReferenceWithLog(ref, old *plumbing.Reference, e *reflog.Entry) error {
+	return r.setReference(ref, old, e)
 }
 
 func (r *ReferenceStorage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
@@ -38,7 +54,11 @@ func (r *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 }
 
 func (r *ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
-	return r.dir.RemoveRef(n)
+	if err := r.dir.RemoveRef(n); err != nil {
+		return err
+	}
+
+	return r.dir.RemoveReflog(n)
 }
 
 func (r *ReferenceStorage) CountLooseRefs() (int, error) {
@@ -0,0 +1,241 @@
+package filesystem
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem/dotgit"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+)
+
+// ReflogStorage stores the reflogs at the logs directory of the .git folder,
+// one file per reference.
+type ReflogStorage struct {
+	dir *dotgit.DotGit
+}
+
+// Reflog returns the entries of the reflog of the given reference.
+func (s *ReflogStorage) Reflog(name plumbing.ReferenceName) (entries []*reflog.Entry, err error) {
+	f, err := s.dir.Reflog(name)
+	if f == nil || err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return reflog.NewDecoder(f).Decode()
+}
+
+// AppendReflog adds an entry at the end of the reflog of the given reference.
+func (s *ReflogStorage) AppendReflog(name plumbing.ReferenceName, e *reflog.Entry) (err error) {
+	f, err := s.dir.ReflogWriter(name, true)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return reflog.NewEncoder(f).Encode(e)
+}
+
+// SetReflog replaces the reflog of the given reference.
+func (s *ReflogStorage) SetReflog(name plumbing.ReferenceName, entries []*reflog.Entry) (err error) {
+	if len(entries) == 0 {
+		return s.dir.RemoveReflog(name)
+	}
+
+	f, err := s.dir.ReflogWriter(name, false)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return reflog.NewEncoder(f).Encode(entries...)
+}
+
+// loggedPrefixes are the prefixes of the references logged when
+// core.logAllRefUpdates is true, besides HEAD.
+var loggedPrefixes = []string{"refs/heads/", "refs/remotes/", "refs/notes/"}
+
+// reflogConfig is the configuration of the reflogs, read from the config at
+// the first reference update of the storage.
+type reflogConfig struct {
+	// logAllRefUpdates is the value of core.logAllRefUpdates, with its default
+	// value already applied.
+	logAllRefUpdates string
+	name, email      string
+}
+
+func (r *ReferenceStorage) reflogConfig() (*reflogConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reflog != nil {
+		return r.reflog, nil
+	}
+
+	cfg, err := (&ConfigStorage{dir: r.dir}).Config()
+	if err != nil {
+		return nil, err
+	}
+
+	mode := cfg.Core.LogAllRefUpdates
+	if mode == "" && !cfg.Core.IsBare {
+		mode = "true"
+	}
+
+	user := cfg.Raw.Section("user").Options
+	r.reflog = &reflogConfig{
+		logAllRefUpdates: mode,
+		name:             user.Get("name"),
+		email:            user.Get("email"),
+	}
+
+	return r.reflog, nil
+}
+
+// resetReflogConfig discards the configuration of the reflogs, it's read again
+// at the next reference update.
+func (r *ReferenceStorage) resetReflogConfig() {
+	r.mu.Lock()
+	r.reflog = nil
+	r.mu.Unlock()
+}
+
+// setReference stores the reference and appends an entry, based on e, to the
+// reflog of the reference and to the one of HEAD if it points to the
+// reference, when they are logged. Updates between references not pointing
+// to any commit, like the creation of HEAD at init, are not logged.
+func (r *ReferenceStorage) setReference(ref, old *plumbing.Reference, e *reflog.Entry) error {
+	cfg, err := r.reflogConfig()
+	if err != nil {
+		return err
+	}
+
+	names, err := r.loggedNames(cfg, ref.Name())
+	if err != nil {
+		return err
+	}
+
+	olds := make([]plumbing.Hash, len(names))
+	for i, name := range names {
+		if olds[i], err = r.resolveHash(name); err != nil {
+			return err
+		}
+	}
+
+	if err := r.dir.SetRef(ref, old); err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	h, err := r.resolveHash(ref.Name())
+	if err != nil {
+		return err
+	}
+
+	entry := *e
+	entry.New = h
+	if entry.Name == "" && entry.Email == "" {
+		entry.Name, entry.Email = cfg.name, cfg.email
+	}
+
+	if entry.When.IsZero() {
+		entry.When = time.Now()
+	}
+
+	s := &ReflogStorage{dir: r.dir}
+	for i, name := range names {
+		if olds[i].IsZero() && h.IsZero() {
+			continue
+		}
+
+		entry.Old = olds[i]
+		if err := s.AppendReflog(name, &entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loggedNames returns the logged references among the given one and HEAD,
+// when HEAD points to it.
+func (r *ReferenceStorage) loggedNames(cfg *reflogConfig,
+	name plumbing.ReferenceName) ([]plumbing.ReferenceName, error) {
+
+	names := []plumbing.ReferenceName{name}
+	if name != plumbing.HEAD {
+		head, err := r.dir.Ref(plumbing.HEAD)
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return nil, err
+		}
+
+		if err == nil && head.Type() == plumbing.SymbolicReference && head.Target() == name {
+			names = append(names, plumbing.HEAD)
+		}
+	}
+
+	var logged []plumbing.ReferenceName
+	for _, n := range names {
+		ok, err := r.isLogged(cfg, n)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			logged = append(logged, n)
+		}
+	}
+
+	return logged, nil
+}
+
+// isLogged returns true if the reference already has a reflog or if it must
+// be logged according to core.logAllRefUpdates.
+func (r *ReferenceStorage) isLogged(cfg *reflogConfig, name plumbing.ReferenceName) (bool, error) {
+	f, err := r.dir.Reflog(name)
+	if err != nil {
+		return false, err
+	}
+
+	if f != nil {
+		return true, f.Close()
+	}
+
+	switch cfg.logAllRefUpdates {
+	case "always":
+		return true, nil
+	case "true":
+		if name == plumbing.HEAD {
+			return true, nil
+		}
+
+		for _, prefix := range loggedPrefixes {
+			if strings.HasPrefix(name.String(), prefix) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// resolveHash returns the hash the given reference points to, or a zero hash
+// if it doesn't exist.
+func (r *ReferenceStorage) resolveHash(name plumbing.ReferenceName) (plumbing.Hash, error) {
+	ref, err := storer.ResolveReference(r, name)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return ref.Hash(), nil
+}
@@ -8,6 +8,7 @@ Sniperkit-Bot
 package filesystem
 
 import (
+	"github.com/sniperkit/snk.fork.go-git.v4/config"
 	"github.com/sniperkit/snk.fork.go-git.v4/storage/filesystem/dotgit"
 
 	"github.com/sniperkit/snk.fork.go-billy.v4"
@@ -26,6 +27,7 @@ type Storage struct {
 	ShallowStorage
 	ConfigStorage
 	ModuleStorage
+	ReflogStorage
 }
 
 // NewStorage returns a new Storage backed by a given `fs.Filesystem`
@@ -46,6 +48,7 @@ func NewStorage(fs billy.Filesystem) (*Storage, error) {
 		ShallowStorage:   ShallowStorage{dir: dir},
 		ConfigStorage:    ConfigStorage{dir: dir},
 		ModuleStorage:    ModuleStorage{dir: dir},
+		ReflogStorage:    ReflogStorage{dir: dir},
 	}, nil
 }
 
@@ -54,6 +57,17 @@ func (s *Storage) Filesystem() billy.Filesystem {
 	return s.fs
 }
 
+// SetConfig stores the configuration, the following reference updates are
+// logged according to it.
+func (s *Storage) SetConfig(cfg *config.Config) error {
+	if err := s.ConfigStorage.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	s.ReferenceStorage.resetReflogConfig()
+	return nil
+}
+
 func (s *Storage) Init() error {
 	return s.dir.Initialize()
 }
@@ -39,6 +39,7 @@ func (s *StorageSuite) SetUpTest(c *C) {
 	var _ storer.ShallowStorer = storage
 	var _ storer.DeltaObjectStorer = storage
 	var _ storer.PackfileWriter = storage
+	var _ storer.ReflogStorer = storage
 
 	s.BaseStorageSuite = test.NewBaseStorageSuite(storage)
 	s.BaseStorageSuite.SetUpTest(c)
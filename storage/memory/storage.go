@@ -9,6 +9,7 @@ package memory
 
 import (
 	"fmt"
+	"strings"
 	"time"
 	// "sync"
 
@@ -18,6 +19,7 @@ import (
 
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
 
 	"github.com/sniperkit/snk.fork.go-git.v4/storage"
@@ -37,6 +39,7 @@ type Storage struct {
 	IndexStorage
 	ReferenceStorage
 	ModuleStorage
+	ReflogStorage
 }
 
 // NewStorage returns a new Storage base on memory
@@ -53,6 +56,7 @@ func NewStorage() *Storage {
 			Tags:    make(map[plumbing.Hash]plumbing.EncodedObject),
 		},
 		ModuleStorage: make(ModuleStorage),
+		ReflogStorage: make(ReflogStorage),
 	}
 }
 
@@ -309,6 +313,169 @@ func (s ShallowStorage) Shallow() ([]plumbing.Hash, error) {
 	return s, nil
 }
 
+type ReflogStorage map[plumbing.ReferenceName][]*reflog.Entry
+
+func (s ReflogStorage) Reflog(name plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	entries, ok := s[name]
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]*reflog.Entry(nil), entries...), nil
+}
+
+func (s ReflogStorage) AppendReflog(name plumbing.ReferenceName, e *reflog.Entry) error {
+	s[name] = append(s[name], e)
+	return nil
+}
+
+func (s ReflogStorage) SetReflog(name plumbing.ReferenceName, entries []*reflog.Entry) error {
+	if len(entries) == 0 {
+		delete(s, name)
+		return nil
+	}
+
+	s[name] = append([]*reflog.Entry(nil), entries...)
+	return nil
+}
+
+// loggedPrefixes are the prefixes of the references logged when
+// core.logAllRefUpdates is true, besides HEAD.
+var loggedPrefixes = []string{"refs/heads/", "refs/remotes/", "refs/notes/"}
+
+// SetReference stores the reference, logging the update as
+// SetReferenceWithLog does.
+func (s *Storage) SetReference(ref *plumbing.Reference) error {
+	return s.SetReferenceWithLog(ref, nil, &reflog.Entry{})
+}
+
+// CheckAndSetReference stores the reference if its previous value is old,
+// logging the update as SetReferenceWithLog does.
+func (s *Storage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	return s.SetReferenceWithLog(ref, old, &reflog.Entry{})
+}
+
+// SetReferenceWithLog stores the reference, checking its previous value if old
+// is not nil. As the filesystem storage does, the update is logged with the
+// identity, time and message of the given entry when the reference has a
+// reflog or when core.logAllRefUpdates requires it, to the reflog of the
+// reference and to the one of HEAD if it points to it. If the entry has no
+// identity the one at the user section of the config is used, and if it has
+// no time the current one.
+func (s *Storage) SetReferenceWithLog(ref, old *plumbing.Reference, e *reflog.Entry) error {
+	if ref == nil {
+		return nil
+	}
+
+	cfg, err := s.Config()
+	if err != nil {
+		return err
+	}
+
+	names := s.loggedNames(cfg, ref.Name())
+	olds := make([]plumbing.Hash, len(names))
+	for i, name := range names {
+		olds[i] = s.resolveHash(name)
+	}
+
+	if err := s.ReferenceStorage.CheckAndSetReference(ref, old); err != nil {
+		return err
+	}
+
+	h := s.resolveHash(ref.Name())
+	for i, name := range names {
+		if olds[i].IsZero() && h.IsZero() {
+			continue
+		}
+
+		entry := *e
+		entry.Old, entry.New = olds[i], h
+		if entry.Name == "" && entry.Email == "" {
+			user := cfg.Raw.Section("user").Options
+			entry.Name, entry.Email = user.Get("name"), user.Get("email")
+		}
+
+		if entry.When.IsZero() {
+			entry.When = time.Now()
+		}
+
+		if err := s.AppendReflog(name, &entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveReference removes the reference along with its reflog.
+func (s *Storage) RemoveReference(n plumbing.ReferenceName) error {
+	if err := s.ReferenceStorage.RemoveReference(n); err != nil {
+		return err
+	}
+
+	return s.SetReflog(n, nil)
+}
+
+// loggedNames returns the logged references among the given one and HEAD,
+// when HEAD points to it.
+func (s *Storage) loggedNames(cfg *config.Config, name plumbing.ReferenceName) []plumbing.ReferenceName {
+	names := []plumbing.ReferenceName{name}
+	if head, ok := s.ReferenceStorage[plumbing.HEAD]; ok && name != plumbing.HEAD &&
+		head.Type() == plumbing.SymbolicReference && head.Target() == name {
+		names = append(names, plumbing.HEAD)
+	}
+
+	var logged []plumbing.ReferenceName
+	for _, n := range names {
+		if s.isLogged(cfg, n) {
+			logged = append(logged, n)
+		}
+	}
+
+	return logged
+}
+
+// isLogged returns true if the reference already has a reflog or if it must
+// be logged according to core.logAllRefUpdates.
+func (s *Storage) isLogged(cfg *config.Config, name plumbing.ReferenceName) bool {
+	if _, ok := s.ReflogStorage[name]; ok {
+		return true
+	}
+
+	mode := cfg.Core.LogAllRefUpdates
+	if mode == "" && !cfg.Core.IsBare {
+		mode = "true"
+	}
+
+	switch mode {
+	case "always":
+		return true
+	case "true":
+		if name == plumbing.HEAD {
+			return true
+		}
+
+		for _, prefix := range loggedPrefixes {
+			if strings.HasPrefix(name.String(), prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// resolveHash returns the hash the given reference points to, or a zero hash
+// if it doesn't exist.
+func (s *Storage) resolveHash(name plumbing.ReferenceName) plumbing.Hash {
+	ref, err := storer.ResolveReference(s.ReferenceStorage, name)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return ref.Hash()
+}
+
 type ModuleStorage map[string]*Storage
 
 func (s ModuleStorage) Module(name string) (storage.Storer, error) {
@@ -12,10 +12,12 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"time"
 
 	"github.com/sniperkit/snk.fork.go-git.v4/config"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
 	"github.com/sniperkit/snk.fork.go-git.v4/storage"
 
@@ -353,6 +355,62 @@ func (s *BaseStorageSuite) TestSetShallowAndShallow(c *C) {
 	c.Assert(result, DeepEquals, expected)
 }
 
+func (s *BaseStorageSuite) TestReflog(c *C) {
+	storer, ok := s.Storer.(storer.ReflogStorer)
+	if !ok {
+		c.Skip("not a storer.ReflogStorer")
+	}
+
+	name := plumbing.ReferenceName("refs/heads/foo")
+	entries, err := storer.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	when := time.Unix(1257894000, 0).In(time.FixedZone("", 3600))
+	expected := []*reflog.Entry{{
+		New:     plumbing.NewHash("b66c08ba28aa1f81eb06a1127aa3936ff77e5e2c"),
+		Name:    "foo",
+		Email:   "foo@foo.foo",
+		When:    when,
+		Message: "branch: Created from HEAD",
+	}, {
+		Old:     plumbing.NewHash("b66c08ba28aa1f81eb06a1127aa3936ff77e5e2c"),
+		New:     plumbing.NewHash("c3f4688a08fd86f1bf8e055724c84b7a40a09733"),
+		Name:    "foo",
+		Email:   "foo@foo.foo",
+		When:    when,
+		Message: "commit: bar",
+	}}
+
+	for _, e := range expected {
+		c.Assert(storer.AppendReflog(name, e), IsNil)
+	}
+
+	entries, err = storer.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	for i, e := range entries {
+		c.Assert(e.New, Equals, expected[i].New)
+		c.Assert(e.Message, Equals, expected[i].Message)
+		c.Assert(e.When.Equal(when), Equals, true)
+	}
+
+	err = storer.SetReflog(name, expected[1:])
+	c.Assert(err, IsNil)
+
+	entries, err = storer.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].New, Equals, expected[1].New)
+
+	err = storer.SetReflog(name, nil)
+	c.Assert(err, IsNil)
+
+	entries, err = storer.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
 func (s *BaseStorageSuite) TestSetConfigAndConfig(c *C) {
 	expected := config.NewConfig()
 	expected.Core.IsBare = true
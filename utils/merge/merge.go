@@ -0,0 +1,347 @@
+// Package merge implements line oriented three-way merges, similar to the
+// merge(1) command of RCS and to the content merge performed by git.
+//
+// The line diffs between the common ancestor and each side are computed with
+// Sergi's go-diff/diffmatchpatch library, the same one used by the diff
+// package.
+package merge
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DefaultMarkerSize is the length of the conflict markers used when
+// Options.MarkerSize is not set.
+const DefaultMarkerSize = 7
+
+// Style is the format used to write the conflicting hunks.
+type Style int
+
+const (
+	// MergeStyle writes our and their side of a conflict, the lines shared
+	// by the beginning and the end of both sides are moved out of the
+	// conflict markers.
+	MergeStyle Style = iota
+	// Diff3Style writes our side, the common ancestor and their side of a
+	// conflict, without trimming the lines shared by both sides.
+	Diff3Style
+)
+
+// Options describes how the conflict markers of a merge are written.
+type Options struct {
+	// Style is the format of the conflicts, by default MergeStyle.
+	Style Style
+	// MarkerSize is the number of characters of the conflict markers, by
+	// default DefaultMarkerSize.
+	MarkerSize int
+	// OursLabel is written after the marker opening our side of a conflict.
+	OursLabel string
+	// BaseLabel is written after the marker opening the common ancestor of a
+	// conflict, only used by Diff3Style.
+	BaseLabel string
+	// TheirsLabel is written after the marker closing their side of a
+	// conflict.
+	TheirsLabel string
+}
+
+func (o *Options) markerSize() int {
+	if o.MarkerSize <= 0 {
+		return DefaultMarkerSize
+	}
+
+	return o.MarkerSize
+}
+
+// Hunk is a region of the result of a merge. The lines of each version keep
+// their trailing '\n', if any.
+type Hunk struct {
+	// Conflict is true when both sides changed the region in a different way.
+	Conflict bool
+	// Lines contains the content of the region when there is no conflict.
+	Lines []string
+	// Base, Ours and Theirs contain the content of the region in each version
+	// when there is a conflict.
+	Base, Ours, Theirs []string
+}
+
+// Result is the outcome of a three-way merge, made of consecutive hunks.
+type Result struct {
+	Hunks []Hunk
+}
+
+// Do performs a line based three-way merge of ours and theirs, being base its
+// common ancestor.
+func Do(base, ours, theirs string) *Result {
+	return &Result{
+		Hunks: merge3(splitLines(base), splitLines(ours), splitLines(theirs)),
+	}
+}
+
+// Conflicts returns the conflicting hunks of the result.
+func (r *Result) Conflicts() []Hunk {
+	var conflicts []Hunk
+	for _, h := range r.Hunks {
+		if h.Conflict {
+			conflicts = append(conflicts, h)
+		}
+	}
+
+	return conflicts
+}
+
+// HasConflicts returns true if any hunk of the result is a conflict.
+func (r *Result) HasConflicts() bool {
+	for _, h := range r.Hunks {
+		if h.Conflict {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Encode writes the merged text to w, the conflicting hunks are written
+// between conflict markers as described by o, a nil o uses the defaults.
+func (r *Result) Encode(w io.Writer, o *Options) error {
+	if o == nil {
+		o = &Options{}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, h := range r.Hunks {
+		if !h.Conflict {
+			writeLines(buf, h.Lines)
+			continue
+		}
+
+		writeConflict(buf, h, o)
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// String returns the merged text using the default options.
+func (r *Result) String() string {
+	buf := bytes.NewBuffer(nil)
+	r.Encode(buf, nil)
+	return buf.String()
+}
+
+// Merge performs a line based three-way merge of ours and theirs, being base
+// its common ancestor. The merged text is returned, the regions changed in a
+// different way by both sides are wrapped between conflict markers and
+// conflict is set to true.
+func Merge(base, ours, theirs string, o *Options) (merged string, conflict bool) {
+	r := Do(base, ours, theirs)
+
+	buf := bytes.NewBuffer(nil)
+	r.Encode(buf, o)
+	return buf.String(), r.HasConflicts()
+}
+
+func writeConflict(buf *bytes.Buffer, h Hunk, o *Options) {
+	size := o.markerSize()
+	ours, theirs := h.Ours, h.Theirs
+
+	var suffix []string
+	if o.Style == MergeStyle {
+		n := commonPrefix(ours, theirs)
+		writeLines(buf, ours[:n])
+		ours, theirs = ours[n:], theirs[n:]
+
+		n = commonSuffix(ours, theirs)
+		suffix = ours[len(ours)-n:]
+		ours, theirs = ours[:len(ours)-n], theirs[:len(theirs)-n]
+	}
+
+	writeMarker(buf, '<', size, o.OursLabel)
+	writeLines(buf, ours)
+	if o.Style == Diff3Style {
+		writeMarker(buf, '|', size, o.BaseLabel)
+		writeLines(buf, h.Base)
+	}
+
+	writeMarker(buf, '=', size, "")
+	writeLines(buf, theirs)
+	writeMarker(buf, '>', size, o.TheirsLabel)
+	writeLines(buf, suffix)
+}
+
+// merge3 is an implementation of the diff3 algorithm, described at "A Formal
+// Investigation of Diff3" by Khanna, Kuber and Pierce. The lines of base
+// matched by both sides split the texts into stable hunks, the lines between
+// them are unstable hunks that are resolved when only one of the sides
+// changed them or when both made the same change.
+func merge3(base, ours, theirs []string) []Hunk {
+	mo := matchLines(base, ours)
+	mt := matchLines(base, theirs)
+
+	var hunks []Hunk
+	var i, a, b int
+	for i < len(base) || a < len(ours) || b < len(theirs) {
+		k := 0
+		for i+k < len(base) && mo[i+k] == a+k && mt[i+k] == b+k {
+			k++
+		}
+
+		if k > 0 {
+			hunks = appendHunk(hunks, Hunk{Lines: base[i : i+k]})
+			i, a, b = i+k, a+k, b+k
+			continue
+		}
+
+		next := i
+		for next < len(base) && (mo[next] < 0 || mt[next] < 0) {
+			next++
+		}
+
+		na, nb := len(ours), len(theirs)
+		if next < len(base) {
+			na, nb = mo[next], mt[next]
+		}
+
+		hunks = appendHunk(hunks, resolve(base[i:next], ours[a:na], theirs[b:nb]))
+		i, a, b = next, na, nb
+	}
+
+	return hunks
+}
+
+// appendHunk appends h to hunks, joining it with the last hunk when both are
+// clean.
+func appendHunk(hunks []Hunk, h Hunk) []Hunk {
+	if !h.Conflict && len(h.Lines) == 0 {
+		return hunks
+	}
+
+	last := len(hunks) - 1
+	if h.Conflict || last < 0 || hunks[last].Conflict {
+		return append(hunks, h)
+	}
+
+	lines := make([]string, 0, len(hunks[last].Lines)+len(h.Lines))
+	lines = append(lines, hunks[last].Lines...)
+	hunks[last].Lines = append(lines, h.Lines...)
+	return hunks
+}
+
+func resolve(base, ours, theirs []string) Hunk {
+	switch {
+	case equalLines(ours, base):
+		return Hunk{Lines: theirs}
+	case equalLines(theirs, base), equalLines(ours, theirs):
+		return Hunk{Lines: ours}
+	}
+
+	return Hunk{Conflict: true, Base: base, Ours: ours, Theirs: theirs}
+}
+
+// matchLines returns for every line of src the position of the same line in
+// dst, according to the line diff between both, or -1 if the line was
+// deleted.
+func matchLines(src, dst []string) []int {
+	m := make([]int, len(src))
+
+	dmp := diffmatchpatch.New()
+	wSrc, wDst, _ := dmp.DiffLinesToRunes(strings.Join(src, ""), strings.Join(dst, ""))
+	diffs := dmp.DiffMainRunes(wSrc, wDst, false)
+
+	var i, j int
+	for _, d := range diffs {
+		n := utf8.RuneCountInString(d.Text)
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for k := 0; k < n; k++ {
+				m[i+k] = j + k
+			}
+
+			i, j = i+n, j+n
+		case diffmatchpatch.DiffDelete:
+			for k := 0; k < n; k++ {
+				m[i+k] = -1
+			}
+
+			i += n
+		case diffmatchpatch.DiffInsert:
+			j += n
+		}
+	}
+
+	return m
+}
+
+// splitLines splits s after each '\n', the last line may not end in '\n'.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// commonPrefix returns the number of lines shared by the beginning of a and b.
+func commonPrefix(a, b []string) int {
+	var n int
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+// commonSuffix returns the number of lines shared by the end of a and b.
+func commonSuffix(a, b []string) int {
+	var n int
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+
+	return n
+}
+
+func writeLines(buf *bytes.Buffer, lines []string) {
+	for _, l := range lines {
+		buf.WriteString(l)
+	}
+}
+
+// writeMarker writes a conflict marker in its own line, a '\n' is added when
+// the content before the marker doesn't end in a new line.
+func writeMarker(buf *bytes.Buffer, c byte, size int, label string) {
+	if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	buf.Write(bytes.Repeat([]byte{c}, size))
+	if label != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(label)
+	}
+
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,136 @@
+package merge_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/merge"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MergeSuite struct{}
+
+var _ = Suite(&MergeSuite{})
+
+var mergeTests = [...]struct {
+	base, ours, theirs string
+	expected           string
+	conflict           bool
+}{
+	// no changes
+	{"", "", "", "", false},
+	{"a\nb\n", "a\nb\n", "a\nb\n", "a\nb\n", false},
+	// changes in one side
+	{"a\nb\nc\n", "a\nB\nc\n", "a\nb\nc\n", "a\nB\nc\n", false},
+	{"a\nb\nc\n", "a\nb\nc\n", "a\nb\nC\n", "a\nb\nC\n", false},
+	{"a\nb\nc\n", "a\nc\n", "a\nb\nc\n", "a\nc\n", false},
+	{"", "a\n", "", "a\n", false},
+	// non overlapping changes in both sides
+	{"a\nb\nc\nd\ne\n", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", false},
+	{"a\nb\nc\n", "x\na\nb\nc\n", "a\nb\nc\ny\n", "x\na\nb\nc\ny\n", false},
+	// same change in both sides
+	{"a\nb\nc\n", "a\nB\nc\n", "a\nB\nc\n", "a\nB\nc\n", false},
+	// conflicting changes
+	{
+		"a\nb\nc\n", "a\nB\nc\n", "a\nX\nc\n",
+		"a\n<<<<<<< ours\nB\n=======\nX\n>>>>>>> theirs\nc\n", true,
+	},
+	{
+		"", "a\n", "b\n",
+		"<<<<<<< ours\na\n=======\nb\n>>>>>>> theirs\n", true,
+	},
+	// missing '\n' at the end of a side
+	{
+		"a\n", "b", "c\n",
+		"<<<<<<< ours\nb\n=======\nc\n>>>>>>> theirs\n", true,
+	},
+}
+
+func (s *MergeSuite) TestMerge(c *C) {
+	opts := &merge.Options{OursLabel: "ours", TheirsLabel: "theirs"}
+	for i, t := range mergeTests {
+		merged, conflict := merge.Merge(t.base, t.ours, t.theirs, opts)
+		comment := Commentf("subtest %d, base=%q, ours=%q, theirs=%q", i, t.base, t.ours, t.theirs)
+		c.Assert(merged, Equals, t.expected, comment)
+		c.Assert(conflict, Equals, t.conflict, comment)
+	}
+}
+
+func (s *MergeSuite) TestMergeWithoutLabels(c *C) {
+	merged, conflict := merge.Merge("a\n", "b\n", "c\n", nil)
+	c.Assert(conflict, Equals, true)
+	c.Assert(merged, Equals, "<<<<<<<\nb\n=======\nc\n>>>>>>>\n")
+}
+
+func (s *MergeSuite) TestDoHunks(c *C) {
+	r := merge.Do("a\nb\nc\nd\n", "a\nB\nc\nd\n", "a\nX\nc\nD\n")
+	c.Assert(r.HasConflicts(), Equals, true)
+	c.Assert(r.Hunks, DeepEquals, []merge.Hunk{
+		{Lines: []string{"a\n"}},
+		{
+			Conflict: true,
+			Base:     []string{"b\n"},
+			Ours:     []string{"B\n"},
+			Theirs:   []string{"X\n"},
+		},
+		{Lines: []string{"c\n", "D\n"}},
+	})
+
+	c.Assert(r.Conflicts(), HasLen, 1)
+	c.Assert(r.Conflicts()[0].Base, DeepEquals, []string{"b\n"})
+}
+
+func (s *MergeSuite) TestDoClean(c *C) {
+	r := merge.Do("a\nb\nc\n", "a\nb\nC\n", "A\nb\nc\n")
+	c.Assert(r.HasConflicts(), Equals, false)
+	c.Assert(r.Conflicts(), HasLen, 0)
+	c.Assert(r.Hunks, DeepEquals, []merge.Hunk{{Lines: []string{"A\n", "b\n", "C\n"}}})
+	c.Assert(r.String(), Equals, "A\nb\nC\n")
+}
+
+func (s *MergeSuite) TestEncodeDiff3Style(c *C) {
+	r := merge.Do("a\nb\nc\n", "a\nB\nc\n", "a\nX\nc\n")
+
+	buf := bytes.NewBuffer(nil)
+	err := r.Encode(buf, &merge.Options{
+		Style:       merge.Diff3Style,
+		OursLabel:   "ours",
+		BaseLabel:   "base",
+		TheirsLabel: "theirs",
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals,
+		"a\n<<<<<<< ours\nB\n||||||| base\nb\n=======\nX\n>>>>>>> theirs\nc\n",
+	)
+}
+
+func (s *MergeSuite) TestEncodeMarkerSize(c *C) {
+	merged, conflict := merge.Merge("a\n", "b\n", "c\n", &merge.Options{
+		Style:      merge.Diff3Style,
+		MarkerSize: 3,
+	})
+
+	c.Assert(conflict, Equals, true)
+	c.Assert(merged, Equals, "<<<\nb\n|||\na\n===\nc\n>>>\n")
+}
+
+func (s *MergeSuite) TestEncodeTrimsCommonLines(c *C) {
+	base := "a\n"
+	ours := "x\nb\ny\n"
+	theirs := "x\nc\ny\n"
+
+	merged, conflict := merge.Merge(base, ours, theirs, nil)
+	c.Assert(conflict, Equals, true)
+	c.Assert(merged, Equals, "x\n<<<<<<<\nb\n=======\nc\n>>>>>>>\ny\n")
+
+	merged, conflict = merge.Merge(base, ours, theirs, &merge.Options{
+		Style: merge.Diff3Style,
+	})
+
+	c.Assert(conflict, Equals, true)
+	c.Assert(merged, Equals, "<<<<<<<\nx\nb\ny\n|||||||\na\n=======\nx\nc\ny\n>>>>>>>\n")
+}
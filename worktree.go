@@ -13,6 +13,7 @@ import (
 	"github.com/sniperkit/snk.fork.go-git.v4/config"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+	format "github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/config"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/gitignore"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
@@ -29,6 +30,18 @@ var (
 	ErrSubmoduleNotFound = errors.New("submodule not found")
 	ErrUnstagedChanges   = errors.New("worktree contains unstaged changes")
 	ErrGitModulesSymlink = errors.New(gitmodulesFile + " is a symlink")
+	// ErrNonFastForwardUpdate is returned by Pull when the current branch
+	// can't be fast-forwarded and a fast-forward was required.
+	ErrNonFastForwardUpdate = errors.New("non-fast-forward update")
+	// ErrUnsupportedPullMode is returned by Pull when the configured rebase
+	// mode, merges or interactive, is not supported.
+	ErrUnsupportedPullMode = errors.New("unsupported pull mode")
+)
+
+const (
+	pullSection    = "pull"
+	rebaseKey      = "rebase"
+	fastForwardKey = "ff"
 )
 
 // Worktree represents a git worktree.
@@ -45,7 +58,8 @@ type Worktree struct {
 // Returns nil if the operation is successful, NoErrAlreadyUpToDate if there are
 // no changes to be fetched, or an error.
 //
-// Pull only supports merges where the can be resolved as a fast-forward.
+// How the fetched branch is integrated into the current branch is defined by
+// PullOptions.Mode, see PullMode.
 func (w *Worktree) Pull(o *PullOptions) error {
 	return w.PullContext(context.Background(), o)
 }
@@ -54,7 +68,8 @@ func (w *Worktree) Pull(o *PullOptions) error {
 // branch. Returns nil if the operation is successful, NoErrAlreadyUpToDate if
 // there are no changes to be fetched, or an error.
 //
-// Pull only supports merges where the can be resolved as a fast-forward.
+// How the fetched branch is integrated into the current branch is defined by
+// PullOptions.Mode, see PullMode.
 //
 // The provided Context must be non-nil. If the context expires before the
 // operation is complete, an error is returned. The context only affects to the
@@ -76,6 +91,7 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		Progress:   o.Progress,
 		Force:      o.Force,
 	})
+	w.r.resetObjectStorer()
 
 	updated := true
 	if err == NoErrAlreadyUpToDate {
@@ -95,13 +111,22 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 			return NoErrAlreadyUpToDate
 		}
 
+		mode, err := w.pullMode(head, o.Mode)
+		if err != nil {
+			return err
+		}
+
 		ff, err := isFastForward(w.r.Storer, head.Hash(), ref.Hash())
 		if err != nil {
 			return err
 		}
 
-		if !ff {
-			return fmt.Errorf("non-fast-forward update")
+		if !ff || mode == NoFastForwardPullMode {
+			if err := w.pullDiverged(remote, head, ref, mode, o); err != nil {
+				return err
+			}
+
+			return w.pullSubmodules(o)
 		}
 	}
 
@@ -109,7 +134,7 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
-	if err := w.updateHEAD(ref.Hash()); err != nil {
+	if err := w.updateHEAD(ref.Hash(), "pull: Fast-forward", o.Committer); err != nil {
 		return err
 	}
 
@@ -120,14 +145,117 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
-	if o.RecurseSubmodules != NoRecurseSubmodules {
-		return w.updateSubmodules(&SubmoduleUpdateOptions{
-			RecurseSubmodules: o.RecurseSubmodules,
-			Auth:              o.Auth,
-		})
+	return w.pullSubmodules(o)
+}
+
+// pullDiverged integrates ref into the current branch when it can't be, or
+// must not be, fast-forwarded.
+func (w *Worktree) pullDiverged(remote *Remote, head, ref *plumbing.Reference,
+	mode PullMode, o *PullOptions) error {
+
+	behind, err := isFastForward(w.r.Storer, ref.Hash(), head.Hash())
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if behind {
+		return NoErrAlreadyUpToDate
+	}
+
+	if mode == FastForwardOnlyPullMode {
+		return ErrNonFastForwardUpdate
+	}
+
+	if mode == RebasePullMode {
+		if o.Committer == nil {
+			return ErrMissingCommitter
+		}
+
+		return w.rebaseOnto(head, ref.Hash(), o.Committer)
+	}
+
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	_, err = w.Merge(&MergeOptions{
+		Commit:        ref.Hash(),
+		Message:       pullMergeMessage(remote, ref),
+		Author:        o.Author,
+		Committer:     o.Committer,
+		NoFastForward: mode == NoFastForwardPullMode,
+	})
+
+	return err
+}
+
+// pullMode returns the mode to be used by a pull into the branch at head, the
+// given mode is returned unless it's DefaultPullMode.
+func (w *Worktree) pullMode(head *plumbing.Reference, mode PullMode) (PullMode, error) {
+	if mode != DefaultPullMode {
+		return mode, nil
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return mode, err
+	}
+
+	value := cfg.Raw.Section(pullSection).Option(rebaseKey)
+	if b, ok := cfg.Branches[head.Name().Short()]; ok && b.Rebase != "" {
+		value = b.Rebase
+	}
+
+	rebase, rebaseSet := format.ParseBool(value)
+	switch {
+	case rebase:
+		return RebasePullMode, nil
+	case value == "merges" || value == "interactive":
+		return mode, ErrUnsupportedPullMode
+	}
+
+	ff := cfg.Raw.Section(pullSection).Option(fastForwardKey)
+	if ff == "only" {
+		return FastForwardOnlyPullMode, nil
+	}
+
+	if b, ok := format.ParseBool(ff); ok {
+		if b {
+			return MergePullMode, nil
+		}
+
+		return NoFastForwardPullMode, nil
+	}
+
+	if rebaseSet {
+		return MergePullMode, nil
+	}
+
+	return FastForwardOnlyPullMode, nil
+}
+
+func pullMergeMessage(remote *Remote, ref *plumbing.Reference) string {
+	name := fmt.Sprintf("commit '%s'", ref.Hash())
+	if ref.Name().IsBranch() {
+		name = fmt.Sprintf("branch '%s'", ref.Name().Short())
+	}
+
+	if urls := remote.Config().URLs; len(urls) > 0 {
+		return fmt.Sprintf("Merge %s of %s\n", name, urls[0])
+	}
+
+	return fmt.Sprintf("Merge %s\n", name)
+}
+
+func (w *Worktree) pullSubmodules(o *PullOptions) error {
+	if o.RecurseSubmodules == NoRecurseSubmodules {
+		return nil
+	}
+
+	return w.updateSubmodules(&SubmoduleUpdateOptions{
+		RecurseSubmodules: o.RecurseSubmodules,
+		Auth:              o.Auth,
+	})
 }
 
 func (w *Worktree) updateSubmodules(o *SubmoduleUpdateOptions) error {
@@ -172,10 +300,15 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		ro.Mode = HardReset
 	}
 
+	from, err := w.checkoutSource()
+	if err != nil {
+		return err
+	}
+
 	if !opts.Hash.IsZero() && !opts.Create {
-		err = w.setHEADToCommit(opts.Hash)
+		err = w.setHEADToCommit(opts.Hash, from)
 	} else {
-		err = w.setHEADToBranch(opts.Branch, c)
+		err = w.setHEADToBranch(opts.Branch, c, from)
 	}
 
 	if err != nil {
@@ -203,8 +336,9 @@ func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 		opts.Hash = ref.Hash()
 	}
 
-	return w.r.Storer.SetReference(
-		plumbing.NewHashReference(opts.Branch, opts.Hash),
+	return setReferenceWithLog(w.r.Storer,
+		plumbing.NewHashReference(opts.Branch, opts.Hash), nil,
+		"branch: Created from "+opts.Hash.String(), nil,
 	)
 }
 
@@ -241,12 +375,32 @@ func (w *Worktree) getCommitFromCheckoutOptions(opts *CheckoutOptions) (plumbing
 	return plumbing.ZeroHash, fmt.Errorf("unsupported tag target %q", o.Type())
 }
 
-func (w *Worktree) setHEADToCommit(commit plumbing.Hash) error {
+// checkoutSource returns the name of the branch, or the commit if detached,
+// checked out before a checkout, as used by its reflog message.
+func (w *Worktree) checkoutSource() (string, error) {
+	head, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.HEAD.String(), nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target().Short(), nil
+	}
+
+	return head.Hash().String(), nil
+}
+
+func (w *Worktree) setHEADToCommit(commit plumbing.Hash, from string) error {
 	head := plumbing.NewHashReference(plumbing.HEAD, commit)
-	return w.r.Storer.SetReference(head)
+	msg := fmt.Sprintf("checkout: moving from %s to %s", from, commit)
+	return setReferenceWithLog(w.r.Storer, head, nil, msg, nil)
 }
 
-func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash) error {
+func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash, from string) error {
 	target, err := w.r.Storer.Reference(branch)
 	if err != nil {
 		return err
@@ -259,7 +413,8 @@ func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbin
 		head = plumbing.NewHashReference(plumbing.HEAD, commit)
 	}
 
-	return w.r.Storer.SetReference(head)
+	msg := fmt.Sprintf("checkout: moving from %s to %s", from, target.Name().Short())
+	return setReferenceWithLog(w.r.Storer, head, nil, msg, nil)
 }
 
 // Reset the worktree to a specified state.
@@ -313,6 +468,10 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 		return err
 	}
 
+	if err := w.resetUnmergedEntries(idx); err != nil {
+		return err
+	}
+
 	changes, err := w.diffTreeWithStaging(t, true)
 	if err != nil {
 		return err
@@ -354,6 +513,24 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
+// resetUnmergedEntries removes the unmerged entries of the index, their paths
+// are restored as any other missing path.
+func (w *Worktree) resetUnmergedEntries(idx *index.Index) error {
+	entries := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if e.Stage == index.Merged {
+			entries = append(entries, e)
+		}
+	}
+
+	if len(entries) == len(idx.Entries) {
+		return nil
+	}
+
+	idx.Entries = entries
+	return w.r.Storer.SetIndex(idx)
+}
+
 func (w *Worktree) resetWorktree(t *object.Tree) error {
 	changes, err := w.diffStagingWithWorktree(true)
 	if err != nil {
@@ -365,8 +542,15 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 		return err
 	}
 
+	c, err := w.newConverter(true)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
 	for _, ch := range changes {
-		if err := w.checkoutChange(ch, t, idx); err != nil {
+		if err := w.checkoutChange(c, ch, t, idx); err != nil {
 			return err
 		}
 	}
@@ -374,7 +558,7 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *index.Index) error {
+func (w *Worktree) checkoutChange(c *converter, ch merkletrie.Change, t *object.Tree, idx *index.Index) error {
 	a, err := ch.Action()
 	if err != nil {
 		return err
@@ -401,7 +585,7 @@ func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *ind
 		return w.checkoutChangeSubmodule(name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(c, name, a, t, e, idx)
 }
 
 func (w *Worktree) containsUnstagedChanges() (bool, error) {
@@ -432,9 +616,14 @@ func (w *Worktree) setHEADCommit(commit plumbing.Hash) error {
 		return err
 	}
 
+	msg := fmt.Sprintf("reset: moving to %s", commit)
 	if head.Type() == plumbing.HashReference {
+		if head.Hash() == commit {
+			return nil
+		}
+
 		head = plumbing.NewHashReference(plumbing.HEAD, commit)
-		return w.r.Storer.SetReference(head)
+		return setReferenceWithLog(w.r.Storer, head, nil, msg, nil)
 	}
 
 	branch, err := w.r.Reference(head.Target(), false)
@@ -446,8 +635,12 @@ func (w *Worktree) setHEADCommit(commit plumbing.Hash) error {
 		return fmt.Errorf("invalid HEAD target should be a branch, found %s", branch.Type())
 	}
 
+	if branch.Hash() == commit {
+		return nil
+	}
+
 	branch = plumbing.NewHashReference(branch.Name(), commit)
-	return w.r.Storer.SetReference(branch)
+	return setReferenceWithLog(w.r.Storer, branch, nil, msg, nil)
 }
 
 func (w *Worktree) checkoutChangeSubmodule(name string,
@@ -483,7 +676,8 @@ func (w *Worktree) checkoutChangeSubmodule(name string,
 	return nil
 }
 
-func (w *Worktree) checkoutChangeRegularFile(name string,
+func (w *Worktree) checkoutChangeRegularFile(c *converter,
+	name string,
 	a merkletrie.Action,
 	t *object.Tree,
 	e *object.TreeEntry,
@@ -506,7 +700,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 			return err
 		}
 
-		if err := w.checkoutFile(f); err != nil {
+		if err := w.checkoutFile(c, f); err != nil {
 			return err
 		}
 
@@ -516,7 +710,9 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	return nil
 }
 
-func (w *Worktree) checkoutFile(f *object.File) (err error) {
+// checkoutFile writes the file to the worktree, its content converted by the
+// given converter, see converter.smudge.
+func (w *Worktree) checkoutFile(c *converter, f *object.File) (err error) {
 	mode, err := f.Mode.ToOSFileMode()
 	if err != nil {
 		return
@@ -540,7 +736,22 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 
 	defer ioutil.CheckClose(to, &err)
 
-	_, err = io.Copy(to, from)
+	if !c.converts(f.Name) {
+		_, err = io.Copy(to, from)
+		return
+	}
+
+	content, err := stdioutil.ReadAll(from)
+	if err != nil {
+		return
+	}
+
+	content, err = c.smudge(f.Name, content)
+	if err != nil {
+		return
+	}
+
+	_, err = to.Write(content)
 	return
 }
 
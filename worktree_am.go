@@ -0,0 +1,276 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/diff"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/mbox"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+)
+
+var (
+	// ErrEmptyPatch is returned when a message of a mbox contains no patch.
+	ErrEmptyPatch = errors.New("patch is empty")
+)
+
+// Am applies the patches of the messages of the given mbox, as written by
+// Repository.FormatPatch or `git format-patch`, committing every patch on
+// top of HEAD with the author, date and message of its message, equivalent
+// to `git am`. Returns the hashes of the new commits.
+//
+// The worktree must be clean. If a patch doesn't apply, the worktree is left
+// at the commit of the previous patch and the hashes of the commits created
+// so far are returned together with the error.
+func (w *Worktree) Am(r io.Reader, opts *AmOptions) ([]plumbing.Hash, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := w.checkCleanForMerge(); err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	d := mbox.NewDecoder(r)
+	for {
+		m, err := d.Decode()
+		if err == io.EOF {
+			return hashes, nil
+		}
+
+		if err != nil {
+			return hashes, err
+		}
+
+		h, err := w.am(m, opts)
+		if err != nil {
+			return hashes, err
+		}
+
+		hashes = append(hashes, h)
+	}
+}
+
+func (w *Worktree) am(m *mbox.Message, opts *AmOptions) (plumbing.Hash, error) {
+	p, err := parsePatchMessage(m, opts.KeepSubject)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if len(p.patch.FilePatches()) == 0 {
+		return plumbing.ZeroHash, ErrEmptyPatch
+	}
+
+	if err := w.Apply(p.patch, &ApplyOptions{Index: true}); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.Commit(p.message, &CommitOptions{
+		Author:    p.author,
+		Committer: opts.Committer,
+	})
+}
+
+// patchMessage is a message of a mbox parsed as a patch.
+type patchMessage struct {
+	author  *object.Signature
+	message string
+	patch   diff.Patch
+}
+
+// parsePatchMessage parses a message written by git format-patch. The
+// headers at the beginning of the body, like From or Subject, override the
+// ones of the message, as git mailinfo does.
+func parsePatchMessage(m *mbox.Message, keepSubject bool) (*patchMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Content))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := decodeMessageBody(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	header := map[string]string{
+		"From":    msg.Header.Get("From"),
+		"Date":    msg.Header.Get("Date"),
+		"Subject": msg.Header.Get("Subject"),
+	}
+
+	body = parseInBodyHeaders(body, header)
+
+	author, err := parseAuthor(header["From"], header["Date"])
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := diff.NewUnifiedDecoder(strings.NewReader(body)).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := &mime.WordDecoder{}
+	subject, err := dec.DecodeHeader(header["Subject"])
+	if err != nil {
+		return nil, err
+	}
+
+	if !keepSubject {
+		subject = cleanSubject(subject)
+	}
+
+	message := subject + "\n"
+	if text := strings.Trim(patchDescription(patch.Message()), "\n"); text != "" {
+		message += "\n" + text + "\n"
+	}
+
+	return &patchMessage{author: author, message: message, patch: patch}, nil
+}
+
+// decodeMessageBody returns the body of the message decoding its transfer
+// encoding.
+func decodeMessageBody(msg *mail.Message) (string, error) {
+	r := msg.Body
+	switch strings.ToLower(msg.Header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Replace(string(body), "\r\n", "\n", -1), nil
+}
+
+// parseInBodyHeaders reads the From, Date and Subject headers at the
+// beginning of the body, storing them in header, and returns the rest of
+// the body.
+func parseInBodyHeaders(body string, header map[string]string) string {
+	rest := strings.TrimLeft(body, "\n")
+	found := make(map[string]string)
+	for {
+		line := rest
+		i := strings.IndexByte(rest, '\n')
+		if i != -1 {
+			line = rest[:i]
+		}
+
+		if line == "" {
+			break
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon == -1 {
+			return body
+		}
+
+		key := line[:colon]
+		if _, ok := header[key]; !ok {
+			return body
+		}
+
+		found[key] = strings.TrimSpace(line[colon+1:])
+		if i == -1 {
+			rest = ""
+			break
+		}
+
+		rest = rest[i+1:]
+	}
+
+	if len(found) == 0 {
+		return body
+	}
+
+	for key, value := range found {
+		header[key] = value
+	}
+
+	return strings.TrimLeft(rest, "\n")
+}
+
+func parseAuthor(from, date string) (*object.Signature, error) {
+	if from == "" {
+		return nil, ErrMissingAuthor
+	}
+
+	author := &object.Signature{When: time.Now()}
+	if addr, err := mail.ParseAddress(from); err == nil {
+		author.Name, author.Email = addr.Name, addr.Address
+	} else {
+		author.Name = strings.TrimSpace(from)
+		if open := strings.LastIndexByte(from, '<'); open != -1 {
+			author.Name = strings.TrimSpace(from[:open])
+			author.Email = strings.Trim(from[open:], "<> ")
+		}
+	}
+
+	if author.Name == "" {
+		author.Name = author.Email
+	}
+
+	if date != "" {
+		when, err := mail.ParseDate(date)
+		if err != nil {
+			return nil, err
+		}
+
+		author.When = when
+	}
+
+	return author, nil
+}
+
+// cleanSubject removes the leading "Re:" and bracketed strings, like
+// "[PATCH 1/2]", of a subject.
+func cleanSubject(subject string) string {
+	for {
+		subject = strings.TrimSpace(subject)
+		switch {
+		case strings.HasPrefix(strings.ToLower(subject), "re:"):
+			subject = subject[len("re:"):]
+		case strings.HasPrefix(subject, "["):
+			end := strings.IndexByte(subject, ']')
+			if end == -1 {
+				return subject
+			}
+
+			subject = subject[end+1:]
+		default:
+			return subject
+		}
+	}
+}
+
+// patchDescription returns the description of a patch, the text before the
+// "---" line separating it from the diffstat.
+func patchDescription(text string) string {
+	var buf bytes.Buffer
+	s := bufio.NewScanner(strings.NewReader(text))
+	for s.Scan() {
+		if strings.TrimRight(s.Text(), " \t") == "---" {
+			break
+		}
+
+		buf.WriteString(s.Text())
+		buf.WriteString("\n")
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,157 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/mbox"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *WorktreeSuite) TestAm(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.FormatPatch(buf, &FormatPatchOptions{Since: base, Signature: "go-git"})
+	c.Assert(err, IsNil)
+
+	original, err := w.r.CommitObject(commits[0])
+	c.Assert(err, IsNil)
+
+	other, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+	_, err = other.Am(bytes.NewReader(buf.Bytes()), &AmOptions{})
+	c.Assert(err, Equals, ErrMissingCommitter)
+
+	hashes, err := other.Am(buf, &AmOptions{Committer: &original.Committer})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 2)
+	c.Assert(hashes[0], Equals, commits[0])
+
+	head, err := other.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, hashes[1])
+
+	commit, err := other.r.CommitObject(commits[0])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Add bar\n\nbar is needed by foo.\n")
+	c.Assert(commit.Author.Name, Equals, "John Doe")
+	c.Assert(commit.Author.Email, Equals, "john@doe.com")
+
+	commit, err = other.r.CommitObject(hashes[1])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Use bar in foo\n")
+
+	assertFileContent(c, other, "foo", "foo\nbar\n")
+	assertFileContent(c, other, "bar", "bar\n")
+}
+
+func (s *WorktreeSuite) TestAmCommitter(c *C) {
+	w, base, _ := newPatchSeriesRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.FormatPatch(buf, &FormatPatchOptions{Since: base})
+	c.Assert(err, IsNil)
+
+	other, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+	hashes, err := other.Am(buf, &AmOptions{Committer: defaultSignature(), KeepSubject: true})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 2)
+
+	commit, err := other.r.CommitObject(hashes[1])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "[PATCH 2/2] Use bar in foo\n")
+	c.Assert(commit.Author.Name, Equals, "John Doe")
+	c.Assert(commit.Committer.Name, Equals, defaultSignature().Name)
+}
+
+// amMessage is a patch sent by a mail client, with an encoded sender,
+// quoted-printable body and the real author in the body.
+const amMessage = `From: =?UTF-8?q?Jos=C3=A9_Doe?= <jose@doe.com>
+Date: Tue, 10 Nov 2009 23:00:00 +0100
+Subject: Re: [PATCH v2] =?UTF-8?q?A=C3=B1adir_bar?=
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: quoted-printable
+
+From: Jane Doe <jane@doe.com>
+
+The body is encoded: caf=C3=A9.
+---
+ bar | 1 +
+ 1 file changed, 1 insertion(+)
+
+diff --git a/bar b/bar
+new file mode 100644
+--- /dev/null
++++ b/bar
+@@ -0,0 +1 @@
++bar
+`
+
+func (s *WorktreeSuite) TestAmMessage(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+
+	buf := bytes.NewBuffer(nil)
+	err := mbox.NewEncoder(buf).Encode(&mbox.Message{
+		From:    "jose@doe.com",
+		Date:    mbox.PatchDate,
+		Content: []byte(amMessage),
+	})
+	c.Assert(err, IsNil)
+
+	hashes, err := w.Am(buf, &AmOptions{Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 1)
+
+	commit, err := w.r.CommitObject(hashes[0])
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Añadir bar\n\nThe body is encoded: café.\n")
+	c.Assert(commit.Author.Name, Equals, "Jane Doe")
+	c.Assert(commit.Author.Email, Equals, "jane@doe.com")
+	c.Assert(commit.Author.When.Equal(time.Date(2009, time.November, 10, 22, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(commit.Committer.Name, Equals, defaultSignature().Name)
+	assertFileContent(c, w, "bar", "bar\n")
+}
+
+func (s *WorktreeSuite) TestAmDoesNotApply(c *C) {
+	w, base, commits := newPatchSeriesRepository(c)
+
+	buf := bytes.NewBuffer(nil)
+	err := w.r.FormatPatch(buf, &FormatPatchOptions{Since: base})
+	c.Assert(err, IsNil)
+
+	other, _, _ := newMergeRepository(c, map[string]string{"foo": "baz\n"}, nil, nil)
+	head, err := other.r.Head()
+	c.Assert(err, IsNil)
+
+	hashes, err := other.Am(buf, &AmOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrPatchDoesNotApply)
+	c.Assert(hashes, HasLen, 1)
+
+	commit, err := other.r.CommitObject(hashes[0])
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{head.Hash()})
+	c.Assert(hashes[0], Not(Equals), commits[0])
+
+	head, err = other.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, hashes[0])
+
+	status, err := other.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestAmEmptyPatch(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+
+	mbox := "From foo Mon Sep 17 00:00:00 2001\nFrom: foo <foo@foo.foo>\nSubject: foo\n\nfoo\n"
+	_, err := w.Am(strings.NewReader(mbox), &AmOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrEmptyPatch)
+
+	mbox = "From foo Mon Sep 17 00:00:00 2001\nSubject: foo\n\n" + applyPatch
+	_, err = w.Am(strings.NewReader(mbox), &AmOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrMissingAuthor)
+}
@@ -0,0 +1,600 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/diff"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+)
+
+var (
+	// ErrPatchDoesNotApply is returned by Apply when a hunk can't be found in
+	// the file being patched.
+	ErrPatchDoesNotApply = errors.New("patch does not apply")
+	// ErrApplyFileExists is returned by Apply when a file created, renamed
+	// or copied by the patch already exists.
+	ErrApplyFileExists = errors.New("file created by the patch already exists")
+	// ErrApplyFileNotFound is returned by Apply when a file patched doesn't
+	// exist.
+	ErrApplyFileNotFound = errors.New("file patched does not exist")
+	// ErrApplyIndexMismatch is returned by Apply, when applied to the index
+	// and the worktree, if a patched file doesn't match the index.
+	ErrApplyIndexMismatch = errors.New("file patched does not match the index")
+	// ErrApplyBinary is returned by Apply when a binary patch can't be
+	// applied, they are only applied when the patched blob is in the
+	// repository.
+	ErrApplyBinary = errors.New("binary patch can't be applied")
+	// ErrApplyInvalidPath is returned by Apply when a path of the patch is
+	// absolute, contains empty, "." or ".." components or goes into a .git
+	// directory.
+	ErrApplyInvalidPath = errors.New("invalid path in patch")
+)
+
+// Apply applies the given patch, as git apply does. By default the patch is
+// applied to the worktree only, ApplyOptions.Cached and ApplyOptions.Index
+// apply it to the index too. The patch is either applied entirely or not at
+// all: if any file patch doesn't apply nothing is changed.
+//
+// Besides the patches decoded by diff.UnifiedDecoder, any diff.Patch can be
+// applied, such as object.Patch, whose chunks contain the whole files.
+//
+// The paths of the patch are checked before anything is read or written,
+// ErrApplyInvalidPath is returned if any of them could escape the worktree or
+// write into a .git directory.
+func (w *Worktree) Apply(patch diff.Patch, opts *ApplyOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	for _, fp := range patch.FilePatches() {
+		from, to := fp.Files()
+		for _, f := range []diff.File{from, to} {
+			if f != nil && !isValidPatchPath(f.Path()) {
+				return ErrApplyInvalidPath
+			}
+		}
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	a := &patchApplier{w: w, idx: idx, o: opts, files: make(map[string]*patchedFile)}
+	for _, fp := range patch.FilePatches() {
+		if err := a.apply(fp); err != nil {
+			return err
+		}
+	}
+
+	if opts.Check {
+		return nil
+	}
+
+	return a.write()
+}
+
+// isValidPatchPath returns false for the absolute paths, the ones with empty,
+// "." or ".." components and the ones with a component that can be
+// interpreted as .git.
+func isValidPatchPath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "/") {
+		return false
+	}
+
+	for _, name := range strings.Split(p, "/") {
+		if name == "" || name == "." || name == ".." || isDotGitName(name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// patchApplier applies the file patches in memory, keeping the result of each
+// one so they can be applied on top of each other, and writes them at once.
+type patchApplier struct {
+	w   *Worktree
+	idx *index.Index
+	o   *ApplyOptions
+
+	files map[string]*patchedFile
+	// paths are the paths of the patched files in the order they were
+	// patched.
+	paths []string
+}
+
+// patchedFile is the result of patching a file, deleted is set if the file
+// was removed.
+type patchedFile struct {
+	content []byte
+	mode    filemode.FileMode
+	deleted bool
+}
+
+// applyHunk is a hunk of a file patch split into lines, reversed if needed.
+type applyHunk struct {
+	fromLine, toLine int
+	ops              []diff.Operation
+	lines            []string
+}
+
+func (a *patchApplier) apply(fp diff.FilePatch) error {
+	from, to := fp.Files()
+	hunks := patchHunks(fp, a.o.Reverse)
+	if a.o.Reverse {
+		from, to = to, from
+	}
+
+	if from == nil && to == nil {
+		return nil
+	}
+
+	var content []byte
+	var mode filemode.FileMode
+	if from != nil {
+		var exists bool
+		var err error
+		if content, mode, exists, err = a.read(from.Path()); err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrApplyFileNotFound
+		}
+	}
+
+	if to != nil && (from == nil || from.Path() != to.Path()) {
+		_, _, exists, err := a.read(to.Path())
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return ErrApplyFileExists
+		}
+	}
+
+	result, err := a.patchContent(fp, from, to, content, hunks)
+	if err != nil {
+		return err
+	}
+
+	if to == nil {
+		if len(result) != 0 {
+			return ErrPatchDoesNotApply
+		}
+
+		a.set(from.Path(), &patchedFile{deleted: true})
+		return nil
+	}
+
+	if from != nil && from.Path() != to.Path() && !isCopyPatch(fp) {
+		a.set(from.Path(), &patchedFile{deleted: true})
+	}
+
+	if to.Mode() != filemode.Empty {
+		mode = to.Mode()
+	} else if mode == filemode.Empty {
+		mode = filemode.Regular
+	}
+
+	a.set(to.Path(), &patchedFile{content: result, mode: mode})
+	return nil
+}
+
+// patchContent returns the content of a file after applying the patch.
+func (a *patchApplier) patchContent(fp diff.FilePatch, from, to diff.File,
+	content []byte, hunks []*applyHunk) ([]byte, error) {
+
+	if !fp.IsBinary() {
+		return applyHunks(content, hunks, a.o)
+	}
+
+	if from != nil && !from.Hash().IsZero() &&
+		from.Hash() != plumbing.ComputeHash(plumbing.BlobObject, content) {
+		return nil, ErrPatchDoesNotApply
+	}
+
+	if to == nil {
+		return nil, nil
+	}
+
+	if to.Hash().IsZero() {
+		return nil, ErrApplyBinary
+	}
+
+	result, err := blobContent(a.w.r.Storer, to.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		return nil, ErrApplyBinary
+	}
+
+	return result, err
+}
+
+func (a *patchApplier) set(path string, f *patchedFile) {
+	if _, ok := a.files[path]; !ok {
+		a.paths = append(a.paths, path)
+	}
+
+	a.files[path] = f
+}
+
+// read returns the content and the mode of a file, as left by the previous
+// file patches, or as found at the index or the worktree.
+func (a *patchApplier) read(path string) (content []byte, mode filemode.FileMode, exists bool, err error) {
+	if f, ok := a.files[path]; ok {
+		return f.content, f.mode, !f.deleted, nil
+	}
+
+	e, err := a.idx.Entry(path)
+	if err != nil && err != index.ErrEntryNotFound {
+		return nil, 0, false, err
+	}
+
+	if a.o.Cached {
+		if e == nil {
+			return nil, 0, false, nil
+		}
+
+		content, err := blobContent(a.w.r.Storer, e.Hash)
+		return content, e.Mode, true, err
+	}
+
+	fi, err := a.w.Filesystem.Lstat(path)
+	if os.IsNotExist(err) {
+		if a.o.Index && e != nil {
+			return nil, 0, false, ErrApplyIndexMismatch
+		}
+
+		return nil, 0, false, nil
+	}
+
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	if mode, err = filemode.NewFromOSFileMode(fi.Mode()); err != nil {
+		return nil, 0, false, err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := a.w.Filesystem.Readlink(path)
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		content = []byte(target)
+	} else if content, err = a.readFile(path); err != nil {
+		return nil, 0, false, err
+	}
+
+	if a.o.Index && (e == nil || e.Hash != plumbing.ComputeHash(plumbing.BlobObject, content)) {
+		return nil, 0, false, ErrApplyIndexMismatch
+	}
+
+	return content, mode, true, nil
+}
+
+func (a *patchApplier) readFile(path string) (content []byte, err error) {
+	f, err := a.w.Filesystem.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := buf.ReadFrom(f); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// write writes the patched files to the worktree and the index.
+func (a *patchApplier) write() error {
+	worktree := !a.o.Cached
+	toIndex := a.o.Cached || a.o.Index
+
+	// the deletions go first, so a file can be replaced by a renamed one
+	for _, deletions := range []bool{true, false} {
+		for _, path := range a.paths {
+			f := a.files[path]
+			if f.deleted != deletions {
+				continue
+			}
+
+			if worktree {
+				if err := a.writeFile(path, f); err != nil {
+					return err
+				}
+			}
+
+			if toIndex {
+				if err := a.writeIndexEntry(path, f, worktree); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if !toIndex {
+		return nil
+	}
+
+	return a.w.r.Storer.SetIndex(a.idx)
+}
+
+func (a *patchApplier) writeFile(path string, f *patchedFile) error {
+	fs := a.w.Filesystem
+	if f.deleted {
+		return rmFileAndDirIfEmpty(fs, path)
+	}
+
+	if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if f.mode == filemode.Symlink {
+		return fs.Symlink(string(f.content), path)
+	}
+
+	perm, err := f.mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(fs, path, f.content, perm.Perm())
+}
+
+func (a *patchApplier) writeIndexEntry(path string, f *patchedFile, worktree bool) error {
+	_, _ = a.idx.Remove(path)
+	if f.deleted {
+		return nil
+	}
+
+	h, err := writeBlob(a.w.r.Storer, f.content)
+	if err != nil {
+		return err
+	}
+
+	if worktree {
+		return a.w.addIndexFromFile(path, h, a.idx)
+	}
+
+	a.idx.Entries = append(a.idx.Entries, &index.Entry{
+		Name: path,
+		Hash: h,
+		Mode: f.mode,
+		Size: uint32(len(f.content)),
+	})
+
+	return nil
+}
+
+func isCopyPatch(fp diff.FilePatch) bool {
+	c, ok := fp.(interface {
+		IsCopy() bool
+	})
+
+	return ok && c.IsCopy()
+}
+
+// patchHunks returns the hunks of a file patch. The patches not decoded from
+// a unified diff contain the whole files so a single hunk is returned.
+func patchHunks(fp diff.FilePatch, reverse bool) []*applyHunk {
+	var hunks []*applyHunk
+	if p, ok := fp.(interface {
+		Hunks() []*diff.Hunk
+	}); ok {
+		for _, h := range p.Hunks() {
+			hunks = append(hunks, newApplyHunk(h.FromLine, h.ToLine, h.Chunks, reverse))
+		}
+	} else if chunks := fp.Chunks(); len(chunks) != 0 {
+		hunks = append(hunks, newApplyHunk(1, 1, chunks, reverse))
+	}
+
+	return hunks
+}
+
+func newApplyHunk(fromLine, toLine int, chunks []diff.Chunk, reverse bool) *applyHunk {
+	h := &applyHunk{fromLine: fromLine, toLine: toLine}
+	if reverse {
+		h.fromLine, h.toLine = toLine, fromLine
+	}
+
+	for _, c := range chunks {
+		op := c.Type()
+		if reverse && op == diff.Add {
+			op = diff.Delete
+		} else if reverse && op == diff.Delete {
+			op = diff.Add
+		}
+
+		for _, line := range splitLinesAfter(c.Content()) {
+			h.ops = append(h.ops, op)
+			h.lines = append(h.lines, line)
+		}
+	}
+
+	return h
+}
+
+// context returns the number of context lines at the beginning and the end
+// of the hunk.
+func (h *applyHunk) context() (leading, trailing int) {
+	for leading < len(h.ops) && h.ops[leading] == diff.Equal {
+		leading++
+	}
+
+	if leading == len(h.ops) {
+		return leading, 0
+	}
+
+	for trailing < len(h.ops) && h.ops[len(h.ops)-1-trailing] == diff.Equal {
+		trailing++
+	}
+
+	return leading, trailing
+}
+
+// applyHunks applies the hunks in order, each one is searched around its
+// position, the nearest match is taken. A hunk starting at the first line
+// must match at the beginning of the file and a hunk without trailing context
+// must match at its end, unless some context is ignored due to fuzz.
+func applyHunks(content []byte, hunks []*applyHunk, o *ApplyOptions) ([]byte, error) {
+	image := splitLinesAfter(string(content))
+	for _, h := range hunks {
+		leading, trailing := h.context()
+		matchBeginning := h.fromLine <= 1
+		matchEnd := trailing == 0
+
+		pos := h.toLine - 1
+		if pos < 0 {
+			pos = 0
+		}
+
+		applied := false
+		for fuzz := 0; fuzz <= o.Fuzz && !applied; fuzz++ {
+			lead, trail := min(fuzz, leading), min(fuzz, trailing)
+			ops := h.ops[lead : len(h.ops)-trail]
+			lines := h.lines[lead : len(h.lines)-trail]
+
+			var pre []string
+			for i, op := range ops {
+				if op != diff.Add {
+					pre = append(pre, lines[i])
+				}
+			}
+
+			found := findHunk(image, pre, pos+lead,
+				matchBeginning && fuzz == 0, matchEnd && fuzz == 0, o.IgnoreWhitespace)
+			if found == -1 {
+				continue
+			}
+
+			image = replaceHunk(image, found, ops, lines, len(pre))
+			applied = true
+		}
+
+		if !applied {
+			return nil, ErrPatchDoesNotApply
+		}
+	}
+
+	return []byte(strings.Join(image, "")), nil
+}
+
+// findHunk returns the position of the lines of pre at image, the nearest one
+// to pos, or -1.
+func findHunk(image, pre []string, pos int, matchBeginning, matchEnd, ignoreWhitespace bool) int {
+	matches := func(at int) bool {
+		if at < 0 || at+len(pre) > len(image) {
+			return false
+		}
+
+		for i, line := range pre {
+			if !equalLines(image[at+i], line, ignoreWhitespace) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	switch {
+	case matchBeginning && matchEnd:
+		if len(image) == len(pre) && matches(0) {
+			return 0
+		}
+
+		return -1
+	case matchBeginning:
+		if matches(0) {
+			return 0
+		}
+
+		return -1
+	case matchEnd:
+		if matches(len(image) - len(pre)) {
+			return len(image) - len(pre)
+		}
+
+		return -1
+	}
+
+	if pos > len(image) {
+		pos = len(image)
+	}
+
+	for d := 0; pos-d >= 0 || pos+d <= len(image); d++ {
+		if matches(pos - d) {
+			return pos - d
+		}
+
+		if d != 0 && matches(pos+d) {
+			return pos + d
+		}
+	}
+
+	return -1
+}
+
+// replaceHunk replaces the preimage of the hunk, found at pos, by its
+// postimage. The context lines of the image are kept.
+func replaceHunk(image []string, pos int, ops []diff.Operation, lines []string, preLen int) []string {
+	result := append([]string{}, image[:pos]...)
+	current := pos
+	for i, op := range ops {
+		switch op {
+		case diff.Equal:
+			result = append(result, image[current])
+			current++
+		case diff.Delete:
+			current++
+		case diff.Add:
+			result = append(result, lines[i])
+		}
+	}
+
+	return append(result, image[pos+preLen:]...)
+}
+
+func equalLines(a, b string, ignoreWhitespace bool) bool {
+	if a == b {
+		return true
+	}
+
+	if !ignoreWhitespace {
+		return false
+	}
+
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+// splitLinesAfter splits s in lines keeping their new lines.
+func splitLinesAfter(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
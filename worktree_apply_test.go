@@ -0,0 +1,313 @@
+package git
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/diff"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+// applyPatch is the output of git diff --cached -M on the files of
+// newApplyRepository.
+const applyPatch = `diff --git a/bar b/bar
+deleted file mode 100644
+index 5716ca5..0000000
+--- a/bar
++++ /dev/null
+@@ -1 +0,0 @@
+-bar
+diff --git a/foo b/foo
+index f00c965..700e820 100644
+--- a/foo
++++ b/foo
+@@ -1,10 +1,10 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+ 7
+ 8
+ 9
+-10
++ten
+\ No newline at end of file
+diff --git a/new b/new
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/new
+@@ -0,0 +1 @@
++new
+diff --git a/q ux b/q ux
+old mode 100644
+new mode 100755
+diff --git a/old b/renamed
+similarity index 83%
+rename from old
+rename to renamed
+index 0fdf397..e0318ee 100644
+--- a/old
++++ b/renamed
+@@ -3,4 +3,4 @@ b
+ c
+ d
+ e
+-f
++F
+`
+
+var applyFiles = map[string]string{
+	"foo":  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+	"bar":  "bar\n",
+	"q ux": "x\ny\nz\n",
+	"old":  "a\nb\nc\nd\ne\nf\n",
+}
+
+func newApplyRepository(c *C) *Worktree {
+	w, _, _ := newMergeRepository(c, applyFiles, nil, nil)
+	return w
+}
+
+func decodePatch(c *C, patch string) diff.Patch {
+	p, err := diff.NewUnifiedDecoder(strings.NewReader(patch)).Decode()
+	c.Assert(err, IsNil)
+	return p
+}
+
+func assertNoFile(c *C, w *Worktree, name string) {
+	_, err := w.Filesystem.Lstat(name)
+	c.Assert(os.IsNotExist(err), Equals, true, Commentf("file %s", name))
+}
+
+func assertAppliedPatch(c *C, w *Worktree) {
+	assertNoFile(c, w, "bar")
+	assertNoFile(c, w, "old")
+	assertFileContent(c, w, "foo", "1\n2\nthree\n4\n5\n6\n7\n8\n9\nten")
+	assertFileContent(c, w, "new", "new\n")
+	assertFileContent(c, w, "renamed", "a\nb\nc\nd\ne\nF\n")
+
+	fi, err := w.Filesystem.Lstat("q ux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0755))
+}
+
+func (s *WorktreeSuite) TestApply(c *C) {
+	w := newApplyRepository(c)
+
+	err := w.Apply(decodePatch(c, applyPatch), &ApplyOptions{})
+	c.Assert(err, IsNil)
+	assertAppliedPatch(c, w)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Unmodified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+	c.Assert(status.File("new").Worktree, Equals, Untracked)
+}
+
+func (s *WorktreeSuite) TestApplyIndex(c *C) {
+	w := newApplyRepository(c)
+
+	err := w.Apply(decodePatch(c, applyPatch), &ApplyOptions{Index: true})
+	c.Assert(err, IsNil)
+	assertAppliedPatch(c, w)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Modified)
+	c.Assert(status.File("new").Staging, Equals, Added)
+	c.Assert(status.File("bar").Staging, Equals, Deleted)
+	c.Assert(status.File("renamed").Staging, Equals, Added)
+	c.Assert(status.File("q ux").Staging, Equals, Modified)
+
+	for _, name := range []string{"foo", "new", "bar", "renamed", "old", "q ux"} {
+		c.Assert(status.File(name).Worktree, Equals, Unmodified, Commentf("file %s", name))
+	}
+}
+
+func (s *WorktreeSuite) TestApplyIndexMismatch(c *C) {
+	w := newApplyRepository(c)
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = w.Apply(decodePatch(c, applyPatch), &ApplyOptions{Index: true})
+	c.Assert(err, Equals, ErrApplyIndexMismatch)
+}
+
+func (s *WorktreeSuite) TestApplyCached(c *C) {
+	w := newApplyRepository(c)
+
+	err := w.Apply(decodePatch(c, applyPatch), &ApplyOptions{Cached: true})
+	c.Assert(err, IsNil)
+
+	for name, content := range applyFiles {
+		assertFileContent(c, w, name, content)
+	}
+
+	hash, err := w.Commit("apply\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	assertTreeFile(c, commit, "foo", "1\n2\nthree\n4\n5\n6\n7\n8\n9\nten")
+	assertTreeFile(c, commit, "renamed", "a\nb\nc\nd\ne\nF\n")
+
+	_, err = commit.File("bar")
+	c.Assert(err, NotNil)
+}
+
+func (s *WorktreeSuite) TestApplyReverse(c *C) {
+	w := newApplyRepository(c)
+	patch := decodePatch(c, applyPatch)
+
+	c.Assert(w.Apply(patch, &ApplyOptions{}), IsNil)
+	c.Assert(w.Apply(patch, &ApplyOptions{Reverse: true}), IsNil)
+
+	for name, content := range applyFiles {
+		assertFileContent(c, w, name, content)
+	}
+
+	assertNoFile(c, w, "new")
+	assertNoFile(c, w, "renamed")
+
+	fi, err := w.Filesystem.Lstat("q ux")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0644))
+}
+
+func (s *WorktreeSuite) TestApplyCheck(c *C) {
+	w := newApplyRepository(c)
+
+	err := w.Apply(decodePatch(c, applyPatch), &ApplyOptions{Check: true})
+	c.Assert(err, IsNil)
+
+	for name, content := range applyFiles {
+		assertFileContent(c, w, name, content)
+	}
+
+	assertNoFile(c, w, "new")
+}
+
+func (s *WorktreeSuite) TestApplyAtomic(c *C) {
+	w := newApplyRepository(c)
+
+	patch := "diff --git a/foo b/foo\n--- a/foo\n+++ b/foo\n@@ -1,2 +1,2 @@\n-1\n+one\n 2\n" +
+		"diff --git a/bar b/bar\n--- a/bar\n+++ b/bar\n@@ -1 +1 @@\n-baz\n+qux\n"
+
+	err := w.Apply(decodePatch(c, patch), &ApplyOptions{})
+	c.Assert(err, Equals, ErrPatchDoesNotApply)
+	assertFileContent(c, w, "foo", applyFiles["foo"])
+
+	err = w.Apply(decodePatch(c, applyPatch), &ApplyOptions{})
+	c.Assert(err, IsNil)
+
+	err = w.Apply(decodePatch(c, applyPatch), &ApplyOptions{})
+	c.Assert(err, Equals, ErrApplyFileNotFound)
+}
+
+func (s *WorktreeSuite) TestApplyInvalidPath(c *C) {
+	w := newApplyRepository(c)
+
+	for _, name := range []string{
+		".git/hooks/post-checkout", ".GIT./config", "dir/.git/config",
+		"../x", "dir/../../x", "/abs", "dir//x", "./x",
+	} {
+		patch := "diff --git a/" + name + " b/" + name + "\n" +
+			"new file mode 100755\n--- /dev/null\n+++ b/" + name + "\n" +
+			"@@ -0,0 +1 @@\n+x\n"
+
+		p := decodePatch(c, patch)
+		_, to := p.FilePatches()[0].Files()
+		c.Assert(to.Path(), Equals, name)
+
+		err := w.Apply(p, &ApplyOptions{})
+		c.Assert(err, Equals, ErrApplyInvalidPath, Commentf("path %s", name))
+
+		err = w.Apply(p, &ApplyOptions{Reverse: true})
+		c.Assert(err, Equals, ErrApplyInvalidPath, Commentf("path %s", name))
+	}
+
+	assertNoFile(c, w, ".git")
+	assertNoFile(c, w, "dir")
+
+	patch := "diff --git a/foo b/.git/foo\nsimilarity index 100%\n" +
+		"rename from foo\nrename to .git/foo\n"
+
+	err := w.Apply(decodePatch(c, patch), &ApplyOptions{})
+	c.Assert(err, Equals, ErrApplyInvalidPath)
+	assertFileContent(c, w, "foo", applyFiles["foo"])
+}
+
+func (s *WorktreeSuite) TestApplyOffsetAndFuzz(c *C) {
+	w := newApplyRepository(c)
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("0\n1\n2\n3\n4\n5\n6\n7\nEIGHT\n9\n10\n11\n"), 0644)
+	c.Assert(err, IsNil)
+
+	patch := "diff --git a/foo b/foo\n--- a/foo\n+++ b/foo\n" +
+		"@@ -1,4 +1,4 @@\n 1\n 2\n-3\n+three\n 4\n" +
+		"@@ -6,4 +6,4 @@\n 6\n-7\n+seven\n 8\n 9\n"
+
+	err = w.Apply(decodePatch(c, patch), &ApplyOptions{})
+	c.Assert(err, Equals, ErrPatchDoesNotApply)
+
+	err = w.Apply(decodePatch(c, patch), &ApplyOptions{Fuzz: 2})
+	c.Assert(err, IsNil)
+	assertFileContent(c, w, "foo", "0\n1\n2\nthree\n4\n5\n6\nseven\nEIGHT\n9\n10\n11\n")
+}
+
+func (s *WorktreeSuite) TestApplyIgnoreWhitespace(c *C) {
+	w := newApplyRepository(c)
+
+	err := util.WriteFile(w.Filesystem, "old", []byte("a\nb\nc  \nd\n\te\nf\n"), 0644)
+	c.Assert(err, IsNil)
+
+	patch := "diff --git a/old b/old\n--- a/old\n+++ b/old\n@@ -3,4 +3,4 @@\n c\n d\n e\n-f\n+F\n"
+	err = w.Apply(decodePatch(c, patch), &ApplyOptions{})
+	c.Assert(err, Equals, ErrPatchDoesNotApply)
+
+	err = w.Apply(decodePatch(c, patch), &ApplyOptions{IgnoreWhitespace: true})
+	c.Assert(err, IsNil)
+	assertFileContent(c, w, "old", "a\nb\nc  \nd\n\te\nF\n")
+}
+
+func (s *WorktreeSuite) TestApplyCommitPatch(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n", "bar": "bar\n"},
+		nil,
+		map[string]string{"foo": "foo\nbar\n", "bar": "", "baz": "baz\n"},
+	)
+
+	from, err := w.r.CommitObject(master)
+	c.Assert(err, IsNil)
+	to, err := w.r.CommitObject(feature)
+	c.Assert(err, IsNil)
+
+	patch, err := from.Patch(to)
+	c.Assert(err, IsNil)
+
+	err = w.Apply(patch, &ApplyOptions{Index: true})
+	c.Assert(err, IsNil)
+
+	assertFileContent(c, w, "foo", "foo\nbar\n")
+	assertFileContent(c, w, "baz", "baz\n")
+	assertNoFile(c, w, "bar")
+
+	hash, err := w.Commit("apply\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	assertTreeFile(c, commit, "foo", "foo\nbar\n")
+	assertTreeFile(c, commit, "baz", "baz\n")
+
+	_, err = commit.File("bar")
+	c.Assert(err, NotNil)
+}
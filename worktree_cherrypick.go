@@ -0,0 +1,216 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/merge"
+)
+
+const (
+	// cherryPickHeadRef is the reference pointing to the commit being picked
+	// while a cherry-pick is stopped due to conflicts.
+	cherryPickHeadRef plumbing.ReferenceName = "CHERRY_PICK_HEAD"
+	// revertHeadRef is the reference pointing to the commit being reverted
+	// while a revert is stopped due to conflicts.
+	revertHeadRef plumbing.ReferenceName = "REVERT_HEAD"
+)
+
+var (
+	// ErrMainlineRequired is returned when a merge commit is picked or
+	// reverted without a mainline parent.
+	ErrMainlineRequired = errors.New("commit is a merge but no mainline was given")
+	// ErrMainlineNotMerge is returned when a mainline parent is given for a
+	// commit that is not a merge.
+	ErrMainlineNotMerge = errors.New("mainline was specified but commit is not a merge")
+	// ErrInvalidMainline is returned when the mainline parent doesn't exist.
+	ErrInvalidMainline = errors.New("commit does not have the given mainline parent")
+	// ErrEmptyCommit is returned when the changes to be committed are already
+	// present in HEAD.
+	ErrEmptyCommit = errors.New("the resulting commit would be empty")
+)
+
+// CherryPick applies the changes introduced by the given commit, relative to
+// its parent, on top of HEAD and commits them with the message and author of
+// the original commit. Returns the hash of the new commit.
+//
+// If the changes can't be applied cleanly ErrMergeConflict is returned, the
+// conflicts are recorded in the index and CHERRY_PICK_HEAD points to the
+// picked commit until the conflicts are resolved and committed.
+func (w *Worktree) CherryPick(h plumbing.Hash, opts *CherryPickOptions) (plumbing.Hash, error) {
+	if err := opts.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	c, err := w.r.CommitObject(h)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	parent, err := mainlineParent(c, opts.Mainline)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	msg := c.Message
+	if opts.RecordOrigin {
+		msg = fmt.Sprintf("%s\n(cherry picked from commit %s)\n",
+			strings.TrimRight(msg, "\n"), c.Hash)
+	}
+
+	ours, err := w.applyChanges(parent, c, commitLabel(c), cherryPickHeadRef, msg)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	author := c.Author
+	return w.Commit(msg, &CommitOptions{
+		Author:    &author,
+		Committer: opts.Committer,
+		Parents:   []plumbing.Hash{ours.Hash},
+	})
+}
+
+// Revert applies the inverse of the changes introduced by the given commit,
+// relative to its parent, on top of HEAD and commits them with a message
+// referencing the reverted commit. Returns the hash of the new commit.
+//
+// If the changes can't be applied cleanly ErrMergeConflict is returned, the
+// conflicts are recorded in the index and REVERT_HEAD points to the reverted
+// commit until the conflicts are resolved and committed.
+func (w *Worktree) Revert(h plumbing.Hash, opts *RevertOptions) (plumbing.Hash, error) {
+	if err := opts.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	c, err := w.r.CommitObject(h)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	parent, err := mainlineParent(c, opts.Mainline)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	msg := revertMessage(c, parent, opts.Mainline)
+	ours, err := w.applyChanges(c, parent, "parent of "+commitLabel(c), revertHeadRef, msg)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.Commit(msg, &CommitOptions{
+		Author:    opts.Author,
+		Committer: opts.Committer,
+		Parents:   []plumbing.Hash{ours.Hash},
+	})
+}
+
+// applyChanges merges into HEAD the changes between base and theirs, returning
+// the commit at HEAD. On conflicts the given reference, if any, is pointed to
+// the commit being applied, msg is stored at MERGE_MSG and ErrMergeConflict is
+// returned.
+func (w *Worktree) applyChanges(base, theirs *object.Commit, label string,
+	stateRef plumbing.ReferenceName, msg string) (*object.Commit, error) {
+
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	ours, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.mergeCommits(base, ours, theirs, &merge.Options{
+		OursLabel:   plumbing.HEAD.String(),
+		TheirsLabel: label,
+	})
+
+	if err == ErrMergeConflict && stateRef != "" {
+		commit := theirs
+		if stateRef == revertHeadRef {
+			commit = base
+		}
+
+		ref := plumbing.NewHashReference(stateRef, commit.Hash)
+		if err := w.r.Storer.SetReference(ref); err != nil {
+			return nil, err
+		}
+
+		if err := w.writeMergeMsg(msg); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrMergeConflict
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	staged, err := w.hasStagedChanges(ours)
+	if err != nil {
+		return nil, err
+	}
+
+	if !staged {
+		return nil, ErrEmptyCommit
+	}
+
+	return ours, nil
+}
+
+// hasStagedChanges reports whether the index differs from the tree of the
+// given commit, the untracked and unstaged files are ignored.
+func (w *Worktree) hasStagedChanges(c *object.Commit) (bool, error) {
+	changes, err := w.diffCommitWithStaging(c.Hash, false)
+	if err != nil {
+		return false, err
+	}
+
+	return len(changes) != 0, nil
+}
+
+// mainlineParent returns the parent of c used as base of its changes, nil if
+// c is a root commit.
+func mainlineParent(c *object.Commit, mainline int) (*object.Commit, error) {
+	switch {
+	case c.NumParents() > 1 && mainline == 0:
+		return nil, ErrMainlineRequired
+	case c.NumParents() <= 1 && mainline != 0:
+		return nil, ErrMainlineNotMerge
+	case mainline < 0 || mainline > c.NumParents():
+		return nil, ErrInvalidMainline
+	case c.NumParents() == 0:
+		return nil, nil
+	case mainline == 0:
+		mainline = 1
+	}
+
+	return c.Parent(mainline - 1)
+}
+
+func revertMessage(c, parent *object.Commit, mainline int) string {
+	subject := commitSubject(c)
+	if mainline == 0 {
+		return fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.\n", subject, c.Hash)
+	}
+
+	return fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s, reversing\nchanges made to %s.\n",
+		subject, c.Hash, parent.Hash)
+}
+
+// commitLabel returns the abbreviated hash and the subject of c, as used by
+// the conflict markers.
+func commitLabel(c *object.Commit) string {
+	return fmt.Sprintf("%s... %s", c.Hash.String()[:7], commitSubject(c))
+}
+
+func commitSubject(c *object.Commit) string {
+	return strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0]
+}
@@ -0,0 +1,280 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+func otherSignature() *object.Signature {
+	s := defaultSignature()
+	s.Name = "bar"
+	s.Email = "bar@bar.bar"
+	return s
+}
+
+func (s *WorktreeSuite) TestCherryPickInvalidOptions(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	_, err := w.CherryPick(feature, &CherryPickOptions{})
+	c.Assert(err, Equals, ErrMissingCommitter)
+
+	_, err = w.CherryPick(feature, &CherryPickOptions{
+		Mainline:  1,
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrMainlineNotMerge)
+}
+
+func (s *WorktreeSuite) TestCherryPick(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "a\nb\nc\nd\ne\n"},
+		map[string]string{"foo": "A\nb\nc\nd\ne\n"},
+		map[string]string{"foo": "a\nb\nc\nd\nE\n", "bar": "bar\n"},
+	)
+
+	hash, err := w.CherryPick(feature, &CherryPickOptions{
+		RecordOrigin: true,
+		Committer:    otherSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{master})
+	c.Assert(commit.Author.Name, Equals, "foo")
+	c.Assert(commit.Committer.Name, Equals, "bar")
+	c.Assert(commit.Message, Equals,
+		"files\n(cherry picked from commit "+feature.String()+")\n",
+	)
+
+	assertFileContent(c, w, "foo", "A\nb\nc\nd\nE\n")
+	assertFileContent(c, w, "bar", "bar\n")
+
+	_, err = w.CherryPick(feature, &CherryPickOptions{Committer: otherSignature()})
+	c.Assert(err, Equals, ErrEmptyCommit)
+}
+
+func (s *WorktreeSuite) TestCherryPickEmptyWithUntracked(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"bar": "bar\n"},
+	)
+
+	err := util.WriteFile(w.Filesystem, "untracked", []byte("untracked\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.CherryPick(feature, &CherryPickOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrEmptyCommit)
+}
+
+func (s *WorktreeSuite) TestCherryPickConflict(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "a\nb\nc\n"},
+		map[string]string{"foo": "a\nB\nc\n"},
+		map[string]string{"foo": "a\nX\nc\n"},
+	)
+
+	_, err := w.CherryPick(feature, &CherryPickOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	ref, err := w.r.Storer.Reference(cherryPickHeadRef)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, feature)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, UpdatedButUnmerged)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("a\nX\nc\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("picked\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{master})
+
+	_, err = w.r.Storer.Reference(cherryPickHeadRef)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestCherryPickConflictMessage(c *C) {
+	r, dotgit := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"foo": "bar\n"},
+		map[string]string{"foo": "baz\n"},
+	)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("qux\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	picked, err := w.Commit("picked\n", &CommitOptions{Author: otherSignature()})
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature"})
+	c.Assert(err, IsNil)
+
+	_, err = w.CherryPick(picked, &CherryPickOptions{
+		Committer:    defaultSignature(),
+		RecordOrigin: true,
+	})
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	f, err := dotgit.Open(mergeMsgFile)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(string(content), Equals, "picked\n(cherry picked from commit "+picked.String()+")\n")
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("baz\nqux\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit(string(content), &CommitOptions{})
+	c.Assert(err, Equals, ErrMissingCommitter)
+
+	// the author of the picked commit is kept
+	hash, err := w.Commit(string(content), &CommitOptions{Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Name, Equals, otherSignature().Name)
+	c.Assert(commit.Committer.Name, Equals, defaultSignature().Name)
+
+	_, err = dotgit.Stat(mergeMsgFile)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WorktreeSuite) TestCherryPickMergeCommit(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	merge, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature"})
+	c.Assert(err, IsNil)
+
+	_, err = w.CherryPick(merge, &CherryPickOptions{Committer: defaultSignature()})
+	c.Assert(err, Equals, ErrMainlineRequired)
+
+	_, err = w.CherryPick(merge, &CherryPickOptions{
+		Mainline:  3,
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrInvalidMainline)
+
+	_, err = w.CherryPick(merge, &CherryPickOptions{
+		Mainline:  2,
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	assertFileContent(c, w, "bar", "bar\n")
+	assertFileContent(c, w, "baz", "baz\n")
+}
+
+func (s *WorktreeSuite) TestRevert(c *C) {
+	w, master, _ := newMergeRepository(c,
+		map[string]string{"foo": "a\nb\nc\nd\ne\n"},
+		map[string]string{"foo": "A\nb\nc\nd\ne\n", "bar": "bar\n"},
+		nil,
+	)
+
+	commitFiles(c, w, map[string]string{"foo": "A\nb\nc\nd\nE\n"})
+
+	_, err := w.Revert(master, &RevertOptions{})
+	c.Assert(err, Equals, ErrMissingAuthor)
+
+	hash, err := w.Revert(master, &RevertOptions{Author: otherSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Name, Equals, "bar")
+	c.Assert(commit.Message, Equals,
+		"Revert \"files\"\n\nThis reverts commit "+master.String()+".\n",
+	)
+
+	assertFileContent(c, w, "foo", "a\nb\nc\nd\nE\n")
+
+	_, err = w.Filesystem.Lstat("bar")
+	c.Assert(err, NotNil)
+}
+
+func (s *WorktreeSuite) TestRevertConflict(c *C) {
+	w, master, _ := newMergeRepository(c,
+		map[string]string{"foo": "a\nb\nc\n"},
+		map[string]string{"foo": "a\nB\nc\n"},
+		nil,
+	)
+
+	commitFiles(c, w, map[string]string{"foo": "a\nX\nc\n"})
+
+	_, err := w.Revert(master, &RevertOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	ref, err := w.r.Storer.Reference(revertHeadRef)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, master)
+}
+
+func (s *WorktreeSuite) TestRevertMergeCommit(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	merge, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	hash, err := w.Revert(merge, &RevertOptions{
+		Mainline: 1,
+		Author:   defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Revert \"Merge commit '"+feature.String()+
+		"'\"\n\nThis reverts commit "+merge.String()+", reversing\n"+
+		"changes made to "+master.String()+".\n",
+	)
+
+	assertFileContent(c, w, "bar", "bar\n")
+
+	_, err = w.Filesystem.Lstat("baz")
+	c.Assert(err, NotNil)
+}
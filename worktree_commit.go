@@ -7,7 +7,9 @@ Sniperkit-Bot
 package git
 
 import (
+	"os"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
@@ -37,6 +39,12 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
+	for _, e := range idx.Entries {
+		if e.Stage != index.Merged {
+			return plumbing.ZeroHash, ErrUnmergedEntries
+		}
+	}
+
 	h := &buildTreeHelper{
 		fs: w.Filesystem,
 		s:  w.r.Storer,
@@ -52,7 +60,41 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
-	return commit, w.updateHEAD(commit)
+	if err := w.updateHEAD(commit, commitReflogMessage(msg, opts), opts.Committer); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return commit, w.removeMergeState()
+}
+
+// removeMergeState removes the MERGE_HEAD, CHERRY_PICK_HEAD and REVERT_HEAD
+// references and the MERGE_MSG file, if any, once the operation stopped by
+// conflicts is concluded.
+func (w *Worktree) removeMergeState() error {
+	if fs := w.r.dotGitFilesystem(); fs != nil {
+		if err := fs.Remove(mergeMsgFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for _, name := range []plumbing.ReferenceName{
+		mergeHeadRef, cherryPickHeadRef, revertHeadRef,
+	} {
+		_, err := w.r.Storer.Reference(name)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := w.r.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (w *Worktree) autoAddModifiedAndDeleted() error {
@@ -74,7 +116,7 @@ func (w *Worktree) autoAddModifiedAndDeleted() error {
 	return nil
 }
 
-func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
+func (w *Worktree) updateHEAD(commit plumbing.Hash, msg string, sig *object.Signature) error {
 	head, err := w.r.Storer.Reference(plumbing.HEAD)
 	if err != nil {
 		return err
@@ -86,7 +128,21 @@ func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
 	}
 
 	ref := plumbing.NewHashReference(name, commit)
-	return w.r.Storer.SetReference(ref)
+	return setReferenceWithLog(w.r.Storer, ref, nil, msg, sig)
+}
+
+// commitReflogMessage returns the reflog message of a new commit, with the
+// subject of its message.
+func commitReflogMessage(msg string, opts *CommitOptions) string {
+	kind := "commit"
+	if len(opts.Parents) == 0 {
+		kind = "commit (initial)"
+	} else if len(opts.Parents) > 1 {
+		kind = "commit (merge)"
+	}
+
+	subject := strings.SplitN(strings.TrimSpace(msg), "\n", 2)[0]
+	return kind + ": " + subject
 }
 
 func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumbing.Hash) (plumbing.Hash, error) {
@@ -98,6 +154,15 @@ func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumb
 		ParentHashes: opts.Parents,
 	}
 
+	if opts.Signer != nil {
+		sig, err := signObject(commit, opts.Signer)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		commit.PGPSignature = sig
+	}
+
 	obj := w.r.Storer.NewEncodedObject()
 	if err := commit.Encode(obj); err != nil {
 		return plumbing.ZeroHash, err
@@ -185,6 +250,7 @@ func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tr
 		t.Entries[i] = e
 	}
 
+	sort.Sort(sortableEntries(t.Entries))
 	o := h.s.NewEncodedObject()
 	if err := t.Encode(o); err != nil {
 		return plumbing.ZeroHash, err
@@ -192,3 +258,20 @@ func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tr
 
 	return h.s.SetEncodedObject(o)
 }
+
+// sortableEntries sorts the entries of a tree as git does, the directories
+// are sorted as if their names ended with a slash. The entries are built
+// from the index, that is only sorted when it is encoded.
+type sortableEntries []object.TreeEntry
+
+func (s sortableEntries) sortName(te object.TreeEntry) string {
+	if te.Mode == filemode.Dir {
+		return te.Name + "/"
+	}
+
+	return te.Name
+}
+
+func (s sortableEntries) Len() int           { return len(s) }
+func (s sortableEntries) Less(i, j int) bool { return s.sortName(s[i]) < s.sortName(s[j]) }
+func (s sortableEntries) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -7,15 +7,22 @@ Sniperkit-Bot
 package git
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"time"
 
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/allowedsigners"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
 	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
 
 	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
 	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/ssh"
 	. "gopkg.in/check.v1"
 )
 
@@ -55,6 +62,89 @@ func (s *WorktreeSuite) TestCommitInitial(c *C) {
 	assertStorageStatus(c, r, 1, 1, 1, expected)
 }
 
+func (s *WorktreeSuite) TestCommitSign(c *C) {
+	fs := memfs.New()
+	storage := memory.NewStorage()
+
+	r, err := Init(storage, fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	key, err := openpgp.NewEntity("foo", "", "foo@foo.foo", nil)
+	c.Assert(err, IsNil)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{
+		Author:  defaultSignature(),
+		SignKey: key,
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "foo\n")
+	c.Assert(commit.PGPSignature, Not(Equals), "")
+
+	var pub bytes.Buffer
+	aw, err := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	c.Assert(err, IsNil)
+	c.Assert(key.Serialize(aw), IsNil)
+	c.Assert(aw.Close(), IsNil)
+
+	signer, err := commit.Verify(pub.String())
+	c.Assert(err, IsNil)
+	c.Assert(signer.PrimaryKey.KeyId, Equals, key.PrimaryKey.KeyId)
+}
+
+func (s *WorktreeSuite) TestCommitSignSSH(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	key, err := ssh.NewSignerFromKey(pk)
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{
+		Author:  defaultSignature(),
+		SignKey: &openpgp.Entity{},
+		Signer:  object.NewSSHSigner(key),
+	})
+	c.Assert(err, Equals, ErrSignKeySignerExclusive)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{
+		Author: defaultSignature(),
+		Signer: object.NewSSHSigner(key),
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	v := object.NewSSHVerifier([]*allowedsigners.Entry{
+		{Principals: []string{"foo@foo.foo"}, Key: key.PublicKey()},
+	})
+
+	principal, err := commit.VerifyWith(v)
+	c.Assert(err, IsNil)
+	c.Assert(principal, Equals, "foo@foo.foo")
+}
+
 func (s *WorktreeSuite) TestCommitParent(c *C) {
 	expected := plumbing.NewHash("ef3ca05477530b37f48564be33ddd48063fc7a22")
 
@@ -105,6 +195,41 @@ func (s *WorktreeSuite) TestCommitAll(c *C) {
 	assertStorageStatus(c, s.Repository, 13, 11, 10, expected)
 }
 
+func (s *WorktreeSuite) TestCommitTreeEntriesOrder(c *C) {
+	// the directories are sorted as if their names ended with a slash, so a.b
+	// goes before a, as written by git
+	expected := plumbing.NewHash("75f4304c0b503db8655560feb2b5f0129f406e5d")
+
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	// added in this order the entries of the index aren't sorted
+	for _, name := range []string{"a/c", "a.b"} {
+		err := util.WriteFile(fs, name, []byte(name), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.TreeHash, Equals, expected)
+
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries, HasLen, 2)
+	c.Assert(tree.Entries[0].Name, Equals, "a.b")
+	c.Assert(tree.Entries[1].Name, Equals, "a")
+}
+
 func (s *WorktreeSuite) TestRemoveAndCommitAll(c *C) {
 	expected := plumbing.NewHash("907cd576c6ced2ecd3dab34a72bf9cf65944b9a9")
 
@@ -0,0 +1,462 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	stdioutil "io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+
+	format "github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/config"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/gitattributes"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/osfs"
+)
+
+var (
+	// ErrCRLFWouldBeReplaced is returned when adding a file whose CRLF line
+	// endings wouldn't be restored on checkout, with core.safecrlf enabled.
+	ErrCRLFWouldBeReplaced = errors.New("CRLF would be replaced by LF")
+	// ErrLFWouldBeReplaced is returned when adding a file whose LF line
+	// endings would be replaced by CRLF on checkout, with core.safecrlf
+	// enabled.
+	ErrLFWouldBeReplaced = errors.New("LF would be replaced by CRLF")
+)
+
+const (
+	textAttr = "text"
+	eolAttr  = "eol"
+	// crlfAttr is the legacy attribute of the text conversion.
+	crlfAttr = "crlf"
+)
+
+type autoCRLF int
+
+const (
+	autoCRLFFalse autoCRLF = iota
+	autoCRLFTrue
+	autoCRLFInput
+)
+
+// crlfAction is the conversion of the line endings of a file, as decided by
+// its attributes and the configuration.
+type crlfAction int
+
+const (
+	crlfUndefined crlfAction = iota
+	crlfBinary
+	crlfText
+	crlfTextInput
+	crlfTextCRLF
+	crlfAuto
+	crlfAutoInput
+	crlfAutoCRLF
+)
+
+func (a crlfAction) isAuto() bool {
+	return a == crlfAuto || a == crlfAutoInput || a == crlfAutoCRLF
+}
+
+// converter converts the contents of the files between the worktree and the
+// repository, applying their filter drivers and normalizing their line endings
+// as git does following core.autocrlf, core.eol and the text, eol and crlf
+// attributes. The converter must be closed to stop the filter processes.
+type converter struct {
+	w     *Worktree
+	idx   *index.Index
+	attrs gitattributes.Matcher
+
+	drivers   map[string]*filterDriver
+	processes map[string]*filterProcess
+	// dir is the directory where the filter commands run.
+	dir string
+
+	autoCRLF autoCRLF
+	safeCRLF bool
+	// eolCRLF is true when the line endings of the text files in the worktree
+	// are CRLF.
+	eolCRLF bool
+}
+
+// newConverter returns the converter of the contents of the worktree. The
+// attributes are read from the .gitattributes files of the index when
+// checkout is true, as git does when checking out files, otherwise the ones
+// of the worktree are preferred to the ones of the index.
+func (w *Worktree) newConverter(checkout bool) (*converter, error) {
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &converter{
+		w:         w,
+		idx:       idx,
+		drivers:   filterDrivers(cfg.Raw),
+		processes: make(map[string]*filterProcess),
+		dir:       w.Filesystem.Root(),
+	}
+
+	core := cfg.Raw.Section("core")
+	if autoCRLF := core.Option("autocrlf"); strings.ToLower(autoCRLF) == "input" {
+		c.autoCRLF = autoCRLFInput
+	} else if b, _ := format.ParseBool(autoCRLF); b {
+		c.autoCRLF = autoCRLFTrue
+	}
+
+	c.safeCRLF, _ = format.ParseBool(core.Option("safecrlf"))
+	switch c.autoCRLF {
+	case autoCRLFTrue:
+		c.eolCRLF = true
+	case autoCRLFFalse:
+		switch strings.ToLower(core.Option("eol")) {
+		case "crlf":
+			c.eolCRLF = true
+		case "", "native":
+			c.eolCRLF = runtime.GOOS == "windows"
+		}
+	}
+
+	var attrs []gitattributes.MatchAttribute
+	if name := core.Option("attributesFile"); name != "" {
+		attrs, err = gitattributes.LoadAttributesFile(osfs.New(""), name)
+	} else {
+		attrs, err = gitattributes.LoadGlobalPatterns(osfs.New(""))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := w.indexAttributes(idx, !checkout)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := w.r.infoAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	c.attrs = gitattributes.NewMatcher(append(append(attrs, tree...), info...))
+	return c, nil
+}
+
+// indexAttributes returns the attributes of the .gitattributes files of the
+// index, the top-level one first. If worktree is true the files are read from
+// the worktree when they exist there, along with the top-level one even if
+// it's not in the index, as git does when adding files.
+func (w *Worktree) indexAttributes(idx *index.Index, worktree bool) ([]gitattributes.MatchAttribute, error) {
+	files := make(map[string]*index.Entry)
+	if worktree {
+		files[attributesFile] = nil
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage == index.Merged && path.Base(e.Name) == attributesFile {
+			files[e.Name] = e
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		di, dj := strings.Count(names[i], "/"), strings.Count(names[j], "/")
+		if di != dj {
+			return di < dj
+		}
+
+		return names[i] < names[j]
+	})
+
+	var attrs []gitattributes.MatchAttribute
+	for _, name := range names {
+		content, err := w.attributesContent(name, files[name], worktree)
+		if err != nil {
+			return nil, err
+		}
+
+		if content == nil {
+			continue
+		}
+
+		var dir []string
+		if d := path.Dir(name); d != "." {
+			dir = strings.Split(d, "/")
+		}
+
+		file, err := gitattributes.ParseAttributes(bytes.NewReader(content), dir, len(dir) == 0)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs = append(attrs, file...)
+	}
+
+	return attrs, nil
+}
+
+// attributesContent returns the content of a .gitattributes file, read from
+// the worktree if worktree is true and it exists there, or from the given
+// entry of the index. nil is returned if it doesn't exist.
+func (w *Worktree) attributesContent(name string, e *index.Entry, worktree bool) ([]byte, error) {
+	if worktree {
+		content, err := w.readFile(name)
+		if err == nil {
+			return content, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if e == nil {
+		return nil, nil
+	}
+
+	return blobContent(w.r.Storer, e.Hash)
+}
+
+// readFile returns the content of the file of the worktree.
+func (w *Worktree) readFile(name string) (content []byte, err error) {
+	f, err := w.Filesystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return stdioutil.ReadAll(f)
+}
+
+// action returns the conversion of the line endings of the file.
+func (c *converter) action(name string) crlfAction {
+	attrs, _ := c.attrs.Match(strings.Split(name, "/"), false, []string{textAttr, crlfAttr, eolAttr})
+
+	action := attributeCRLFAction(attrs[textAttr])
+	if action == crlfUndefined {
+		action = attributeCRLFAction(attrs[crlfAttr])
+	}
+
+	if eol := attrs[eolAttr]; action != crlfBinary && eol.IsValueSet() {
+		switch {
+		case action == crlfAuto && eol.Value == "lf":
+			action = crlfAutoInput
+		case action == crlfAuto && eol.Value == "crlf":
+			action = crlfAutoCRLF
+		case eol.Value == "lf":
+			action = crlfTextInput
+		case eol.Value == "crlf":
+			action = crlfTextCRLF
+		}
+	}
+
+	switch {
+	case action == crlfText && c.eolCRLF:
+		return crlfTextCRLF
+	case action == crlfText:
+		return crlfTextInput
+	case action == crlfUndefined && c.autoCRLF == autoCRLFTrue:
+		return crlfAutoCRLF
+	case action == crlfUndefined && c.autoCRLF == autoCRLFInput:
+		return crlfAutoInput
+	case action == crlfUndefined:
+		return crlfBinary
+	}
+
+	return action
+}
+
+func attributeCRLFAction(a gitattributes.Attribute) crlfAction {
+	switch {
+	case a.IsSet():
+		return crlfText
+	case a.IsUnset():
+		return crlfBinary
+	case a.IsValueSet() && a.Value == "input":
+		return crlfTextInput
+	case a.IsValueSet() && a.Value == "auto":
+		return crlfAuto
+	}
+
+	return crlfUndefined
+}
+
+// converts reports whether the content of the file may be converted.
+func (c *converter) converts(name string) bool {
+	return c.action(name) != crlfBinary || c.hasFilter(name)
+}
+
+// clean converts the content of the file in the worktree to its content in
+// the repository, applying its clean filter and then replacing CRLF line
+// endings with LF. The files with
+// automatic conversion aren't converted if they have CRLF line endings in the
+// index, unless renormalize is true. If add is true and core.safecrlf is
+// enabled, the conversions that wouldn't be reverted on checkout fail.
+func (c *converter) clean(name string, content []byte, add, renormalize bool) ([]byte, error) {
+	content, err := c.filter(name, cleanFilter, content)
+	if err != nil {
+		return nil, err
+	}
+
+	action := c.action(name)
+	if action == crlfBinary || len(content) == 0 {
+		return content, nil
+	}
+
+	stats := gatherTextStats(content)
+	convert := stats.crlf > 0
+	if action.isAuto() {
+		if stats.isBinary() {
+			return content, nil
+		}
+
+		if !renormalize && c.hasCRLFInIndex(name) {
+			convert = false
+		}
+	}
+
+	if add && c.safeCRLF {
+		checkout := stats
+		if convert {
+			checkout.lonelf += checkout.crlf
+			checkout.crlf = 0
+		}
+
+		if c.convertsLFToCRLF(checkout, action) {
+			checkout.crlf += checkout.lonelf
+			checkout.lonelf = 0
+		}
+
+		switch {
+		case stats.crlf > 0 && checkout.crlf == 0:
+			return nil, ErrCRLFWouldBeReplaced
+		case stats.lonelf > 0 && checkout.lonelf == 0:
+			return nil, ErrLFWouldBeReplaced
+		}
+	}
+
+	if !convert {
+		return content, nil
+	}
+
+	return bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1), nil
+}
+
+// smudge converts the content of the file in the repository to its content in
+// the worktree, replacing LF line endings with CRLF if required and then
+// applying its smudge filter.
+func (c *converter) smudge(name string, content []byte) ([]byte, error) {
+	action := c.action(name)
+	if action == crlfBinary || !c.convertsLFToCRLF(gatherTextStats(content), action) {
+		return c.filter(name, smudgeFilter, content)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(content)+len(content)/16))
+	for i, b := range content {
+		if b == '\n' && (i == 0 || content[i-1] != '\r') {
+			buf.WriteByte('\r')
+		}
+
+		buf.WriteByte(b)
+	}
+
+	return c.filter(name, smudgeFilter, buf.Bytes())
+}
+
+func (c *converter) convertsLFToCRLF(stats textStats, action crlfAction) bool {
+	if !c.outputsCRLF(action) || stats.lonelf == 0 {
+		return false
+	}
+
+	if action.isAuto() && (stats.lonecr > 0 || stats.crlf > 0 || stats.isBinary()) {
+		return false
+	}
+
+	return true
+}
+
+func (c *converter) outputsCRLF(action crlfAction) bool {
+	switch action {
+	case crlfTextCRLF, crlfAutoCRLF:
+		return true
+	case crlfText, crlfAuto:
+		return c.eolCRLF
+	}
+
+	return false
+}
+
+// hasCRLFInIndex reports whether the file in the index is a text file with
+// CRLF line endings.
+func (c *converter) hasCRLFInIndex(name string) bool {
+	e, err := c.idx.Entry(name)
+	if err != nil {
+		return false
+	}
+
+	content, err := blobContent(c.w.r.Storer, e.Hash)
+	if err != nil || bytes.IndexByte(content, '\r') < 0 {
+		return false
+	}
+
+	stats := gatherTextStats(content)
+	return stats.crlf > 0 && !stats.isBinary()
+}
+
+// textStats are the statistics of a content used to detect its line endings
+// and whether it is binary.
+type textStats struct {
+	nul, lonecr, lonelf, crlf int
+	printable, nonprintable   int
+}
+
+func gatherTextStats(content []byte) textStats {
+	var s textStats
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\r' && i+1 < len(content) && content[i+1] == '\n':
+			s.crlf++
+			i++
+		case c == '\r':
+			s.lonecr++
+		case c == '\n':
+			s.lonelf++
+		case c == 127:
+			s.nonprintable++
+		case c == '\b', c == '\t', c == '\033', c == '\014':
+			s.printable++
+		case c == 0:
+			s.nul++
+			s.nonprintable++
+		case c < 32:
+			s.nonprintable++
+		default:
+			s.printable++
+		}
+	}
+
+	// a trailing EOF character of DOS files isn't a binary character
+	if len(content) > 0 && content[len(content)-1] == '\032' {
+		s.nonprintable--
+	}
+
+	return s
+}
+
+func (s textStats) isBinary() bool {
+	return s.lonecr > 0 || s.nul > 0 || s.printable>>7 < s.nonprintable
+}
@@ -0,0 +1,238 @@
+package git
+
+import (
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+func newConvertRepository(c *C, core map[string]string) *Worktree {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Storer.Config()
+	c.Assert(err, IsNil)
+	for key, value := range core {
+		cfg.Raw.Section("core").SetOption(key, value)
+	}
+
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	return w
+}
+
+func assertIndexContent(c *C, w *Worktree, name, expected string) {
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry(name)
+	c.Assert(err, IsNil)
+
+	content, err := blobContent(w.r.Storer, e.Hash)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, expected)
+}
+
+func assertCleanWorktree(c *C, w *Worktree) {
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true, Commentf("%s", status))
+}
+
+func (s *WorktreeSuite) TestAddAutoCRLF(c *C) {
+	w := newConvertRepository(c, map[string]string{"autocrlf": "true"})
+	stageFile(c, w, "foo", "foo\r\nbar\r\n")
+	stageFile(c, w, "bin", "foo\r\n\x00")
+
+	assertIndexContent(c, w, "foo", "foo\nbar\n")
+	assertIndexContent(c, w, "bin", "foo\r\n\x00")
+
+	commit, err := w.Commit("files\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	assertCleanWorktree(c, w)
+
+	c.Assert(w.Filesystem.Remove("foo"), IsNil)
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: commit}), IsNil)
+	assertFileContent(c, w, "foo", "foo\r\nbar\r\n")
+	assertFileContent(c, w, "bin", "foo\r\n\x00")
+	assertCleanWorktree(c, w)
+}
+
+func (s *WorktreeSuite) TestAddAutoCRLFInput(c *C) {
+	w := newConvertRepository(c, map[string]string{"autocrlf": "input"})
+	stageFile(c, w, "foo", "foo\r\nbar\r\n")
+	assertIndexContent(c, w, "foo", "foo\nbar\n")
+
+	commit, err := w.Commit("files\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	assertCleanWorktree(c, w)
+
+	c.Assert(w.Filesystem.Remove("foo"), IsNil)
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: commit}), IsNil)
+	assertFileContent(c, w, "foo", "foo\nbar\n")
+}
+
+func (s *WorktreeSuite) TestAddTextAutoEOLCRLF(c *C) {
+	w := newConvertRepository(c, nil)
+	commitFiles(c, w, map[string]string{
+		".gitattributes": "* text=auto eol=crlf\n*.txt text eol=lf\n",
+	})
+
+	stageFile(c, w, "foo", "foo\r\nbar\r\n")
+	stageFile(c, w, "mixed", "foo\r\nbar\n")
+	stageFile(c, w, "bar.txt", "foo\r\n")
+	assertIndexContent(c, w, "foo", "foo\nbar\n")
+	assertIndexContent(c, w, "mixed", "foo\nbar\n")
+	assertIndexContent(c, w, "bar.txt", "foo\n")
+
+	commit, err := w.Commit("files\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo", "mixed", "bar.txt"} {
+		c.Assert(w.Filesystem.Remove(name), IsNil)
+	}
+
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: commit}), IsNil)
+	assertFileContent(c, w, "foo", "foo\r\nbar\r\n")
+	assertFileContent(c, w, "mixed", "foo\r\nbar\r\n")
+	assertFileContent(c, w, "bar.txt", "foo\n")
+	assertCleanWorktree(c, w)
+}
+
+func (s *WorktreeSuite) TestAddCoreEOL(c *C) {
+	w := newConvertRepository(c, map[string]string{"eol": "crlf"})
+	commitFiles(c, w, map[string]string{".gitattributes": "*.txt text\n"})
+
+	stageFile(c, w, "foo.txt", "foo\r\n")
+	stageFile(c, w, "bar", "bar\r\n")
+	assertIndexContent(c, w, "foo.txt", "foo\n")
+	assertIndexContent(c, w, "bar", "bar\r\n")
+
+	commit, err := w.Commit("files\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	c.Assert(w.Filesystem.Remove("foo.txt"), IsNil)
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: commit}), IsNil)
+	assertFileContent(c, w, "foo.txt", "foo\r\n")
+	assertCleanWorktree(c, w)
+}
+
+func (s *WorktreeSuite) TestAddSafeCRLF(c *C) {
+	w := newConvertRepository(c, map[string]string{
+		"autocrlf": "input",
+		"safecrlf": "true",
+	})
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("foo\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, Equals, ErrCRLFWouldBeReplaced)
+
+	w = newConvertRepository(c, map[string]string{"safecrlf": "true"})
+	commitFiles(c, w, map[string]string{".gitattributes": "foo text eol=crlf\nbar text eol=crlf\n"})
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, Equals, ErrLFWouldBeReplaced)
+
+	stageFile(c, w, "bar", "bar\r\n")
+	assertIndexContent(c, w, "bar", "bar\n")
+}
+
+func (s *WorktreeSuite) TestAddCRLFBooleans(c *C) {
+	for _, v := range []string{"yes", "On", "1"} {
+		w := newConvertRepository(c, map[string]string{"autocrlf": v})
+		stageFile(c, w, "foo", "foo\r\n")
+		assertIndexContent(c, w, "foo", "foo\n")
+
+		w = newConvertRepository(c, map[string]string{
+			"autocrlf": "input",
+			"safecrlf": v,
+		})
+
+		err := util.WriteFile(w.Filesystem, "foo", []byte("foo\r\n"), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add("foo")
+		c.Assert(err, Equals, ErrCRLFWouldBeReplaced)
+	}
+}
+
+func (s *WorktreeSuite) TestAddAttributesFiles(c *C) {
+	w := newConvertRepository(c, nil)
+	commitFiles(c, w, map[string]string{"dir/.gitattributes": "*.txt text\n"})
+
+	stageFile(c, w, "dir/foo.txt", "foo\r\n")
+	assertIndexContent(c, w, "dir/foo.txt", "foo\n")
+
+	// the top-level file is read from the worktree before it's added
+	err := util.WriteFile(w.Filesystem, ".gitattributes", []byte("*.md text\n"), 0644)
+	c.Assert(err, IsNil)
+
+	stageFile(c, w, "bar.md", "bar\r\n")
+	assertIndexContent(c, w, "bar.md", "bar\n")
+
+	// the files of the worktree are preferred to the ones of the index
+	err = util.WriteFile(w.Filesystem, "dir/.gitattributes", []byte("*.txt -text\n"), 0644)
+	c.Assert(err, IsNil)
+
+	stageFile(c, w, "dir/baz.txt", "baz\r\n")
+	assertIndexContent(c, w, "dir/baz.txt", "baz\r\n")
+}
+
+func (s *WorktreeSuite) TestAddRenormalize(c *C) {
+	w := newConvertRepository(c, nil)
+	commitFiles(c, w, map[string]string{
+		"foo":     "foo\r\nbar\r\n",
+		"dir/bar": "bar\r\n",
+	})
+
+	commitFiles(c, w, map[string]string{".gitattributes": "* text=auto\n"})
+	assertCleanWorktree(c, w)
+
+	stageFile(c, w, "foo", "foo\r\nbar\r\nbaz\r\n")
+	assertIndexContent(c, w, "foo", "foo\r\nbar\r\nbaz\r\n")
+
+	err := w.AddWithOptions(&AddOptions{Path: "dir", Renormalize: true})
+	c.Assert(err, IsNil)
+	assertIndexContent(c, w, "foo", "foo\r\nbar\r\nbaz\r\n")
+	assertIndexContent(c, w, "dir/bar", "bar\n")
+
+	err = w.AddWithOptions(&AddOptions{Renormalize: true})
+	c.Assert(err, IsNil)
+	assertIndexContent(c, w, "foo", "foo\nbar\nbaz\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Modified)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+	c.Assert(status.File("dir/bar").Worktree, Equals, Unmodified)
+}
+
+func (s *WorktreeSuite) TestStatusConverted(c *C) {
+	w := newConvertRepository(c, map[string]string{"autocrlf": "true"})
+	commitFiles(c, w, map[string]string{"foo": "foo\n"})
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("foo\r\n"), 0644)
+	c.Assert(err, IsNil)
+	assertCleanWorktree(c, w)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo\r\nbar\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+
+	h, err := w.Add("foo")
+	c.Assert(err, IsNil)
+	c.Assert(h, Equals, plumbing.ComputeHash(plumbing.BlobObject, []byte("foo\nbar\n")))
+}
@@ -0,0 +1,445 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/config"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/pktline"
+)
+
+var (
+	// ErrFilterProtocol is returned when a filter process doesn't follow the
+	// long-running filter protocol.
+	ErrFilterProtocol = errors.New("invalid filter process protocol")
+
+	errFilterNotApplied = errors.New("filter not applied")
+	errFilterStatus     = errors.New("filter process failed")
+)
+
+const (
+	filterAttr    = "filter"
+	filterSection = "filter"
+	cleanFilter   = "clean"
+	smudgeFilter  = "smudge"
+)
+
+// Filter defines a filter driver, transforming the content of the files
+// between the worktree and the repository. The filter driver of a file is set
+// by its filter attribute.
+type Filter interface {
+	// Clean converts the content of the file in the worktree to its content
+	// in the repository, when the file is added.
+	Clean(path string, content []byte) ([]byte, error)
+	// Smudge converts the content of the file in the repository to its
+	// content in the worktree, when the file is checked out.
+	Smudge(path string, content []byte) ([]byte, error)
+}
+
+// Filters are the Go-native filter drivers by name, used instead of the
+// filter.<driver> commands of the configuration.
+var Filters = map[string]Filter{}
+
+// InstallFilter adds or modifies an existing Go-native filter driver, a nil
+// filter removes it.
+func InstallFilter(driver string, f Filter) {
+	if f == nil {
+		delete(Filters, driver)
+		return
+	}
+
+	Filters[driver] = f
+}
+
+// filterDriver is a filter driver of the configuration, with its commands.
+type filterDriver struct {
+	name     string
+	clean    string
+	smudge   string
+	process  string
+	required bool
+}
+
+func filterDrivers(raw *config.Config) map[string]*filterDriver {
+	drivers := make(map[string]*filterDriver)
+	for _, s := range raw.Sections {
+		if !s.IsName(filterSection) {
+			continue
+		}
+
+		for _, ss := range s.Subsections {
+			d := &filterDriver{
+				name:     ss.Name,
+				clean:    ss.Option(cleanFilter),
+				smudge:   ss.Option(smudgeFilter),
+				process:  ss.Option("process"),
+				required: strings.ToLower(ss.Option("required")) == "true",
+			}
+
+			if d.clean != "" || d.smudge != "" || d.process != "" {
+				drivers[d.name] = d
+			}
+		}
+	}
+
+	return drivers
+}
+
+// hasFilter reports whether the file has a Go-native or configured filter
+// driver.
+func (c *converter) hasFilter(name string) bool {
+	return c.filterDriver(name) != ""
+}
+
+func (c *converter) filterDriver(name string) string {
+	attrs, _ := c.attrs.Match(strings.Split(name, "/"), false, []string{filterAttr})
+	a := attrs[filterAttr]
+	if !a.IsValueSet() {
+		return ""
+	}
+
+	if _, ok := Filters[a.Value]; ok {
+		return a.Value
+	}
+
+	if _, ok := c.drivers[a.Value]; ok {
+		return a.Value
+	}
+
+	return ""
+}
+
+// filter applies the clean or smudge filter of the file. If a configured
+// filter fails the content is kept as is, unless the filter is required.
+func (c *converter) filter(name, op string, content []byte) ([]byte, error) {
+	driver := c.filterDriver(name)
+	if driver == "" {
+		return content, nil
+	}
+
+	if f, ok := Filters[driver]; ok {
+		if op == cleanFilter {
+			return f.Clean(name, content)
+		}
+
+		return f.Smudge(name, content)
+	}
+
+	d := c.drivers[driver]
+	out, err := c.runFilterDriver(d, name, op, content)
+	if err == nil {
+		return out, nil
+	}
+
+	if d.required {
+		return nil, fmt.Errorf("%s: %s filter %s failed: %s", name, op, d.name, err)
+	}
+
+	return content, nil
+}
+
+func (c *converter) runFilterDriver(d *filterDriver, name, op string, content []byte) ([]byte, error) {
+	if d.process != "" {
+		p, err := c.filterProcess(d)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := p.filter(op, name, content)
+		if err != nil && err != errFilterNotApplied && err != errFilterStatus {
+			delete(c.processes, d.name)
+			_ = p.Close()
+		}
+
+		return out, err
+	}
+
+	command := d.clean
+	if op == smudgeFilter {
+		command = d.smudge
+	}
+
+	if command == "" {
+		return nil, errFilterNotApplied
+	}
+
+	return runFilterCommand(command, c.dir, name, content)
+}
+
+// filterProcess returns the running process of the driver, starting it if
+// needed.
+func (c *converter) filterProcess(d *filterDriver) (*filterProcess, error) {
+	if p, ok := c.processes[d.name]; ok {
+		return p, nil
+	}
+
+	p, err := startFilterProcess(d.process, c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.processes[d.name] = p
+	return p, nil
+}
+
+// Close stops the filter processes started by the converter.
+func (c *converter) Close() error {
+	var firstErr error
+	for name, p := range c.processes {
+		if err := p.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		delete(c.processes, name)
+	}
+
+	return firstErr
+}
+
+// runFilterCommand runs the command of a filter with the content as its input,
+// %f is replaced with the quoted path of the file.
+func runFilterCommand(command, dir, name string, content []byte) ([]byte, error) {
+	command = strings.Replace(command, "%f", shellQuote(name), -1)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", err, msg)
+		}
+
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// filterProcess is a long-running filter process, talking the version 2 of
+// the filter protocol of git over pkt-lines.
+type filterProcess struct {
+	cmd          *exec.Cmd
+	stdin        io.WriteCloser
+	encoder      *pktline.Encoder
+	scanner      *pktline.Scanner
+	capabilities map[string]bool
+}
+
+func startFilterProcess(command, dir string) (*filterProcess, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &filterProcess{
+		cmd:          cmd,
+		stdin:        stdin,
+		encoder:      pktline.NewEncoder(stdin),
+		scanner:      pktline.NewScanner(stdout),
+		capabilities: make(map[string]bool),
+	}
+
+	if err := p.handshake(); err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// handshake negotiates the version of the protocol and the capabilities of
+// the process.
+func (p *filterProcess) handshake() error {
+	if err := p.writeList("git-filter-client", "version=2"); err != nil {
+		return err
+	}
+
+	welcome, err := p.readList()
+	if err != nil {
+		return err
+	}
+
+	if len(welcome) != 2 || welcome[0] != "git-filter-server" || welcome[1] != "version=2" {
+		return ErrFilterProtocol
+	}
+
+	if err := p.writeList("capability="+cleanFilter, "capability="+smudgeFilter); err != nil {
+		return err
+	}
+
+	capabilities, err := p.readList()
+	if err != nil {
+		return err
+	}
+
+	for _, line := range capabilities {
+		if strings.HasPrefix(line, "capability=") {
+			p.capabilities[strings.TrimPrefix(line, "capability=")] = true
+		}
+	}
+
+	return nil
+}
+
+// filter sends the content of the file to the process and returns the
+// filtered content.
+func (p *filterProcess) filter(op, name string, content []byte) ([]byte, error) {
+	if !p.capabilities[op] {
+		return nil, errFilterNotApplied
+	}
+
+	if err := p.writeList("command="+op, "pathname="+name); err != nil {
+		return nil, err
+	}
+
+	if err := p.writeContent(content); err != nil {
+		return nil, err
+	}
+
+	status, err := p.readStatus("")
+	if err != nil {
+		return nil, err
+	}
+
+	if status != "success" {
+		return nil, p.failed(op, status)
+	}
+
+	out, err := p.readContent()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err = p.readStatus(status)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != "success" {
+		return nil, p.failed(op, status)
+	}
+
+	return out, nil
+}
+
+// failed handles an unsuccessful status, the process doesn't get more
+// requests of the command if it aborted.
+func (p *filterProcess) failed(op, status string) error {
+	if status == "abort" {
+		p.capabilities[op] = false
+	}
+
+	return errFilterStatus
+}
+
+func (p *filterProcess) writeList(lines ...string) error {
+	for _, line := range lines {
+		if err := p.encoder.EncodeString(line + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return p.encoder.Flush()
+}
+
+func (p *filterProcess) writeContent(content []byte) error {
+	for len(content) > 0 {
+		n := len(content)
+		if n > pktline.MaxPayloadSize {
+			n = pktline.MaxPayloadSize
+		}
+
+		if err := p.encoder.Encode(content[:n]); err != nil {
+			return err
+		}
+
+		content = content[n:]
+	}
+
+	return p.encoder.Flush()
+}
+
+// readList reads the lines until a flush-pkt.
+func (p *filterProcess) readList() ([]string, error) {
+	var lines []string
+	for p.scanner.Scan() {
+		line := p.scanner.Bytes()
+		if len(line) == 0 {
+			return lines, nil
+		}
+
+		lines = append(lines, strings.TrimSuffix(string(line), "\n"))
+	}
+
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.ErrUnexpectedEOF
+}
+
+// readStatus reads a list of status lines, an empty list keeps the current
+// status.
+func (p *filterProcess) readStatus(status string) (string, error) {
+	lines, err := p.readList()
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "status=") {
+			status = strings.TrimPrefix(line, "status=")
+		}
+	}
+
+	return status, nil
+}
+
+// readContent reads the content packets until a flush-pkt.
+func (p *filterProcess) readContent() ([]byte, error) {
+	var content []byte
+	for p.scanner.Scan() {
+		data := p.scanner.Bytes()
+		if len(data) == 0 {
+			return content, nil
+		}
+
+		content = append(content, data...)
+	}
+
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.ErrUnexpectedEOF
+}
+
+// Close closes the input of the process, asking it to exit, and waits for it.
+func (p *filterProcess) Close() error {
+	err := p.stdin.Close()
+	if werr := p.cmd.Wait(); err == nil {
+		err = werr
+	}
+
+	return err
+}
@@ -0,0 +1,244 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/pktline"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+type rot13Filter struct{}
+
+func (rot13Filter) Clean(path string, content []byte) ([]byte, error) {
+	return rot13(content), nil
+}
+
+func (rot13Filter) Smudge(path string, content []byte) ([]byte, error) {
+	return rot13(content), nil
+}
+
+func rot13(content []byte) []byte {
+	out := make([]byte, len(content))
+	for i, b := range content {
+		switch {
+		case b >= 'a' && b <= 'z':
+			b = 'a' + (b-'a'+13)%26
+		case b >= 'A' && b <= 'Z':
+			b = 'A' + (b-'A'+13)%26
+		}
+
+		out[i] = b
+	}
+
+	return out
+}
+
+func newFilterRepository(c *C, attributes string, driver map[string]string) *Worktree {
+	w := newConvertRepository(c, nil)
+
+	cfg, err := w.r.Storer.Config()
+	c.Assert(err, IsNil)
+	for key, value := range driver {
+		cfg.Raw.Section("filter").Subsection("test").SetOption(key, value)
+	}
+
+	c.Assert(w.r.Storer.SetConfig(cfg), IsNil)
+
+	commitFiles(c, w, map[string]string{".gitattributes": attributes})
+	return w
+}
+
+// assertFilterCheckout removes the files from the worktree and checks them out
+// again from HEAD.
+func assertFilterCheckout(c *C, w *Worktree, files map[string]string) {
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+
+	for name := range files {
+		c.Assert(w.Filesystem.Remove(name), IsNil)
+	}
+
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: head.Hash()}), IsNil)
+	for name, content := range files {
+		assertFileContent(c, w, name, content)
+	}
+
+	assertCleanWorktree(c, w)
+}
+
+func (s *WorktreeSuite) TestFilterNative(c *C) {
+	InstallFilter("test", rot13Filter{})
+	defer InstallFilter("test", nil)
+
+	w := newFilterRepository(c, "*.txt filter=test\n", nil)
+	commitFiles(c, w, map[string]string{
+		"foo.txt": "foo\n",
+		"bar":     "bar\n",
+	})
+
+	assertIndexContent(c, w, "foo.txt", "sbb\n")
+	assertIndexContent(c, w, "bar", "bar\n")
+	assertCleanWorktree(c, w)
+	assertFilterCheckout(c, w, map[string]string{"foo.txt": "foo\n"})
+}
+
+func (s *WorktreeSuite) TestFilterCommand(c *C) {
+	w := newFilterRepository(c, "*.txt filter=test\n*.path filter=test\n", map[string]string{
+		"clean":  "tr a-z A-Z",
+		"smudge": "tr A-Z a-z",
+	})
+
+	commitFiles(c, w, map[string]string{"foo.txt": "foo\n"})
+	assertIndexContent(c, w, "foo.txt", "FOO\n")
+	assertCleanWorktree(c, w)
+	assertFilterCheckout(c, w, map[string]string{"foo.txt": "foo\n"})
+
+	w = newFilterRepository(c, "*.txt filter=test\n", map[string]string{
+		"clean": "echo %f",
+	})
+
+	stageFile(c, w, "it's.txt", "foo\n")
+	assertIndexContent(c, w, "it's.txt", "it's.txt\n")
+}
+
+func (s *WorktreeSuite) TestFilterCommandFailed(c *C) {
+	w := newFilterRepository(c, "*.txt filter=test\n", map[string]string{
+		"clean": "false",
+	})
+
+	stageFile(c, w, "foo.txt", "foo\n")
+	assertIndexContent(c, w, "foo.txt", "foo\n")
+
+	w = newFilterRepository(c, "*.txt filter=test\n", map[string]string{
+		"clean":    "echo failure >&2; false",
+		"required": "true",
+	})
+
+	err := util.WriteFile(w.Filesystem, "foo.txt", []byte("foo\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo.txt")
+	c.Assert(err, ErrorMatches, "foo.txt: clean filter test failed: .*failure")
+
+	w = newFilterRepository(c, "*.txt filter=test\n", map[string]string{
+		"smudge":   "cat",
+		"required": "true",
+	})
+
+	_, err = w.Add("foo.txt")
+	c.Assert(err, NotNil)
+}
+
+func (s *WorktreeSuite) TestFilterEOL(c *C) {
+	InstallFilter("test", rot13Filter{})
+	defer InstallFilter("test", nil)
+
+	w := newFilterRepository(c, "*.txt filter=test text eol=crlf\n", nil)
+	commitFiles(c, w, map[string]string{"foo.txt": "foo\r\nbar\r\n"})
+
+	assertIndexContent(c, w, "foo.txt", "sbb\none\n")
+	assertFilterCheckout(c, w, map[string]string{"foo.txt": "foo\r\nbar\r\n"})
+}
+
+func (s *WorktreeSuite) TestFilterProcess(c *C) {
+	command := "GO_FILTER_PROCESS_HELPER=1 " + shellQuote(os.Args[0]) +
+		" -test.run=^TestFilterProcessHelper$"
+
+	w := newFilterRepository(c, "*.txt filter=test\n", map[string]string{
+		"process": command,
+	})
+
+	large := strings.Repeat("foo bar\n", 2*pktline.MaxPayloadSize/8)
+	commitFiles(c, w, map[string]string{
+		"foo.txt":   "foo\n",
+		"large.txt": large,
+		"error.txt": "foo\n",
+	})
+
+	assertIndexContent(c, w, "foo.txt", "sbb\n")
+	assertIndexContent(c, w, "large.txt", string(rot13([]byte(large))))
+	assertIndexContent(c, w, "error.txt", "foo\n")
+	assertCleanWorktree(c, w)
+
+	assertFilterCheckout(c, w, map[string]string{
+		"foo.txt":   "foo\n",
+		"large.txt": large,
+	})
+
+	w = newFilterRepository(c, "*.txt filter=test\n", map[string]string{
+		"process":  command,
+		"required": "true",
+	})
+
+	err := util.WriteFile(w.Filesystem, "error.txt", []byte("foo\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("error.txt")
+	c.Assert(err, ErrorMatches, "error.txt: clean filter test failed: .*")
+}
+
+// TestFilterProcessHelper isn't a real test, it's a rot13 filter process used
+// by TestFilterProcess, failing on the error.txt files.
+func TestFilterProcessHelper(t *testing.T) {
+	if os.Getenv("GO_FILTER_PROCESS_HELPER") != "1" {
+		return
+	}
+
+	s := pktline.NewScanner(os.Stdin)
+	e := pktline.NewEncoder(os.Stdout)
+	readList := func() []string {
+		var lines []string
+		for s.Scan() && len(s.Bytes()) > 0 {
+			lines = append(lines, strings.TrimSuffix(string(s.Bytes()), "\n"))
+		}
+
+		return lines
+	}
+
+	readList()
+	e.EncodeString("git-filter-server\n", "version=2\n")
+	e.Flush()
+	readList()
+	e.EncodeString("capability=clean\n", "capability=smudge\n")
+	e.Flush()
+
+	for {
+		request := readList()
+		if len(request) == 0 {
+			os.Exit(0)
+		}
+
+		var content bytes.Buffer
+		for s.Scan() && len(s.Bytes()) > 0 {
+			content.Write(s.Bytes())
+		}
+
+		if request[1] == "pathname=error.txt" {
+			e.EncodeString("status=error\n")
+			e.Flush()
+			continue
+		}
+
+		e.EncodeString("status=success\n")
+		e.Flush()
+
+		out := rot13(content.Bytes())
+		for len(out) > 0 {
+			n := len(out)
+			if n > pktline.MaxPayloadSize {
+				n = pktline.MaxPayloadSize
+			}
+
+			e.Encode(out[:n])
+			out = out[n:]
+		}
+
+		e.Flush()
+		e.Flush()
+	}
+}
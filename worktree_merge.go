@@ -0,0 +1,713 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/filemode"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/binary"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/ioutil"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/merge"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+)
+
+const (
+	// mergeHeadRef is the reference pointing to the commit being merged while
+	// a merge is stopped due to conflicts.
+	mergeHeadRef plumbing.ReferenceName = "MERGE_HEAD"
+	// mergeMsgFile is the file of the git directory keeping the message of
+	// the merge commit while a merge is stopped due to conflicts.
+	mergeMsgFile = "MERGE_MSG"
+)
+
+var (
+	// ErrMergeConflict is returned when the changes can't be merged
+	// automatically, the conflicting paths are left unmerged in the index and
+	// with conflict markers in the worktree.
+	ErrMergeConflict = errors.New("merge conflict")
+	// ErrUnrelatedHistories is returned when the commits being merged don't
+	// have any common ancestor.
+	ErrUnrelatedHistories = errors.New("refusing to merge unrelated histories")
+	// ErrMultipleMergeBases is returned when the commits being merged have
+	// more than one merge base, recursive merges are not supported.
+	ErrMultipleMergeBases = errors.New("multiple merge bases, recursive merge not supported")
+	// ErrUnmergedEntries is returned when the index contains conflicts that
+	// must be resolved before the operation.
+	ErrUnmergedEntries = errors.New("index contains unmerged entries")
+	// ErrUntrackedOverwritten is returned when an untracked file of the
+	// worktree would be overwritten by the operation.
+	ErrUntrackedOverwritten = errors.New("untracked files would be overwritten")
+)
+
+// Merge incorporates the changes from the given commit into the current
+// branch. If the current branch is an ancestor of the commit the branch is
+// fast-forwarded, otherwise the trees are merged using their merge base and a
+// merge commit with both commits as parents is created, its hash is returned.
+// A merge commit is created too for fast-forwards when NoFastForward is set.
+//
+// If the changes can't be merged automatically ErrMergeConflict is returned,
+// the conflicts are recorded in the index as stages 1 (base), 2 (ours) and 3
+// (theirs) and conflict markers are written in the worktree. The message of
+// the merge commit is kept at MERGE_MSG, as git does. Once the conflicts are
+// resolved and added, Commit creates the merge commit. When a file collides
+// with a directory of the other side, the file is written as <path>~<side>.
+//
+// If the commits have more than one merge base ErrMultipleMergeBases is
+// returned.
+func (w *Worktree) Merge(opts *MergeOptions) (plumbing.Hash, error) {
+	if err := opts.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ours, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	theirs, err := w.r.CommitObject(opts.Commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if ours.Hash == theirs.Hash {
+		return ours.Hash, NoErrAlreadyUpToDate
+	}
+
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if len(bases) == 0 {
+		return plumbing.ZeroHash, ErrUnrelatedHistories
+	}
+
+	switch {
+	case bases[0].Hash == theirs.Hash:
+		return ours.Hash, NoErrAlreadyUpToDate
+	case bases[0].Hash == ours.Hash && !opts.NoFastForward:
+		return theirs.Hash, w.Reset(&ResetOptions{
+			Mode:   MergeReset,
+			Commit: theirs.Hash,
+		})
+	}
+
+	if len(bases) > 1 {
+		return plumbing.ZeroHash, ErrMultipleMergeBases
+	}
+
+	if err := w.mergeCommits(bases[0], ours, theirs, &merge.Options{
+		OursLabel:   plumbing.HEAD.String(),
+		TheirsLabel: theirs.Hash.String(),
+	}); err != nil {
+		if err == ErrMergeConflict {
+			ref := plumbing.NewHashReference(mergeHeadRef, theirs.Hash)
+			if err := w.r.Storer.SetReference(ref); err != nil {
+				return plumbing.ZeroHash, err
+			}
+
+			if err := w.writeMergeMsg(opts.Message); err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+
+		return plumbing.ZeroHash, err
+	}
+
+	return w.Commit(opts.Message, &CommitOptions{
+		Author:    opts.Author,
+		Committer: opts.Committer,
+		Parents:   []plumbing.Hash{ours.Hash, theirs.Hash},
+	})
+}
+
+// mergeCommits merges into the index and the worktree the changes between the
+// trees of base and theirs, ours should be the commit at HEAD. ErrMergeConflict
+// is returned if any path can't be merged automatically.
+func (w *Worktree) mergeCommits(base, ours, theirs *object.Commit, o *merge.Options) error {
+	var trees [3]*object.Tree
+	for i, c := range []*object.Commit{base, ours, theirs} {
+		if c == nil {
+			continue
+		}
+
+		t, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		trees[i] = t
+	}
+
+	if err := w.checkCleanForMerge(); err != nil {
+		return err
+	}
+
+	m := &treeMerger{s: w.r.Storer, opts: o}
+	if err := m.Merge(trees[0], trees[1], trees[2]); err != nil {
+		return err
+	}
+
+	if err := w.applyTreeMerge(m); err != nil {
+		return err
+	}
+
+	if len(m.conflicts) != 0 {
+		return ErrMergeConflict
+	}
+
+	return nil
+}
+
+// writeMergeMsg stores the message of a stopped merge at MERGE_MSG, it's not
+// stored if the storage of the repository is not based on a filesystem.
+func (w *Worktree) writeMergeMsg(msg string) error {
+	fs := w.r.dotGitFilesystem()
+	if fs == nil {
+		return nil
+	}
+
+	return util.WriteFile(fs, mergeMsgFile, []byte(msg), 0644)
+}
+
+// checkCleanForMerge returns an error if the index or the tracked files of the
+// worktree contain changes.
+func (w *Worktree) checkCleanForMerge() error {
+	s, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, fs := range s {
+		if fs.Staging == UpdatedButUnmerged {
+			return ErrUnmergedEntries
+		}
+
+		if fs.Staging != Unmodified && fs.Staging != Untracked {
+			return ErrWorktreeNotClean
+		}
+
+		if fs.Worktree != Unmodified && fs.Worktree != Untracked {
+			return ErrWorktreeNotClean
+		}
+	}
+
+	return nil
+}
+
+// applyTreeMerge updates the worktree and the index with the result of a tree
+// merge, the worktree is expected to match the index and ours tree.
+func (w *Worktree) applyTreeMerge(m *treeMerger) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	c, err := w.newConverter(true)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	for _, name := range m.written() {
+		if _, ok := m.ours[name]; ok {
+			continue
+		}
+
+		if _, err := w.Filesystem.Lstat(name); err == nil {
+			return ErrUntrackedOverwritten
+		}
+	}
+
+	for name := range m.ours {
+		if _, ok := m.merged[name]; ok {
+			continue
+		}
+
+		if u, ok := m.unmerged[name]; ok && u.path == name {
+			continue
+		}
+
+		if err := rmFileAndDirIfEmpty(w.Filesystem, name); err != nil {
+			return err
+		}
+	}
+
+	var entries []*index.Entry
+	for _, name := range m.paths {
+		if e, ok := m.merged[name]; ok {
+			entry, err := w.mergedIndexEntry(c, idx, name, e, m.ours[name])
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+			continue
+		}
+
+		u, ok := m.unmerged[name]
+		if !ok {
+			continue
+		}
+
+		if u.content != nil {
+			if err := w.writeMergedFile(c, u.path, u.mode, u.content); err != nil {
+				return err
+			}
+		}
+
+		for i, e := range u.stages {
+			if e == nil {
+				continue
+			}
+
+			entries = append(entries, &index.Entry{
+				Name:  name,
+				Hash:  e.Hash,
+				Mode:  e.Mode,
+				Stage: index.AncestorMode + index.Stage(i),
+			})
+		}
+	}
+
+	idx.Entries = entries
+	return w.r.Storer.SetIndex(idx)
+}
+
+// mergedIndexEntry returns the index entry for a merged path, the entry of the
+// current index is kept if the path was not changed, otherwise the file is
+// written to the worktree.
+func (w *Worktree) mergedIndexEntry(c *converter, idx *index.Index, name string,
+	e, ours *object.TreeEntry) (*index.Entry, error) {
+
+	if ours != nil && ours.Hash == e.Hash && ours.Mode == e.Mode {
+		if current, err := idx.Entry(name); err == nil && current.Hash == e.Hash {
+			return current, nil
+		}
+
+		return &index.Entry{Name: name, Hash: e.Hash, Mode: e.Mode}, nil
+	}
+
+	if ours != nil {
+		if err := w.Filesystem.Remove(name); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	tmp := &index.Index{}
+	if e.Mode == filemode.Submodule {
+		if err := w.Filesystem.MkdirAll(name, os.ModeDir|os.ModePerm); err != nil {
+			return nil, err
+		}
+
+		if err := w.addIndexFromTreeEntry(name, e, tmp); err != nil {
+			return nil, err
+		}
+
+		return tmp.Entries[0], nil
+	}
+
+	blob, err := object.GetBlob(w.r.Storer, e.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.checkoutFile(c, object.NewFile(name, e.Mode, blob)); err != nil {
+		return nil, err
+	}
+
+	if err := w.addIndexFromFile(name, e.Hash, tmp); err != nil {
+		return nil, err
+	}
+
+	return tmp.Entries[0], nil
+}
+
+func (w *Worktree) writeMergedFile(c *converter, name string, mode filemode.FileMode, content []byte) error {
+	perm, err := mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	if err := w.Filesystem.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	content, err = c.smudge(name, content)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(w.Filesystem, name, content, perm.Perm())
+}
+
+// treeMerger performs a three-way merge of trees, path by path. The changes
+// made by only one side are taken, files changed by both sides are merged line
+// by line, any other case is a conflict.
+type treeMerger struct {
+	s    storer.EncodedObjectStorer
+	opts *merge.Options
+
+	ours     map[string]*object.TreeEntry
+	paths    []string
+	merged   map[string]*object.TreeEntry
+	unmerged map[string]*unmergedEntry
+	// conflicts is the sorted list of unmerged paths.
+	conflicts []string
+}
+
+// unmergedEntry is a conflicting path, stages contains the base, ours and
+// theirs entries, if content is not nil it should be written to the worktree
+// at path, that differs from the name of the entry for file/directory
+// conflicts.
+type unmergedEntry struct {
+	stages  [3]*object.TreeEntry
+	mode    filemode.FileMode
+	content []byte
+	path    string
+}
+
+// Merge merges the trees, any of them can be nil, meaning an empty tree.
+func (m *treeMerger) Merge(base, ours, theirs *object.Tree) error {
+	var entries [3]map[string]*object.TreeEntry
+	seen := make(map[string]bool)
+	for i, t := range []*object.Tree{base, ours, theirs} {
+		e, err := treeEntries(t)
+		if err != nil {
+			return err
+		}
+
+		for name := range e {
+			if !seen[name] {
+				seen[name] = true
+				m.paths = append(m.paths, name)
+			}
+		}
+
+		entries[i] = e
+	}
+
+	sort.Strings(m.paths)
+	m.ours = entries[1]
+	m.merged = make(map[string]*object.TreeEntry)
+	m.unmerged = make(map[string]*unmergedEntry)
+
+	for _, name := range m.paths {
+		b, o, t := entries[0][name], entries[1][name], entries[2][name]
+
+		var err error
+		switch {
+		case sameEntry(o, t):
+			m.take(name, o)
+		case sameEntry(b, o):
+			m.take(name, t)
+		case sameEntry(b, t):
+			m.take(name, o)
+		default:
+			err = m.mergeEntry(name, b, o, t)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.mergeDirectoryConflicts(entries)
+}
+
+// mergeDirectoryConflicts turns into conflicts the files of the result
+// colliding with a directory, a path of the result inside them. As git does,
+// the file is written to the worktree as <path>~<side>.
+func (m *treeMerger) mergeDirectoryConflicts(entries [3]map[string]*object.TreeEntry) error {
+	result := make(map[string]bool)
+	for _, name := range m.paths {
+		_, merged := m.merged[name]
+		_, unmerged := m.unmerged[name]
+		result[name] = merged || unmerged
+	}
+
+	colliding := make(map[string]bool)
+	for _, name := range m.paths {
+		if !result[name] {
+			continue
+		}
+
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if result[dir] {
+				colliding[dir] = true
+			}
+		}
+	}
+
+	if len(colliding) == 0 {
+		return nil
+	}
+
+	for _, name := range m.paths {
+		if !colliding[name] {
+			continue
+		}
+
+		b, o, t := entries[0][name], entries[1][name], entries[2][name]
+		if err := m.directoryConflict(name, b, o, t); err != nil {
+			return err
+		}
+	}
+
+	sort.Strings(m.conflicts)
+	return nil
+}
+
+func (m *treeMerger) directoryConflict(name string, b, o, t *object.TreeEntry) error {
+	ours := o != nil
+	u, ok := m.unmerged[name]
+	if !ok {
+		ours = sameEntry(m.merged[name], o)
+		delete(m.merged, name)
+		u = &unmergedEntry{stages: [3]*object.TreeEntry{b, o, t}}
+		m.conflict(name, u)
+	}
+
+	side, file := m.opts.TheirsLabel, t
+	if ours {
+		side, file = m.opts.OursLabel, o
+	}
+
+	u.path = name + "~" + strings.Replace(side, "/", "_", -1)
+	if u.content != nil {
+		return nil
+	}
+
+	return m.setContent(u, file)
+}
+
+func (m *treeMerger) take(name string, e *object.TreeEntry) {
+	if e != nil {
+		m.merged[name] = e
+	}
+}
+
+func (m *treeMerger) mergeEntry(name string, b, o, t *object.TreeEntry) error {
+	u := &unmergedEntry{stages: [3]*object.TreeEntry{b, o, t}, path: name}
+	if o == nil || t == nil || !isMergeableMode(o.Mode) || !isMergeableMode(t.Mode) ||
+		(b != nil && !isMergeableMode(b.Mode)) {
+		// deleted or changed in a way that can't be merged by one of the
+		// sides, the worktree keeps our version if any.
+		if o == nil {
+			if err := m.setContent(u, t); err != nil {
+				return err
+			}
+		}
+
+		m.conflict(name, u)
+		return nil
+	}
+
+	var contents [3][]byte
+	for i, e := range u.stages {
+		if e == nil {
+			continue
+		}
+
+		content, err := blobContent(m.s, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		contents[i] = content
+	}
+
+	mode, modeConflict := mergeModes(b, o, t)
+	if isBinaryContent(contents[0]) || isBinaryContent(contents[1]) || isBinaryContent(contents[2]) {
+		m.conflict(name, u)
+		return nil
+	}
+
+	merged, conflict := merge.Merge(
+		string(contents[0]), string(contents[1]), string(contents[2]), m.opts,
+	)
+
+	if conflict || modeConflict {
+		u.mode = mode
+		u.content = []byte(merged)
+		m.conflict(name, u)
+		return nil
+	}
+
+	h, err := writeBlob(m.s, []byte(merged))
+	if err != nil {
+		return err
+	}
+
+	m.merged[name] = &object.TreeEntry{Name: o.Name, Mode: mode, Hash: h}
+	return nil
+}
+
+func (m *treeMerger) setContent(u *unmergedEntry, e *object.TreeEntry) error {
+	if e == nil || e.Mode == filemode.Submodule {
+		return nil
+	}
+
+	content, err := blobContent(m.s, e.Hash)
+	if err != nil {
+		return err
+	}
+
+	u.mode = e.Mode
+	u.content = content
+	return nil
+}
+
+func (m *treeMerger) conflict(name string, u *unmergedEntry) {
+	m.unmerged[name] = u
+	m.conflicts = append(m.conflicts, name)
+}
+
+// written returns the paths that are going to be written to the worktree.
+func (m *treeMerger) written() []string {
+	var paths []string
+	for _, name := range m.paths {
+		if e, ok := m.merged[name]; ok {
+			if o := m.ours[name]; o == nil || o.Hash != e.Hash || o.Mode != e.Mode {
+				paths = append(paths, name)
+			}
+
+			continue
+		}
+
+		if u, ok := m.unmerged[name]; ok && u.content != nil {
+			paths = append(paths, u.path)
+		}
+	}
+
+	return paths
+}
+
+// treeEntries returns all the non-tree entries of t by its full path.
+func treeEntries(t *object.Tree) (map[string]*object.TreeEntry, error) {
+	entries := make(map[string]*object.TreeEntry)
+	if t == nil {
+		return entries, nil
+	}
+
+	walker := object.NewTreeWalker(t, true, nil)
+	defer walker.Close()
+
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Mode == filemode.Dir {
+			continue
+		}
+
+		entry := e
+		entries[name] = &entry
+	}
+
+	return entries, nil
+}
+
+func sameEntry(a, b *object.TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+func isMergeableMode(m filemode.FileMode) bool {
+	return m.IsRegular() || m == filemode.Executable
+}
+
+// mergeModes returns the mode resulting of merging the mode of the entries,
+// if both sides changed it in a different way our mode is returned and
+// conflict is true.
+func mergeModes(b, o, t *object.TreeEntry) (mode filemode.FileMode, conflict bool) {
+	switch {
+	case o.Mode == t.Mode:
+		return o.Mode, false
+	case b == nil:
+		return o.Mode, true
+	case b.Mode == o.Mode:
+		return t.Mode, false
+	case b.Mode == t.Mode:
+		return o.Mode, false
+	}
+
+	return o.Mode, true
+}
+
+func isBinaryContent(content []byte) bool {
+	if content == nil {
+		return false
+	}
+
+	bin, _ := binary.IsBinary(bytes.NewReader(content))
+	return bin
+}
+
+func blobContent(s storer.EncodedObjectStorer, h plumbing.Hash) (content []byte, err error) {
+	blob, err := object.GetBlob(s, h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeBlob(s storer.EncodedObjectStorer, content []byte) (h plumbing.Hash, err error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	writer, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return plumbing.ZeroHash, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(obj)
+}
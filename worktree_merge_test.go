@@ -0,0 +1,377 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/storage/memory"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/memfs"
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+// newMergeRepository returns a worktree with a master branch and a feature
+// branch forked from it, each one with the given files committed on top of
+// base. HEAD is left at master, no commit is added to a branch with nil files.
+func newMergeRepository(c *C, base, master, feature map[string]string) (
+	w *Worktree, masterHash, featureHash plumbing.Hash) {
+
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err = r.Worktree()
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, base)
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: plumbing.ReferenceName("refs/heads/feature"),
+		Create: true,
+	})
+	c.Assert(err, IsNil)
+
+	if feature != nil {
+		featureHash = commitFiles(c, w, feature)
+	}
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	if master == nil {
+		head, err := w.r.Head()
+		c.Assert(err, IsNil)
+		return w, head.Hash(), featureHash
+	}
+
+	masterHash = commitFiles(c, w, master)
+	return w, masterHash, featureHash
+}
+
+// commitFiles writes and commits the given files, an empty content deletes
+// the file.
+func commitFiles(c *C, w *Worktree, files map[string]string) plumbing.Hash {
+	for name, content := range files {
+		if content == "" {
+			_, err := w.Remove(name)
+			c.Assert(err, IsNil)
+			continue
+		}
+
+		err := util.WriteFile(w.Filesystem, name, []byte(content), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	h, err := w.Commit("files\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	return h
+}
+
+func assertFileContent(c *C, w *Worktree, name, expected string) {
+	f, err := w.Filesystem.Open(name)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, expected)
+}
+
+func (s *WorktreeSuite) TestMergeInvalidOptions(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.Merge(&MergeOptions{})
+	c.Assert(err, Equals, ErrMissingMergeCommit)
+
+	_, err = w.Merge(&MergeOptions{Commit: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")})
+	c.Assert(err, Equals, ErrMissingAuthor)
+}
+
+func (s *WorktreeSuite) TestMergeFastForward(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		nil,
+		map[string]string{"bar": "bar\n"},
+	)
+
+	hash, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	c.Assert(hash, Equals, feature)
+	c.Assert(master, Not(Equals), feature)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+	c.Assert(head.Hash(), Equals, feature)
+
+	assertFileContent(c, w, "bar", "bar\n")
+}
+
+func (s *WorktreeSuite) TestMergeAlreadyUpToDate(c *C) {
+	w, master, _ := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		nil,
+	)
+
+	base, err := w.r.CommitObject(master)
+	c.Assert(err, IsNil)
+
+	hash, err := w.Merge(&MergeOptions{
+		Commit: base.ParentHashes[0],
+		Author: defaultSignature(),
+	})
+
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+	c.Assert(hash, Equals, master)
+}
+
+func (s *WorktreeSuite) TestMergeClean(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "a\nb\nc\nd\ne\n", "qux": "qux\n"},
+		map[string]string{"foo": "A\nb\nc\nd\ne\n", "bar": "bar\n"},
+		map[string]string{"foo": "a\nb\nc\nd\nE\n", "baz": "baz\n", "qux": ""},
+	)
+
+	hash, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{master, feature})
+	c.Assert(commit.Message, Equals, "Merge commit '"+feature.String()+"'\n")
+
+	f, err := commit.File("foo")
+	c.Assert(err, IsNil)
+	content, err := f.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, "A\nb\nc\nd\nE\n")
+
+	_, err = commit.File("qux")
+	c.Assert(err, NotNil)
+
+	assertFileContent(c, w, "foo", "A\nb\nc\nd\nE\n")
+	assertFileContent(c, w, "bar", "bar\n")
+	assertFileContent(c, w, "baz", "baz\n")
+
+	_, err = w.Filesystem.Lstat("qux")
+	c.Assert(err, NotNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestMergeConflict(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "a\nb\nc\n"},
+		map[string]string{"foo": "a\nB\nc\n"},
+		map[string]string{"foo": "a\nX\nc\n"},
+	)
+
+	hash, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrMergeConflict)
+	c.Assert(hash.IsZero(), Equals, true)
+
+	assertFileContent(c, w, "foo",
+		"a\n<<<<<<< HEAD\nB\n=======\nX\n>>>>>>> "+feature.String()+"\nc\n",
+	)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 3)
+	for i, stage := range []index.Stage{index.AncestorMode, index.OurMode, index.TheirMode} {
+		c.Assert(idx.Entries[i].Name, Equals, "foo")
+		c.Assert(idx.Entries[i].Stage, Equals, stage)
+	}
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, UpdatedButUnmerged)
+	c.Assert(status.File("foo").Worktree, Equals, UpdatedButUnmerged)
+
+	_, err = w.Commit("merge\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrUnmergedEntries)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("a\nB\nX\nc\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	hash, err = w.Commit("merge\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{master, feature})
+
+	_, err = w.r.Storer.Reference(mergeHeadRef)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestMergeModifyDeleteConflict(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n", "bar": "bar\n"},
+		map[string]string{"foo": ""},
+		map[string]string{"foo": "qux\n"},
+	)
+
+	_, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	assertFileContent(c, w, "foo", "qux\n")
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 3)
+	c.Assert(idx.Entries[0].Name, Equals, "bar")
+	c.Assert(idx.Entries[0].Stage, Equals, index.Merged)
+	c.Assert(idx.Entries[1].Stage, Equals, index.AncestorMode)
+	c.Assert(idx.Entries[2].Stage, Equals, index.TheirMode)
+}
+
+func (s *WorktreeSuite) TestMergeFileDirectoryConflict(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"a": "a\n"},
+		map[string]string{"a/b": "b\n"},
+	)
+
+	_, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	assertFileContent(c, w, "a~HEAD", "a\n")
+	assertFileContent(c, w, "a/b", "b\n")
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 3)
+	c.Assert(idx.Entries[0].Name, Equals, "a")
+	c.Assert(idx.Entries[0].Stage, Equals, index.OurMode)
+	c.Assert(idx.Entries[1].Name, Equals, "a/b")
+	c.Assert(idx.Entries[1].Stage, Equals, index.Merged)
+
+	w, _, feature = newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"a/b": "b\n"},
+		map[string]string{"a": "a\n"},
+	)
+
+	_, err = w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	assertFileContent(c, w, "a~"+feature.String(), "a\n")
+	assertFileContent(c, w, "a/b", "b\n")
+
+	idx, err = w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 3)
+	c.Assert(idx.Entries[0].Name, Equals, "a")
+	c.Assert(idx.Entries[0].Stage, Equals, index.TheirMode)
+	c.Assert(idx.Entries[1].Name, Equals, "a/b")
+	c.Assert(idx.Entries[1].Stage, Equals, index.Merged)
+}
+
+func (s *WorktreeSuite) TestMergeMultipleBases(c *C) {
+	w, master, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	// criss-cross merge, master and feature merge each other
+	_, err := w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature"})
+	c.Assert(err, IsNil)
+
+	_, err = w.Merge(&MergeOptions{Commit: master, Author: otherSignature()})
+	c.Assert(err, IsNil)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, IsNil)
+
+	_, err = w.Merge(&MergeOptions{Commit: head.Hash(), Author: defaultSignature()})
+	c.Assert(err, Equals, ErrMultipleMergeBases)
+}
+
+func (s *WorktreeSuite) TestMergeConflictMessage(c *C) {
+	r, dotgit := newRebaseRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"foo": "bar\n"},
+		map[string]string{"foo": "baz\n"},
+	)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	master, err := r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+
+	_, err = w.Merge(&MergeOptions{
+		Commit:  master.Hash(),
+		Message: "merge master\n",
+		Author:  defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	f, err := dotgit.Open(mergeMsgFile)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(string(content), Equals, "merge master\n")
+
+	commitFiles(c, w, map[string]string{"foo": "bar\nbaz\n"})
+
+	_, err = dotgit.Stat(mergeMsgFile)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WorktreeSuite) TestMergeWorktreeNotClean(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("modified\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+}
+
+func (s *WorktreeSuite) TestMergeUntrackedOverwritten(c *C) {
+	w, _, feature := newMergeRepository(c,
+		map[string]string{"foo": "foo\n"},
+		map[string]string{"bar": "bar\n"},
+		map[string]string{"baz": "baz\n"},
+	)
+
+	err := util.WriteFile(w.Filesystem, "baz", []byte("untracked\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Merge(&MergeOptions{Commit: feature, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrUntrackedOverwritten)
+
+	assertFileContent(c, w, "baz", "untracked\n")
+}
@@ -0,0 +1,581 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/index"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/format/reflog"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/storer"
+	"github.com/sniperkit/snk.fork.go-git.v4/utils/merge"
+)
+
+// stashRef is the reference pointing to the most recent stash entry, the
+// older entries are kept in its reflog.
+const stashRef plumbing.ReferenceName = "refs/stash"
+
+var (
+	// ErrNoLocalChanges is returned by StashPush when there are no changes to
+	// be stashed.
+	ErrNoLocalChanges = errors.New("no local changes to save")
+	// ErrStashNotFound is returned when the given stash entry doesn't exist.
+	ErrStashNotFound = errors.New("stash entry not found")
+	// ErrInvalidStash is returned when a stash entry doesn't point to a
+	// commit with the structure created by StashPush.
+	ErrInvalidStash = errors.New("stash entry is not a stash commit")
+)
+
+// StashEntry is an entry of the stash.
+type StashEntry struct {
+	// Hash is the hash of the stash commit. Its tree contains the stashed
+	// worktree and its parents are the HEAD commit, the commit of the stashed
+	// index and, when untracked files were stashed, the commit of the
+	// untracked files.
+	Hash plumbing.Hash
+	// Message describes the stashed changes.
+	Message string
+	// When is the time the entry was created.
+	When time.Time
+}
+
+// StashPush saves the changes of the index and the worktree in a new stash
+// entry and reverts them, leaving the worktree matching HEAD. The entry is
+// recorded as the commit pointed by refs/stash, the previous entries are kept
+// in its reflog when the storer supports reflogs. Returns the hash of the
+// stash commit.
+//
+// ErrNoLocalChanges is returned if there is nothing to stash.
+func (w *Worktree) StashPush(opts *StashPushOptions) (plumbing.Hash, error) {
+	if err := opts.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	s, err := w.Status()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var tracked, untracked []string
+	for path, fs := range s {
+		switch {
+		case fs.Staging == UpdatedButUnmerged:
+			return plumbing.ZeroHash, ErrUnmergedEntries
+		case fs.Staging == Untracked:
+			if opts.IncludeUntracked {
+				untracked = append(untracked, path)
+			}
+		case fs.Staging != Unmodified || fs.Worktree != Unmodified:
+			tracked = append(tracked, path)
+		}
+	}
+
+	if len(tracked) == 0 && len(untracked) == 0 {
+		return plumbing.ZeroHash, ErrNoLocalChanges
+	}
+
+	sort.Strings(tracked)
+	sort.Strings(untracked)
+
+	ref, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	branch := stashBranch(ref)
+	label := fmt.Sprintf("%s: %s %s", branch, commit.Hash.String()[:7], commitSubject(commit))
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	indexCommit, err := w.stashIndex(idx, "index on "+label, opts, commit.Hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	worktree, err := w.stashWorktreeIndex(idx, s, tracked)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	parents := []plumbing.Hash{commit.Hash, indexCommit}
+	if len(untracked) != 0 {
+		files, err := w.stashUntrackedIndex(idx.Version, untracked)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		h, err := w.stashIndex(files, "untracked files on "+label, opts)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		parents = append(parents, h)
+	}
+
+	msg := "WIP on " + label
+	if opts.Message != "" {
+		msg = fmt.Sprintf("On %s: %s", branch, opts.Message)
+	}
+
+	stash, err := w.stashIndex(worktree, msg, opts, parents...)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.pushStashRef(stash, opts.Committer, msg); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return stash, w.resetStashedPaths(commit, append(tracked, untracked...))
+}
+
+// StashList returns the entries of the stash, the most recent first. The
+// position of an entry in the list is the number used to refer to it, as
+// stash@{n} in git.
+func (w *Worktree) StashList() ([]StashEntry, error) {
+	entries, err := w.stashReflog()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]StashEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		list = append(list, StashEntry{
+			Hash:    entries[i].New,
+			Message: entries[i].Message,
+			When:    entries[i].When,
+		})
+	}
+
+	return list, nil
+}
+
+// StashApply applies on top of HEAD the changes saved in the given stash
+// entry, 0 being the most recent one. The worktree must not contain changes
+// to tracked files. The files added to the index when the entry was created
+// are added again, any other change is left unstaged and the stashed
+// untracked files are restored.
+//
+// If the changes can't be applied cleanly ErrMergeConflict is returned and
+// the conflicts are recorded in the index, the untracked files are not
+// restored and the entry is kept in the stash. If a stashed untracked file
+// exists in the worktree ErrUntrackedOverwritten is returned before applying
+// any change.
+func (w *Worktree) StashApply(n int) error {
+	stash, err := w.stashCommit(n)
+	if err != nil {
+		return err
+	}
+
+	if stash.NumParents() < 2 {
+		return ErrInvalidStash
+	}
+
+	if err := w.checkCleanForMerge(); err != nil {
+		return err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return err
+	}
+
+	ours, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	base, err := stash.Parent(0)
+	if err != nil {
+		return err
+	}
+
+	var untracked []*object.File
+	if stash.NumParents() > 2 {
+		c, err := stash.Parent(2)
+		if err != nil {
+			return err
+		}
+
+		if untracked, err = w.stashedUntracked(c); err != nil {
+			return err
+		}
+	}
+
+	if err := w.mergeCommits(base, ours, stash, &merge.Options{
+		OursLabel:   "Updated upstream",
+		TheirsLabel: "Stashed changes",
+	}); err != nil {
+		return err
+	}
+
+	if err := w.unstageStashedChanges(ours); err != nil {
+		return err
+	}
+
+	return w.restoreUntracked(untracked)
+}
+
+// StashPop applies the given stash entry, as StashApply does, and drops it
+// from the stash if it was applied cleanly.
+func (w *Worktree) StashPop(n int) error {
+	if err := w.StashApply(n); err != nil {
+		return err
+	}
+
+	return w.StashDrop(n)
+}
+
+// StashDrop removes the given entry, 0 being the most recent one, from the
+// stash. The refs/stash reference is removed with its last entry.
+func (w *Worktree) StashDrop(n int) error {
+	entries, err := w.stashReflog()
+	if err != nil {
+		return err
+	}
+
+	if n < 0 || n >= len(entries) {
+		return ErrStashNotFound
+	}
+
+	pos := len(entries) - 1 - n
+	entries = append(entries[:pos], entries[pos+1:]...)
+	if pos < len(entries) {
+		entries[pos].Old = plumbing.ZeroHash
+		if pos > 0 {
+			entries[pos].Old = entries[pos-1].New
+		}
+	}
+
+	if len(entries) == 0 {
+		err = w.r.Storer.RemoveReference(stashRef)
+	} else {
+		ref := plumbing.NewHashReference(stashRef, entries[len(entries)-1].New)
+		err = w.r.Storer.SetReference(ref)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rs, ok := w.r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return nil
+	}
+
+	return rs.SetReflog(stashRef, entries)
+}
+
+// stashReflog returns the reflog of refs/stash, oldest first. If the storer
+// doesn't support reflogs only the entry pointed by refs/stash is returned.
+func (w *Worktree) stashReflog() ([]*reflog.Entry, error) {
+	ref, err := w.r.Storer.Reference(stashRef)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := w.r.Storer.(storer.ReflogStorer); ok {
+		entries, err := rs.Reflog(stashRef)
+		if err != nil || len(entries) != 0 {
+			return entries, err
+		}
+	}
+
+	c, err := w.r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return []*reflog.Entry{{
+		New:     c.Hash,
+		Name:    c.Committer.Name,
+		Email:   c.Committer.Email,
+		When:    c.Committer.When,
+		Message: commitSubject(c),
+	}}, nil
+}
+
+func (w *Worktree) stashCommit(n int) (*object.Commit, error) {
+	entries, err := w.stashReflog()
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 0 || n >= len(entries) {
+		return nil, ErrStashNotFound
+	}
+
+	return w.r.CommitObject(entries[len(entries)-1-n].New)
+}
+
+// pushStashRef points refs/stash to the given commit, logging the previous
+// value in its reflog. The stash is always logged, so the log is rewritten
+// after the update in case the storer already logged it, or not, depending on
+// its configuration.
+func (w *Worktree) pushStashRef(h plumbing.Hash, sig *object.Signature, msg string) error {
+	entries, err := w.stashReflog()
+	if err != nil {
+		return err
+	}
+
+	if err := w.r.Storer.SetReference(plumbing.NewHashReference(stashRef, h)); err != nil {
+		return err
+	}
+
+	rs, ok := w.r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return nil
+	}
+
+	old := plumbing.ZeroHash
+	if len(entries) != 0 {
+		old = entries[len(entries)-1].New
+	}
+
+	return rs.SetReflog(stashRef, append(entries, &reflog.Entry{
+		Old:     old,
+		New:     h,
+		Name:    sig.Name,
+		Email:   sig.Email,
+		When:    sig.When,
+		Message: msg,
+	}))
+}
+
+// stashIndex creates a commit with the tree of the given index.
+func (w *Worktree) stashIndex(idx *index.Index, msg string, opts *StashPushOptions,
+	parents ...plumbing.Hash) (plumbing.Hash, error) {
+
+	h := &buildTreeHelper{fs: w.Filesystem, s: w.r.Storer}
+	tree, err := h.BuildTree(idx)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.buildCommitObject(msg+"\n", &CommitOptions{
+		Author:    opts.Author,
+		Committer: opts.Committer,
+		Parents:   parents,
+	}, tree)
+}
+
+// stashWorktreeIndex returns a copy of idx updated with the worktree version
+// of the given tracked paths.
+func (w *Worktree) stashWorktreeIndex(idx *index.Index, s Status, paths []string) (*index.Index, error) {
+	c, err := w.newConverter(false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.Close()
+
+	worktree := &index.Index{Version: idx.Version}
+	for _, e := range idx.Entries {
+		entry := *e
+		worktree.Entries = append(worktree.Entries, &entry)
+	}
+
+	for _, path := range paths {
+		switch s.File(path).Worktree {
+		case Deleted:
+			if _, err := worktree.Remove(path); err != nil {
+				return nil, err
+			}
+		case Modified:
+			if err := w.addStashedFile(c, worktree, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sortIndexEntries(worktree)
+	return worktree, nil
+}
+
+// stashUntrackedIndex returns an index containing the given untracked paths.
+func (w *Worktree) stashUntrackedIndex(version uint32, paths []string) (*index.Index, error) {
+	c, err := w.newConverter(false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.Close()
+
+	files := &index.Index{Version: version}
+	for _, path := range paths {
+		if err := w.addStashedFile(c, files, path); err != nil {
+			return nil, err
+		}
+	}
+
+	sortIndexEntries(files)
+	return files, nil
+}
+
+func (w *Worktree) addStashedFile(c *converter, idx *index.Index, path string) error {
+	h, err := w.copyFileToStorage(c, path, false)
+	if err != nil {
+		return err
+	}
+
+	return w.addOrUpdateFileToIndex(idx, path, h)
+}
+
+// resetStashedPaths resets the index to the given commit and restores the
+// given paths of the worktree to their version in the commit, removing them
+// if they don't exist in it. Other untracked files are kept.
+func (w *Worktree) resetStashedPaths(c *object.Commit, paths []string) error {
+	if err := w.Reset(&ResetOptions{Mode: MixedReset, Commit: c.Hash}); err != nil {
+		return err
+	}
+
+	t, err := c.Tree()
+	if err != nil {
+		return err
+	}
+
+	conv, err := w.newConverter(true)
+	if err != nil {
+		return err
+	}
+
+	defer conv.Close()
+
+	for _, path := range paths {
+		if err := rmFileAndDirIfEmpty(w.Filesystem, path); err != nil {
+			return err
+		}
+
+		f, err := t.File(path)
+		if err == object.ErrFileNotFound {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := w.checkoutFile(conv, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stashedUntracked returns the files of the given commit of stashed untracked
+// files, ErrUntrackedOverwritten is returned if any of them exists in the
+// worktree.
+func (w *Worktree) stashedUntracked(c *object.Commit) ([]*object.File, error) {
+	t, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*object.File
+	err = t.Files().ForEach(func(f *object.File) error {
+		if _, err := w.Filesystem.Lstat(f.Name); err == nil {
+			return ErrUntrackedOverwritten
+		}
+
+		files = append(files, f)
+		return nil
+	})
+
+	return files, err
+}
+
+// restoreUntracked writes the stashed untracked files to the worktree.
+func (w *Worktree) restoreUntracked(files []*object.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	conv, err := w.newConverter(true)
+	if err != nil {
+		return err
+	}
+
+	defer conv.Close()
+
+	for _, f := range files {
+		if err := w.checkoutFile(conv, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unstageStashedChanges resets the index to the given commit, keeping staged
+// the paths that don't exist in it.
+func (w *Worktree) unstageStashedChanges(c *object.Commit) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	t, err := c.Tree()
+	if err != nil {
+		return err
+	}
+
+	var added []string
+	for _, e := range idx.Entries {
+		if _, err := t.FindEntry(e.Name); err != nil {
+			added = append(added, e.Name)
+		}
+	}
+
+	if err := w.Reset(&ResetOptions{Mode: MixedReset, Commit: c.Hash}); err != nil {
+		return err
+	}
+
+	for _, path := range added {
+		if _, err := w.Add(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stashBranch returns the short name of the branch HEAD points to, or
+// "(no branch)" if HEAD is detached.
+func stashBranch(head *plumbing.Reference) string {
+	if head.Type() != plumbing.SymbolicReference {
+		return "(no branch)"
+	}
+
+	return head.Target().Short()
+}
+
+type indexEntriesByName []*index.Entry
+
+func (l indexEntriesByName) Len() int           { return len(l) }
+func (l indexEntriesByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l indexEntriesByName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+
+func sortIndexEntries(idx *index.Index) {
+	sort.Sort(indexEntriesByName(idx.Entries))
+}
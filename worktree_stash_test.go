@@ -0,0 +1,276 @@
+package git
+
+import (
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
+	"github.com/sniperkit/snk.fork.go-git.v4/plumbing/object"
+
+	"github.com/sniperkit/snk.fork.go-billy.v4/util"
+	. "gopkg.in/check.v1"
+)
+
+func (s *WorktreeSuite) TestStashPushNoChanges(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+
+	_, err := w.StashPush(&StashPushOptions{})
+	c.Assert(err, Equals, ErrMissingAuthor)
+
+	err = util.WriteFile(w.Filesystem, "bar", []byte("bar\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.StashPush(&StashPushOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrNoLocalChanges)
+
+	list, err := w.StashList()
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 0)
+}
+
+func (s *WorktreeSuite) TestStashPush(c *C) {
+	w, master, _ := newMergeRepository(c,
+		map[string]string{"foo": "foo\n", "bar": "bar\n"}, nil, nil,
+	)
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("staged\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("unstaged\n"), 0644)
+	c.Assert(err, IsNil)
+	err = util.WriteFile(w.Filesystem, "qux", []byte("qux\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = w.Add("qux")
+	c.Assert(err, IsNil)
+	err = w.Filesystem.Remove("bar")
+	c.Assert(err, IsNil)
+	err = util.WriteFile(w.Filesystem, "untracked", []byte("untracked\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hash, err := w.StashPush(&StashPushOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	ref, err := w.r.Storer.Reference(stashRef)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, hash)
+
+	stash, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(stash.Message, Equals, "WIP on master: "+master.String()[:7]+" files\n")
+	c.Assert(stash.NumParents(), Equals, 2)
+	c.Assert(stash.ParentHashes[0], Equals, master)
+
+	assertTreeFile(c, stash, "foo", "unstaged\n")
+	assertTreeFile(c, stash, "qux", "qux\n")
+	_, err = stash.File("bar")
+	c.Assert(err, NotNil)
+
+	idx, err := stash.Parent(1)
+	c.Assert(err, IsNil)
+	c.Assert(idx.Message, Equals, "index on master: "+master.String()[:7]+" files\n")
+	assertTreeFile(c, idx, "foo", "staged\n")
+	assertTreeFile(c, idx, "bar", "bar\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("untracked").Worktree, Equals, Untracked)
+
+	assertFileContent(c, w, "foo", "foo\n")
+	assertFileContent(c, w, "bar", "bar\n")
+	_, err = w.Filesystem.Lstat("qux")
+	c.Assert(err, NotNil)
+}
+
+func (s *WorktreeSuite) TestStashPushIncludeUntracked(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+
+	err := util.WriteFile(w.Filesystem, "dir/untracked", []byte("untracked\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hash, err := w.StashPush(&StashPushOptions{
+		Message:          "foo",
+		IncludeUntracked: true,
+		Author:           defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	stash, err := w.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(stash.Message, Equals, "On master: foo\n")
+	c.Assert(stash.NumParents(), Equals, 3)
+
+	untracked, err := stash.Parent(2)
+	c.Assert(err, IsNil)
+	c.Assert(untracked.NumParents(), Equals, 0)
+	assertTreeFile(c, untracked, "dir/untracked", "untracked\n")
+
+	_, err = w.Filesystem.Lstat("dir/untracked")
+	c.Assert(err, NotNil)
+
+	err = w.StashPop(0)
+	c.Assert(err, IsNil)
+	assertFileContent(c, w, "dir/untracked", "untracked\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("dir/untracked").Worktree, Equals, Untracked)
+}
+
+func (s *WorktreeSuite) TestStashList(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "foo\n"}, nil, nil)
+
+	var hashes []plumbing.Hash
+	for _, content := range []string{"bar\n", "baz\n"} {
+		err := util.WriteFile(w.Filesystem, "foo", []byte(content), 0644)
+		c.Assert(err, IsNil)
+
+		h, err := w.StashPush(&StashPushOptions{
+			Message: content[:3],
+			Author:  defaultSignature(),
+		})
+		c.Assert(err, IsNil)
+		hashes = append(hashes, h)
+	}
+
+	list, err := w.StashList()
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 2)
+	c.Assert(list[0].Hash, Equals, hashes[1])
+	c.Assert(list[0].Message, Equals, "On master: baz")
+	c.Assert(list[1].Hash, Equals, hashes[0])
+	c.Assert(list[1].Message, Equals, "On master: bar")
+
+	err = w.StashApply(2)
+	c.Assert(err, Equals, ErrStashNotFound)
+
+	err = w.StashDrop(1)
+	c.Assert(err, IsNil)
+
+	list, err = w.StashList()
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 1)
+	c.Assert(list[0].Hash, Equals, hashes[1])
+
+	err = w.StashDrop(0)
+	c.Assert(err, IsNil)
+
+	list, err = w.StashList()
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 0)
+
+	_, err = w.r.Storer.Reference(stashRef)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestStashApply(c *C) {
+	w, _, _ := newMergeRepository(c,
+		map[string]string{"foo": "a\nb\nc\nd\ne\n"}, nil, nil,
+	)
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("a\nb\nc\nd\nE\n"), 0644)
+	c.Assert(err, IsNil)
+	err = util.WriteFile(w.Filesystem, "bar", []byte("bar\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = w.Add("bar")
+	c.Assert(err, IsNil)
+
+	_, err = w.StashPush(&StashPushOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, map[string]string{"foo": "A\nb\nc\nd\ne\n"})
+
+	err = w.StashApply(0)
+	c.Assert(err, IsNil)
+
+	assertFileContent(c, w, "foo", "A\nb\nc\nd\nE\n")
+	assertFileContent(c, w, "bar", "bar\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Unmodified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+	c.Assert(status.File("bar").Staging, Equals, Added)
+
+	list, err := w.StashList()
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 1)
+
+	err = w.StashApply(0)
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+}
+
+func (s *WorktreeSuite) TestStashPopConflict(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "a\nb\nc\n"}, nil, nil)
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("a\nB\nc\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.StashPush(&StashPushOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commitFiles(c, w, map[string]string{"foo": "a\nX\nc\n"})
+
+	err = w.StashPop(0)
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, UpdatedButUnmerged)
+
+	assertFileContent(c, w, "foo",
+		"a\n<<<<<<< Updated upstream\nX\n=======\nB\n>>>>>>> Stashed changes\nc\n",
+	)
+
+	list, err := w.StashList()
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 1)
+}
+
+func (s *WorktreeSuite) TestStashPopConflictUntracked(c *C) {
+	w, _, _ := newMergeRepository(c, map[string]string{"foo": "a\nb\nc\n"}, nil, nil)
+
+	err := util.WriteFile(w.Filesystem, "foo", []byte("a\nB\nc\n"), 0644)
+	c.Assert(err, IsNil)
+	err = util.WriteFile(w.Filesystem, "untracked", []byte("untracked\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.StashPush(&StashPushOptions{
+		IncludeUntracked: true,
+		Author:           defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	// an existing file keeps the stash from being applied at all
+	commitFiles(c, w, map[string]string{"foo": "a\nX\nc\n", "untracked": "tracked\n"})
+
+	err = w.StashPop(0)
+	c.Assert(err, Equals, ErrUntrackedOverwritten)
+	assertFileContent(c, w, "foo", "a\nX\nc\n")
+	assertFileContent(c, w, "untracked", "tracked\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	// on conflicts the untracked files aren't restored
+	commitFiles(c, w, map[string]string{"untracked": ""})
+
+	err = w.StashPop(0)
+	c.Assert(err, Equals, ErrMergeConflict)
+
+	_, err = w.Filesystem.Lstat("untracked")
+	c.Assert(err, NotNil)
+
+	list, err := w.StashList()
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 1)
+}
+
+func assertTreeFile(c *C, commit *object.Commit, name, expected string) {
+	f, err := commit.File(name)
+	c.Assert(err, IsNil)
+
+	content, err := f.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, expected)
+}
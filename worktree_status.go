@@ -10,9 +10,11 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	stdioutil "io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/sniperkit/snk.fork.go-billy.v4/util"
 	"github.com/sniperkit/snk.fork.go-git.v4/plumbing"
@@ -106,6 +108,21 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		}
 	}
 
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage == index.Merged {
+			continue
+		}
+
+		fs := s.File(e.Name)
+		fs.Staging = UpdatedButUnmerged
+		fs.Worktree = UpdatedButUnmerged
+	}
+
 	return s, nil
 }
 
@@ -143,9 +160,81 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
+	c, err = w.excludeConvertedChanges(idx, c)
+	if err != nil {
+		return nil, err
+	}
+
 	return w.excludeIgnoredChanges(c), nil
 }
 
+// excludeConvertedChanges excludes the modified files whose content in the
+// worktree matches the index once converted, as the files with CRLF line
+// endings checked out from a blob with LF line endings.
+func (w *Worktree) excludeConvertedChanges(idx *index.Index, changes merkletrie.Changes) (merkletrie.Changes, error) {
+	var c *converter
+	var res merkletrie.Changes
+	for _, ch := range changes {
+		a, err := ch.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		if a != merkletrie.Modify {
+			res = append(res, ch)
+			continue
+		}
+
+		if c == nil {
+			if c, err = w.newConverter(false); err != nil {
+				return nil, err
+			}
+
+			defer c.Close()
+		}
+
+		converted, err := w.matchesConvertedIndexEntry(idx, c, nameFromAction(&ch))
+		if err != nil {
+			return nil, err
+		}
+
+		if !converted {
+			res = append(res, ch)
+		}
+	}
+
+	return res, nil
+}
+
+func (w *Worktree) matchesConvertedIndexEntry(idx *index.Index, c *converter, name string) (bool, error) {
+	e, err := idx.Entry(name)
+	if err != nil || !e.Mode.IsRegular() || !c.converts(name) {
+		return false, nil
+	}
+
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return false, err
+	}
+
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil || mode != e.Mode {
+		return false, nil
+	}
+
+	content, err := w.readFile(name)
+	if err != nil {
+		return false, err
+	}
+
+	content, err = c.clean(name, content, false, false)
+	if err != nil {
+		return false, err
+	}
+
+	return plumbing.ComputeHash(plumbing.BlobObject, content) == e.Hash, nil
+}
+
 func (w *Worktree) excludeIgnoredChanges(changes merkletrie.Changes) merkletrie.Changes {
 	patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
 	if err != nil || len(patterns) == 0 {
@@ -277,14 +366,21 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	c, err := w.newConverter(false)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	defer c.Close()
+
 	var h plumbing.Hash
 	var added bool
 
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil || !fi.IsDir() {
-		added, h, err = w.doAddFile(idx, s, path)
+		added, h, err = w.doAddFile(idx, s, c, path)
 	} else {
-		added, err = w.doAddDirectory(idx, s, path)
+		added, err = w.doAddDirectory(idx, s, c, path)
 	}
 
 	if err != nil {
@@ -298,7 +394,7 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 	return h, w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string) (added bool, err error) {
+func (w *Worktree) doAddDirectory(idx *index.Index, s Status, c *converter, directory string) (added bool, err error) {
 	files, err := w.Filesystem.ReadDir(directory)
 	if err != nil {
 		return false, err
@@ -313,9 +409,9 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string)
 				// ignore special git directory
 				continue
 			}
-			a, err = w.doAddDirectory(idx, s, name)
+			a, err = w.doAddDirectory(idx, s, c, name)
 		} else {
-			a, _, err = w.doAddFile(idx, s, name)
+			a, _, err = w.doAddFile(idx, s, c, name)
 		}
 
 		if err != nil {
@@ -353,6 +449,13 @@ func (w *Worktree) AddGlob(pattern string) error {
 		return err
 	}
 
+	c, err := w.newConverter(false)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
 	var saveIndex bool
 	for _, file := range files {
 		fi, err := w.Filesystem.Lstat(file)
@@ -362,9 +465,9 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 		var added bool
 		if fi.IsDir() {
-			added, err = w.doAddDirectory(idx, s, file)
+			added, err = w.doAddDirectory(idx, s, c, file)
 		} else {
-			added, _, err = w.doAddFile(idx, s, file)
+			added, _, err = w.doAddFile(idx, s, c, file)
 		}
 
 		if err != nil {
@@ -383,14 +486,80 @@ func (w *Worktree) AddGlob(pattern string) error {
 	return nil
 }
 
+// AddWithOptions adds the files to the index following the given options.
+func (w *Worktree) AddWithOptions(opts *AddOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	if !opts.Renormalize {
+		_, err := w.Add(opts.Path)
+		return err
+	}
+
+	return w.doRenormalize(opts.Path)
+}
+
+// doRenormalize adds again the tracked files of the given path, converting
+// their content even if they have CRLF line endings in the index.
+func (w *Worktree) doRenormalize(directory string) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	c, err := w.newConverter(false)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	var saveIndex bool
+	for _, e := range idx.Entries {
+		if e.Stage != index.Merged || !e.Mode.IsFile() || !isPathInDirectory(e.Name, directory) {
+			continue
+		}
+
+		h, err := w.copyFileToStorage(c, e.Name, true)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if h == e.Hash {
+			continue
+		}
+
+		if err := w.doUpdateFileToIndex(e, e.Name, h); err != nil {
+			return err
+		}
+
+		saveIndex = true
+	}
+
+	if saveIndex {
+		return w.r.Storer.SetIndex(idx)
+	}
+
+	return nil
+}
+
+func isPathInDirectory(name, directory string) bool {
+	return directory == "." || name == directory || strings.HasPrefix(name, directory+"/")
+}
+
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
-func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added bool, h plumbing.Hash, err error) {
+func (w *Worktree) doAddFile(idx *index.Index, s Status, c *converter, path string) (added bool, h plumbing.Hash, err error) {
 	if s.File(path).Worktree == Unmodified {
 		return false, h, nil
 	}
 
-	h, err = w.copyFileToStorage(path)
+	h, err = w.copyFileToStorage(c, path, false)
 	if err != nil {
 		if os.IsNotExist(err) {
 			added = true
@@ -407,7 +576,9 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added boo
 	return true, h, err
 }
 
-func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error) {
+// copyFileToStorage stores the content of the file as a blob, converted by the
+// given converter, see converter.clean.
+func (w *Worktree) copyFileToStorage(c *converter, path string, renormalize bool) (hash plumbing.Hash, err error) {
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -427,7 +598,7 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 	if fi.Mode()&os.ModeSymlink != 0 {
 		err = w.fillEncodedObjectFromSymlink(writer, path, fi)
 	} else {
-		err = w.fillEncodedObjectFromFile(writer, path, fi)
+		err = w.fillEncodedObjectFromFile(writer, c, path, renormalize)
 	}
 
 	if err != nil {
@@ -437,7 +608,7 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 	return w.r.Storer.SetEncodedObject(obj)
 }
 
-func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, fi os.FileInfo) (err error) {
+func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, c *converter, path string, renormalize bool) (err error) {
 	src, err := w.Filesystem.Open(path)
 	if err != nil {
 		return err
@@ -445,10 +616,22 @@ func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, fi os.F
 
 	defer ioutil.CheckClose(src, &err)
 
-	if _, err := io.Copy(dst, src); err != nil {
+	if !c.converts(path) {
+		_, err = io.Copy(dst, src)
+		return err
+	}
+
+	content, err := stdioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	content, err = c.clean(path, content, true, renormalize)
+	if err != nil {
 		return err
 	}
 
+	_, err = dst.Write(content)
 	return err
 }
 
@@ -463,6 +646,8 @@ func (w *Worktree) fillEncodedObjectFromSymlink(dst io.Writer, path string, fi o
 }
 
 func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, filename string, h plumbing.Hash) error {
+	removeUnmergedEntries(idx, filename)
+
 	e, err := idx.Entry(filename)
 	if err != nil && err != index.ErrEntryNotFound {
 		return err
@@ -475,6 +660,23 @@ func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, filename string, h p
 	return w.doUpdateFileToIndex(e, filename, h)
 }
 
+// removeUnmergedEntries removes the conflict stages of the given path, if any,
+// resolving the conflict.
+func removeUnmergedEntries(idx *index.Index, filename string) {
+	filename = filepath.ToSlash(filename)
+
+	entries := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if e.Name == filename && e.Stage != index.Merged {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	idx.Entries = entries
+}
+
 func (w *Worktree) doAddFileToIndex(idx *index.Index, filename string, h plumbing.Hash) error {
 	return w.doUpdateFileToIndex(idx.Add(filename), filename, h)
 }
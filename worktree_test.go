@@ -130,6 +130,198 @@ func (s *WorktreeSuite) TestPullNonFastForward(c *C) {
 	c.Assert(err, ErrorMatches, "non-fast-forward update")
 }
 
+// newPullRepositories returns a repository with a commit, used as remote, and
+// a clone of it.
+func newPullRepositories(c *C) (server, client *Worktree) {
+	url := c.MkDir()
+	r, err := PlainInit(url, false)
+	c.Assert(err, IsNil)
+
+	server, err = r.Worktree()
+	c.Assert(err, IsNil)
+	commitFiles(c, server, map[string]string{"foo": "foo\n"})
+
+	r, err = PlainClone(c.MkDir(), false, &CloneOptions{URL: url})
+	c.Assert(err, IsNil)
+
+	client, err = r.Worktree()
+	c.Assert(err, IsNil)
+	return server, client
+}
+
+func (s *WorktreeSuite) TestPullFastForwardOnly(c *C) {
+	server, client := newPullRepositories(c)
+	commitFiles(c, server, map[string]string{"foo": "bar\n"})
+	commitFiles(c, client, map[string]string{"bar": "bar\n"})
+
+	err := client.Pull(&PullOptions{Mode: FastForwardOnlyPullMode})
+	c.Assert(err, Equals, ErrNonFastForwardUpdate)
+}
+
+func (s *WorktreeSuite) TestPullMerge(c *C) {
+	server, client := newPullRepositories(c)
+	theirs := commitFiles(c, server, map[string]string{"foo": "bar\n"})
+	ours := commitFiles(c, client, map[string]string{"bar": "bar\n"})
+
+	err := client.Pull(&PullOptions{Mode: MergePullMode})
+	c.Assert(err, Equals, ErrMissingAuthor)
+
+	err = client.Pull(&PullOptions{
+		Mode:   MergePullMode,
+		Author: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	head, err := client.r.Head()
+	c.Assert(err, IsNil)
+
+	commit, err := client.r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{ours, theirs})
+	c.Assert(commit.Message, Matches, "Merge branch 'master' of .*\n")
+
+	assertFileContent(c, client, "foo", "bar\n")
+	assertFileContent(c, client, "bar", "bar\n")
+
+	err = client.Pull(&PullOptions{
+		Mode:   MergePullMode,
+		Author: defaultSignature(),
+	})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+}
+
+func (s *WorktreeSuite) TestPullNoFastForward(c *C) {
+	server, client := newPullRepositories(c)
+	ours, err := client.r.Head()
+	c.Assert(err, IsNil)
+
+	theirs := commitFiles(c, server, map[string]string{"foo": "bar\n"})
+
+	err = client.Pull(&PullOptions{
+		Mode:   NoFastForwardPullMode,
+		Author: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	head, err := client.r.Head()
+	c.Assert(err, IsNil)
+
+	commit, err := client.r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{ours.Hash(), theirs})
+
+	assertFileContent(c, client, "foo", "bar\n")
+}
+
+func (s *WorktreeSuite) TestPullRebaseFromBranchConfig(c *C) {
+	server, client := newPullRepositories(c)
+	theirs := commitFiles(c, server, map[string]string{"foo": "bar\n"})
+	commitFiles(c, client, map[string]string{"bar": "bar\n"})
+	commitFiles(c, client, map[string]string{"baz": "baz\n"})
+
+	cfg, err := client.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("pull").SetOption("rebase", "false")
+	cfg.Branches["master"] = &config.Branch{
+		Name:   "master",
+		Remote: DefaultRemoteName,
+		Merge:  plumbing.Master,
+		Rebase: "true",
+	}
+	c.Assert(client.r.Storer.SetConfig(cfg), IsNil)
+
+	err = client.Pull(&PullOptions{})
+	c.Assert(err, Equals, ErrMissingCommitter)
+
+	err = client.Pull(&PullOptions{Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	head, err := client.r.Head()
+	c.Assert(err, IsNil)
+
+	commit, err := client.r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.NumParents(), Equals, 1)
+
+	parent, err := commit.Parent(0)
+	c.Assert(err, IsNil)
+	c.Assert(parent.NumParents(), Equals, 1)
+	c.Assert(parent.ParentHashes[0], Equals, theirs)
+
+	assertFileContent(c, client, "foo", "bar\n")
+	assertFileContent(c, client, "bar", "bar\n")
+	assertFileContent(c, client, "baz", "baz\n")
+}
+
+func (s *WorktreeSuite) TestPullRebaseConflict(c *C) {
+	server, client := newPullRepositories(c)
+	commitFiles(c, server, map[string]string{"foo": "bar\n"})
+	ours := commitFiles(c, client, map[string]string{"foo": "baz\n"})
+
+	err := client.Pull(&PullOptions{
+		Mode:      RebasePullMode,
+		Committer: defaultSignature(),
+	})
+	c.Assert(err, Equals, ErrRebaseConflict)
+
+	head, err := client.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, ours)
+
+	assertFileContent(c, client, "foo", "baz\n")
+}
+
+func (s *WorktreeSuite) TestPullRebaseUnsupportedMode(c *C) {
+	server, client := newPullRepositories(c)
+	commitFiles(c, server, map[string]string{"foo": "bar\n"})
+	ours := commitFiles(c, client, map[string]string{"bar": "bar\n"})
+
+	for _, rebase := range []string{"merges", "interactive"} {
+		cfg, err := client.r.Config()
+		c.Assert(err, IsNil)
+		cfg.Raw.Section("pull").SetOption("rebase", rebase)
+		c.Assert(client.r.Storer.SetConfig(cfg), IsNil)
+
+		err = client.Pull(&PullOptions{Committer: defaultSignature()})
+		c.Assert(err, Equals, ErrUnsupportedPullMode)
+
+		head, err := client.r.Head()
+		c.Assert(err, IsNil)
+		c.Assert(head.Hash(), Equals, ours)
+	}
+}
+
+func (s *WorktreeSuite) TestPullModeBooleans(c *C) {
+	_, client := newPullRepositories(c)
+	head, err := client.r.Head()
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		rebase, ff string
+		mode       PullMode
+	}{
+		{"yes", "", RebasePullMode},
+		{"On", "", RebasePullMode},
+		{"1", "false", RebasePullMode},
+		{"off", "", MergePullMode},
+		{"NO", "0", NoFastForwardPullMode},
+		{"", "Yes", MergePullMode},
+		{"", "off", NoFastForwardPullMode},
+		{"", "only", FastForwardOnlyPullMode},
+		{"", "", FastForwardOnlyPullMode},
+	} {
+		cfg, err := client.r.Config()
+		c.Assert(err, IsNil)
+		cfg.Raw.Section("pull").SetOption("rebase", t.rebase)
+		cfg.Raw.Section("pull").SetOption("ff", t.ff)
+		c.Assert(client.r.Storer.SetConfig(cfg), IsNil)
+
+		mode, err := client.pullMode(head, DefaultPullMode)
+		c.Assert(err, IsNil)
+		c.Assert(mode, Equals, t.mode, Commentf("rebase %q, ff %q", t.rebase, t.ff))
+	}
+}
+
 func (s *WorktreeSuite) TestPullUpdateReferencesIfNeeded(c *C) {
 	r, _ := Init(memory.NewStorage(), memfs.New())
 	r.CreateRemote(&config.RemoteConfig{